@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// fileFlags are the flags shared by all commands reading state files.
+type fileFlags struct {
+	format     string
+	key        string
+	keyring    string
+	passphrase string
+	identity   string
+	recipients string
+}
+
+// newFlagSet creates the flags of the named command.
+func newFlagSet(name string, f *fileFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&f.format, "format", "", "format of the state file (json, yaml, bin, state, bson, xml)")
+	fs.StringVar(&f.key, "key", os.Getenv("STATE_KEY"), "encryption key, hex or base64 encoded")
+	fs.StringVar(&f.keyring, "keyring", "", "read the encryption key from the OS keychain (service/account)")
+	fs.StringVar(&f.passphrase, "passphrase", os.Getenv("STATE_PASSPHRASE"), "derive the encryption key from the passphrase")
+	fs.StringVar(&f.identity, "identity", os.Getenv("STATE_AGE_IDENTITY"), "age identity (AGE-SECRET-KEY-1...) or a file containing it")
+	fs.StringVar(&f.recipients, "recipient", "", "comma separated age recipients (age1...) to encrypt to")
+	return fs
+}
+
+// parse parses the flags and checks the number of positional arguments.
+func parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != n {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", fs.Name(), n, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+// open creates the manager of the state file, detecting its format unless set.
+func (f *fileFlags) open(path string) (*manager.StateManager, error) {
+	options, err := f.keyOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	format := manager.SerializationType(f.format)
+	if format == "" {
+		if format, err = detect(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return manager.NewStateManager(append([]manager.StateOption{
+		manager.WithFilePath(path),
+		manager.WithSerializationType(format),
+	}, options...)...)
+}
+
+// hasKey checks if the encryption key is set.
+func (f *fileFlags) hasKey() bool {
+	return f.key != "" || f.keyring != "" || f.passphrase != "" || f.identity != "" || f.recipients != ""
+}
+
+// keyOptions returns the encryption option when the key is set.
+func (f *fileFlags) keyOptions() ([]manager.StateOption, error) {
+	if f.identity != "" || f.recipients != "" {
+		return f.ageOptions()
+	}
+
+	if f.passphrase != "" {
+		return []manager.StateOption{manager.WithPassphrase(f.passphrase)}, nil
+	}
+
+	if f.keyring != "" {
+		service, account, ok := strings.Cut(f.keyring, "/")
+		if !ok {
+			return nil, fmt.Errorf("keyring must be set as service/account, got %q", f.keyring)
+		}
+		return []manager.StateOption{manager.WithKeyFromKeyring(service, account)}, nil
+	}
+
+	if f.key == "" {
+		return nil, nil
+	}
+
+	key, err := manager.ParseKey(f.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return []manager.StateOption{manager.WithEncryptionKey(key)}, nil
+}
+
+// detect returns the format of the state file from its envelope or content.
+func detect(path string) (manager.SerializationType, error) {
+	m, err := manager.NewStateManager(manager.WithFilePath(path))
+	if err != nil {
+		return "", err
+	}
+
+	env, err := m.Envelope()
+	if err != nil {
+		return "", err
+	}
+	if env != nil {
+		return env.Format, nil
+	}
+
+	c, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	format := manager.DetectFormat(c)
+	if format == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return format, nil
+}
+
+// printValue writes the value as indented JSON or YAML.
+func printValue(w io.Writer, v interface{}, output string) error {
+	switch output {
+	case "json":
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "yaml":
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		_, err = w.Write(b)
+		return err
+	default:
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+}
+
+// catCmd prints the state.
+func catCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("cat", &f)
+	output := fs.String("o", "json", "output format (json, yaml)")
+	meta := fs.Bool("meta", false, "print the envelope instead of the state")
+
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	m, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	if *meta {
+		env, err := m.Envelope()
+		if err != nil {
+			return err
+		}
+		return printValue(stdout, env, *output)
+	}
+
+	values, err := m.Values()
+	if err != nil {
+		return err
+	}
+
+	return printValue(stdout, values, *output)
+}
+
+// statCmd prints the metadata of the state file.
+func statCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("stat", &f)
+	output := fs.String("o", "json", "output format (json, yaml)")
+
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	m, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	info, err := m.Stat()
+	if err != nil {
+		return err
+	}
+
+	return printValue(stdout, info, *output)
+}
+
+// convertCmd rewrites the state in another format.
+func convertCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("convert", &f)
+	to := fs.String("to", "json", "target format (json, yaml, state)")
+
+	args, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	m, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := m.ConvertTo(manager.SerializationType(*to), args[1], nil); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "converted %s to %s\n", args[0], args[1])
+	return err
+}
+
+// validateCmd verifies the envelope and decodes the state.
+func validateCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("validate", &f)
+	schemaPath := fs.String("schema", "", "JSON Schema file to validate the state against")
+
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var s *schema.Schema
+	if *schemaPath != "" {
+		b, err := os.ReadFile(*schemaPath)
+		if err != nil {
+			return fmt.Errorf("failed to read schema: %w", err)
+		}
+
+		if err := json.Unmarshal(b, &s); err != nil {
+			return fmt.Errorf("failed to decode schema: %w", err)
+		}
+	}
+
+	m, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := m.Payload(); err != nil {
+		return err
+	}
+
+	// binary and XML state can only be decoded with its Go type
+	if m.SerializationType != manager.BIN && m.SerializationType != manager.XML {
+		values, err := m.Values()
+		if err != nil {
+			return err
+		}
+
+		if s != nil {
+			if err := s.ValidateDocument(values); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = fmt.Fprintf(stdout, "%s is valid %s state\n", args[0], m.SerializationType)
+	return err
+}
+
+// diffCmd prints the JSON Patch between two states.
+func diffCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("diff", &f)
+
+	args, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	values := make([]map[string]interface{}, len(args))
+	var m *manager.StateManager
+	for i, path := range args {
+		if m, err = f.open(path); err != nil {
+			return err
+		}
+		if values[i], err = m.Values(); err != nil {
+			return err
+		}
+	}
+
+	ops, err := m.Diff(values[0], values[1])
+	if err != nil {
+		return err
+	}
+
+	return printValue(stdout, ops, "json")
+}
+
+// getCmd prints the value of a top-level key.
+func getCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("get", &f)
+
+	args, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+
+	m, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	if err := m.Get(args[1], &v); err != nil {
+		return err
+	}
+
+	if s, ok := v.(string); ok {
+		_, err = fmt.Fprintln(stdout, s)
+		return err
+	}
+
+	return printValue(stdout, v, "json")
+}
+
+// setCmd sets a top-level key.
+func setCmd(args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet("set", &f)
+
+	args, err := parse(fs, args, 3)
+	if err != nil {
+		return err
+	}
+
+	m, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(args[2]), &v); err != nil {
+		v = args[2]
+	}
+
+	return m.Set(args[1], v)
+}
+
+// encryptCmd encrypts the state.
+func encryptCmd(args []string, stdout io.Writer) error {
+	return crypt("encrypt", args, stdout)
+}
+
+// decryptCmd decrypts the state.
+func decryptCmd(args []string, stdout io.Writer) error {
+	return crypt("decrypt", args, stdout)
+}
+
+// crypt copies the state into a file written with or without encryption.
+func crypt(name string, args []string, stdout io.Writer) error {
+	var f fileFlags
+	fs := newFlagSet(name, &f)
+	out := fs.String("out", "", "output file, the input file is replaced by default")
+
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if !f.hasKey() {
+		return fmt.Errorf("%s requires the encryption key", name)
+	}
+
+	if *out == "" {
+		*out = args[0]
+	}
+
+	src, err := f.open(args[0])
+	if err != nil {
+		return err
+	}
+
+	options := []manager.StateOption{
+		manager.WithFilePath(*out),
+		manager.WithSerializationType(src.SerializationType),
+	}
+	if name == "encrypt" {
+		keyOptions, err := f.keyOptions()
+		if err != nil {
+			return err
+		}
+		options = append(options, keyOptions...)
+	}
+
+	dst, err := manager.NewStateManager(options...)
+	if err != nil {
+		return err
+	}
+
+	if err := src.CopyTo(dst); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "%sed %s\n", name, *out)
+	return err
+}
+
+// ageOptions returns the age identity and recipient options.
+func (f *fileFlags) ageOptions() ([]manager.StateOption, error) {
+	var options []manager.StateOption
+	if f.recipients != "" {
+		options = append(options, manager.WithAgeRecipients(strings.Split(f.recipients, ",")...))
+	}
+
+	if f.identity == "" {
+		return options, nil
+	}
+
+	identity := f.identity
+	if !strings.HasPrefix(identity, "AGE-SECRET-KEY-") {
+		b, err := os.ReadFile(identity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identity file: %w", err)
+		}
+		identity = ""
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, "AGE-SECRET-KEY-") {
+				identity = line
+				break
+			}
+		}
+		if identity == "" {
+			return nil, fmt.Errorf("no age identity found in %s", f.identity)
+		}
+	}
+
+	return append(options, manager.WithAgeIdentity(identity)), nil
+}
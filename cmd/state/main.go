@@ -0,0 +1,66 @@
+// Command state inspects and converts state files produced by the manager package.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: state <command> [flags] <args>
+
+commands:
+  cat      <file>                 print the state (-o json|yaml, -meta)
+  stat     <file>                 print the file metadata (-o json|yaml)
+  convert  <in> <out>             rewrite the state in another format (-to)
+  validate <file>                 verify and decode the state (-schema)
+  diff     <old> <new>            print the RFC 6902 JSON Patch between states
+  get      <file> <key>           print the value of a top-level key
+  set      <file> <key> <value>   set a top-level key (value is JSON or a string)
+  encrypt  <file>                 encrypt the state (-out)
+  decrypt  <file>                 decrypt the state (-out)
+
+The format of the files is detected unless set with -format. The encryption key
+is read from -key or the STATE_KEY environment variable (hex or base64 encoded),
+or from the OS keychain with -keyring service/account. Alternatively the key is
+derived from -passphrase or the STATE_PASSPHRASE environment variable. State
+encrypted with age is written to -recipient and read with -identity or the
+STATE_AGE_IDENTITY environment variable.
+`
+
+// command runs a single subcommand.
+type command func(args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"cat":      catCmd,
+	"stat":     statCmd,
+	"convert":  convertCmd,
+	"validate": validateCmd,
+	"diff":     diffCmd,
+	"get":      getCmd,
+	"set":      setCmd,
+	"encrypt":  encryptCmd,
+	"decrypt":  decryptCmd,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "state: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command named by the first argument.
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, run 'state help' for usage", args[0])
+	}
+
+	return cmd(args[1:], stdout)
+}
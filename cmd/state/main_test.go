@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+type testState struct {
+	Name string   `json:"name" yaml:"name"`
+	Age  int      `json:"age" yaml:"age"`
+	Tags []string `json:"tags" yaml:"tags"`
+}
+
+// runCmd runs the command and returns its output.
+func runCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := run(args, &out)
+	return out.String(), err
+}
+
+// saveState writes the test state in the given format.
+func saveState(t *testing.T, path string, st manager.SerializationType, options ...manager.StateOption) {
+	t.Helper()
+
+	m, err := manager.NewStateManager(append([]manager.StateOption{
+		manager.WithFilePath(path),
+		manager.WithSerializationType(st),
+	}, options...)...)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Save(&testState{Name: "alice", Age: 30, Tags: []string{"a"}}))
+}
+
+func TestCat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	saveState(t, path, manager.YAML, manager.WithEnvelope())
+
+	out, err := runCmd(t, "cat", path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"alice","age":30,"tags":["a"]}`, out)
+
+	out, err = runCmd(t, "cat", "-o", "yaml", path)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "name: alice")
+
+	out, err = runCmd(t, "cat", "-meta", path)
+	assert.NoError(t, err)
+	assert.Contains(t, out, `"format": "yaml"`)
+
+	out, err = runCmd(t, "stat", path)
+	assert.NoError(t, err)
+	assert.Contains(t, out, `"format": "yaml"`)
+	assert.Contains(t, out, `"revision": 1`)
+
+	_, err = runCmd(t, "cat")
+	assert.Error(t, err)
+
+	_, err = runCmd(t, "unknown")
+	assert.Error(t, err)
+}
+
+func TestGetSetAndValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	saveState(t, path, manager.JSON)
+
+	out, err := runCmd(t, "get", path, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice\n", out)
+
+	_, err = runCmd(t, "set", path, "tags", `["x","y"]`)
+	assert.NoError(t, err)
+	_, err = runCmd(t, "set", path, "name", "bob")
+	assert.NoError(t, err)
+
+	out, err = runCmd(t, "get", path, "tags")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `["x","y"]`, out)
+
+	out, err = runCmd(t, "validate", path)
+	assert.NoError(t, err)
+	assert.Equal(t, path+" is valid json state\n", out)
+}
+
+func TestConvertAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	saveState(t, old, manager.JSON)
+
+	converted := filepath.Join(dir, "new")
+	_, err := runCmd(t, "convert", "-to", "yaml", old, converted)
+	assert.NoError(t, err)
+
+	out, err := runCmd(t, "cat", "-o", "yaml", converted)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "name: alice")
+
+	_, err = runCmd(t, "set", converted, "age", "31")
+	assert.NoError(t, err)
+
+	out, err = runCmd(t, "diff", old, converted)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"replace","path":"/age","value":31}]`, out)
+}
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	key := hex.EncodeToString(bytes.Repeat([]byte{7}, manager.EncryptionKeySize))
+	path := filepath.Join(t.TempDir(), "state")
+	saveState(t, path, manager.BIN)
+
+	_, err := runCmd(t, "encrypt", path)
+	assert.Error(t, err)
+
+	_, err = runCmd(t, "encrypt", "-key", key, path)
+	assert.NoError(t, err)
+
+	_, err = runCmd(t, "validate", path)
+	assert.ErrorIs(t, err, manager.ErrDecryption)
+
+	out, err := runCmd(t, "validate", "-key", key, path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(out, "valid bin state\n"))
+
+	plain := path + ".plain"
+	_, err = runCmd(t, "decrypt", "-key", key, "-out", plain, path)
+	assert.NoError(t, err)
+
+	m, err := manager.NewStateManager(manager.WithFilePath(plain), manager.WithSerializationType(manager.BIN))
+	assert.NoError(t, err)
+
+	loaded := &testState{}
+	assert.NoError(t, m.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+}
+
+func TestValidateWithSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	saveState(t, path, manager.YAML)
+
+	s, err := schema.Generate(&testState{}, manager.YAML)
+	assert.NoError(t, err)
+	b, err := json.Marshal(s)
+	assert.NoError(t, err)
+
+	schemaPath := filepath.Join(dir, "schema.json")
+	assert.NoError(t, os.WriteFile(schemaPath, b, 0600))
+
+	_, err = runCmd(t, "validate", "-schema", schemaPath, path)
+	assert.NoError(t, err)
+
+	_, err = runCmd(t, "set", path, "age", `"old"`)
+	assert.NoError(t, err)
+
+	_, err = runCmd(t, "validate", "-schema", schemaPath, path)
+	assert.ErrorContains(t, err, "/age: expected integer, got string")
+}
+
+func TestEncryptWithPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	saveState(t, path, manager.JSON)
+
+	_, err := runCmd(t, "encrypt", "-passphrase", "secret", path)
+	assert.NoError(t, err)
+
+	_, err = runCmd(t, "get", path, "name")
+	assert.ErrorIs(t, err, manager.ErrDecryption)
+
+	out, err := runCmd(t, "get", "-passphrase", "secret", path, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice\n", out)
+}
+
+func TestEncryptWithAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	saveState(t, path, manager.JSON)
+
+	identity, recipient, err := manager.GenerateAgeIdentity()
+	assert.NoError(t, err)
+
+	_, err = runCmd(t, "encrypt", "-recipient", recipient, path)
+	assert.NoError(t, err)
+
+	_, err = runCmd(t, "get", path, "name")
+	assert.ErrorIs(t, err, manager.ErrDecryption)
+
+	out, err := runCmd(t, "get", "-identity", identity, path, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice\n", out)
+
+	keyFile := filepath.Join(dir, "key.txt")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("# public key: "+recipient+"\n"+identity+"\n"), 0600))
+
+	_, err = runCmd(t, "decrypt", "-identity", keyFile, path)
+	assert.NoError(t, err)
+
+	out, err = runCmd(t, "get", path, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice\n", out)
+}
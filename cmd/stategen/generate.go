@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// header marks the generated files, which are skipped when parsing the package.
+const header = "// Code generated by stategen. DO NOT EDIT.\n"
+
+// fieldKind is how the generated code encodes a field.
+type fieldKind int
+
+const (
+	// kindFallback fields are encoded by reflection
+	kindFallback fieldKind = iota
+	kindString
+	kindInt
+	kindUint
+	kindFloat
+	kindBool
+	kindTime
+	kindDuration
+	// kindNested fields are annotated structs of the package
+	kindNested
+)
+
+// field is a struct field as seen by the generator.
+type field struct {
+	name      string
+	key       string
+	typ       string
+	kind      fieldKind
+	tagged    bool
+	exported  bool
+	omitEmpty bool
+	required  bool
+	// inline fields persist the fields of the struct as keys of the parent
+	inline bool
+}
+
+// pkg is the parsed package.
+type pkg struct {
+	name    string
+	structs map[string]*ast.StructType
+	methods map[string]map[string]bool
+}
+
+// generate returns the source of the methods for the types of the package in dir.
+func generate(dir string, typeNames []string, output string) ([]byte, error) {
+	p, err := parsePackage(dir, output)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{pkg: p, done: make(map[string]bool), requested: make(map[string]bool)}
+	for _, name := range typeNames {
+		g.requested[name] = true
+	}
+	for _, name := range typeNames {
+		if err := g.addType(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "\npackage %s\n\nimport (\n", p.name)
+	if g.usesTime {
+		buf.WriteString("\t\"time\"\n\n")
+	}
+	buf.WriteString("\t\"github.com/mchmarny/state/manager\"\n)\n")
+	buf.Write(g.body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// parsePackage parses the non-test, non-generated Go files of the directory.
+func parsePackage(dir, output string) (*pkg, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pkg{structs: make(map[string]*ast.StructType), methods: make(map[string]map[string]bool)}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || filepath.Base(path) == output {
+			continue
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(src, []byte(header)) {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if p.name == "" {
+			p.name = f.Name.Name
+		}
+		p.collect(f)
+	}
+
+	if p.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return p, nil
+}
+
+// collect records the struct types and methods declared in the file.
+func (p *pkg) collect(f *ast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.TypeParams == nil {
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						p.structs[ts.Name.Name] = st
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				continue
+			}
+			recv := d.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if id, ok := recv.(*ast.Ident); ok {
+				if p.methods[id.Name] == nil {
+					p.methods[id.Name] = make(map[string]bool)
+				}
+				p.methods[id.Name][d.Name.Name] = true
+			}
+		}
+	}
+}
+
+// generator accumulates the generated code.
+type generator struct {
+	pkg       *pkg
+	body      bytes.Buffer
+	done      map[string]bool
+	requested map[string]bool
+	usesTime  bool
+}
+
+// addType generates the MarshalState and UnmarshalState methods of the type.
+func (g *generator) addType(name string) error {
+	if _, ok := g.pkg.structs[name]; !ok {
+		return fmt.Errorf("struct type %s not found in package %s", name, g.pkg.name)
+	}
+	if g.pkg.methods[name]["MarshalState"] || g.pkg.methods[name]["UnmarshalState"] {
+		return fmt.Errorf("%s already implements MarshalState or UnmarshalState", name)
+	}
+
+	fields, err := g.fields(name)
+	if err != nil {
+		return err
+	}
+	if !anyTagged(fields) {
+		return fmt.Errorf("%s has no fields annotated with state tags", name)
+	}
+
+	fmt.Fprintf(&g.body, `
+// MarshalState encodes the %[1]s in the STATE format.
+func (v *%[1]s) MarshalState() ([]byte, error) {
+	values, err := v.stateValues()
+	if err != nil {
+		return nil, err
+	}
+	return manager.EncodeStateValues(values)
+}
+
+// UnmarshalState decodes the %[1]s from the STATE format.
+func (v *%[1]s) UnmarshalState(b []byte) error {
+	values, err := manager.DecodeStateValues(b)
+	if err != nil {
+		return err
+	}
+	return v.setStateValues(values, "")
+}
+`, name)
+
+	return g.addHelpers(name, fields)
+}
+
+// addHelpers generates the stateValues and setStateValues methods of the type
+// and of the nested annotated structs it refers to.
+func (g *generator) addHelpers(name string, fields []field) error {
+	if g.done[name] {
+		return nil
+	}
+	g.done[name] = true
+
+	fmt.Fprintf(&g.body, "\n// stateValues returns the values of the fields of the %s annotated for the STATE format.\n", name)
+	fmt.Fprintf(&g.body, "func (v *%s) stateValues() (map[string]interface{}, error) {\n", name)
+	fmt.Fprintf(&g.body, "\tvalues := make(map[string]interface{}, %d)\n", len(fields))
+	for _, f := range fields {
+		if f.tagged && f.exported && !f.inline {
+			g.encodeField(f)
+		}
+	}
+	for _, f := range fields {
+		if f.inline {
+			fmt.Fprintf(&g.body, "\tif nested, err := v.%s.stateValues(); err != nil {\n\t\treturn nil, err\n\t} else {\n"+
+				"\t\tfor k, x := range nested {\n\t\t\tif _, ok := values[k]; !ok {\n\t\t\t\tvalues[k] = x\n\t\t\t}\n\t\t}\n\t}\n", f.name)
+		}
+	}
+	g.body.WriteString("\treturn values, nil\n}\n")
+
+	fmt.Fprintf(&g.body, "\n// setStateValues sets the fields of the %s from the decoded values.\n", name)
+	fmt.Fprintf(&g.body, "func (v *%s) setStateValues(values map[string]interface{}, prefix string) error {\n", name)
+	for _, f := range fields {
+		if f.inline {
+			fmt.Fprintf(&g.body, "\tif err := v.%s.setStateValues(values, prefix); err != nil {\n\t\treturn err\n\t}\n", f.name)
+		} else if f.exported {
+			g.decodeField(f)
+		}
+	}
+	g.body.WriteString("\treturn nil\n}\n")
+
+	for _, f := range fields {
+		if f.kind != kindNested {
+			continue
+		}
+		nested, err := g.fields(f.typ)
+		if err != nil {
+			return err
+		}
+		if err := g.addHelpers(f.typ, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeField writes the code adding the value of the field.
+func (g *generator) encodeField(f field) {
+	key := strconv.Quote(f.key)
+
+	var value, nonEmpty string
+	switch f.kind {
+	case kindString:
+		value, nonEmpty = "v."+f.name, `v.`+f.name+` != ""`
+	case kindInt, kindUint, kindFloat:
+		value, nonEmpty = "v."+f.name, "v."+f.name+" != 0"
+	case kindBool:
+		value, nonEmpty = "v."+f.name, "v."+f.name
+	case kindTime:
+		g.usesTime = true
+		value, nonEmpty = "v."+f.name+".Format(time.RFC3339Nano)", "!v."+f.name+".IsZero()"
+	case kindDuration:
+		value, nonEmpty = "v."+f.name+".String()", "v."+f.name+" != 0"
+	case kindNested:
+		if !f.omitEmpty {
+			fmt.Fprintf(&g.body, "\tif nested, err := v.%s.stateValues(); err == nil {\n\t\tvalues[%s] = nested\n\t} else {\n\t\treturn nil, err\n\t}\n", f.name, key)
+			return
+		}
+		fallthrough
+	default:
+		fmt.Fprintf(&g.body, "\tif value, ok, err := manager.FieldValue(&v.%s, %t); err != nil {\n\t\treturn nil, err\n\t} else if ok {\n\t\tvalues[%s] = value\n\t}\n", f.name, f.omitEmpty, key)
+		return
+	}
+
+	if f.omitEmpty {
+		fmt.Fprintf(&g.body, "\tif %s {\n\t\tvalues[%s] = %s\n\t}\n", nonEmpty, key, value)
+		return
+	}
+	fmt.Fprintf(&g.body, "\tvalues[%s] = %s\n", key, value)
+}
+
+// decodeField writes the code setting the field from its decoded value.
+func (g *generator) decodeField(f field) {
+	key := strconv.Quote(f.key)
+	fmt.Fprintf(&g.body, "\tif value, ok := values[%s]; ok {\n", key)
+
+	switch f.kind {
+	case kindString:
+		fmt.Fprintf(&g.body, "\t\tif value == nil {\n\t\t\tv.%[1]s = \"\"\n\t\t} else if x, ok := value.(string); ok {\n\t\t\tv.%[1]s = x\n\t\t}\n", f.name)
+	case kindInt:
+		g.decodeNumber(f, "IntValue", "0")
+	case kindUint:
+		g.decodeNumber(f, "UintValue", "0")
+	case kindFloat:
+		g.decodeNumber(f, "FloatValue", "0")
+	case kindBool:
+		g.decodeNumber(f, "BoolValue", "false")
+	case kindNested:
+		fmt.Fprintf(&g.body, "\t\tif value == nil {\n\t\t\tv.%[1]s = %[2]s{}\n\t\t} else if m, ok := value.(map[string]interface{}); ok {\n"+
+			"\t\t\tif err := v.%[1]s.setStateValues(m, prefix+%[3]s); err != nil {\n\t\t\t\treturn err\n\t\t\t}\n\t\t}\n",
+			f.name, f.typ, strconv.Quote(f.key+"."))
+	default:
+		fmt.Fprintf(&g.body, "\t\tif err := manager.SetFieldValue(&v.%s, value, prefix+%s); err != nil {\n\t\t\treturn err\n\t\t}\n",
+			f.name, strconv.Quote(f.key+"."))
+	}
+
+	if f.required {
+		fmt.Fprintf(&g.body, "\t} else {\n\t\treturn &manager.MissingFieldError{Key: prefix + %s}\n", key)
+	}
+	g.body.WriteString("\t}\n")
+}
+
+// decodeNumber writes the code setting a numeric or boolean field with the converter.
+func (g *generator) decodeNumber(f field, converter, zero string) {
+	conversion := f.typ + "(x)"
+	switch f.typ {
+	case "bool", "int64", "uint64", "float64":
+		conversion = "x"
+	}
+	fmt.Fprintf(&g.body, "\t\tif value == nil {\n\t\t\tv.%[1]s = %[2]s\n\t\t} else if x, ok := manager.%[3]s(value); ok {\n\t\t\tv.%[1]s = %[4]s\n\t\t}\n",
+		f.name, zero, converter, conversion)
+}
+
+// fields analyzes the fields of the struct type.
+func (g *generator) fields(name string) ([]field, error) {
+	st := g.pkg.structs[name]
+
+	list := make([]field, 0, len(st.Fields.List))
+	for _, af := range st.Fields.List {
+		var tag reflect.StructTag
+		if af.Tag != nil {
+			unquoted, err := strconv.Unquote(af.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid tag %s", name, af.Tag.Value)
+			}
+			tag = reflect.StructTag(unquoted)
+		}
+		// fields excluded from persistence are left out of the generated code
+		if tag.Get("state") == "-" {
+			continue
+		}
+		parts := strings.Split(tag.Get("state"), ",")
+		options := parts[1:]
+
+		names := make([]string, 0, len(af.Names))
+		for _, n := range af.Names {
+			names = append(names, n.Name)
+		}
+		if len(names) == 0 {
+			names = append(names, embeddedName(af.Type))
+		}
+
+		for _, n := range names {
+			f := field{
+				name:      n,
+				key:       parts[0],
+				typ:       types.ExprString(af.Type),
+				tagged:    parts[0] != "" || len(options) > 0,
+				exported:  ast.IsExported(n),
+				omitEmpty: hasOption(options, "omitempty"),
+				required:  hasOption(options, "required"),
+			}
+			if f.key == "" {
+				f.key = strings.ToLower(n)
+			}
+
+			// embedded annotated structs are inlined unless they have a key
+			if hasOption(options, "inline") || len(af.Names) == 0 && !f.tagged && g.annotated(f.typ) {
+				if !g.annotated(f.typ) {
+					return nil, fmt.Errorf("%s.%s: inline fields must be annotated structs of the package", name, n)
+				}
+				f.inline, f.kind = true, kindNested
+				list = append(list, f)
+				continue
+			}
+
+			if f.tagged && !f.exported {
+				return nil, fmt.Errorf("%s.%s is annotated but not exported", name, n)
+			}
+
+			kind, err := g.kindOf(af.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, n, err)
+			}
+			f.kind = kind
+			list = append(list, f)
+		}
+	}
+	return list, nil
+}
+
+// kindOf classifies the field type, failing for types which can not be persisted.
+func (g *generator) kindOf(expr ast.Expr) (fieldKind, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return kindString, nil
+		case "int", "int8", "int16", "int32", "int64":
+			return kindInt, nil
+		case "uint", "uint8", "uint16", "uint32", "uint64", "byte":
+			return kindUint, nil
+		case "float32", "float64":
+			return kindFloat, nil
+		case "bool":
+			return kindBool, nil
+		case "complex64", "complex128", "uintptr":
+			return 0, fmt.Errorf("unsupported field type %s", t.Name)
+		}
+		if g.nested(t.Name) {
+			return kindNested, nil
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" {
+			switch t.Sel.Name {
+			case "Time":
+				return kindTime, nil
+			case "Duration":
+				return kindDuration, nil
+			}
+		}
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "unsafe" {
+			return 0, fmt.Errorf("unsupported field type %s", types.ExprString(t))
+		}
+	case *ast.ChanType, *ast.FuncType:
+		return 0, fmt.Errorf("unsupported field type %s", types.ExprString(t))
+	case *ast.StarExpr:
+		if _, err := g.kindOf(t.X); err != nil {
+			return 0, err
+		}
+	case *ast.ArrayType:
+		if _, err := g.kindOf(t.Elt); err != nil {
+			return 0, err
+		}
+	case *ast.MapType:
+		if _, err := g.kindOf(t.Value); err != nil {
+			return 0, err
+		}
+	}
+	return kindFallback, nil
+}
+
+// nested reports whether the name is an annotated struct of the package
+// without its own or a generated MarshalState method.
+func (g *generator) nested(name string) bool {
+	return !g.requested[name] && g.annotated(name)
+}
+
+// annotated reports whether the name is an annotated struct of the package
+// which does not encode itself, so helpers are generated for it.
+func (g *generator) annotated(name string) bool {
+	st, ok := g.pkg.structs[name]
+	if !ok || g.pkg.methods[name]["MarshalState"] {
+		return false
+	}
+	for _, af := range st.Fields.List {
+		if af.Tag == nil {
+			continue
+		}
+		unquoted, err := strconv.Unquote(af.Tag.Value)
+		if err != nil {
+			continue
+		}
+		if reflect.StructTag(unquoted).Get("state") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedName returns the field name of an embedded type.
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return types.ExprString(expr)
+}
+
+// anyTagged reports whether any field is annotated.
+func anyTagged(fields []field) bool {
+	for _, f := range fields {
+		if f.tagged {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOption checks if the tag options include the option.
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
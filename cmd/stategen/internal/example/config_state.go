@@ -0,0 +1,207 @@
+// Code generated by stategen. DO NOT EDIT.
+
+package example
+
+import (
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// MarshalState encodes the Config in the STATE format.
+func (v *Config) MarshalState() ([]byte, error) {
+	values, err := v.stateValues()
+	if err != nil {
+		return nil, err
+	}
+	return manager.EncodeStateValues(values)
+}
+
+// UnmarshalState decodes the Config from the STATE format.
+func (v *Config) UnmarshalState(b []byte) error {
+	values, err := manager.DecodeStateValues(b)
+	if err != nil {
+		return err
+	}
+	return v.setStateValues(values, "")
+}
+
+// stateValues returns the values of the fields of the Config annotated for the STATE format.
+func (v *Config) stateValues() (map[string]interface{}, error) {
+	values := make(map[string]interface{}, 13)
+	values["name"] = v.Name
+	values["port"] = v.Port
+	if v.Ratio != 0 {
+		values["ratio"] = v.Ratio
+	}
+	values["enabled"] = v.Enabled
+	values["updated"] = v.Updated.Format(time.RFC3339Nano)
+	values["timeout"] = v.Timeout.String()
+	if value, ok, err := manager.FieldValue(&v.Tags, true); err != nil {
+		return nil, err
+	} else if ok {
+		values["tags"] = value
+	}
+	if value, ok, err := manager.FieldValue(&v.Labels, false); err != nil {
+		return nil, err
+	} else if ok {
+		values["labels"] = value
+	}
+	if nested, err := v.Server.stateValues(); err == nil {
+		values["server"] = nested
+	} else {
+		return nil, err
+	}
+	if value, ok, err := manager.FieldValue(&v.Backup, false); err != nil {
+		return nil, err
+	} else if ok {
+		values["backup"] = value
+	}
+	if value, ok, err := manager.FieldValue(&v.Secret, false); err != nil {
+		return nil, err
+	} else if ok {
+		values["secret"] = value
+	}
+	if nested, err := v.Meta.stateValues(); err != nil {
+		return nil, err
+	} else {
+		for k, x := range nested {
+			if _, ok := values[k]; !ok {
+				values[k] = x
+			}
+		}
+	}
+	return values, nil
+}
+
+// setStateValues sets the fields of the Config from the decoded values.
+func (v *Config) setStateValues(values map[string]interface{}, prefix string) error {
+	if err := v.Meta.setStateValues(values, prefix); err != nil {
+		return err
+	}
+	if value, ok := values["name"]; ok {
+		if value == nil {
+			v.Name = ""
+		} else if x, ok := value.(string); ok {
+			v.Name = x
+		}
+	} else {
+		return &manager.MissingFieldError{Key: prefix + "name"}
+	}
+	if value, ok := values["port"]; ok {
+		if value == nil {
+			v.Port = 0
+		} else if x, ok := manager.IntValue(value); ok {
+			v.Port = int(x)
+		}
+	}
+	if value, ok := values["ratio"]; ok {
+		if value == nil {
+			v.Ratio = 0
+		} else if x, ok := manager.FloatValue(value); ok {
+			v.Ratio = x
+		}
+	}
+	if value, ok := values["enabled"]; ok {
+		if value == nil {
+			v.Enabled = false
+		} else if x, ok := manager.BoolValue(value); ok {
+			v.Enabled = x
+		}
+	}
+	if value, ok := values["updated"]; ok {
+		if err := manager.SetFieldValue(&v.Updated, value, prefix+"updated."); err != nil {
+			return err
+		}
+	}
+	if value, ok := values["timeout"]; ok {
+		if err := manager.SetFieldValue(&v.Timeout, value, prefix+"timeout."); err != nil {
+			return err
+		}
+	}
+	if value, ok := values["tags"]; ok {
+		if err := manager.SetFieldValue(&v.Tags, value, prefix+"tags."); err != nil {
+			return err
+		}
+	}
+	if value, ok := values["labels"]; ok {
+		if err := manager.SetFieldValue(&v.Labels, value, prefix+"labels."); err != nil {
+			return err
+		}
+	}
+	if value, ok := values["server"]; ok {
+		if value == nil {
+			v.Server = Server{}
+		} else if m, ok := value.(map[string]interface{}); ok {
+			if err := v.Server.setStateValues(m, prefix+"server."); err != nil {
+				return err
+			}
+		}
+	}
+	if value, ok := values["backup"]; ok {
+		if err := manager.SetFieldValue(&v.Backup, value, prefix+"backup."); err != nil {
+			return err
+		}
+	}
+	if value, ok := values["secret"]; ok {
+		if err := manager.SetFieldValue(&v.Secret, value, prefix+"secret."); err != nil {
+			return err
+		}
+	}
+	if value, ok := values["internal"]; ok {
+		if value == nil {
+			v.Internal = ""
+		} else if x, ok := value.(string); ok {
+			v.Internal = x
+		}
+	}
+	return nil
+}
+
+// stateValues returns the values of the fields of the Meta annotated for the STATE format.
+func (v *Meta) stateValues() (map[string]interface{}, error) {
+	values := make(map[string]interface{}, 1)
+	values["owner"] = v.Owner
+	return values, nil
+}
+
+// setStateValues sets the fields of the Meta from the decoded values.
+func (v *Meta) setStateValues(values map[string]interface{}, prefix string) error {
+	if value, ok := values["owner"]; ok {
+		if value == nil {
+			v.Owner = ""
+		} else if x, ok := value.(string); ok {
+			v.Owner = x
+		}
+	}
+	return nil
+}
+
+// stateValues returns the values of the fields of the Server annotated for the STATE format.
+func (v *Server) stateValues() (map[string]interface{}, error) {
+	values := make(map[string]interface{}, 2)
+	values["host"] = v.Host
+	values["port"] = v.Port
+	return values, nil
+}
+
+// setStateValues sets the fields of the Server from the decoded values.
+func (v *Server) setStateValues(values map[string]interface{}, prefix string) error {
+	if value, ok := values["host"]; ok {
+		if value == nil {
+			v.Host = ""
+		} else if x, ok := value.(string); ok {
+			v.Host = x
+		}
+	} else {
+		return &manager.MissingFieldError{Key: prefix + "host"}
+	}
+	if value, ok := values["port"]; ok {
+		if value == nil {
+			v.Port = 0
+		} else if x, ok := manager.UintValue(value); ok {
+			v.Port = uint16(x)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Package example holds state structs with methods generated by stategen.
+package example
+
+import "time"
+
+//go:generate go run github.com/mchmarny/state/cmd/stategen -type Config
+
+// Config is a state struct with fields of every kind handled by stategen.
+type Config struct {
+	Meta
+	Name     string            `state:"name,required"`
+	Port     int               `state:"port"`
+	Ratio    float64           `state:"ratio,omitempty"`
+	Enabled  bool              `state:"enabled"`
+	Updated  time.Time         `state:"updated"`
+	Timeout  time.Duration     `state:"timeout"`
+	Tags     []string          `state:"tags,omitempty"`
+	Labels   map[string]string `state:"labels"`
+	Server   Server            `state:"server"`
+	Backup   *Server           `state:"backup"`
+	Secret   Secret            `state:"secret"`
+	Internal string
+}
+
+// Server is an annotated struct nested in Config.
+type Server struct {
+	Host string `state:"host,required"`
+	Port uint16 `state:"port"`
+}
+
+// Meta is an annotated struct embedded in Config, so its fields are inlined.
+type Meta struct {
+	Owner string `state:"owner"`
+}
+
+// Secret encodes itself.
+type Secret struct {
+	Value string
+}
+
+// MarshalState encodes the secret.
+func (s Secret) MarshalState() ([]byte, error) {
+	return []byte("***" + s.Value), nil
+}
+
+// UnmarshalState decodes the secret.
+func (s *Secret) UnmarshalState(b []byte) error {
+	s.Value = string(b[3:])
+	return nil
+}
@@ -0,0 +1,82 @@
+package example
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// reflected is Config without the generated methods, encoded by reflection.
+type reflected Config
+
+// testConfig returns a Config with every field set.
+func testConfig() *Config {
+	return &Config{
+		Meta:    Meta{Owner: "ops"},
+		Name:    "app",
+		Port:    8080,
+		Enabled: true,
+		Updated: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Timeout: 90 * time.Second,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"env": "prod"},
+		Server:  Server{Host: "localhost", Port: 80},
+		Backup:  &Server{Host: "backup", Port: 81},
+		Secret:  Secret{Value: "token"},
+	}
+}
+
+// TestGeneratedMatchesReflection ensures the generated methods encode the
+// same document as the reflection based codec and decode it back.
+func TestGeneratedMatchesReflection(t *testing.T) {
+	c := testConfig()
+
+	generated, err := manager.Marshal(manager.STATE, c)
+	assert.NoError(t, err)
+	expected, err := manager.Marshal(manager.STATE, (*reflected)(c))
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(generated))
+
+	decoded := &Config{}
+	assert.NoError(t, manager.Unmarshal(manager.STATE, generated, decoded))
+	assert.Equal(t, c, decoded)
+
+	viaReflection := &reflected{}
+	assert.NoError(t, manager.Unmarshal(manager.STATE, generated, viaReflection))
+	assert.Equal(t, (*reflected)(c), viaReflection)
+}
+
+// TestGeneratedRequired ensures missing required fields are reported with their path.
+func TestGeneratedRequired(t *testing.T) {
+	var missing *manager.MissingFieldError
+
+	err := manager.Unmarshal(manager.STATE, []byte("port: 1\n"), &Config{})
+	assert.ErrorAs(t, err, &missing)
+	assert.Equal(t, "name", missing.Key)
+
+	err = manager.Unmarshal(manager.STATE, []byte("name: a\nserver:\n  port: 1\n"), &Config{})
+	assert.ErrorAs(t, err, &missing)
+	assert.Equal(t, "server.host", missing.Key)
+}
+
+// BenchmarkGenerated measures the generated encoding of Config.
+func BenchmarkGenerated(b *testing.B) {
+	c := testConfig()
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.Marshal(manager.STATE, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReflection measures the reflection based encoding of Config.
+func BenchmarkReflection(b *testing.B) {
+	c := (*reflected)(testConfig())
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.Marshal(manager.STATE, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
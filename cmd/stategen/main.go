@@ -0,0 +1,65 @@
+// Command stategen generates MarshalState and UnmarshalState methods for
+// structs annotated with state tags, so the manager encodes them in the STATE
+// format without reflection. Run it with go generate next to the types:
+//
+//	//go:generate go run github.com/mchmarny/state/cmd/stategen -type Config
+//
+// The generated methods produce the same documents as the reflection based
+// codec. Annotated structs of the same package nested in the listed types are
+// encoded by generated code too; other field types fall back to reflection.
+// Fields which can not be persisted, e.g. channels, fail the generation.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "stategen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the flags and writes the generated file.
+func run(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("stategen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	typeNames := flags.String("type", "", "comma-separated list of struct type names (required)")
+	output := flags.String("output", "", "output file name (default <dir>/<type>_state.go)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *typeNames == "" {
+		flags.Usage()
+		return errors.New("-type is required")
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+
+	out := *output
+	if out == "" {
+		out = filepath.Join(dir, strings.ToLower(types[0])+"_state.go")
+	}
+
+	src, err := generate(dir, types, filepath.Base(out))
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(out, src, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exampleDir = "internal/example"
+
+// TestGenerateUpToDate ensures the checked-in example matches the generator output.
+func TestGenerateUpToDate(t *testing.T) {
+	src, err := generate(exampleDir, []string{"Config"}, "config_state.go")
+	assert.NoError(t, err)
+
+	expected, err := os.ReadFile(filepath.Join(exampleDir, "config_state.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(src))
+}
+
+// TestRun ensures the generated file is written next to the types.
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	b, err := os.ReadFile(filepath.Join(exampleDir, "example.go"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "example.go"), b, 0600))
+
+	var stderr bytes.Buffer
+	assert.NoError(t, run([]string{"-type", "Config", dir}, &stderr))
+	assert.FileExists(t, filepath.Join(dir, "config_state.go"))
+
+	// the generated file is ignored when generating again
+	assert.NoError(t, run([]string{"-type", "Config", "-output", filepath.Join(dir, "out.go"), dir}, &stderr))
+	assert.FileExists(t, filepath.Join(dir, "out.go"))
+
+	assert.Error(t, run([]string{dir}, &stderr))
+}
+
+// TestGenerateErrors ensures unsupported types fail the generation.
+func TestGenerateErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"not found":     "type Other struct{}",
+		"not annotated": "type Config struct{ Name string }",
+		"channel":       "type Config struct{ Events chan int `state:\"events\"` }",
+		"function":      "type Config struct{ Fn []func() `state:\"fn\"` }",
+		"unexported":    "type Config struct{ name string `state:\"name\"` }",
+		"implemented":   "type Config struct{ Name string `state:\"name\"` }\nfunc (Config) MarshalState() ([]byte, error) { return nil, nil }",
+		"inline":        "type Base struct{ ID string `state:\"id\"` }\ntype Config struct{ *Base `state:\",inline\"` }",
+	} {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "types.go"), []byte("package types\n"+src+"\n"), 0600))
+
+		_, err := generate(dir, []string{"Config"}, "config_state.go")
+		assert.Error(t, err, name)
+	}
+
+	_, err := generate(t.TempDir(), []string{"Config"}, "config_state.go")
+	assert.Error(t, err)
+}
+
+// TestGenerateExcluded ensures fields annotated with "-" are left out of the generated code.
+func TestGenerateExcluded(t *testing.T) {
+	dir := t.TempDir()
+	src := "package types\ntype Config struct{\n\tName string `state:\"name\"`\n\tFn func() `state:\"-\"`\n}\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0600))
+
+	code, err := generate(dir, []string{"Config"}, "config_state.go")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(code), "Fn")
+}
@@ -0,0 +1,251 @@
+// Package git keeps dotfile-style state in a git repository: every save is
+// committed, giving history, blame and sync with a remote for free. It runs
+// the git command line tool, which must be on the PATH.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultRemote is the name of the remote pulled from and pushed to
+	DefaultRemote = "origin"
+)
+
+// Repo is a git working tree holding state files.
+type Repo struct {
+	dir       string
+	remote    string
+	remoteURL string
+	branch    string
+	name      string
+	email     string
+	push      bool
+	message   func(files []string) string
+}
+
+// Option configures the Repo.
+type Option func(*Repo)
+
+// WithRemote sets the remote pulled from and pushed to, DefaultRemote by
+// default. A non-empty URL adds the remote when it is missing.
+func WithRemote(name, url string) Option {
+	return func(r *Repo) {
+		r.remote = name
+		r.remoteURL = url
+	}
+}
+
+// WithBranch sets the remote branch, the one of the current branch by default.
+func WithBranch(branch string) Option {
+	return func(r *Repo) {
+		r.branch = branch
+	}
+}
+
+// WithAuthor sets the author of the commits, the one configured in git by default.
+func WithAuthor(name, email string) Option {
+	return func(r *Repo) {
+		r.name = name
+		r.email = email
+	}
+}
+
+// WithPushOnCommit pushes every auto-commit to the remote.
+func WithPushOnCommit() Option {
+	return func(r *Repo) {
+		r.push = true
+	}
+}
+
+// WithMessage sets the func building the message of auto-commits from the
+// changed files, "Update <files>" by default.
+func WithMessage(fn func(files []string) string) Option {
+	return func(r *Repo) {
+		r.message = fn
+	}
+}
+
+// Open opens the repository at the directory, initializing it when it is not
+// inside a working tree yet.
+func Open(dir string, options ...Option) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("failed to find git: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create repository directory: %w", err)
+	}
+
+	r := &Repo{
+		dir:     dir,
+		remote:  DefaultRemote,
+		message: defaultMessage,
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	ctx := context.Background()
+	if _, err := r.git(ctx, "rev-parse", "--is-inside-work-tree"); err != nil {
+		if _, err := r.git(ctx, "init", "--quiet"); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.remoteURL != "" {
+		if _, err := r.git(ctx, "remote", "get-url", r.remote); err != nil {
+			if _, err := r.git(ctx, "remote", "add", r.remote, r.remoteURL); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// WithAutoCommit commits the state file and the files of its named states to
+// the repository after every save, leaving other changes of the working tree,
+// staged or not, to the user. Failures of the commit or the push are returned
+// by Save.
+func WithAutoCommit(r *Repo) manager.StateOption {
+	return func(m *manager.StateManager) {
+		m.OnAfterSave(func(interface{}) error {
+			paths, err := r.statePaths(context.Background(), m.FilePath)
+			if err != nil {
+				return err
+			}
+			if _, err := r.Commit(context.Background(), "", paths...); err != nil {
+				return err
+			}
+			if r.push {
+				return r.Push(context.Background())
+			}
+			return nil
+		})
+	}
+}
+
+// statePaths returns the path of the state file and the paths of its named
+// states in the working tree or the index, as git fails to stage paths
+// matching neither.
+func (r *Repo) statePaths(ctx context.Context, file string) ([]string, error) {
+	paths := []string{file}
+
+	named, err := filepath.Glob(file + "-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list named states: %w", err)
+	}
+	paths = append(paths, named...)
+
+	out, err := r.git(ctx, "ls-files", "-z", "--", file+"-*")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range splitNames(out) {
+		paths = append(paths, filepath.Join(r.dir, f))
+	}
+
+	return paths, nil
+}
+
+// Commit stages the changes of the paths and commits only them, leaving
+// changes staged for other paths uncommitted, or stages and commits all
+// changes when no paths are given. The message is the one of WithMessage when
+// empty. It reports whether there was anything to commit.
+func (r *Repo) Commit(ctx context.Context, message string, paths ...string) (bool, error) {
+	if _, err := r.git(ctx, append([]string{"add", "--all", "--"}, paths...)...); err != nil {
+		return false, err
+	}
+
+	out, err := r.git(ctx, append([]string{"diff", "--cached", "--name-only", "-z", "--"}, paths...)...)
+	if err != nil {
+		return false, err
+	}
+	files := splitNames(out)
+	if len(files) == 0 {
+		return false, nil
+	}
+
+	if message == "" {
+		message = r.message(files)
+	}
+
+	// commit the staged files by name as the paths may match nothing else
+	args := []string{"commit", "--quiet", "--message", message, "--"}
+	for _, f := range files {
+		args = append(args, ":(top,literal)"+f)
+	}
+	if _, err := r.git(ctx, args...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// splitNames splits the NUL terminated file names git prints with -z.
+func splitNames(out string) []string {
+	out = strings.TrimSuffix(out, "\x00")
+	if out == "" {
+		return nil
+	}
+	return strings.Split(out, "\x00")
+}
+
+// Pull fast-forwards the working tree to the remote. Reload the state afterwards.
+func (r *Repo) Pull(ctx context.Context) error {
+	args := []string{"pull", "--quiet", "--ff-only", r.remote}
+	if r.branch != "" {
+		args = append(args, r.branch)
+	}
+	_, err := r.git(ctx, args...)
+	return err
+}
+
+// Push pushes the current branch to the remote.
+func (r *Repo) Push(ctx context.Context) error {
+	ref := "HEAD"
+	if r.branch != "" {
+		ref = "HEAD:" + r.branch
+	}
+	_, err := r.git(ctx, "push", "--quiet", r.remote, ref)
+	return err
+}
+
+// git runs the git command in the repository and returns its output.
+func (r *Repo) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	if r.name != "" {
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME="+r.name, "GIT_AUTHOR_EMAIL="+r.email,
+			"GIT_COMMITTER_NAME="+r.name, "GIT_COMMITTER_EMAIL="+r.email)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return "", fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("failed to run git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// defaultMessage lists the base names of the changed files.
+func defaultMessage(files []string) string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = filepath.Base(f)
+	}
+	return "Update " + strings.Join(names, ", ")
+}
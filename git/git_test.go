@@ -0,0 +1,115 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// TestWithAutoCommit ensures every save is committed and pushed.
+func TestWithAutoCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	remote := t.TempDir()
+	gitOutput(t, remote, "init", "--quiet", "--bare")
+
+	dir := t.TempDir()
+	repo, err := Open(dir, WithAuthor("Test", "test@example.com"), WithRemote(DefaultRemote, remote),
+		WithBranch("main"), WithPushOnCommit())
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithFilePath(filepath.Join(dir, "state.json")),
+		manager.WithSerializationType(manager.JSON), WithAutoCommit(repo))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.SaveNamed("work", &config{"bob"}))
+
+	log := gitOutput(t, dir, "log", "--format=%an %s")
+	assert.Equal(t, "Test Update state.json-work\nTest Update state.json", log)
+	assert.Equal(t, log, gitOutput(t, remote, "log", "--format=%an %s", "main"))
+
+	committed, err := repo.Commit(context.Background(), "nothing")
+	assert.NoError(t, err)
+	assert.False(t, committed)
+}
+
+// TestPull ensures changes pushed by another clone are pulled.
+func TestPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	remote := t.TempDir()
+	gitOutput(t, remote, "init", "--quiet", "--bare")
+	ctx := context.Background()
+
+	first, err := Open(t.TempDir(), WithAuthor("A", "a@example.com"), WithRemote(DefaultRemote, remote), WithBranch("main"))
+	assert.NoError(t, err)
+	second, err := Open(t.TempDir(), WithAuthor("B", "b@example.com"), WithRemote(DefaultRemote, remote), WithBranch("main"))
+	assert.NoError(t, err)
+
+	a, err := manager.NewStateManager(manager.WithFilePath(filepath.Join(first.dir, "state.json")),
+		manager.WithSerializationType(manager.JSON), WithAutoCommit(first))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.NoError(t, a.Save(&config{"alice"}))
+	assert.NoError(t, first.Push(ctx))
+
+	assert.NoError(t, second.Pull(ctx))
+	b, err := manager.NewStateManager(manager.WithFilePath(filepath.Join(second.dir, "state.json")),
+		manager.WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+
+	loaded := &config{}
+	assert.NoError(t, b.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	assert.Error(t, (&Repo{dir: t.TempDir(), remote: "missing"}).Push(ctx))
+}
+
+// TestWithAutoCommitOnlyState ensures other changes of the working tree are
+// left uncommitted and file names with spaces are committed.
+func TestWithAutoCommitOnlyState(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	repo, err := Open(dir, WithAuthor("Test", "test@example.com"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("draft"), 0600))
+	gitOutput(t, dir, "add", "notes.txt")
+
+	sm, err := manager.NewStateManager(manager.WithFilePath(filepath.Join(dir, "my state.json")),
+		manager.WithSerializationType(manager.JSON), WithAutoCommit(repo))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.SaveNamed("work", &config{"bob"}))
+
+	assert.Equal(t, "Update my state.json-work\nUpdate my state.json", gitOutput(t, dir, "log", "--format=%s"))
+	assert.Equal(t, "A  notes.txt", gitOutput(t, dir, "status", "--porcelain"))
+}
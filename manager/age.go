@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+)
+
+// EncryptionAge is the cipher recorded in the envelope of state encrypted to age recipients
+const EncryptionAge = "age"
+
+// ageKeys holds the age recipients the state is encrypted to and the identities to decrypt it with.
+type ageKeys struct {
+	recipients []age.Recipient
+	identities []*age.X25519Identity
+}
+
+// WithAgeRecipients encrypts the state to the age public keys (age1...), so
+// any holder of one of the matching identities can decrypt it. The payload
+// inside the envelope is a regular age file. It takes precedence over
+// WithPassphrase and WithEncryptionKey when saving.
+func WithAgeRecipients(recipients ...string) StateOption {
+	return func(s *StateManager) {
+		if len(recipients) == 0 {
+			s.optionErr = errors.New("at least one age recipient is required")
+			return
+		}
+
+		keys := s.ageKeys()
+		for _, r := range recipients {
+			recipient, err := age.ParseX25519Recipient(r)
+			if err != nil {
+				s.optionErr = err
+				return
+			}
+			keys.recipients = append(keys.recipients, recipient)
+		}
+	}
+}
+
+// WithAgeIdentity decrypts the state with the age private key (AGE-SECRET-KEY-1...).
+// Without WithAgeRecipients the state is encrypted to the identity itself.
+func WithAgeIdentity(identity string) StateOption {
+	return func(s *StateManager) {
+		id, err := age.ParseX25519Identity(identity)
+		if err != nil {
+			s.optionErr = err
+			return
+		}
+
+		keys := s.ageKeys()
+		keys.identities = append(keys.identities, id)
+	}
+}
+
+// GenerateAgeIdentity creates a new age identity and returns it with its recipient.
+func GenerateAgeIdentity() (identity, recipient string, err error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", err
+	}
+	return id.String(), id.Recipient().String(), nil
+}
+
+// ageKeys returns the age configuration, creating it when needed.
+func (s *StateManager) ageKeys() *ageKeys {
+	if s.age == nil {
+		s.age = &ageKeys{}
+	}
+	return s.age
+}
+
+// sealAge encrypts the payload to the recipients, or to the identities when none are set.
+func (s *StateManager) sealAge(env *Envelope, payload []byte) ([]byte, error) {
+	recipients := s.age.recipients
+	if len(recipients) == 0 {
+		for _, id := range s.age.identities {
+			recipients = append(recipients, id.Recipient())
+		}
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt state: %w", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, fmt.Errorf("failed to encrypt state: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt state: %w", err)
+	}
+
+	env.Encryption = EncryptionAge
+	return buf.Bytes(), nil
+}
+
+// openAge decrypts the age payload with the identities.
+func (s *StateManager) openAge(payload []byte) ([]byte, error) {
+	if s.age == nil || len(s.age.identities) == 0 {
+		return nil, fmt.Errorf("%w: state is encrypted with age but no identity is configured", ErrDecryption)
+	}
+
+	identities := make([]age.Identity, len(s.age.identities))
+	for i, id := range s.age.identities {
+		identities[i] = id
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(payload), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	agetest "c2sp.org/CCTV/age"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithAgeRecipients ensures the state is encrypted to every age recipient.
+func TestWithAgeRecipients(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+
+	alice, aliceRecipient, err := GenerateAgeIdentity()
+	assert.NoError(t, err)
+	bob, bobRecipient, err := GenerateAgeIdentity()
+	assert.NoError(t, err)
+	eve, _, err := GenerateAgeIdentity()
+	assert.NoError(t, err)
+
+	_, err = NewStateManager(WithFilePath(path), WithAgeRecipients())
+	assert.Error(t, err)
+	_, err = NewStateManager(WithFilePath(path), WithAgeRecipients("age1invalid"))
+	assert.Error(t, err)
+	_, err = NewStateManager(WithFilePath(path), WithAgeIdentity(aliceRecipient))
+	assert.Error(t, err)
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON),
+		WithAgeRecipients(aliceRecipient, bobRecipient))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(c), "Alice")
+	assert.Contains(t, string(c), "age-encryption.org/v1")
+
+	env, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, EncryptionAge, env.Encryption)
+
+	// recipients alone cannot read the state back
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrDecryption)
+
+	for _, id := range []string{alice, bob} {
+		other, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithAgeIdentity(id))
+		assert.NoError(t, err)
+
+		loaded := &TestStruct{}
+		assert.NoError(t, other.Load(loaded))
+		assert.Equal(t, "Alice", loaded.Name)
+	}
+
+	wrong, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithAgeIdentity(eve))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, wrong.Load(&TestStruct{}), ErrDecryption)
+
+	key, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithEncryptionKey(testKey(1)))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, key.Load(&TestStruct{}), ErrDecryption)
+}
+
+// TestWithAgeIdentity ensures the state is encrypted to the identity without recipients.
+func TestWithAgeIdentity(t *testing.T) {
+	path := setupTempStateManager(t, YAML).FilePath
+
+	id, _, err := GenerateAgeIdentity()
+	assert.NoError(t, err)
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(YAML), WithAgeIdentity(id))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Bob", Age: 42}))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "Bob", loaded.Name)
+	assert.Equal(t, 42, loaded.Age)
+}
+
+// TestAgeKnownAnswer ensures files written by the age reference implementation decrypt, using its example vector.
+func TestAgeKnownAnswer(t *testing.T) {
+	sm, err := NewStateManager(WithFilePath(setupTempStateManager(t, JSON).FilePath),
+		WithAgeIdentity("AGE-SECRET-KEY-184JMZMVQH3E6U0PSL869004Y3U2NYV7R30EU99CSEDNPH02YUVFSZW44VU"))
+	assert.NoError(t, err)
+
+	file, err := base64.StdEncoding.DecodeString("YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSA4aHJsTStaQkczRGQ0ZkYyK2E1ODN6ZFRJV0RrOC9SNDFrQ1lac3Z3VFc0CnlPNFBZZGxNV0RKK0N4Z1VOUnFZNVowVC9tK2czRkNoNWpJeEdMYkNWWGMKLS0tIEkvaW1ldlp6eTgxMjBKU3ptSm5tbi9LTWszcDVBMTFWODNOazQxbTlOUEUKcMXlNiShUgdT+Sxa0Q7KsnO6TWEXgHcT6DggQXod8soIGCJyyPhchXc0oTEaO3XpjQ6v")
+	assert.NoError(t, err)
+
+	b, err := sm.openAge(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "Black lives matter.", string(b))
+
+	file[len(file)-1] ^= 1
+	_, err = sm.openAge(file)
+	assert.ErrorIs(t, err, ErrDecryption)
+}
+
+// TestAgeVectors ensures the X25519 test vectors of the age specification decrypt or fail as expected.
+func TestAgeVectors(t *testing.T) {
+	names, err := fs.Glob(agetest.Vectors, "*")
+	assert.NoError(t, err)
+
+	for _, name := range names {
+		c, err := fs.ReadFile(agetest.Vectors, name)
+		assert.NoError(t, err)
+
+		var expect, payload string
+		var opts []StateOption
+		skip := false
+		for {
+			line, rest, _ := bytes.Cut(c, []byte("\n"))
+			c = rest
+			if len(line) == 0 {
+				break
+			}
+			key, value, _ := strings.Cut(string(line), ": ")
+			switch key {
+			case "expect":
+				expect = value
+			case "payload":
+				payload = value
+			case "identity":
+				opts = append(opts, WithAgeIdentity(value))
+			case "passphrase", "armored":
+				// only binary files to X25519 recipients are stored in envelopes
+				skip = true
+			}
+		}
+		if skip || len(opts) == 0 {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			sm, err := NewStateManager(append(opts, WithFilePath(setupTempStateManager(t, JSON).FilePath))...)
+			assert.NoError(t, err)
+
+			b, err := sm.openAge(c)
+			if expect != "success" {
+				assert.ErrorIs(t, err, ErrDecryption, expect)
+				return
+			}
+			assert.NoError(t, err)
+			sum := sha256.Sum256(b)
+			assert.Equal(t, payload, hex.EncodeToString(sum[:]))
+		})
+	}
+}
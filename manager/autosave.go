@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AutoSave persists the target every interval for as long as the context is
+// alive, writing only when the encoded state changed since the last write.
+// When the context is done or the manager is closed the target is saved one
+// last time and the error of that save is returned. Failed periodic saves are retried on the next tick.
+// A target implementing sync.Locker is locked while it is encoded.
+func (s *StateManager) AutoSave(ctx context.Context, target interface{}, interval time.Duration) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("invalid auto-save interval: %s", interval)
+	}
+
+	closing, ok := s.start()
+	if !ok {
+		return s.saveChanged(target)
+	}
+	defer s.life.running.Done()
+
+	ticker := s.timers().NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return s.saveChanged(target)
+		case <-closing:
+			return s.saveChanged(target)
+		case <-ticker.C():
+			_ = s.saveChanged(target)
+		}
+	}
+}
+
+// saveChanged persists the target when it changed since the last write.
+func (s *StateManager) saveChanged(target interface{}) error {
+	if l, ok := target.(sync.Locker); ok {
+		l.Lock()
+		defer l.Unlock()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.persist(target, true)
+}
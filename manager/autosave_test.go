@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedState is a target which is modified while being auto-saved.
+type lockedState struct {
+	sync.Mutex
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TestAutoSave ensures the target is persisted periodically and on cancel.
+func TestAutoSave(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	writes := 0
+	sm.OnAfterSave(func(interface{}) error {
+		writes++
+		return nil
+	})
+
+	target := &lockedState{Name: "a"}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- sm.AutoSave(ctx, target, 5*time.Millisecond)
+	}()
+
+	assert.Eventually(t, func() bool {
+		loaded := &lockedState{}
+		return sm.Load(loaded) == nil && loaded.Name == "a"
+	}, time.Second, 5*time.Millisecond)
+
+	target.Lock()
+	target.Count = 42
+	target.Unlock()
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	loaded := &lockedState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, 42, loaded.Count)
+
+	// unchanged state is written once per change
+	assert.Equal(t, 2, writes)
+}
+
+// TestAutoSaveInvalidInterval ensures a non-positive interval is rejected.
+func TestAutoSaveInvalidInterval(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.Error(t, sm.AutoSave(context.Background(), &TestStruct{}, 0))
+}
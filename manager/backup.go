@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// WithBackups keeps up to n previous generations of the state file.
+// Before each Save the existing file is renamed to FilePath.1, shifting
+// older backups up to FilePath.n.
+func WithBackups(n int) StateOption {
+	return func(s *StateManager) {
+		if n > 0 {
+			s.backups = n
+		}
+	}
+}
+
+// backupPath returns the file path of the given backup generation.
+func (s *StateManager) backupPath(generation int) string {
+	return fmt.Sprintf("%s.%d", s.FilePath, generation)
+}
+
+// rotateBackups shifts the existing backups up one generation and moves
+// the current state file into generation 1. Caller must hold the lock.
+func (s *StateManager) rotateBackups() error {
+	if _, err := s.files().Stat(s.FilePath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err := s.files().Remove(s.backupPath(s.backups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove oldest backup: %w", err)
+	}
+
+	for i := s.backups - 1; i > 0; i-- {
+		if err := s.files().Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate backup %d: %w", i, err)
+		}
+	}
+
+	if err := s.files().Rename(s.FilePath, s.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to backup state file: %w", err)
+	}
+
+	return nil
+}
+
+// Restore replaces the state file with the given backup generation.
+// Generation 1 is the most recent backup. The replaced state is itself
+// rotated into the backups so a Restore can be undone.
+func (s *StateManager) Restore(generation int) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if generation < 1 || generation > s.backups {
+		return fmt.Errorf("invalid backup generation %d, expected 1-%d", generation, s.backups)
+	}
+
+	b, err := s.readFile(s.backupPath(generation))
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if b, err = s.reseal(b); err != nil {
+		return err
+	}
+
+	return s.write(s.FilePath, b)
+}
@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBackupStateManager(t *testing.T, n int) *StateManager {
+	t.Helper()
+
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithBackups(n),
+	)
+	assert.NoError(t, err)
+
+	return sm
+}
+
+// TestBackupRotation ensures Save keeps at most n previous generations.
+func TestBackupRotation(t *testing.T) {
+	sm := setupBackupStateManager(t, 2)
+
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, sm.Save(&TestStruct{Name: fmt.Sprintf("v%d", i), Age: i}))
+	}
+
+	assert.FileExists(t, sm.backupPath(1))
+	assert.FileExists(t, sm.backupPath(2))
+	assert.NoFileExists(t, sm.backupPath(3))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "v4", loaded.Name)
+
+	c, err := os.ReadFile(sm.backupPath(2))
+	assert.NoError(t, err)
+	assert.Contains(t, string(c), "v2")
+}
+
+// TestBackupFirstSave ensures no backup is created when there is no existing file.
+func TestBackupFirstSave(t *testing.T) {
+	sm := setupBackupStateManager(t, 3)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "first"}))
+	assert.NoFileExists(t, sm.backupPath(1))
+}
+
+// TestRestore ensures backups can be restored and the restore undone.
+func TestRestore(t *testing.T) {
+	sm := setupBackupStateManager(t, 3)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "good"}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "bad"}))
+
+	assert.NoError(t, sm.Restore(1))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "good", loaded.Name)
+
+	// the replaced state is now the most recent backup
+	assert.NoError(t, sm.Restore(1))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bad", loaded.Name)
+}
+
+// TestRestoreInvalidGeneration ensures out of range and missing generations fail.
+func TestRestoreInvalidGeneration(t *testing.T) {
+	sm := setupBackupStateManager(t, 2)
+	assert.Error(t, sm.Restore(0))
+	assert.Error(t, sm.Restore(3))
+	assert.Error(t, sm.Restore(1))
+
+	noBackups := setupTempStateManager(t, JSON)
+	assert.Error(t, noBackups.Restore(1))
+}
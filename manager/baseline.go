@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithBaseline loads the named file of fsys, typically an embed.FS with the
+// defaults shipped with the application, as the baseline of the state. Load
+// deep merges the persisted state over the baseline, and Save persists only
+// the values that differ from it, so the state file holds the user's changes.
+// The baseline is in the manager format; BIN is not supported.
+func WithBaseline(fsys fs.FS, name string) StateOption {
+	return func(s *StateManager) {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			s.optionErr = fmt.Errorf("failed to read baseline: %w", err)
+			return
+		}
+		s.baseline = b
+	}
+}
+
+// overlay merges the persisted payload over the baseline.
+func (s *StateManager) overlay(st SerializationType, payload []byte) ([]byte, error) {
+	base, err := parseDocument(st, s.baseline)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseline: %w", err)
+	}
+
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return s.baseline, nil
+	}
+
+	over, err := parseDocument(st, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	return marshalDocument(st, mergeValues(base, over))
+}
+
+// delta reduces the encoded payload to the values that differ from the baseline.
+func (s *StateManager) delta(payload []byte) ([]byte, error) {
+	base, err := parseDocument(s.SerializationType, s.baseline)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseline: %w", err)
+	}
+
+	values, err := parseDocument(s.SerializationType, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	d, _ := deltaValues(base, values)
+	if d == nil {
+		d = map[string]interface{}{}
+	}
+
+	return marshalDocument(s.SerializationType, d)
+}
+
+// parseDocument decodes the text payload into generic values.
+func parseDocument(st SerializationType, payload []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	switch st {
+	case JSON:
+		d := json.NewDecoder(bytes.NewReader(payload))
+		d.UseNumber()
+		if err := d.Decode(&values); err != nil {
+			return nil, err
+		}
+	case YAML, STATE:
+		if err := yaml.Unmarshal(payload, &values); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: baseline not supported for %s serialization", ErrUnsupportedFormat, st)
+	}
+
+	return values, nil
+}
+
+// marshalDocument encodes the generic values.
+func marshalDocument(st SerializationType, v interface{}) ([]byte, error) {
+	if st == JSON {
+		return Marshal(JSON, v)
+	}
+	return yaml.Marshal(v)
+}
+
+// mergeValues deep merges over into base; other values of over replace those of base.
+func mergeValues(base, over interface{}) interface{} {
+	b, ok := base.(map[string]interface{})
+	o, ok2 := over.(map[string]interface{})
+	if !ok || !ok2 {
+		return over
+	}
+
+	merged := make(map[string]interface{}, len(b)+len(o))
+	for k, v := range b {
+		merged[k] = v
+	}
+	for k, v := range o {
+		merged[k] = mergeValues(b[k], v)
+	}
+	return merged
+}
+
+// deltaValues returns the parts of v differing from base and whether there are any.
+// Keys of base missing from v are recorded as null.
+func deltaValues(base, v interface{}) (interface{}, bool) {
+	b, ok := base.(map[string]interface{})
+	m, ok2 := v.(map[string]interface{})
+	if !ok || !ok2 {
+		return v, !reflect.DeepEqual(base, v)
+	}
+
+	var d map[string]interface{}
+	set := func(k string, val interface{}) {
+		if d == nil {
+			d = make(map[string]interface{})
+		}
+		d[k] = val
+	}
+
+	for k, val := range m {
+		bv, found := b[k]
+		if !found {
+			set(k, val)
+			continue
+		}
+		if dv, changed := deltaValues(bv, val); changed {
+			set(k, dv)
+		}
+	}
+	for k := range b {
+		if _, found := m[k]; !found {
+			set(k, nil)
+		}
+	}
+
+	if d == nil {
+		return nil, false
+	}
+	return d, true
+}
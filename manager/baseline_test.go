@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type baselineState struct {
+	Name   string            `json:"name" yaml:"name" state:"name,required"`
+	Port   int               `json:"port" yaml:"port" state:"port"`
+	Tags   []string          `json:"tags" yaml:"tags" state:"tags"`
+	Limits map[string]int    `json:"limits" yaml:"limits" state:"limits"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty" state:"labels,omitempty"`
+}
+
+var baselineFiles = fstest.MapFS{
+	"defaults.json": {Data: []byte(`{"name": "app", "port": 8080, "tags": ["a"], "limits": {"cpu": 1, "mem": 512}, "labels": {"env": "dev"}}`)},
+	"defaults.yaml": {Data: []byte("name: app\nport: 8080\ntags: [a]\nlimits: {cpu: 1, mem: 512}\nlabels: {env: dev}\n")},
+}
+
+// TestWithBaseline ensures the persisted state overlays the baseline and only holds the changes.
+func TestWithBaseline(t *testing.T) {
+	for st, name := range map[SerializationType]string{JSON: "defaults.json", YAML: "defaults.yaml", STATE: "defaults.yaml"} {
+		path := setupTempStateManager(t, st).FilePath
+
+		_, err := NewStateManager(WithFilePath(path), WithBaseline(baselineFiles, "missing.json"))
+		assert.Error(t, err)
+
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithBaseline(baselineFiles, name))
+		assert.NoError(t, err)
+
+		// without a state file the baseline is loaded
+		loaded := &baselineState{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, "app", loaded.Name)
+		assert.Equal(t, 8080, loaded.Port)
+		assert.Equal(t, map[string]int{"cpu": 1, "mem": 512}, loaded.Limits)
+
+		loaded.Port = 9090
+		loaded.Limits["cpu"] = 2
+		loaded.Labels = nil
+		assert.NoError(t, sm.Save(loaded), st)
+
+		c, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Contains(t, string(c), "9090", st)
+		assert.NotContains(t, string(c), "app", st)
+		assert.NotContains(t, string(c), "512", st)
+
+		reloaded := &baselineState{}
+		assert.NoError(t, sm.Load(reloaded), st)
+		assert.Equal(t, &baselineState{
+			Name:   "app",
+			Port:   9090,
+			Tags:   []string{"a"},
+			Limits: map[string]int{"cpu": 2, "mem": 512},
+		}, reloaded, st)
+	}
+}
+
+// TestBaselineUnsupported ensures BIN state can not use a baseline.
+func TestBaselineUnsupported(t *testing.T) {
+	path := setupTempStateManager(t, BIN).FilePath
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(BIN), WithBaseline(baselineFiles, "defaults.json"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Load(&baselineState{}), ErrUnsupportedFormat)
+}
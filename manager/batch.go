@@ -0,0 +1,179 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SaveAll persists the named structs together to the state file in a single
+// atomic write, so related states are never observed out of sync. The file
+// holds a document keyed by name, read back with LoadAll rather than Load, and
+// is written like Save writes, with the manager format, encryption, envelope,
+// directory mode, incremental saves, hooks and metrics. The hooks run for each
+// struct. Streaming, baselines, split files and shards work on a single struct
+// and fail with ErrInvalid.
+func (s *StateManager) SaveAll(states map[string]interface{}) (err error) {
+	if err := s.writable(); err != nil {
+		return err
+	}
+	if s.streaming || s.baseline != nil || len(s.splits) > 0 || len(s.shards) > 0 {
+		return fmt.Errorf("%w: SaveAll does not support streaming, baselines, split files or shards", ErrInvalid)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	start := s.now()
+	var payload []byte
+	defer func() { s.observe(OperationSave, start, len(payload), err) }()
+
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	encoded := make(map[string][]byte, len(states))
+	for _, name := range names {
+		b, err := s.prepare(states[name])
+		if err != nil {
+			return fmt.Errorf("state %q: %w", name, err)
+		}
+		encoded[name] = b
+	}
+
+	if payload, err = joinStates(s.SerializationType, encoded); err != nil {
+		return err
+	}
+	if payload, err = s.formatJSON(payload); err != nil {
+		return err
+	}
+
+	if _, err := s.writePayload(states, payload, false); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := runHooks(s.hooks.afterSave, states[name]); err != nil {
+			return fmt.Errorf("state %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// LoadAll reads the named structs saved with SaveAll into the given targets,
+// returning ErrNotFound when one of them is missing from the file.
+func (s *StateManager) LoadAll(states map[string]interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	payload, st, err := s.readValues()
+	if err != nil {
+		return err
+	}
+
+	encoded, err := splitStates(st, payload)
+	if err != nil {
+		return err
+	}
+
+	for name, data := range states {
+		b, ok := encoded[name]
+		if !ok {
+			return fmt.Errorf("%w: state %q", ErrNotFound, name)
+		}
+
+		if err := s.demarshal(st, b, data); err != nil {
+			return fmt.Errorf("failed to decode state %q: %w", name, err)
+		}
+
+		if err := s.complete(data); err != nil {
+			return fmt.Errorf("state %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// joinStates combines the encoded states into a single document of the format.
+func joinStates(st SerializationType, encoded map[string][]byte) ([]byte, error) {
+	switch st {
+	case JSON:
+		raw := make(map[string]json.RawMessage, len(encoded))
+		for name, b := range encoded {
+			raw[name] = b
+		}
+		return Marshal(JSON, raw)
+	case YAML, STATE:
+		nodes := make(map[string]*yaml.Node, len(encoded))
+		for name, b := range encoded {
+			var n yaml.Node
+			if err := yaml.Unmarshal(b, &n); err != nil {
+				return nil, fmt.Errorf("failed to encode state %q: %w", name, err)
+			}
+			nodes[name] = n.Content[0]
+		}
+		return yaml.Marshal(nodes)
+	default:
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(encoded); err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// splitStates separates the document written by joinStates into the encoded states.
+func splitStates(st SerializationType, payload []byte) (map[string][]byte, error) {
+	encoded := make(map[string][]byte)
+
+	switch st {
+	case JSON:
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+		for name, b := range raw {
+			encoded[name] = b
+		}
+	case YAML, STATE:
+		var nodes map[string]yaml.Node
+		if err := yaml.Unmarshal(payload, &nodes); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+		for name, n := range nodes {
+			b, err := yaml.Marshal(&n)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode state %q: %w", name, err)
+			}
+			encoded[name] = b
+		}
+	default:
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&encoded); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+	}
+
+	return encoded, nil
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type batchSettings struct {
+	Theme string `json:"theme" yaml:"theme" state:"theme"`
+	Port  int    `json:"port" yaml:"port" state:"port" default:"8080"`
+}
+
+// TestSaveAllLoadAll ensures the named structs round-trip through a single file.
+func TestSaveAllLoadAll(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE} {
+		sm := setupTempStateManager(t, st)
+
+		user := &TestStruct{Name: "Alice", Age: 30}
+		settings := &batchSettings{Theme: "dark"}
+		assert.NoError(t, sm.SaveAll(map[string]interface{}{"user": user, "settings": settings}), st)
+
+		loadedUser := &TestStruct{}
+		loadedSettings := &batchSettings{}
+		assert.NoError(t, sm.LoadAll(map[string]interface{}{"user": loadedUser, "settings": loadedSettings}), st)
+		assert.Equal(t, user, loadedUser, st)
+		assert.Equal(t, "dark", loadedSettings.Theme, st)
+		assert.Equal(t, 8080, loadedSettings.Port, st)
+
+		assert.ErrorIs(t, sm.LoadAll(map[string]interface{}{"missing": &TestStruct{}}), ErrNotFound, st)
+	}
+}
+
+// TestSaveAllOptions ensures the batch goes through the envelope and validation.
+func TestSaveAllOptions(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithEnvelope(),
+		WithEncryptionKey(testKey(1)), WithValidator(func(data interface{}) error {
+			if u, ok := data.(*TestStruct); ok && u.Name == "" {
+				return assert.AnError
+			}
+			return nil
+		}))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.SaveAll(map[string]interface{}{"user": &TestStruct{}}), assert.AnError)
+	assert.ErrorIs(t, sm.LoadAll(map[string]interface{}{"user": &TestStruct{}}), ErrNotFound)
+
+	assert.NoError(t, sm.SaveAll(map[string]interface{}{"user": &TestStruct{Name: "Bob"}}))
+	env, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, EncryptionAES256GCM, env.Encryption)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.LoadAll(map[string]interface{}{"user": loaded}))
+	assert.Equal(t, "Bob", loaded.Name)
+}
+
+// TestSaveAllPipeline ensures the batch runs the hooks and metrics and is
+// written in directory mode and the configured JSON layout.
+func TestSaveAllPipeline(t *testing.T) {
+	var observed []Observation
+	dir := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(dir), WithSerializationType(JSON), WithDirectory(), WithCompactJSON(),
+		WithMetrics(MetricsFunc(func(o Observation) { observed = append(observed, o) })))
+	assert.NoError(t, err)
+
+	var before, after int
+	sm.OnBeforeSave(func(interface{}) error { before++; return nil })
+	sm.OnAfterSave(func(interface{}) error { after++; return nil })
+
+	assert.NoError(t, sm.SaveAll(map[string]interface{}{"user": &TestStruct{Name: "Alice"}, "settings": &batchSettings{Theme: "dark"}}))
+	assert.Equal(t, 2, before)
+	assert.Equal(t, 2, after)
+	assert.Len(t, observed, 1)
+	assert.Equal(t, OperationSave, observed[0].Operation)
+
+	b, err := os.ReadFile(filepath.Join(dir, "settings.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"theme":"dark","port":0}`, string(b))
+
+	loaded := &batchSettings{}
+	assert.NoError(t, sm.LoadAll(map[string]interface{}{"settings": loaded}))
+	assert.Equal(t, "dark", loaded.Theme)
+}
+
+// TestSaveAllUnsupported ensures options working on a single struct are rejected.
+func TestSaveAllUnsupported(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+	for _, option := range []StateOption{WithStreaming(), WithSplit("Name", path+".name")} {
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), option)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, sm.SaveAll(map[string]interface{}{"user": &TestStruct{}}), ErrInvalid)
+	}
+}
@@ -0,0 +1,723 @@
+package manager
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BSON element types, see https://bsonspec.org/spec.html.
+const (
+	bsonDouble    = 0x01
+	bsonString    = 0x02
+	bsonDocument  = 0x03
+	bsonArray     = 0x04
+	bsonBinary    = 0x05
+	bsonUndefined = 0x06
+	bsonObjectID  = 0x07
+	bsonBool      = 0x08
+	bsonDateTime  = 0x09
+	bsonNull      = 0x0A
+	bsonRegex     = 0x0B
+	bsonDBPointer = 0x0C
+	bsonCode      = 0x0D
+	bsonSymbol    = 0x0E
+	bsonCodeScope = 0x0F
+	bsonInt32     = 0x10
+	bsonTimestamp = 0x11
+	bsonInt64     = 0x12
+	bsonDecimal   = 0x13
+	bsonMinKey    = 0xFF
+	bsonMaxKey    = 0x7F
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// bsonElement is an element of a BSON document with its undecoded value.
+type bsonElement struct {
+	kind byte
+	key  string
+	data []byte
+}
+
+// bsonMarshal encodes the struct or string keyed map as a BSON document.
+// Fields are keyed like the MongoDB driver does: by their bson tag, e.g.
+// `bson:"name,omitempty"`, or their lowercased name. Times are encoded as
+// UTC datetimes with millisecond precision and byte slices as binary data.
+func bsonMarshal(data interface{}) ([]byte, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, fmt.Errorf("marshal source must not be nil")
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct && (v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String) {
+		return nil, fmt.Errorf("marshal source must be a struct or string keyed map")
+	}
+	return appendBSONDocument(nil, v)
+}
+
+// appendBSONDocument appends the struct or map as a BSON document.
+func appendBSONDocument(b []byte, v reflect.Value) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+
+	var err error
+	if v.Kind() == reflect.Struct {
+		b, err = appendBSONFields(b, v)
+	} else {
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if b, err = appendBSONElement(b, k.String(), v.MapIndex(k)); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b, nil
+}
+
+// appendBSONFields appends the persisted fields of the struct as elements.
+func appendBSONFields(b []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, omitEmpty, inline := bsonKey(field)
+		fv := v.Field(i)
+
+		if inline {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			var err error
+			if b, err = appendBSONFields(b, fv); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if key == "" || omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		var err error
+		if b, err = appendBSONElement(b, key, fv); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return b, nil
+}
+
+// appendBSONElement appends the value as an element with the key.
+func appendBSONElement(b []byte, key string, v reflect.Value) ([]byte, error) {
+	if strings.IndexByte(key, 0) >= 0 {
+		return nil, fmt.Errorf("invalid BSON key %q", key)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return appendBSONHeader(b, bsonNull, key), nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		b = appendBSONHeader(b, bsonDateTime, key)
+		return binary.LittleEndian.AppendUint64(b, uint64(v.Interface().(time.Time).UnixMilli())), nil
+	}
+	if v.Kind() != reflect.String && v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return appendBSONString(appendBSONHeader(b, bsonString, key), string(text)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b = appendBSONHeader(b, bsonBool, key)
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return appendBSONInt(b, key, numberOf(v)), nil
+	case reflect.Int:
+		return appendBSONInt(b, key, v.Int()), nil
+	case reflect.Int64:
+		b = appendBSONHeader(b, bsonInt64, key)
+		return binary.LittleEndian.AppendUint64(b, uint64(v.Int())), nil
+	case reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows BSON int64", v.Uint())
+		}
+		b = appendBSONHeader(b, bsonInt64, key)
+		return binary.LittleEndian.AppendUint64(b, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		b = appendBSONHeader(b, bsonDouble, key)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendBSONString(appendBSONHeader(b, bsonString, key), v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return appendBSONHeader(b, bsonNull, key), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			b = appendBSONHeader(b, bsonBinary, key)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+			b = append(b, 0x00) // generic binary subtype
+			return append(b, data...), nil
+		}
+		return appendBSONArray(appendBSONHeader(b, bsonArray, key), v)
+	case reflect.Map:
+		if v.IsNil() {
+			return appendBSONHeader(b, bsonNull, key), nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported BSON map key type: %s", v.Type().Key())
+		}
+		return appendBSONDocument(appendBSONHeader(b, bsonDocument, key), v)
+	case reflect.Struct:
+		return appendBSONDocument(appendBSONHeader(b, bsonDocument, key), v)
+	}
+	return nil, fmt.Errorf("unsupported BSON type: %s", v.Type())
+}
+
+// appendBSONArray appends the elements of the slice or array as a document
+// keyed by their index.
+func appendBSONArray(b []byte, v reflect.Value) ([]byte, error) {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	for i := 0; i < v.Len(); i++ {
+		var err error
+		if b, err = appendBSONElement(b, strconv.Itoa(i), v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, 0)
+	binary.LittleEndian.PutUint32(b[start:], uint32(len(b)-start))
+	return b, nil
+}
+
+// appendBSONHeader appends the type and key of an element.
+func appendBSONHeader(b []byte, kind byte, key string) []byte {
+	b = append(b, kind)
+	b = append(b, key...)
+	return append(b, 0)
+}
+
+// appendBSONString appends the value of a string element.
+func appendBSONString(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)+1))
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// appendBSONInt appends the integer as int32 when it fits, like the MongoDB
+// driver does for Go ints, and as int64 otherwise.
+func appendBSONInt(b []byte, key string, n int64) []byte {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		b = appendBSONHeader(b, bsonInt32, key)
+		return binary.LittleEndian.AppendUint32(b, uint32(int32(n)))
+	}
+	b = appendBSONHeader(b, bsonInt64, key)
+	return binary.LittleEndian.AppendUint64(b, uint64(n))
+}
+
+// numberOf returns the value of the small signed or unsigned integer.
+func numberOf(v reflect.Value) int64 {
+	if v.CanInt() {
+		return v.Int()
+	}
+	return int64(v.Uint())
+}
+
+// bsonKey returns the key of the field, whether it is omitted when empty and
+// whether its fields are inlined into the parent. An empty key means the
+// field is not persisted, e.g. when annotated with `state:"-"` or `bson:"-"`.
+func bsonKey(field reflect.StructField) (key string, omitEmpty, inline bool) {
+	if excludedField(field) {
+		return "", false, false
+	}
+
+	name, opts, _ := strings.Cut(field.Tag.Get("bson"), ",")
+	if name == "-" && opts == "" {
+		return "", false, false
+	}
+	omitEmpty = hasOption(opts, "omitempty")
+
+	ft := field.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if hasOption(opts, "inline") && ft.Kind() == reflect.Struct {
+		return "", false, true
+	}
+
+	if !field.IsExported() {
+		return "", false, false
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, omitEmpty, false
+}
+
+// bsonUnmarshal decodes the BSON document into the struct, map or interface
+// data points to.
+func bsonUnmarshal(b []byte, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("unmarshal target must be a non-nil pointer")
+	}
+	return decodeBSONDocument(b, v.Elem())
+}
+
+// bsonUnmarshalKey decodes the value of the key in the BSON document into the
+// value out points to.
+func bsonUnmarshalKey(b []byte, key string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("unmarshal target must be a non-nil pointer")
+	}
+	elems, err := bsonElements(b)
+	if err != nil {
+		return err
+	}
+	for _, e := range elems {
+		if e.key == key {
+			return decodeBSONElement(e, v.Elem())
+		}
+	}
+	return fmt.Errorf("key %q not found", key)
+}
+
+// decodeBSONDocument decodes the document into the value.
+func decodeBSONDocument(doc []byte, v reflect.Value) error {
+	elems, err := bsonElements(doc)
+	if err != nil {
+		return err
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		m := make(map[string]interface{}, len(elems))
+		for _, e := range elems {
+			if m[e.key], err = bsonGeneric(e); err != nil {
+				return err
+			}
+		}
+		v.Set(reflect.ValueOf(m))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(elems)))
+		}
+		for _, e := range elems {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeBSONElement(e, elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(e.key).Convert(v.Type().Key()), elem)
+		}
+	case v.Kind() == reflect.Struct:
+		fields := make(map[string][]int)
+		bsonFields(v.Type(), nil, fields)
+		for _, e := range elems {
+			index, ok := fields[e.key]
+			if !ok {
+				continue
+			}
+			if err := decodeBSONElement(e, bsonField(v, index)); err != nil {
+				return fmt.Errorf("key %q: %w", e.key, err)
+			}
+		}
+	default:
+		return fmt.Errorf("cannot decode BSON document into %s", v.Type())
+	}
+	return nil
+}
+
+// bsonFields adds the index paths of the persisted fields of the struct type
+// by their key, including those of inlined structs.
+func bsonFields(t reflect.Type, prefix []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, inline := bsonKey(field)
+		index := append(append([]int{}, prefix...), i)
+
+		if inline {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			bsonFields(ft, index, fields)
+			continue
+		}
+		if key != "" {
+			if _, ok := fields[key]; !ok {
+				fields[key] = index
+			}
+		}
+	}
+}
+
+// bsonField returns the field at the index path, allocating the inlined
+// struct pointers on the way.
+func bsonField(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// decodeBSONElement decodes the value of the element into v.
+func decodeBSONElement(e bsonElement, v reflect.Value) error {
+	if e.kind == bsonNull || e.kind == bsonUndefined {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeBSONElement(e, v.Elem())
+	}
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		val, err := bsonGeneric(e)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(val))
+		}
+		return nil
+	}
+
+	if e.kind == bsonString && v.Kind() != reflect.String && v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			s, err := bsonStringValue(e.data)
+			if err != nil {
+				return err
+			}
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch e.kind {
+	case bsonDouble:
+		f := math.Float64frombits(binary.LittleEndian.Uint64(e.data))
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+			return nil
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f <= math.MaxInt64 {
+			return setBSONInt(v, int64(f), e)
+		}
+	case bsonInt32:
+		return setBSONInt(v, int64(int32(binary.LittleEndian.Uint32(e.data))), e)
+	case bsonInt64:
+		return setBSONInt(v, int64(binary.LittleEndian.Uint64(e.data)), e)
+	case bsonString:
+		if v.Kind() == reflect.String {
+			s, err := bsonStringValue(e.data)
+			if err != nil {
+				return err
+			}
+			v.SetString(s)
+			return nil
+		}
+	case bsonBool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(e.data[0] != 0)
+			return nil
+		}
+	case bsonDateTime:
+		if v.Type() == timeType {
+			ms := int64(binary.LittleEndian.Uint64(e.data))
+			v.Set(reflect.ValueOf(time.UnixMilli(ms).UTC()))
+			return nil
+		}
+	case bsonBinary:
+		data, err := bsonBinaryValue(e.data)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			s := reflect.MakeSlice(v.Type(), len(data), len(data))
+			reflect.Copy(s, reflect.ValueOf(data))
+			v.Set(s)
+			return nil
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			reflect.Copy(v, reflect.ValueOf(data))
+			return nil
+		}
+	case bsonObjectID:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(hex.EncodeToString(e.data))
+			return nil
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			reflect.Copy(v, reflect.ValueOf(e.data))
+			return nil
+		}
+	case bsonDocument:
+		return decodeBSONDocument(e.data, v)
+	case bsonArray:
+		elems, err := bsonElements(e.data)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Slice:
+			s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+			for i, elem := range elems {
+				if err := decodeBSONElement(elem, s.Index(i)); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		case reflect.Array:
+			for i := 0; i < v.Len() && i < len(elems); i++ {
+				if err := decodeBSONElement(elems[i], v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot decode BSON %s into %s", bsonTypeName(e.kind), v.Type())
+}
+
+// setBSONInt sets the numeric value from the integer element.
+func setBSONInt(v reflect.Value, n int64, e bsonElement) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%d overflows %s", n, v.Type())
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(n))
+	default:
+		return fmt.Errorf("cannot decode BSON %s into %s", bsonTypeName(e.kind), v.Type())
+	}
+	return nil
+}
+
+// bsonGeneric returns the value of the element as a generic Go value: maps,
+// slices, strings, numbers, booleans, times and byte slices.
+func bsonGeneric(e bsonElement) (interface{}, error) {
+	switch e.kind {
+	case bsonNull, bsonUndefined:
+		return nil, nil
+	case bsonDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(e.data)), nil
+	case bsonInt32:
+		return int(int32(binary.LittleEndian.Uint32(e.data))), nil
+	case bsonInt64:
+		return int(int64(binary.LittleEndian.Uint64(e.data))), nil
+	case bsonString, bsonSymbol, bsonCode:
+		return bsonStringValue(e.data)
+	case bsonBool:
+		return e.data[0] != 0, nil
+	case bsonDateTime:
+		return time.UnixMilli(int64(binary.LittleEndian.Uint64(e.data))).UTC(), nil
+	case bsonBinary:
+		return bsonBinaryValue(e.data)
+	case bsonObjectID:
+		return hex.EncodeToString(e.data), nil
+	case bsonDocument:
+		var m interface{}
+		if err := decodeBSONDocument(e.data, reflect.ValueOf(&m).Elem()); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case bsonArray:
+		elems, err := bsonElements(e.data)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, len(elems))
+		for i, elem := range elems {
+			if list[i], err = bsonGeneric(elem); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unsupported BSON %s", bsonTypeName(e.kind))
+}
+
+// bsonElements splits the document into its elements.
+func bsonElements(doc []byte) ([]bsonElement, error) {
+	if len(doc) < 5 {
+		return nil, fmt.Errorf("invalid BSON document: %w", io.ErrUnexpectedEOF)
+	}
+	n := int(binary.LittleEndian.Uint32(doc))
+	if n < 5 || n > len(doc) {
+		return nil, fmt.Errorf("invalid BSON document length %d: %w", n, io.ErrUnexpectedEOF)
+	}
+	if n != len(doc) || doc[n-1] != 0 {
+		return nil, fmt.Errorf("invalid BSON document: trailing data")
+	}
+
+	var elems []bsonElement
+	body := doc[4 : n-1]
+	for len(body) > 0 {
+		kind := body[0]
+		end := bytes.IndexByte(body[1:], 0)
+		if end < 0 {
+			return nil, fmt.Errorf("invalid BSON key: %w", io.ErrUnexpectedEOF)
+		}
+		key := string(body[1 : end+1])
+		body = body[end+2:]
+
+		size, err := bsonSize(kind, body)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		if size < 0 || size > len(body) {
+			return nil, fmt.Errorf("invalid BSON value of key %q: %w", key, io.ErrUnexpectedEOF)
+		}
+
+		elems = append(elems, bsonElement{kind: kind, key: key, data: body[:size]})
+		body = body[size:]
+	}
+	return elems, nil
+}
+
+// bsonSize returns the size of the value of the element type at the start of b.
+func bsonSize(kind byte, b []byte) (int, error) {
+	length := func() (int, error) {
+		if len(b) < 4 {
+			return 0, fmt.Errorf("invalid BSON value: %w", io.ErrUnexpectedEOF)
+		}
+		return int(int32(binary.LittleEndian.Uint32(b))), nil
+	}
+
+	switch kind {
+	case bsonUndefined, bsonNull, bsonMinKey, bsonMaxKey:
+		return 0, nil
+	case bsonBool:
+		return 1, nil
+	case bsonInt32:
+		return 4, nil
+	case bsonDouble, bsonDateTime, bsonTimestamp, bsonInt64:
+		return 8, nil
+	case bsonObjectID:
+		return 12, nil
+	case bsonDecimal:
+		return 16, nil
+	case bsonString, bsonCode, bsonSymbol:
+		n, err := length()
+		return 4 + n, err
+	case bsonDocument, bsonArray, bsonCodeScope:
+		return length()
+	case bsonBinary:
+		n, err := length()
+		return 5 + n, err
+	case bsonDBPointer:
+		n, err := length()
+		return 4 + n + 12, err
+	case bsonRegex:
+		pattern := bytes.IndexByte(b, 0)
+		if pattern < 0 {
+			return 0, fmt.Errorf("invalid BSON regex: %w", io.ErrUnexpectedEOF)
+		}
+		options := bytes.IndexByte(b[pattern+1:], 0)
+		if options < 0 {
+			return 0, fmt.Errorf("invalid BSON regex: %w", io.ErrUnexpectedEOF)
+		}
+		return pattern + options + 2, nil
+	}
+	return 0, fmt.Errorf("unknown BSON element type 0x%02x", kind)
+}
+
+// bsonStringValue returns the value of a string element.
+func bsonStringValue(data []byte) (string, error) {
+	if len(data) < 5 || data[len(data)-1] != 0 {
+		return "", fmt.Errorf("invalid BSON string")
+	}
+	return string(data[4 : len(data)-1]), nil
+}
+
+// bsonBinaryValue returns a copy of the data of a binary element.
+func bsonBinaryValue(data []byte) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("invalid BSON binary")
+	}
+	return bytes.Clone(data[5:]), nil
+}
+
+// bsonTypeName names the element type in errors.
+func bsonTypeName(kind byte) string {
+	switch kind {
+	case bsonDouble:
+		return "double"
+	case bsonString:
+		return "string"
+	case bsonDocument:
+		return "document"
+	case bsonArray:
+		return "array"
+	case bsonBinary:
+		return "binary"
+	case bsonObjectID:
+		return "objectId"
+	case bsonBool:
+		return "bool"
+	case bsonDateTime:
+		return "datetime"
+	case bsonInt32:
+		return "int32"
+	case bsonInt64:
+		return "int64"
+	}
+	return fmt.Sprintf("type 0x%02x", kind)
+}
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bsonMeta struct {
+	Owner string `bson:"owner"`
+}
+
+type bsonState struct {
+	bsonMeta  `bson:",inline"`
+	Name      string            `bson:"name"`
+	Count     int               `bson:"count"`
+	Total     int64             `bson:"total"`
+	Ratio     float64           `bson:"ratio"`
+	Enabled   bool              `bson:"enabled"`
+	Updated   time.Time         `bson:"updated"`
+	Blob      []byte            `bson:"blob"`
+	Tags      []string          `bson:"tags"`
+	Labels    map[string]string `bson:"labels"`
+	Next      *bsonState        `bson:"next,omitempty"`
+	Secret    string            `bson:"secret" state:"-"`
+	Untagged  string
+	Temporary string `bson:"-"`
+}
+
+// TestBSONRoundTrip ensures state saved as BSON loads back, with times and binary data intact.
+func TestBSONRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bson")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(BSON))
+	assert.NoError(t, err)
+
+	updated := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.FixedZone("X", 3600))
+	data := &bsonState{
+		bsonMeta: bsonMeta{Owner: "bob"},
+		Name:     "alice",
+		Count:    3,
+		Total:    1 << 40,
+		Ratio:    0.5,
+		Enabled:  true,
+		Updated:  updated,
+		Blob:     []byte{0, 1, 2, 0xff},
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"env": "prod"},
+		Next:     &bsonState{Name: "next"},
+		Secret:   "secret",
+		Untagged: "untagged",
+	}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, BSON, DetectFormat(c))
+	assert.NotContains(t, string(c), "secret")
+	assert.Contains(t, string(c), "untagged\x00")
+
+	loaded := &bsonState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Owner)
+	assert.Equal(t, updated.UTC().Truncate(time.Millisecond), loaded.Updated)
+	assert.Equal(t, data.Blob, loaded.Blob)
+	assert.Equal(t, "", loaded.Secret)
+
+	data.Updated = loaded.Updated
+	data.Secret = ""
+	assert.Equal(t, data, loaded)
+}
+
+// TestBSONEncoding ensures values are encoded with the BSON element types MongoDB tooling expects.
+func TestBSONEncoding(t *testing.T) {
+	b, err := bsonMarshal(map[string]interface{}{
+		"b":  []byte{7},
+		"i":  1,
+		"l":  int64(1),
+		"t":  time.UnixMilli(1000),
+		"n":  nil,
+		"id": "x",
+	})
+	assert.NoError(t, err)
+
+	elems, err := bsonElements(b)
+	assert.NoError(t, err)
+	kinds := make(map[string]byte)
+	for _, e := range elems {
+		kinds[e.key] = e.kind
+	}
+	assert.Equal(t, map[string]byte{
+		"b":  bsonBinary,
+		"i":  bsonInt32,
+		"l":  bsonInt64,
+		"t":  bsonDateTime,
+		"n":  bsonNull,
+		"id": bsonString,
+	}, kinds)
+
+	_, err = bsonMarshal("scalar")
+	assert.Error(t, err)
+}
+
+// TestBSONGeneric ensures BSON documents decode to generic maps and convert to other formats.
+func TestBSONGeneric(t *testing.T) {
+	b, err := bsonMarshal(map[string]interface{}{"name": "alice", "tags": []interface{}{"a", 1}})
+	assert.NoError(t, err)
+
+	var v interface{}
+	assert.NoError(t, bsonUnmarshal(b, &v))
+	assert.Equal(t, map[string]interface{}{"name": "alice", "tags": []interface{}{"a", 1}}, v)
+
+	out, err := Convert(BSON, b, JSON, nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "alice", "tags": ["a", 1]}`, string(out))
+}
+
+// TestBSONCorrupted ensures truncated BSON documents fail to load.
+func TestBSONCorrupted(t *testing.T) {
+	b, err := bsonMarshal(&bsonState{Name: "alice"})
+	assert.NoError(t, err)
+
+	assert.Error(t, bsonUnmarshal(b[:len(b)-3], &bsonState{}))
+	assert.Error(t, bsonUnmarshal(append(b, 0), &bsonState{}))
+	assert.Error(t, bsonUnmarshal(b, &[]string{}))
+}
+
+// TestBSONKeyAccess ensures single keys of BSON state can be read and written.
+func TestBSONKeyAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bson")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(BSON))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&bsonState{Name: "alice", Count: 2}))
+
+	var count int
+	assert.NoError(t, sm.Get("count", &count))
+	assert.Equal(t, 2, count)
+
+	assert.NoError(t, sm.Set("name", "bob"))
+	loaded := &bsonState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+	assert.Equal(t, 2, loaded.Count)
+}
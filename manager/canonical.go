@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithCanonicalOutput writes JSON, YAML and STATE in a canonical form: keys
+// sorted at every level, two-space indentation and a trailing newline, so
+// state files kept in version control only change where the state did.
+func WithCanonicalOutput() StateOption {
+	return func(s *StateManager) {
+		s.canonical = true
+	}
+}
+
+// canonicalize rewrites the encoded payload in its canonical form. Other
+// serialization types are returned as is.
+func canonicalize(st SerializationType, b []byte) ([]byte, error) {
+	switch st {
+	case JSON:
+		return canonicalJSON(b)
+	case YAML, STATE:
+		return canonicalYAML(b)
+	}
+	return b, nil
+}
+
+// canonicalJSON re-encodes the JSON document with sorted object keys.
+func canonicalJSON(b []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalYAML re-encodes the YAML document with sorted mapping keys.
+func canonicalYAML(b []byte) ([]byte, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	sortNode(&n)
+
+	var buf bytes.Buffer
+	e := yaml.NewEncoder(&buf)
+	e.SetIndent(2)
+	if err := e.Encode(&n); err != nil {
+		return nil, fmt.Errorf("failed to encode yaml: %w", err)
+	}
+	if err := e.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sortNode sorts the keys of every mapping in the node tree.
+func sortNode(n *yaml.Node) {
+	for _, c := range n.Content {
+		sortNode(c)
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+
+	n.Content = n.Content[:0]
+	for _, p := range pairs {
+		n.Content = append(n.Content, p[0], p[1])
+	}
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// canonicalState is a state with nested maps and out of order fields.
+type canonicalState struct {
+	Zone   string            `json:"zone" yaml:"zone" state:"zone"`
+	Labels map[string]string `json:"labels" yaml:"labels" state:"labels"`
+	Nested struct {
+		Beta  int `json:"beta" yaml:"beta" state:"beta"`
+		Alpha int `json:"alpha" yaml:"alpha" state:"alpha"`
+	} `json:"nested" yaml:"nested" state:"nested"`
+}
+
+// TestWithCanonicalOutput ensures keys are sorted, indented by two spaces and
+// followed by a trailing newline, and that the state round-trips.
+func TestWithCanonicalOutput(t *testing.T) {
+	expected := map[SerializationType]string{
+		JSON: "{\n  \"labels\": {\n    \"a\": \"1\",\n    \"b\": \"2\"\n  },\n  \"nested\": {\n" +
+			"    \"alpha\": 1,\n    \"beta\": 2\n  },\n  \"zone\": \"west\"\n}\n",
+		YAML:  "labels:\n  a: \"1\"\n  b: \"2\"\nnested:\n  alpha: 1\n  beta: 2\nzone: west\n",
+		STATE: "labels:\n  a: \"1\"\n  b: \"2\"\nnested:\n  alpha: 1\n  beta: 2\nzone: west\n",
+	}
+
+	for st, want := range expected {
+		path := filepath.Join(t.TempDir(), "state")
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithCanonicalOutput())
+		assert.NoError(t, err)
+
+		state := &canonicalState{Zone: "west", Labels: map[string]string{"b": "2", "a": "1"}}
+		state.Nested.Beta, state.Nested.Alpha = 2, 1
+		assert.NoError(t, sm.Save(state))
+
+		b, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(b), st)
+
+		loaded := &canonicalState{}
+		assert.NoError(t, sm.Load(loaded))
+		assert.Equal(t, state, loaded, st)
+	}
+}
+
+// TestCanonicalizeOtherFormats ensures formats without a canonical form are left as is.
+func TestCanonicalizeOtherFormats(t *testing.T) {
+	b, err := canonicalize(BIN, []byte{0, 1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, b)
+
+	_, err = canonicalize(JSON, []byte("{"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"errors"
+	"time"
+)
+
+// Clock is the source of time of the manager, used for envelope timestamps,
+// debounced saves and auto-save ticks. See statetest.FakeClock for tests.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a timer created by Clock.AfterFunc.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a ticker created by Clock.NewTicker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// WithClock sets the clock of the manager, the system clock by default.
+func WithClock(c Clock) StateOption {
+	return func(s *StateManager) {
+		if c == nil {
+			s.optionErr = errors.New("clock must not be nil")
+			return
+		}
+		s.clock = c
+	}
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+// systemTicker adapts time.Ticker to Ticker.
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// now returns the current time of the manager clock.
+func (s *StateManager) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// timers returns the clock of the manager.
+func (s *StateManager) timers() Clock {
+	if s.clock == nil {
+		return systemClock{}
+	}
+	return s.clock
+}
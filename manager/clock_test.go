@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedClock is the system clock reporting a constant time.
+type fixedClock struct {
+	systemClock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.now }
+
+// TestWithClock ensures envelope timestamps come from the configured clock.
+func TestWithClock(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithEnvelope(),
+		WithClock(fixedClock{now: now}),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+
+	env, _, err := unwrapEnvelope(c)
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(env.Timestamp))
+}
+
+// TestWithClockNil ensures a nil clock is rejected.
+func TestWithClockNil(t *testing.T) {
+	_, err := NewStateManager(WithClock(nil))
+	assert.Error(t, err)
+}
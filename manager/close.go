@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// lifecycle tracks the background work which has to finish on Close.
+type lifecycle struct {
+	mutex       sync.Mutex
+	closed      bool
+	done        chan struct{}
+	running     sync.WaitGroup
+	stopSignals func()
+}
+
+// WithSignalFlush closes the manager on SIGINT or SIGTERM, so the pending
+// debounced saves and the state of running auto-saves are persisted before
+// the process exits. The signal is then raised again, terminating the process
+// unless the application handles it.
+func WithSignalFlush() StateOption {
+	return func(s *StateManager) {
+		s.signalFlush = true
+	}
+}
+
+// closing returns the channel closed when the manager is closed. Caller must
+// hold the lifecycle lock.
+func (l *lifecycle) closing() chan struct{} {
+	if l.done == nil {
+		l.done = make(chan struct{})
+	}
+	return l.done
+}
+
+// start registers background work and returns the channel closed on Close.
+// It reports false when the manager is already closed.
+func (s *StateManager) start() (<-chan struct{}, bool) {
+	s.life.mutex.Lock()
+	defer s.life.mutex.Unlock()
+
+	if s.life.closed {
+		return nil, false
+	}
+
+	s.life.running.Add(1)
+	return s.life.closing(), true
+}
+
+// Close stops the running auto-saves once they persisted the latest state,
+// writes the pending debounced save, closes the named states and stops the
+// signal handling. The manager can still be used for direct Save and Load
+// calls. Closing a closed manager is a no-op.
+func (s *StateManager) Close() error {
+	s.life.mutex.Lock()
+	if s.life.closed {
+		s.life.mutex.Unlock()
+		return nil
+	}
+	s.life.closed = true
+	close(s.life.closing())
+	if s.life.stopSignals != nil {
+		s.life.stopSignals()
+	}
+	s.life.mutex.Unlock()
+
+	s.life.running.Wait()
+
+	errs := []error{s.Flush()}
+
+	s.mutex.RLock()
+	named := make([]*StateManager, 0, len(s.named))
+	for _, n := range s.named {
+		named = append(named, n)
+	}
+	s.mutex.RUnlock()
+
+	for _, n := range named {
+		errs = append(errs, n.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// handleSignals closes the manager on the first shutdown signal.
+func (s *StateManager) handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	stop := make(chan struct{})
+	var once sync.Once
+	s.life.stopSignals = func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(stop)
+		})
+	}
+
+	go func() {
+		select {
+		case sig := <-signals:
+			_ = s.Close()
+			raise(sig)
+		case <-stop:
+		}
+	}()
+}
+
+// raise sends the signal to the current process now that it is no longer
+// handled by the manager, exiting when the platform cannot deliver it.
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClose ensures Close stops auto-saves after persisting the latest state.
+func TestClose(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	named, err := sm.Named("other")
+	assert.NoError(t, err)
+
+	target := &lockedState{Name: "a"}
+	done := make(chan error)
+	go func() {
+		done <- sm.AutoSave(context.Background(), target, time.Hour)
+	}()
+	go func() {
+		done <- named.AutoSave(context.Background(), &lockedState{Name: "b"}, time.Hour)
+	}()
+
+	assert.Eventually(t, func() bool {
+		sm.life.mutex.Lock()
+		defer sm.life.mutex.Unlock()
+		return sm.life.done != nil
+	}, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		named.life.mutex.Lock()
+		defer named.life.mutex.Unlock()
+		return named.life.done != nil
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, sm.Close())
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-done)
+	assert.NoError(t, sm.Close())
+
+	loaded := &lockedState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+	assert.NoError(t, named.Load(loaded))
+	assert.Equal(t, "b", loaded.Name)
+
+	// auto-save of a closed manager saves once and returns
+	target.Name = "c"
+	assert.NoError(t, sm.AutoSave(context.Background(), target, time.Hour))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "c", loaded.Name)
+}
+
+// TestWithSignalFlush ensures a termination signal closes the manager.
+func TestWithSignalFlush(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can not be sent to the current process on windows")
+	}
+
+	// keep the raised signal from terminating the test
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	sm, err := NewStateManager(
+		WithFilePath(t.TempDir()+"/test_state"),
+		WithSerializationType(JSON),
+		WithSignalFlush(),
+	)
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- sm.AutoSave(context.Background(), &lockedState{Name: "a"}, time.Hour)
+	}()
+
+	assert.Eventually(t, func() bool {
+		sm.life.mutex.Lock()
+		defer sm.life.mutex.Unlock()
+		return sm.life.done != nil
+	}, time.Second, time.Millisecond)
+
+	p, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, p.Signal(syscall.SIGTERM))
+	assert.NoError(t, <-done)
+
+	// the sent signal and the one raised after closing
+	<-signals
+	<-signals
+
+	loaded := &lockedState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Codec encodes and decodes state for a serialization type.
+type Codec interface {
+	Encode(data interface{}) ([]byte, error)
+	Decode(b []byte, data interface{}) error
+}
+
+// codecFuncs adapts a pair of functions to the Codec interface.
+type codecFuncs struct {
+	encode func(data interface{}) ([]byte, error)
+	decode func(b []byte, data interface{}) error
+}
+
+// Encode encodes the data.
+func (c codecFuncs) Encode(data interface{}) ([]byte, error) {
+	return c.encode(data)
+}
+
+// Decode decodes the data.
+func (c codecFuncs) Decode(b []byte, data interface{}) error {
+	return c.decode(b, data)
+}
+
+var (
+	codecMutex sync.RWMutex
+	codecs     = map[SerializationType]Codec{
+		BIN:   codecFuncs{binaryMarshal, binaryUnmarshal},
+		JSON:  codecFuncs{jsonMarshal, jsonUnmarshal},
+		YAML:  codecFuncs{yaml.Marshal, yaml.Unmarshal},
+		STATE: codecFuncs{stateMarshal, stateUnmarshal},
+		PROTO: codecFuncs{protoMarshal, protoUnmarshal},
+		BSON:  codecFuncs{bsonMarshal, bsonUnmarshal},
+		XML:   codecFuncs{xmlMarshal, xml.Unmarshal},
+	}
+)
+
+// RegisterCodec makes the codec available under the given serialization type
+// for all managers, replacing any codec registered under the same name,
+// including the built-in ones. Registering a nil codec removes it.
+func RegisterCodec(name SerializationType, c Codec) {
+	codecMutex.Lock()
+	defer codecMutex.Unlock()
+
+	if c == nil {
+		delete(codecs, name)
+		return
+	}
+	codecs[name] = c
+}
+
+// codecFor returns the codec registered for the serialization type.
+func codecFor(serializationType SerializationType) (Codec, error) {
+	codecMutex.RLock()
+	defer codecMutex.RUnlock()
+
+	c, ok := codecs[serializationType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, serializationType)
+	}
+	return c, nil
+}
+
+// Marshal encodes the data using the given serialization type without
+// the envelope or any other options of a StateManager.
+func Marshal(serializationType SerializationType, data interface{}) ([]byte, error) {
+	c, err := codecFor(serializationType)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encode(data)
+}
+
+// Unmarshal decodes the data using the given serialization type without
+// the envelope or any other options of a StateManager.
+func Unmarshal(serializationType SerializationType, b []byte, data interface{}) error {
+	c, err := codecFor(serializationType)
+	if err != nil {
+		return err
+	}
+	return c.Decode(b, data)
+}
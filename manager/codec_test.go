@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMarshalUnmarshal ensures the exported codecs round-trip without a manager.
+func TestMarshalUnmarshal(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE, BSON, XML} {
+		t.Run(string(st), func(t *testing.T) {
+			data := &TestStruct{"Alice", 30, 98.6, true}
+
+			b, err := Marshal(st, data)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, b)
+
+			decoded := &TestStruct{}
+			assert.NoError(t, Unmarshal(st, b, decoded))
+			assert.Equal(t, data, decoded)
+		})
+	}
+}
+
+// TestMarshalUnsupported ensures unknown serialization types are rejected.
+func TestMarshalUnsupported(t *testing.T) {
+	_, err := Marshal("toml", &TestStruct{})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.ErrorIs(t, Unmarshal("toml", []byte("x"), &TestStruct{}), ErrUnsupportedFormat)
+}
+
+// upperCodec stores a single string field in upper case.
+type upperCodec struct{}
+
+func (upperCodec) Encode(data interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(data.(*TestStruct).Name)), nil
+}
+
+func (upperCodec) Decode(b []byte, data interface{}) error {
+	data.(*TestStruct).Name = strings.ToLower(string(b))
+	return nil
+}
+
+// TestRegisterCodec ensures custom codecs are used by managers and the exported functions.
+func TestRegisterCodec(t *testing.T) {
+	const upper SerializationType = "upper"
+	RegisterCodec(upper, upperCodec{})
+	defer RegisterCodec(upper, nil)
+
+	sm := setupTempStateManager(t, upper)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALICE", string(c))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	RegisterCodec(upper, nil)
+	_, err = Marshal(upper, &TestStruct{})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// WithYAMLComments makes Save keep the comments and the key order of the
+// YAML or STATE file it replaces, so notes users added to the file by hand
+// survive. Keys new to the file follow the existing ones in the order they
+// are encoded and the comments of removed keys are dropped.
+func WithYAMLComments() StateOption {
+	return func(s *StateManager) {
+		s.yamlComments = true
+	}
+}
+
+// keepComments carries the comments and key order of the persisted document
+// over to the encoded payload. The payload is returned as is when there is
+// no persisted YAML document to take them from.
+func (s *StateManager) keepComments(payload []byte) []byte {
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return payload
+	}
+	prevPayload, st, err := s.open(c)
+	if err != nil || (st != YAML && st != STATE) {
+		return payload
+	}
+
+	var prev, next yaml.Node
+	if err := yaml.Unmarshal(prevPayload, &prev); err != nil || len(prev.Content) == 0 {
+		return payload
+	}
+	if err := yaml.Unmarshal(payload, &next); err != nil || len(next.Content) == 0 {
+		return payload
+	}
+
+	mergeComments(&prev, &next)
+
+	b, err := yaml.Marshal(&next)
+	if err != nil {
+		return payload
+	}
+	return b
+}
+
+// mergeComments copies the comments of prev to the matching nodes of next and
+// orders the keys of its mappings like those of prev.
+func mergeComments(prev, next *yaml.Node) {
+	copyComments(prev, next)
+
+	switch {
+	case prev.Kind == yaml.DocumentNode && next.Kind == yaml.DocumentNode:
+		if len(prev.Content) > 0 && len(next.Content) > 0 {
+			mergeComments(prev.Content[0], next.Content[0])
+		}
+	case prev.Kind == yaml.MappingNode && next.Kind == yaml.MappingNode:
+		index := make(map[string]int, len(next.Content)/2)
+		for i := 0; i+1 < len(next.Content); i += 2 {
+			index[next.Content[i].Value] = i
+		}
+
+		ordered := make([]*yaml.Node, 0, len(next.Content))
+		used := make(map[string]bool, len(index))
+		for i := 0; i+1 < len(prev.Content); i += 2 {
+			key := prev.Content[i].Value
+			j, ok := index[key]
+			if !ok || used[key] {
+				continue
+			}
+			used[key] = true
+
+			copyComments(prev.Content[i], next.Content[j])
+			mergeComments(prev.Content[i+1], next.Content[j+1])
+			ordered = append(ordered, next.Content[j], next.Content[j+1])
+		}
+		for i := 0; i+1 < len(next.Content); i += 2 {
+			if !used[next.Content[i].Value] {
+				ordered = append(ordered, next.Content[i], next.Content[i+1])
+			}
+		}
+		next.Content = ordered
+	case prev.Kind == yaml.SequenceNode && next.Kind == yaml.SequenceNode:
+		for i := 0; i < len(prev.Content) && i < len(next.Content); i++ {
+			mergeComments(prev.Content[i], next.Content[i])
+		}
+	}
+}
+
+// copyComments copies the comments of prev to next where it has none.
+func copyComments(prev, next *yaml.Node) {
+	if next.HeadComment == "" {
+		next.HeadComment = prev.HeadComment
+	}
+	if next.LineComment == "" {
+		next.LineComment = prev.LineComment
+	}
+	if next.FootComment == "" {
+		next.FootComment = prev.FootComment
+	}
+}
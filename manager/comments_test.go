@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type commentedState struct {
+	Name  string   `yaml:"name" state:"name"`
+	Port  int      `yaml:"port" state:"port"`
+	Tags  []string `yaml:"tags" state:"tags"`
+	Extra string   `yaml:"extra,omitempty" state:"extra,omitempty"`
+}
+
+// TestWithYAMLComments ensures saves keep the comments and key order of the file.
+func TestWithYAMLComments(t *testing.T) {
+	for _, st := range []SerializationType{YAML, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(`# service settings
+port: 80 # default port
+# the service name
+name: web
+tags:
+    - a # first
+    - b
+`), 0600))
+
+			sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithYAMLComments())
+			assert.NoError(t, err)
+
+			data := &commentedState{}
+			assert.NoError(t, sm.Load(data))
+			data.Port = 8080
+			data.Extra = "new"
+			assert.NoError(t, sm.Save(data))
+
+			c, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, `# service settings
+port: 8080 # default port
+# the service name
+name: web
+tags:
+    - a # first
+    - b
+extra: new
+`, string(c))
+
+			loaded := &commentedState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, data, loaded)
+		})
+	}
+}
+
+// TestWithYAMLCommentsNoFile ensures the first save writes the regular encoding.
+func TestWithYAMLCommentsNoFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(YAML), WithYAMLComments())
+	assert.NoError(t, err)
+
+	data := &commentedState{Name: "web", Port: 80}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	expected, err := Marshal(YAML, data)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(c))
+}
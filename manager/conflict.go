@@ -0,0 +1,231 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Merger resolves a SaveIfVersion conflict by merging the concurrent changes
+// into local: base is the state at the expected revision local was derived
+// from and remote is the state persisted since by another writer.
+type Merger interface {
+	Merge(local, remote, base interface{}) error
+}
+
+// MergeFunc adapts a function to Merger.
+type MergeFunc func(local, remote, base interface{}) error
+
+// Merge calls f.
+func (f MergeFunc) Merge(local, remote, base interface{}) error {
+	return f(local, remote, base)
+}
+
+// WithConflictMerge makes SaveIfVersion merge the state with the one saved
+// concurrently instead of failing with ErrConflict, e.g. with LastWriterWins.
+// The base is the state read by LoadVersion or, failing that, the backup at the
+// expected revision. Without a base the save still fails with ErrConflict.
+func WithConflictMerge(m Merger) StateOption {
+	return func(s *StateManager) {
+		if m == nil {
+			s.optionErr = errors.New("merger must not be nil")
+			return
+		}
+		s.merger = m
+	}
+}
+
+// Timestamped is implemented by values carrying the time they were last
+// changed, like Stamped, so LastWriterWins can tell which writer was last.
+type Timestamped interface {
+	Timestamp() time.Time
+}
+
+// Stamped holds a value with the time it was last changed, to be merged by
+// LastWriterWins as a whole.
+type Stamped[T any] struct {
+	Value T         `json:"value" yaml:"value" state:"value"`
+	Time  time.Time `json:"time" yaml:"time" state:"time"`
+}
+
+// Timestamp returns the time the value was last changed.
+func (s Stamped[T]) Timestamp() time.Time {
+	return s.Time
+}
+
+// Set changes the value and stamps it with the current time.
+func (s *Stamped[T]) Set(v T) {
+	s.Value, s.Time = v, time.Now().UTC()
+}
+
+// LastWriterWins returns a Merger merging per field: changes made on one side
+// only are kept and values implementing Timestamped, like Stamped, changed
+// on both sides take the value with the later timestamp. Other fields
+// changed on both sides carry no timestamp and take the local value, the
+// save in progress being the last one written. Nested structs are merged
+// field by field and maps key by key, all other values are replaced as a whole.
+func LastWriterWins() Merger {
+	return MergeFunc(func(local, remote, base interface{}) error {
+		l, r, b := reflect.ValueOf(local), reflect.ValueOf(remote), reflect.ValueOf(base)
+		if l.Kind() != reflect.Ptr || l.IsNil() || r.Type() != l.Type() || b.Type() != l.Type() {
+			return fmt.Errorf("merge requires pointers of the same type, got %T, %T and %T", local, remote, base)
+		}
+		mergeChanges(l.Elem(), r.Elem(), b.Elem())
+		return nil
+	})
+}
+
+// mergeChanges applies the changes from base to remote onto local.
+func mergeChanges(local, remote, base reflect.Value) {
+	if reflect.DeepEqual(remote.Interface(), base.Interface()) {
+		return
+	}
+	if reflect.DeepEqual(local.Interface(), base.Interface()) {
+		local.Set(remote)
+		return
+	}
+
+	if l, ok := timestamp(local); ok {
+		if r, ok := timestamp(remote); ok && r.After(l) {
+			local.Set(remote)
+		}
+		return
+	}
+
+	switch local.Kind() {
+	case reflect.Struct:
+		for i := 0; i < local.NumField(); i++ {
+			if local.Field(i).CanSet() {
+				mergeChanges(local.Field(i), remote.Field(i), base.Field(i))
+			}
+		}
+	case reflect.Map:
+		mergeMapChanges(local, remote, base)
+	}
+}
+
+// timestamp returns the time the value was last changed when it is Timestamped.
+func timestamp(v reflect.Value) (time.Time, bool) {
+	if !v.CanInterface() {
+		return time.Time{}, false
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return time.Time{}, false
+	}
+	t, ok := v.Interface().(Timestamped)
+	if !ok {
+		return time.Time{}, false
+	}
+	return t.Timestamp(), true
+}
+
+// mergeMapChanges applies the keys added, changed and removed from base to remote onto local.
+func mergeMapChanges(local, remote, base reflect.Value) {
+	if local.IsNil() {
+		local.Set(reflect.MakeMap(local.Type()))
+	}
+
+	for _, k := range remote.MapKeys() {
+		r, b, l := remote.MapIndex(k), base.MapIndex(k), local.MapIndex(k)
+		switch {
+		case !b.IsValid() && !l.IsValid():
+			local.SetMapIndex(k, r)
+		case b.IsValid() && l.IsValid():
+			merged := reflect.New(l.Type()).Elem()
+			merged.Set(l)
+			mergeChanges(merged, r, b)
+			local.SetMapIndex(k, merged)
+		}
+	}
+
+	// keys removed remotely and left alone locally
+	for _, k := range base.MapKeys() {
+		l := local.MapIndex(k)
+		if remote.MapIndex(k).IsValid() || !l.IsValid() {
+			continue
+		}
+		if reflect.DeepEqual(l.Interface(), base.MapIndex(k).Interface()) {
+			local.SetMapIndex(k, reflect.Value{})
+		}
+	}
+}
+
+// resolveConflict merges the persisted state into data with the merger.
+// Caller must hold the lock.
+func (s *StateManager) resolveConflict(data interface{}, expected, found int64) error {
+	conflict := fmt.Errorf("%w: expected revision %d, found %d", ErrConflict, expected, found)
+
+	t := reflect.TypeOf(data)
+	if s.merger == nil || t.Kind() != reflect.Ptr {
+		return conflict
+	}
+
+	baseContent := s.baseContent(expected)
+	if baseContent == nil {
+		return fmt.Errorf("%w: no base to merge with", conflict)
+	}
+
+	base := reflect.New(t.Elem()).Interface()
+	if err := s.decode(baseContent, base); err != nil {
+		return fmt.Errorf("failed to decode merge base: %w", err)
+	}
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return err
+	}
+	remote := reflect.New(t.Elem()).Interface()
+	if err := s.decode(c, remote); err != nil {
+		return fmt.Errorf("failed to decode persisted state: %w", err)
+	}
+
+	if err := s.merger.Merge(data, remote, base); err != nil {
+		return fmt.Errorf("failed to merge concurrent changes: %w", err)
+	}
+
+	s.debug("merged concurrent changes", "expected", expected, "found", found)
+	return nil
+}
+
+// baseContent returns the persisted content at the revision, from the last
+// LoadVersion or the backups. Caller must hold the lock.
+func (s *StateManager) baseContent(revision int64) []byte {
+	s.baseMutex.Lock()
+	base, baseRevision := s.mergeBase, s.mergeBaseRevision
+	s.baseMutex.Unlock()
+	if base != nil && baseRevision == revision {
+		return base
+	}
+
+	for i := 1; i <= s.backups; i++ {
+		c, err := s.files().ReadFile(s.backupPath(i))
+		if err != nil {
+			continue
+		}
+		if env := envelopeHeader(c); env != nil && env.Revision == revision {
+			return c
+		}
+	}
+	return nil
+}
+
+// rememberBase keeps the persisted content as the base of later merges.
+// Caller must hold the read lock, LoadVersion calls it concurrently.
+func (s *StateManager) rememberBase() {
+	if s.merger == nil {
+		return
+	}
+
+	c, err := s.files().ReadFile(s.FilePath)
+
+	s.baseMutex.Lock()
+	defer s.baseMutex.Unlock()
+	if err != nil {
+		s.mergeBase = nil
+		return
+	}
+	if env := envelopeHeader(c); env != nil {
+		s.mergeBase, s.mergeBaseRevision = c, env.Revision
+	}
+}
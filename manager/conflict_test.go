@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type device struct {
+	Name     string            `json:"name"`
+	Theme    string            `json:"theme"`
+	Count    int               `json:"count"`
+	Settings map[string]string `json:"settings"`
+	Window   struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"window"`
+}
+
+// TestLastWriterWins ensures changes are merged per field, map key and nested field.
+func TestLastWriterWins(t *testing.T) {
+	base := &device{Name: "a", Theme: "light", Count: 1, Settings: map[string]string{"x": "1", "y": "1", "z": "1"}}
+	base.Window.Width, base.Window.Height = 100, 100
+
+	local := &device{Name: "b", Theme: "light", Count: 2, Settings: map[string]string{"x": "2", "y": "1", "z": "1", "l": "1"}}
+	local.Window.Width, local.Window.Height = 200, 100
+
+	remote := &device{Name: "a", Theme: "dark", Count: 3, Settings: map[string]string{"x": "3", "y": "3", "r": "1"}}
+	remote.Window.Width, remote.Window.Height = 100, 300
+
+	assert.NoError(t, LastWriterWins().Merge(local, remote, base))
+	assert.Equal(t, "b", local.Name)
+	assert.Equal(t, "dark", local.Theme)
+	assert.Equal(t, 2, local.Count)
+	assert.Equal(t, map[string]string{"x": "2", "y": "3", "l": "1", "r": "1"}, local.Settings)
+	assert.Equal(t, 200, local.Window.Width)
+	assert.Equal(t, 300, local.Window.Height)
+
+	assert.Error(t, LastWriterWins().Merge(device{}, remote, base))
+}
+
+type stampedDevice struct {
+	Theme Stamped[string]            `json:"theme"`
+	Font  Stamped[string]            `json:"font"`
+	Keys  map[string]Stamped[string] `json:"keys"`
+}
+
+// TestLastWriterWinsTimestamps ensures values changed on both sides take the one with the later timestamp.
+func TestLastWriterWinsTimestamps(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stamp := func(v string, minutes int) Stamped[string] {
+		return Stamped[string]{Value: v, Time: t0.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	base := &stampedDevice{Theme: stamp("light", 0), Font: stamp("mono", 0), Keys: map[string]Stamped[string]{"k": stamp("a", 0)}}
+	local := &stampedDevice{Theme: stamp("dark", 1), Font: stamp("serif", 3), Keys: map[string]Stamped[string]{"k": stamp("b", 1)}}
+	remote := &stampedDevice{Theme: stamp("solar", 2), Font: stamp("sans", 2), Keys: map[string]Stamped[string]{"k": stamp("c", 2)}}
+
+	assert.NoError(t, LastWriterWins().Merge(local, remote, base))
+	assert.Equal(t, stamp("solar", 2), local.Theme)
+	assert.Equal(t, stamp("serif", 3), local.Font)
+	assert.Equal(t, stamp("c", 2), local.Keys["k"])
+
+	var s Stamped[int]
+	s.Set(1)
+	assert.Equal(t, 1, s.Value)
+	assert.False(t, s.Timestamp().IsZero())
+}
+
+// TestLoadVersionConcurrent ensures concurrent LoadVersion calls remember the merge base safely.
+func TestLoadVersionConcurrent(t *testing.T) {
+	sm, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state.json")), WithSerializationType(JSON),
+		WithEnvelope(), WithConflictMerge(LastWriterWins()))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.SaveIfVersion(&device{Name: "a"}, 0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, err := sm.LoadVersion(&device{})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.NoError(t, sm.SaveIfVersion(&device{Name: "b"}, 1))
+}
+
+// TestWithConflictMerge ensures concurrent SaveIfVersion calls are merged instead of rejected.
+func TestWithConflictMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	newManager := func() *StateManager {
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithEnvelope(),
+			WithConflictMerge(LastWriterWins()))
+		assert.NoError(t, err)
+		return sm
+	}
+	laptop, desktop := newManager(), newManager()
+
+	assert.NoError(t, laptop.SaveIfVersion(&device{Name: "a", Theme: "light"}, 0))
+
+	a := &device{}
+	revA, err := laptop.LoadVersion(a)
+	assert.NoError(t, err)
+	b := &device{}
+	revB, err := desktop.LoadVersion(b)
+	assert.NoError(t, err)
+
+	a.Theme = "dark"
+	assert.NoError(t, laptop.SaveIfVersion(a, revA))
+
+	b.Count = 5
+	assert.NoError(t, desktop.SaveIfVersion(b, revB))
+	assert.Equal(t, "dark", b.Theme)
+
+	loaded := &device{}
+	assert.NoError(t, laptop.Load(loaded))
+	assert.Equal(t, "dark", loaded.Theme)
+	assert.Equal(t, 5, loaded.Count)
+
+	// without a base the conflict surfaces
+	other := newManager()
+	assert.ErrorIs(t, other.SaveIfVersion(&device{}, 1), ErrConflict)
+}
+
+// TestWithConflictMergeBackups ensures the base is found in the backups.
+func TestWithConflictMergeBackups(t *testing.T) {
+	failed := errors.New("rejected")
+	sm, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state.json")), WithSerializationType(JSON),
+		WithEnvelope(), WithBackups(2), WithConflictMerge(MergeFunc(func(local, remote, base interface{}) error {
+			if remote.(*device).Name == "b" {
+				return failed
+			}
+			return LastWriterWins().Merge(local, remote, base)
+		})))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.SaveIfVersion(&device{Name: "a"}, 0))
+	assert.NoError(t, sm.SaveIfVersion(&device{Name: "a", Count: 1}, 1))
+
+	local := &device{Name: "b"}
+	assert.NoError(t, sm.SaveIfVersion(local, 1))
+	assert.Equal(t, 1, local.Count)
+
+	assert.ErrorIs(t, sm.SaveIfVersion(&device{Name: "c"}, 2), failed)
+	_, err = NewStateManager(WithConflictMerge(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Convert re-encodes the payload from one serialization type to another.
+// The payload is decoded into data, a pointer to the state struct, or into
+// generic values when data is nil. Binary payloads can only be decoded into
+// the struct they were encoded from, so they require data.
+func Convert(from SerializationType, payload []byte, to SerializationType, data interface{}) ([]byte, error) {
+	if data != nil {
+		if err := Unmarshal(from, payload, data); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+		b, err := Marshal(to, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		return b, nil
+	}
+
+	var v interface{}
+	var err error
+	switch from {
+	case JSON:
+		err = json.Unmarshal(payload, &v)
+	case YAML, STATE:
+		err = yaml.Unmarshal(payload, &v)
+	case BSON:
+		err = bsonUnmarshal(payload, &v)
+	default:
+		return nil, fmt.Errorf("%w: converting %s serialization requires the state struct", ErrUnsupportedFormat, from)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	// the state codec only encodes structs, generic values are written as YAML
+	if to == STATE {
+		to = YAML
+	}
+
+	b, err := Marshal(to, v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+	return b, nil
+}
+
+// ConvertTo rewrites the persisted state in the target serialization type
+// to newPath, keeping the other options of the manager (file mode, envelope).
+// See Convert for the meaning of data. The state file of this manager is left
+// untouched unless newPath is the same path, in which case the manager should
+// be recreated with the target serialization type.
+func (s *StateManager) ConvertTo(target SerializationType, newPath string, data interface{}) error {
+	payload, err := s.convert(target, data)
+	if err != nil {
+		return err
+	}
+
+	m := &StateManager{
+		FilePath:          newPath,
+		SerializationType: target,
+		config:            s.config,
+	}
+
+	unlock, err := m.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := m.seal(payload)
+	if err != nil {
+		return err
+	}
+
+	return m.write(newPath, b)
+}
+
+// CopyTo writes the persisted state to the file of dst using the serialization
+// type and options of dst, e.g. to encrypt or decrypt it. The payload is converted
+// when the formats differ, see Convert. The destination may be the same file.
+func (s *StateManager) CopyTo(dst *StateManager) error {
+	payload, st, err := s.Payload()
+	if err != nil {
+		return err
+	}
+
+	if st != dst.SerializationType {
+		if payload, err = Convert(st, payload, dst.SerializationType, nil); err != nil {
+			return err
+		}
+	}
+
+	dst.mutex.Lock()
+	defer dst.mutex.Unlock()
+
+	unlock, err := dst.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := dst.seal(payload)
+	if err != nil {
+		return err
+	}
+
+	return dst.write(dst.FilePath, b)
+}
+
+// Payload returns the persisted payload, unwrapped from the envelope and
+// decrypted, together with the serialization type it is encoded in.
+func (s *StateManager) Payload() ([]byte, SerializationType, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, "", err
+	}
+	defer unlock()
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.open(c)
+}
+
+// convert reads the persisted state and encodes it in the target serialization type.
+func (s *StateManager) convert(target SerializationType, data interface{}) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if data != nil {
+		if err := s.decode(c, data); err != nil {
+			return nil, err
+		}
+		b, err := s.marshal(target, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		return b, nil
+	}
+
+	payload, st, err := s.open(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return Convert(st, payload, target, nil)
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConvert ensures payloads are converted between formats.
+func TestConvert(t *testing.T) {
+	expected := &TestStruct{"Alice", 30, 98.6, true}
+
+	b, err := Marshal(JSON, expected)
+	assert.NoError(t, err)
+
+	y, err := Convert(JSON, b, YAML, nil)
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, Unmarshal(YAML, y, loaded))
+	assert.Equal(t, expected, loaded)
+
+	bin, err := Convert(YAML, y, BIN, &TestStruct{})
+	assert.NoError(t, err)
+
+	_, err = Convert(BIN, bin, JSON, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	j, err := Convert(BIN, bin, JSON, &TestStruct{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(b), string(j))
+}
+
+// TestConvertTo ensures the persisted state is rewritten in another format.
+func TestConvertTo(t *testing.T) {
+	expected := &TestStruct{"Alice", 30, 98.6, true}
+
+	sm := setupTempStateManager(t, BIN)
+	WithEnvelope()(sm)
+	assert.NoError(t, sm.Save(expected))
+
+	jsonPath := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, sm.ConvertTo(JSON, jsonPath, &TestStruct{}))
+
+	js, err := NewStateManager(WithFilePath(jsonPath), WithSerializationType(JSON), WithEnvelope())
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, js.Load(loaded))
+	assert.Equal(t, expected, loaded)
+
+	statePath := filepath.Join(t.TempDir(), "state")
+	assert.NoError(t, js.ConvertTo(STATE, statePath, nil))
+
+	st, err := NewStateManager(WithFilePath(statePath), WithSerializationType(STATE), WithEnvelope())
+	assert.NoError(t, err)
+
+	loaded = &TestStruct{}
+	assert.NoError(t, st.Load(loaded))
+	assert.Equal(t, expected, loaded)
+}
+
+// TestCopyTo ensures the persisted state is copied in the format of the destination.
+func TestCopyTo(t *testing.T) {
+	src := setupTempStateManager(t, BIN)
+	assert.NoError(t, src.Save(&TestStruct{Name: "a"}))
+
+	dst := setupTempStateManager(t, BIN)
+	WithEnvelope()(dst)
+	assert.NoError(t, src.CopyTo(dst))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, dst.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+
+	// binary state can not be converted without the struct
+	assert.ErrorIs(t, src.CopyTo(setupTempStateManager(t, JSON)), ErrUnsupportedFormat)
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// pendingSave is a debounced save waiting to be written.
+type pendingSave struct {
+	data    interface{}
+	payload []byte
+}
+
+// WithDebounce coalesces successive Save calls into a single write once no
+// Save was made for the given duration. Save still runs the hooks, validates
+// and encodes the state right away, so later changes to the struct are not
+// picked up. Update, LoadOrCreate and Set write the pending state first,
+// other operations do not see it until it is written; call Flush to write
+// it immediately.
+func WithDebounce(d time.Duration) StateOption {
+	return func(s *StateManager) {
+		s.debounce = d
+	}
+}
+
+// Flush writes the pending debounced save, if any, and returns its error or
+// the error of a debounced write which failed in the background.
+func (s *StateManager) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.lockAndFlush()
+	flushErr := s.flushErr
+	s.flushErr = nil
+
+	return errors.Join(flushErr, err)
+}
+
+// deferSave encodes the struct and schedules its write. Caller must hold the lock.
+func (s *StateManager) deferSave(data interface{}) error {
+	payload, err := s.prepare(data)
+	if err != nil {
+		return err
+	}
+
+	s.pending = &pendingSave{data: data, payload: payload}
+
+	if s.flushTimer == nil {
+		s.flushTimer = s.timers().AfterFunc(s.debounce, s.flushInBackground)
+	} else {
+		s.flushTimer.Reset(s.debounce)
+	}
+
+	return nil
+}
+
+// flushInBackground writes the pending save once the debounce period passed.
+func (s *StateManager) flushInBackground() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.lockAndFlush(); err != nil {
+		s.debug("failed to write debounced state", slog.Any("error", err))
+		s.flushErr = err
+	}
+}
+
+// lockAndFlush writes the pending save holding the file lock. Caller must hold the lock.
+func (s *StateManager) lockAndFlush() error {
+	if s.pending == nil {
+		return nil
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.flush()
+}
+
+// flush writes the pending save. Caller must hold the lock and the file lock.
+func (s *StateManager) flush() error {
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+	}
+
+	p := s.pending
+	if p == nil {
+		return nil
+	}
+	s.pending = nil
+
+	start := s.now()
+	err := s.commit(p.data, p.payload, s.dirtyTracking)
+	s.observe(OperationSave, start, len(p.payload), err)
+
+	return err
+}
@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupDebounceStateManager creates a manager writing after the given quiet period.
+func setupDebounceStateManager(t *testing.T, d time.Duration, options ...StateOption) *StateManager {
+	t.Helper()
+
+	options = append([]StateOption{
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithDebounce(d),
+	}, options...)
+
+	sm, err := NewStateManager(options...)
+	assert.NoError(t, err)
+
+	return sm
+}
+
+// TestWithDebounce ensures successive saves are coalesced into a single write.
+func TestWithDebounce(t *testing.T) {
+	sm := setupDebounceStateManager(t, 20*time.Millisecond)
+
+	writes := 0
+	sm.OnAfterSave(func(interface{}) error {
+		writes++
+		return nil
+	})
+
+	data := &TestStruct{}
+	for i := 1; i <= 10; i++ {
+		data.Age = i
+		assert.NoError(t, sm.Save(data))
+	}
+
+	// changes made after Save are not picked up
+	data.Age = 100
+
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrNotFound)
+
+	assert.Eventually(t, func() bool {
+		return sm.Exists()
+	}, time.Second, 5*time.Millisecond)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, 10, loaded.Age)
+
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	assert.Equal(t, 1, writes)
+}
+
+// TestFlush ensures the pending save is written on Flush and Close.
+func TestFlush(t *testing.T) {
+	sm := setupDebounceStateManager(t, time.Hour)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.False(t, sm.Exists())
+	assert.NoError(t, sm.Flush())
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "b"}))
+	assert.NoError(t, sm.Close())
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "b", loaded.Name)
+
+	// nothing pending
+	assert.NoError(t, sm.Flush())
+}
+
+// TestDebounceUpdate ensures read-modify-write operations see the pending save.
+func TestDebounceUpdate(t *testing.T) {
+	sm := setupDebounceStateManager(t, time.Hour, WithFileLock())
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a", Age: 1}))
+
+	data := &TestStruct{}
+	assert.NoError(t, sm.Update(data, func() error {
+		data.Age++
+		return nil
+	}))
+	assert.Equal(t, &TestStruct{Name: "a", Age: 2}, data)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "c"}))
+	assert.NoError(t, sm.Delete())
+	assert.NoError(t, sm.Flush())
+	assert.False(t, sm.Exists())
+}
+
+// TestDebounceErrors ensures encoding errors are returned by Save and
+// background write errors by Flush.
+func TestDebounceErrors(t *testing.T) {
+	sm := setupDebounceStateManager(t, time.Millisecond)
+
+	assert.Error(t, sm.Save(make(chan int)))
+
+	failure := errors.New("failed")
+	sm.OnAfterSave(func(interface{}) error {
+		return failure
+	})
+
+	assert.NoError(t, sm.Save(&TestStruct{}))
+	assert.Eventually(t, func() bool {
+		return sm.Exists()
+	}, time.Second, time.Millisecond)
+
+	assert.Eventually(t, func() bool {
+		sm.mutex.RLock()
+		defer sm.mutex.RUnlock()
+		return sm.flushErr != nil
+	}, time.Second, time.Millisecond)
+
+	assert.ErrorIs(t, sm.Flush(), failure)
+	assert.NoError(t, sm.Flush())
+}
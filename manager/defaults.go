@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultAnnotationKey is the key used to define field default values
+	DefaultAnnotationKey = "default"
+)
+
+// applyDefaults sets the fields annotated with a default value which are still
+// zero after decoding, e.g. `state:"port" default:"8080"`. The default is parsed
+// as YAML so slices and maps can be expressed too, e.g. `default:"[a, b]"`.
+func applyDefaults(data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	return applyStructDefaults(v.Elem())
+}
+
+// applyStructDefaults sets the defaults of the struct fields, recursing into nested structs.
+func applyStructDefaults(v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		def, ok := field.Tag.Lookup(DefaultAnnotationKey)
+		if ok && fv.IsZero() {
+			if err := yaml.Unmarshal([]byte(def), fv.Addr().Interface()); err != nil {
+				return fmt.Errorf("invalid default for field %s: %w", field.Name, err)
+			}
+			continue
+		}
+
+		switch {
+		case fv.Kind() == reflect.Struct:
+			if err := applyStructDefaults(fv); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			if err := applyStructDefaults(fv.Elem()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
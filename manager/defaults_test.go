@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type defaultsInner struct {
+	Retries int `json:"retries" state:"retries" default:"3"`
+}
+
+type defaultsStruct struct {
+	Host    string         `json:"host" state:"host" default:"localhost"`
+	Port    int            `json:"port" state:"port" default:"8080"`
+	Timeout time.Duration  `json:"timeout" state:"timeout" default:"30s"`
+	Tags    []string       `json:"tags" state:"tags" default:"[a, b]"`
+	Inner   defaultsInner  `json:"inner" state:"inner"`
+	Ptr     *defaultsInner `json:"ptr" state:"ptr"`
+	Debug   bool           `json:"debug" state:"debug"`
+}
+
+// TestLoadAppliesDefaults ensures absent or zero fields receive their default value.
+func TestLoadAppliesDefaults(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupTempStateManager(t, st)
+			assert.NoError(t, sm.Save(&struct {
+				Host  string `json:"host" state:"host"`
+				Debug bool   `json:"debug" state:"debug"`
+			}{Host: "example.com", Debug: true}))
+
+			loaded := &defaultsStruct{Ptr: &defaultsInner{}}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, "example.com", loaded.Host)
+			assert.Equal(t, 8080, loaded.Port)
+			assert.Equal(t, 30*time.Second, loaded.Timeout)
+			assert.Equal(t, []string{"a", "b"}, loaded.Tags)
+			assert.Equal(t, 3, loaded.Inner.Retries)
+			assert.Equal(t, 3, loaded.Ptr.Retries)
+			assert.True(t, loaded.Debug)
+		})
+	}
+}
+
+// TestInvalidDefault ensures unparsable defaults are reported.
+func TestInvalidDefault(t *testing.T) {
+	type Invalid struct {
+		Port int `default:"not-a-number"`
+	}
+	assert.Error(t, applyDefaults(&Invalid{}))
+	assert.NoError(t, applyDefaults(Invalid{}))
+}
@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// WithFormatDetection decodes the persisted state in the format it was saved in
+// (recorded in the envelope or sniffed from the content) rather than the manager
+// format. Save keeps writing in the manager format.
+func WithFormatDetection() StateOption {
+	return func(s *StateManager) {
+		s.detectFormat = true
+	}
+}
+
+// builtinFormat reports whether the serialization type can be told apart by sniffing.
+func builtinFormat(st SerializationType) bool {
+	switch st {
+	case JSON, YAML, BIN, STATE, BSON, XML:
+		return true
+	}
+	return false
+}
+
+// DetectFormat guesses the built-in serialization type of the payload:
+// a length prefixed document is BSON, other binary content is BIN, text
+// starting with an object or array is JSON, text starting with a tag is XML
+// and any other text is YAML (which STATE is a subset of).
+// An empty payload yields an empty type.
+func DetectFormat(payload []byte) SerializationType {
+	if isBSON(payload) {
+		return BSON
+	}
+
+	b := bytes.TrimSpace(bytes.TrimPrefix(payload, []byte("\xef\xbb\xbf")))
+	if len(b) == 0 {
+		return ""
+	}
+
+	if !isText(b) {
+		return BIN
+	}
+
+	if b[0] == '{' || b[0] == '[' {
+		return JSON
+	}
+
+	if b[0] == '<' {
+		return XML
+	}
+
+	return YAML
+}
+
+// isBSON reports whether the payload is a single BSON document: its
+// little-endian length prefix matches its size and it ends with a zero byte.
+func isBSON(b []byte) bool {
+	return len(b) >= 5 && int(binary.LittleEndian.Uint32(b)) == len(b) && b[len(b)-1] == 0
+}
+
+// isText reports whether the content is valid UTF-8 without control characters.
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// compatibleFormat reports whether the payload detected as the given format
+// can be decoded by the manager format.
+func compatibleFormat(detected, st SerializationType) bool {
+	switch {
+	case detected == "" || detected == st || !builtinFormat(st):
+		return true
+	case detected == YAML && st == STATE:
+		return true
+	case detected == JSON && (st == YAML || st == STATE):
+		// JSON is valid YAML
+		return true
+	}
+	return false
+}
+
+// mismatchError describes content which appears to be saved in a different format.
+func mismatchError(detected, st SerializationType) error {
+	return fmt.Errorf("%w: file appears to be %s but manager is %s", ErrFormatMismatch, detected, st)
+}
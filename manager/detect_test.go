@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDetectFormat ensures the built-in formats are told apart by their content.
+func TestDetectFormat(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN} {
+		b, err := Marshal(st, &TestStruct{"Alice", 30, 98.6, true})
+		assert.NoError(t, err)
+		assert.Equal(t, st, DetectFormat(b), st)
+	}
+
+	assert.Equal(t, SerializationType(""), DetectFormat([]byte(" \n")))
+	assert.Equal(t, JSON, DetectFormat([]byte("\xef\xbb\xbf [1]")))
+}
+
+// TestFormatMismatch ensures reading a file saved in another format names both formats.
+func TestFormatMismatch(t *testing.T) {
+	sm := setupTempStateManager(t, BIN)
+
+	b, err := Marshal(JSON, &TestStruct{Name: "Alice"})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(sm.FilePath, b, 0600))
+
+	err = sm.Load(&TestStruct{})
+	assert.ErrorIs(t, err, ErrFormatMismatch)
+	assert.Contains(t, err.Error(), "file appears to be json but manager is bin")
+}
+
+// TestWithFormatDetection ensures state saved in any built-in format is loaded.
+func TestWithFormatDetection(t *testing.T) {
+	expected := &TestStruct{"Alice", 30, 98.6, true}
+
+	for _, saved := range []SerializationType{JSON, YAML, BIN, STATE} {
+		for _, envelope := range []bool{false, true} {
+			writer := setupTempStateManager(t, saved)
+			writer.envelope = envelope
+			assert.NoError(t, writer.Save(expected))
+
+			reader, err := NewStateManager(WithFilePath(writer.FilePath), WithSerializationType(JSON), WithFormatDetection())
+			assert.NoError(t, err)
+
+			loaded := &TestStruct{}
+			assert.NoError(t, reader.Load(loaded), saved)
+			assert.Equal(t, expected, loaded, saved)
+		}
+	}
+}
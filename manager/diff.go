@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON keeps the value of add and replace operations even when it is empty.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{o.Op, o.Path})
+	}
+
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+// Diff returns the RFC 6902 JSON Patch turning old into new. The paths use
+// the key names of the manager format (JSON for binary and custom formats).
+// An empty patch means both are equal.
+func (s *StateManager) Diff(old, new interface{}) ([]PatchOperation, error) {
+	o, err := s.generic(old)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := s.generic(new)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffValues("", o, n, make([]PatchOperation, 0)), nil
+}
+
+// DiffSaved returns the RFC 6902 JSON Patch turning the persisted state into current.
+func (s *StateManager) DiffSaved(current interface{}) ([]PatchOperation, error) {
+	t := reflect.TypeOf(current)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("current must be a pointer, got %T", current)
+	}
+
+	saved := reflect.New(t.Elem()).Interface()
+
+	s.mutex.RLock()
+	err := func() error {
+		unlock, err := s.lockFile(false)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		c, err := s.readFile(s.FilePath)
+		if err != nil {
+			return err
+		}
+		return s.decode(c, saved)
+	}()
+	s.mutex.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Diff(saved, current)
+}
+
+// generic converts the value into maps, slices and scalars keyed the way the
+// manager format names them.
+func (s *StateManager) generic(v interface{}) (interface{}, error) {
+	var out interface{}
+
+	switch s.SerializationType {
+	case YAML, STATE:
+		b, err := s.marshal(s.SerializationType, v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		if err := yaml.Unmarshal(b, &out); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		if err := json.Unmarshal(b, &out); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+// diffValues appends the operations turning o into n at the given path.
+func diffValues(path string, o, n interface{}, ops []PatchOperation) []PatchOperation {
+	if reflect.DeepEqual(o, n) {
+		return ops
+	}
+
+	switch ov := o.(type) {
+	case map[string]interface{}:
+		if nv, ok := n.(map[string]interface{}); ok {
+			return diffMaps(path, ov, nv, ops)
+		}
+	case []interface{}:
+		if nv, ok := n.([]interface{}); ok {
+			return diffSlices(path, ov, nv, ops)
+		}
+	}
+
+	return append(ops, PatchOperation{Op: "replace", Path: path, Value: n})
+}
+
+// diffMaps appends the operations turning the o object into n.
+func diffMaps(path string, o, n map[string]interface{}, ops []PatchOperation) []PatchOperation {
+	keys := make([]string, 0, len(o)+len(n))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	for k := range n {
+		if _, ok := o[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointer(k)
+		ov, inOld := o[k]
+		nv, inNew := n[k]
+
+		switch {
+		case !inNew:
+			ops = append(ops, PatchOperation{Op: "remove", Path: p})
+		case !inOld:
+			ops = append(ops, PatchOperation{Op: "add", Path: p, Value: nv})
+		default:
+			ops = diffValues(p, ov, nv, ops)
+		}
+	}
+
+	return ops
+}
+
+// diffSlices appends the operations turning the o array into n, comparing
+// the elements by index.
+func diffSlices(path string, o, n []interface{}, ops []PatchOperation) []PatchOperation {
+	common := len(o)
+	if len(n) < common {
+		common = len(n)
+	}
+
+	for i := 0; i < common; i++ {
+		ops = diffValues(path+"/"+strconv.Itoa(i), o[i], n[i], ops)
+	}
+
+	// remove from the end so the indexes stay valid
+	for i := len(o) - 1; i >= common; i-- {
+		ops = append(ops, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+
+	for i := common; i < len(n); i++ {
+		ops = append(ops, PatchOperation{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: n[i]})
+	}
+
+	return ops
+}
+
+// escapePointer escapes a key for use as an RFC 6901 JSON Pointer token.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
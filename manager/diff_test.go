@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// diffState has nested objects and arrays to diff.
+type diffState struct {
+	Name  string            `json:"name" yaml:"name"`
+	Tags  []string          `json:"tags" yaml:"tags"`
+	Attrs map[string]string `json:"attrs,omitempty" yaml:"attrs,omitempty"`
+	Flag  bool              `json:"flag" yaml:"flag"`
+}
+
+// TestDiff ensures the JSON Patch describes all changes.
+func TestDiff(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	old := &diffState{Name: "a", Tags: []string{"x", "y", "z"}, Attrs: map[string]string{"a/b": "1", "gone": "2"}, Flag: true}
+	new := &diffState{Name: "b", Tags: []string{"x", "q"}, Attrs: map[string]string{"a/b": "1", "new": "3"}}
+
+	ops, err := sm.Diff(old, new)
+	assert.NoError(t, err)
+	assert.Equal(t, []PatchOperation{
+		{Op: "remove", Path: "/attrs/gone"},
+		{Op: "add", Path: "/attrs/new", Value: "3"},
+		{Op: "replace", Path: "/flag", Value: false},
+		{Op: "replace", Path: "/name", Value: "b"},
+		{Op: "replace", Path: "/tags/1", Value: "q"},
+		{Op: "remove", Path: "/tags/2"},
+	}, ops)
+
+	ops, err = sm.Diff(old, old)
+	assert.NoError(t, err)
+	assert.Empty(t, ops)
+
+	b, err := json.Marshal([]PatchOperation{
+		{Op: "replace", Path: "/flag", Value: false},
+		{Op: "remove", Path: "/attrs/a~1b"},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"replace","path":"/flag","value":false},{"op":"remove","path":"/attrs/a~1b"}]`, string(b))
+}
+
+// TestDiffSaved ensures the in-memory state is compared with the persisted one.
+func TestDiffSaved(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupTempStateManager(t, st)
+
+			current := &diffState{Name: "a", Tags: []string{"x"}}
+			_, err := sm.DiffSaved(current)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.NoError(t, sm.Save(current))
+
+			current.Tags = append(current.Tags, "y")
+			ops, err := sm.DiffSaved(current)
+			assert.NoError(t, err)
+			assert.Equal(t, []PatchOperation{{Op: "add", Path: "/tags/1", Value: "y"}}, ops)
+		})
+	}
+}
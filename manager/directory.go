@@ -0,0 +1,202 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WithDirectory persists the state as a directory at FilePath holding a file
+// per top-level key instead of a single file, e.g. the field tagged
+// `state:"servers"` in servers.yaml, so large states can be edited and merged
+// piece by piece. All files are saved and loaded together and files of keys no
+// longer in the state are removed. Supported with JSON, YAML and STATE.
+func WithDirectory() StateOption {
+	return func(s *StateManager) {
+		s.directory = true
+	}
+}
+
+// directoryExt returns the extension of the files in directory mode.
+func (s *StateManager) directoryExt() (string, error) {
+	switch s.SerializationType {
+	case JSON:
+		return ".json", nil
+	case YAML, STATE:
+		return ".yaml", nil
+	}
+	return "", fmt.Errorf("%w: directory mode requires json, yaml or state, got %s", ErrUnsupportedFormat, s.SerializationType)
+}
+
+// writeDirectory writes every top-level key of the payload to its own file
+// and removes the files of keys no longer present. Caller must hold the lock.
+func (s *StateManager) writeDirectory(payload []byte) error {
+	ext, err := s.directoryExt()
+	if err != nil {
+		return err
+	}
+
+	parts, err := splitStates(s.SerializationType, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := s.files().MkdirAll(s.FilePath, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		if !validName(name) {
+			return fmt.Errorf("invalid state file name: %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		part, err := s.formatPart(parts[name])
+		if err != nil {
+			return fmt.Errorf("state %q: %w", name, err)
+		}
+
+		b, err := s.seal(part)
+		if err != nil {
+			return err
+		}
+
+		if err := s.write(filepath.Join(s.FilePath, name+ext), b); err != nil {
+			return err
+		}
+	}
+
+	existing, err := s.directoryFiles(ext)
+	if err != nil {
+		return err
+	}
+	for _, name := range existing {
+		if _, ok := parts[name]; ok {
+			continue
+		}
+		if err := s.files().Remove(filepath.Join(s.FilePath, name+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove file: %w", err)
+		}
+	}
+
+	s.pending = nil
+	return nil
+}
+
+// formatPart lays out a key split off the payload like a document of its own.
+func (s *StateManager) formatPart(b []byte) ([]byte, error) {
+	if s.canonical {
+		return canonicalize(s.SerializationType, b)
+	}
+
+	if s.SerializationType == JSON && s.jsonIndent == nil {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", defaultJSONIndent); err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return s.formatJSON(b)
+}
+
+// loadDirectory reads the files of the directory into the given struct and
+// returns the combined payload. Caller must hold the read lock.
+func (s *StateManager) loadDirectory(data interface{}) ([]byte, error) {
+	payload, err := s.readDirectory()
+	if errors.Is(err, ErrNotFound) && s.baseline != nil {
+		return nil, s.decodePayload(s.SerializationType, nil, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, s.decodePayload(s.SerializationType, payload, data)
+}
+
+// readDirectory reads the files of the directory and returns the combined
+// payload. Caller must hold the read lock.
+func (s *StateManager) readDirectory() ([]byte, error) {
+	ext, err := s.directoryExt()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.directoryFiles(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[string][]byte, len(names))
+	for _, name := range names {
+		path := filepath.Join(s.FilePath, name+ext)
+
+		c, err := s.readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s.fixFileMode(path)
+
+		part, _, err := s.open(c)
+		if err != nil {
+			return nil, fmt.Errorf("state %q: %w", name, err)
+		}
+		parts[name] = part
+	}
+
+	return joinStates(s.SerializationType, parts)
+}
+
+// directoryFiles returns the names of the files with the extension in the
+// directory, without the extension.
+func (s *StateManager) directoryFiles(ext string) ([]string, error) {
+	entries, err := s.files().ReadDir(s.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read directory: %w: %w", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ext); ok && !e.IsDir() && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// removeDirectory removes the files of the directory and the directory once empty.
+func (s *StateManager) removeDirectory() error {
+	ext, err := s.directoryExt()
+	if err != nil {
+		return err
+	}
+
+	names, err := s.directoryFiles(ext)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := s.files().Remove(filepath.Join(s.FilePath, name+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove file: %w", err)
+		}
+	}
+
+	// leave the directory in place when it holds other files
+	_ = s.files().Remove(s.FilePath)
+	return nil
+}
@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// directoryState is a state with several top-level keys.
+type directoryState struct {
+	Servers []string          `json:"servers,omitempty" yaml:"servers,omitempty" state:"servers,omitempty"`
+	Secrets map[string]string `json:"secrets" yaml:"secrets" state:"secrets"`
+	Name    string            `json:"name" yaml:"name" state:"name"`
+}
+
+// TestWithDirectory ensures each top-level key is saved to its own file and
+// loaded back together.
+func TestWithDirectory(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		dir := filepath.Join(t.TempDir(), "state")
+		sm, err := NewStateManager(WithFilePath(dir), WithSerializationType(st), WithDirectory(), WithEnvelope())
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, sm.Load(&directoryState{}), ErrNotFound, st)
+
+		state := &directoryState{Servers: []string{"a", "b"}, Secrets: map[string]string{"key": "v"}, Name: "app"}
+		assert.NoError(t, sm.Save(state), st)
+
+		ext := ".yaml"
+		if st == JSON {
+			ext = ".json"
+		}
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3, st)
+
+		loaded := &directoryState{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, state, loaded, st)
+
+		// keys no longer in the state are removed
+		state.Servers = nil
+		assert.NoError(t, sm.Save(state), st)
+		assert.NoFileExists(t, filepath.Join(dir, "servers"+ext), st)
+		assert.FileExists(t, filepath.Join(dir, "secrets"+ext), st)
+
+		assert.NoError(t, sm.Delete(), st)
+		assert.NoDirExists(t, dir, st)
+	}
+}
+
+// TestWithDirectoryFileContent ensures the files read like documents of their own.
+func TestWithDirectoryFileContent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(dir), WithSerializationType(JSON), WithDirectory())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&directoryState{Secrets: map[string]string{"key": "v"}, Name: "app"}))
+
+	b, err := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"key\": \"v\"\n}", string(b))
+
+	// an edit of a single file is picked up
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "name.json"), []byte(`"edited"`), 0600))
+	loaded := &directoryState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "edited", loaded.Name)
+}
+
+// TestWithDirectoryFormat ensures formats without top-level keys are rejected.
+func TestWithDirectoryFormat(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(dir), WithSerializationType(BIN), WithDirectory())
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.Save(&directoryState{Name: "app"}), ErrUnsupportedFormat)
+}
+
+// TestWithDirectoryKeyValue ensures Get, Set and Patch read and write the files of the directory.
+func TestWithDirectoryKeyValue(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		dir := filepath.Join(t.TempDir(), "state")
+		sm, err := NewStateManager(WithFilePath(dir), WithSerializationType(st), WithDirectory(), WithEnvelope())
+		assert.NoError(t, err)
+
+		assert.NoError(t, sm.Set("name", "app"), st)
+		assert.NoError(t, sm.Save(&directoryState{Secrets: map[string]string{"key": "v"}, Name: "app"}), st)
+		assert.NoError(t, sm.Set("servers", []string{"a"}), st)
+
+		ext := ".yaml"
+		if st == JSON {
+			ext = ".json"
+		}
+		assert.FileExists(t, filepath.Join(dir, "servers"+ext), st)
+
+		var name string
+		assert.NoError(t, sm.Get("name", &name), st)
+		assert.Equal(t, "app", name, st)
+
+		assert.NoError(t, sm.Patch([]byte(`{"name": "patched", "servers": null}`)), st)
+		assert.NoFileExists(t, filepath.Join(dir, "servers"+ext), st)
+
+		loaded := &directoryState{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, &directoryState{Secrets: map[string]string{"key": "v"}, Name: "patched"}, loaded, st)
+	}
+}
+
+// TestWithDirectoryCompactJSON ensures the files keep the configured JSON layout.
+func TestWithDirectoryCompactJSON(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(dir), WithSerializationType(JSON), WithDirectory(), WithCompactJSON())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&directoryState{Secrets: map[string]string{"key": "v", "other": "w"}, Name: "app"}))
+	assert.NoError(t, sm.Set("name", "edited"))
+
+	b, err := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"key":"v","other":"w"}`, string(b))
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithDurableWrites flushes every write of the state file to the disk before
+// it replaces the file and the directory holding it after the replacement, so
+// a saved state survives a power loss instead of sitting in the page cache.
+// This makes saves slower. It has no effect with a custom FS.
+func WithDurableWrites() StateOption {
+	return func(s *StateManager) {
+		s.durable = true
+	}
+}
+
+// durableWrites reports whether writes are flushed to the disk.
+func (s *StateManager) durableWrites() bool {
+	return s.durable && s.osFiles()
+}
+
+// writeSynced writes the file like os.WriteFile and flushes it to the disk.
+func writeSynced(name string, b []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// syncParent flushes the directory entry of the renamed file at path to the disk.
+func syncParent(path string) error {
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package manager
+
+// syncDir is a no-op on platforms which can not flush directories, such as
+// Windows, where the rename is written through by the file system.
+func syncDir(_ string) error {
+	return nil
+}
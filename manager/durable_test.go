@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithDurableWrites ensures durable saves replace the file like regular ones.
+func TestWithDurableWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithDurableWrites(), WithBackups(1))
+	assert.NoError(t, err)
+	assert.True(t, sm.durableWrites())
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "bob"}))
+	assert.NoFileExists(t, path+".tmp")
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+	assert.FileExists(t, path+".1")
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultFileMode, info.Mode().Perm())
+
+	sm, err = NewStateManager(WithStore(mapStore{}), WithDurableWrites())
+	assert.NoError(t, err)
+	assert.False(t, sm.durableWrites())
+}
+
+// TestWriteSynced ensures the file is written with the content and mode.
+func TestWriteSynced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, writeSynced(path, []byte("content"), 0600))
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(b))
+	assert.NoError(t, syncParent(path))
+
+	assert.Error(t, writeSynced(filepath.Join(path, "missing", "file"), nil, 0600))
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package manager
+
+import (
+	"os"
+)
+
+// syncDir flushes the entries of the directory to the disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
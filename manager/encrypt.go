@@ -0,0 +1,185 @@
+package manager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+const (
+	// EncryptionAES256GCM is the cipher recorded in the envelope of encrypted state
+	EncryptionAES256GCM = "aes-256-gcm"
+
+	// EncryptionKeySize is the size of the encryption key in bytes
+	EncryptionKeySize = 32
+)
+
+// WithEncryptionKey encrypts the saved payload with AES-256-GCM using the given
+// 32 byte key. Encrypted state is always written with the envelope, which stays
+// readable; unencrypted state is still loaded so existing files can be migrated.
+func WithEncryptionKey(key []byte) StateOption {
+	return func(s *StateManager) {
+		if len(key) != EncryptionKeySize {
+			s.optionErr = fmt.Errorf("invalid encryption key size %d, expected %d bytes", len(key), EncryptionKeySize)
+			return
+		}
+		s.encryptionKey = append([]byte(nil), key...)
+	}
+}
+
+// encrypted reports whether the saved payload is encrypted.
+func (s *StateManager) encrypted() bool {
+	return s.encryptionKey != nil || s.passphrase != nil || s.dataKey != nil || s.age != nil
+}
+
+// encrypt encrypts the payload and records the cipher in the envelope.
+func (s *StateManager) encrypt(env *Envelope, payload []byte) ([]byte, error) {
+	if s.age != nil {
+		return s.sealAge(env, payload)
+	}
+
+	key, err := s.sealKey(env)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	env.Encryption = EncryptionAES256GCM
+	return gcm.Seal(nonce, nonce, payload, nil), nil
+}
+
+// decrypt decrypts the payload of the envelope.
+func (s *StateManager) decrypt(env *Envelope, payload []byte) ([]byte, error) {
+	switch env.Encryption {
+	case EncryptionAES256GCM:
+	case EncryptionAge:
+		return s.openAge(payload)
+	default:
+		return nil, fmt.Errorf("%w: unsupported encryption %q", ErrDecryption, env.Encryption)
+	}
+
+	key, err := s.openKey(env)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: payload too short", ErrDecryption)
+	}
+
+	nonce, ciphertext := payload[:gcm.NonceSize()], payload[gcm.NonceSize():]
+	b, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+
+	return b, nil
+}
+
+// sealKey returns the key to encrypt the payload with, recording its derivation in the envelope.
+func (s *StateManager) sealKey(env *Envelope) ([]byte, error) {
+	if s.passphrase == nil {
+		return s.wrappedKey(env)
+	}
+
+	// keep the salt of the file so the key is derived only once, unless
+	// the parameters changed
+	params := s.passphrase.params
+	var salt []byte
+	if prev := s.header(); prev != nil && prev.KDF == KDFArgon2id && argon2Params(prev) == params {
+		salt = prev.Salt
+	}
+
+	if len(salt) == 0 {
+		salt = make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+
+	env.KDF = KDFArgon2id
+	env.Salt = salt
+	env.Argon2 = &params
+	return s.passphrase.derive(salt, params), nil
+}
+
+// argon2Params returns the Argon2id parameters recorded in the envelope.
+func argon2Params(env *Envelope) Argon2Params {
+	if env.Argon2 == nil {
+		return DefaultArgon2Params
+	}
+	return *env.Argon2
+}
+
+// wrappedKey returns the data key when a key wrapper is set, recording the wrapped key in the envelope.
+func (s *StateManager) wrappedKey(env *Envelope) ([]byte, error) {
+	if s.dataKey == nil {
+		return s.encryptionKey, nil
+	}
+
+	key, wrapped, err := s.dataKey.seal()
+	if err != nil {
+		return nil, err
+	}
+
+	env.KDF = KDFKeyWrap
+	env.WrappedKey = wrapped
+	return key, nil
+}
+
+// openKey returns the key to decrypt the payload of the envelope with.
+func (s *StateManager) openKey(env *Envelope) ([]byte, error) {
+	switch env.KDF {
+	case "":
+		if s.encryptionKey == nil {
+			return nil, fmt.Errorf("%w: state is encrypted but no key is configured", ErrDecryption)
+		}
+		return s.encryptionKey, nil
+	case KDFArgon2id:
+		if s.passphrase == nil {
+			return nil, fmt.Errorf("%w: state is protected by a passphrase", ErrDecryption)
+		}
+		params := argon2Params(env)
+		if err := params.validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecryption, err)
+		}
+		return s.passphrase.derive(env.Salt, params), nil
+	case KDFKeyWrap:
+		if s.dataKey == nil {
+			return nil, fmt.Errorf("%w: state is encrypted with a wrapped key but no key wrapper is configured", ErrDecryption)
+		}
+		return s.dataKey.open(env.WrappedKey)
+	default:
+		return nil, fmt.Errorf("%w: unsupported key derivation %q", ErrDecryption, env.KDF)
+	}
+}
+
+// newGCM creates the AES-GCM cipher for the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return gcm, nil
+}
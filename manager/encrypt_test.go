@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testKey returns a key of the required size filled with the given byte.
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, EncryptionKeySize)
+}
+
+// TestWithEncryptionKey ensures the state is encrypted at rest and round-trips.
+func TestWithEncryptionKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+	expected := &TestStruct{"Alice", 30, 98.6, true}
+
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE} {
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithEncryptionKey(testKey(1)))
+		assert.NoError(t, err)
+		assert.NoError(t, sm.Save(expected))
+
+		c, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(c), "Alice")
+
+		env, err := sm.Envelope()
+		assert.NoError(t, err)
+		assert.Equal(t, EncryptionAES256GCM, env.Encryption)
+
+		loaded := &TestStruct{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, expected, loaded, st)
+	}
+}
+
+// TestEncryptionErrors ensures wrong or missing keys are reported.
+func TestEncryptionErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	_, err := NewStateManager(WithFilePath(path), WithEncryptionKey([]byte("short")))
+	assert.Error(t, err)
+
+	sm, err := NewStateManager(WithFilePath(path), WithEncryptionKey(testKey(1)))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+
+	wrong, err := NewStateManager(WithFilePath(path), WithEncryptionKey(testKey(2)))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, wrong.Load(&TestStruct{}), ErrDecryption)
+
+	plain, err := NewStateManager(WithFilePath(path))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, plain.Load(&TestStruct{}), ErrDecryption)
+}
+
+// TestEncryptionMigration ensures unencrypted state is loaded and encrypted on the next save.
+func TestEncryptionMigration(t *testing.T) {
+	plain := setupTempStateManager(t, JSON)
+	assert.NoError(t, plain.Save(&TestStruct{Name: "a"}))
+
+	sm, err := NewStateManager(WithFilePath(plain.FilePath), WithSerializationType(JSON), WithEncryptionKey(testKey(1)))
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+
+	// encrypt in place
+	assert.NoError(t, plain.CopyTo(sm))
+	assert.ErrorIs(t, plain.Load(&TestStruct{}), ErrDecryption)
+
+	// and decrypt again
+	assert.NoError(t, sm.CopyTo(plain))
+	assert.NoError(t, plain.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+}
+
+// TestPayload ensures the payload is returned decrypted.
+func TestPayload(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	WithEncryptionKey(testKey(1))(sm)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	payload, st, err := sm.Payload()
+	assert.NoError(t, err)
+	assert.Equal(t, JSON, st)
+	assert.Contains(t, string(payload), "Alice")
+}
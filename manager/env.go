@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithEnvOverrides overrides the loaded fields with environment variables
+// named after the upper-cased prefix and the state key of the field, e.g.
+// MYAPP_PORT for the `state:"port"` field and MYAPP_DB_HOST for nested fields.
+// Values are parsed as YAML, so lists can be expressed as [a, b].
+func WithEnvOverrides(prefix string) StateOption {
+	return func(s *StateManager) {
+		s.envPrefix = strings.TrimSuffix(prefix, "_")
+	}
+}
+
+// applyEnvOverrides sets the struct fields from the matching environment variables.
+func applyEnvOverrides(data interface{}, prefix string) error {
+	v := reflect.ValueOf(data)
+	if prefix == "" || v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	return applyStructEnvOverrides(v.Elem(), envName(prefix))
+}
+
+// applyStructEnvOverrides sets the fields of the struct, recursing into nested structs.
+func applyStructEnvOverrides(v reflect.Value, prefix string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + "_" + envName(parseStateTag(field).key(field))
+
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != timeType:
+			if err := applyStructEnvOverrides(fv, name); err != nil {
+				return err
+			}
+			continue
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct && fv.Type().Elem() != timeType:
+			if !fv.IsNil() {
+				if err := applyStructEnvOverrides(fv.Elem(), name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := yaml.Unmarshal([]byte(val), fv.Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid value of %s for field %s: %w", name, field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// envName converts the key into an environment variable name.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEnvOverrides ensures environment variables override loaded values.
+func TestEnvOverrides(t *testing.T) {
+	type DB struct {
+		Host string `state:"host"`
+	}
+
+	type Config struct {
+		Port    int      `state:"port"`
+		Name    string   `state:"app-name"`
+		Tags    []string `state:"tags"`
+		DB      DB       `state:"db"`
+		Replica *DB      `state:"replica"`
+		Plain   string
+	}
+
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(STATE),
+		WithEnvOverrides("MYAPP_"),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&Config{Port: 80, Name: "a", DB: DB{Host: "db"}, Replica: &DB{}}))
+
+	t.Setenv("MYAPP_PORT", "9090")
+	t.Setenv("MYAPP_APP_NAME", "b")
+	t.Setenv("MYAPP_TAGS", "[x, y]")
+	t.Setenv("MYAPP_DB_HOST", "remote")
+	t.Setenv("MYAPP_REPLICA_HOST", "replica")
+	t.Setenv("MYAPP_PLAIN", "plain")
+
+	loaded := &Config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, 9090, loaded.Port)
+	assert.Equal(t, "b", loaded.Name)
+	assert.Equal(t, []string{"x", "y"}, loaded.Tags)
+	assert.Equal(t, "remote", loaded.DB.Host)
+	assert.Equal(t, "replica", loaded.Replica.Host)
+	assert.Equal(t, "plain", loaded.Plain)
+
+	t.Setenv("MYAPP_PORT", "not-a-number")
+	assert.Error(t, sm.Load(&Config{}))
+}
+
+// TestEnvOverridesDisabled ensures environment is ignored without the option.
+func TestEnvOverridesDisabled(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+
+	t.Setenv("_NAME", "b")
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+}
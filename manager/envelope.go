@@ -0,0 +1,148 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// envelopeMagic is the first line of every file written with an envelope
+	envelopeMagic = "STATE-ENVELOPE/1\n"
+)
+
+// Envelope is the metadata header written in front of the saved payload.
+type Envelope struct {
+	Format        SerializationType `json:"format"`
+	SchemaVersion int               `json:"schema_version"`
+	Revision      int64             `json:"revision"`
+	Encryption    string            `json:"encryption,omitempty"`
+	KDF           string            `json:"kdf,omitempty"`
+	Salt          []byte            `json:"salt,omitempty"`
+	Argon2        *Argon2Params     `json:"argon2,omitempty"`
+	WrappedKey    []byte            `json:"wrapped_key,omitempty"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Checksum      string            `json:"checksum"`
+	Size          int               `json:"size"`
+	Signature     []byte            `json:"signature,omitempty"`
+}
+
+// WithEnvelope wraps every saved payload in a metadata envelope
+func WithEnvelope() StateOption {
+	return func(s *StateManager) {
+		s.envelope = true
+	}
+}
+
+// WithSchemaVersion sets the schema version recorded in the envelope
+func WithSchemaVersion(version int) StateOption {
+	return func(s *StateManager) {
+		s.schemaVersion = version
+	}
+}
+
+// Envelope returns the verified envelope of the persisted state or nil when
+// the state was saved without one.
+func (s *StateManager) Envelope() (*Envelope, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	env, _, err := unwrapEnvelope(c)
+	return env, err
+}
+
+// checksum returns the hex encoded SHA-256 of the given payload.
+func checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// wrapEnvelope prefixes the payload with the envelope header.
+func wrapEnvelope(env *Envelope, payload []byte) ([]byte, error) {
+	env.Checksum = checksum(payload)
+	env.Size = len(payload)
+
+	h, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(envelopeMagic) + len(h) + 1 + len(payload))
+	buf.WriteString(envelopeMagic)
+	buf.Write(h)
+	buf.WriteByte('\n')
+	buf.Write(payload)
+
+	return buf.Bytes(), nil
+}
+
+// hasEnvelope checks if the content starts with the envelope header.
+func hasEnvelope(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(envelopeMagic))
+}
+
+// envelopeHeader parses the envelope header without verifying the payload.
+// Content without an envelope or with a malformed header yields nil.
+func envelopeHeader(content []byte) *Envelope {
+	if !hasEnvelope(content) {
+		return nil
+	}
+
+	rest := content[len(envelopeMagic):]
+	i := bytes.IndexByte(rest, '\n')
+	if i < 0 {
+		return nil
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(rest[:i], &env); err != nil {
+		return nil
+	}
+
+	return &env
+}
+
+// unwrapEnvelope parses the envelope header and verifies the payload integrity.
+// Content without an envelope is returned as is with a nil envelope.
+func unwrapEnvelope(content []byte) (*Envelope, []byte, error) {
+	if !hasEnvelope(content) {
+		return nil, content, nil
+	}
+
+	rest := content[len(envelopeMagic):]
+	i := bytes.IndexByte(rest, '\n')
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w: incomplete envelope header", ErrCorrupted)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(rest[:i], &env); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid envelope header: %v", ErrCorrupted, err)
+	}
+
+	payload := rest[i+1:]
+	if len(payload) != env.Size {
+		return nil, nil, fmt.Errorf("%w: %w: expected %d bytes, got %d", ErrCorrupted, ErrChecksumMismatch, env.Size, len(payload))
+	}
+
+	if checksum(payload) != env.Checksum {
+		return nil, nil, fmt.Errorf("%w: %w: expected sha256 %s", ErrCorrupted, ErrChecksumMismatch, env.Checksum)
+	}
+
+	return &env, payload, nil
+}
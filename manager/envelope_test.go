@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupEnvelopeStateManager(t *testing.T, serializationType SerializationType) *StateManager {
+	t.Helper()
+
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(serializationType),
+		WithEnvelope(),
+		WithSchemaVersion(3),
+	)
+	assert.NoError(t, err)
+
+	return sm
+}
+
+// TestSaveAndLoadWithEnvelope ensures all formats round-trip inside of an envelope.
+func TestSaveAndLoadWithEnvelope(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupEnvelopeStateManager(t, st)
+			data := &TestStruct{"Alice", 30, 98.6, true}
+			assert.NoError(t, sm.Save(data))
+
+			c, err := os.ReadFile(sm.FilePath)
+			assert.NoError(t, err)
+			assert.True(t, hasEnvelope(c))
+
+			env, _, err := unwrapEnvelope(c)
+			assert.NoError(t, err)
+			assert.Equal(t, st, env.Format)
+			assert.Equal(t, 3, env.SchemaVersion)
+			assert.False(t, env.Timestamp.IsZero())
+
+			loaded := &TestStruct{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, data, loaded)
+		})
+	}
+}
+
+// TestLoadTruncatedEnvelope ensures truncated files surface ErrCorrupted.
+func TestLoadTruncatedEnvelope(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, BIN)
+	assert.NoError(t, sm.Save(&TestStruct{"Bob", 40, 36.5, false}))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(sm.FilePath, c[:len(c)-5], 0600))
+
+	err = sm.Load(&TestStruct{})
+	assert.ErrorIs(t, err, ErrCorrupted)
+
+	// header cut short
+	assert.NoError(t, os.WriteFile(sm.FilePath, c[:len(envelopeMagic)+5], 0600))
+	err = sm.Load(&TestStruct{})
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+// TestLoadTamperedEnvelope ensures payload modifications fail the checksum.
+func TestLoadTamperedEnvelope(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	assert.NoError(t, sm.Save(&TestStruct{"Bob", 40, 36.5, false}))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+	c[len(c)-3] = 'X'
+	assert.NoError(t, os.WriteFile(sm.FilePath, c, 0600))
+
+	err = sm.Load(&TestStruct{})
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+// TestLoadTruncatedBinary ensures truncated gob files without envelope surface ErrCorrupted.
+func TestLoadTruncatedBinary(t *testing.T) {
+	sm := setupTempStateManager(t, BIN)
+	assert.NoError(t, sm.Save(&TestStruct{"Bob", 40, 36.5, false}))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(sm.FilePath, c[:len(c)/2], 0600))
+
+	err = sm.Load(&TestStruct{})
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+// TestLoadEnvelopeWithoutOption ensures enveloped files load regardless of the option.
+func TestLoadEnvelopeWithoutOption(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, YAML)
+	data := &TestStruct{"Ann", 22, 36.6, true}
+	assert.NoError(t, sm.Save(data))
+
+	plain, err := NewStateManager(
+		WithFilePath(sm.FilePath),
+		WithSerializationType(YAML),
+	)
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, plain.Load(loaded))
+	assert.Equal(t, data, loaded)
+
+	other, err := NewStateManager(
+		WithFilePath(sm.FilePath),
+		WithSerializationType(JSON),
+	)
+	assert.NoError(t, err)
+	assert.Error(t, other.Load(&TestStruct{}))
+}
+
+// TestEnvelope ensures the envelope of the persisted state is returned.
+func TestEnvelope(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	_, err := sm.Envelope()
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	env, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, JSON, env.Format)
+	assert.Equal(t, int64(1), env.Revision)
+
+	plain := setupTempStateManager(t, JSON)
+	assert.NoError(t, plain.Save(&TestStruct{Name: "a"}))
+	env, err = plain.Envelope()
+	assert.NoError(t, err)
+	assert.Nil(t, env)
+}
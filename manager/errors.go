@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNotFound is returned when the persisted state or the requested part of it does not exist.
+	ErrNotFound = errors.New("state not found")
+
+	// ErrUnsupportedFormat is returned when the serialization type does not support the operation.
+	ErrUnsupportedFormat = errors.New("unsupported serialization format")
+
+	// ErrLocked is returned when the state lock could not be acquired within the lock timeout.
+	ErrLocked = errors.New("state is locked")
+
+	// ErrCorrupted is returned when the persisted state is truncated or fails integrity checks.
+	ErrCorrupted = errors.New("state is corrupted")
+
+	// ErrChecksumMismatch is returned when the payload does not match the size or SHA-256 recorded
+	// in the envelope, e.g. after the file was modified or truncated. It is also an ErrCorrupted.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrFormatMismatch is returned when the persisted state was saved in a different serialization format.
+	ErrFormatMismatch = errors.New("serialization format mismatch")
+
+	// ErrConflict is returned when the persisted state was changed since the expected revision.
+	ErrConflict = errors.New("state was changed concurrently")
+
+	// ErrDecryption is returned when the encrypted state can not be decrypted, e.g. with a wrong key.
+	ErrDecryption = errors.New("failed to decrypt state")
+
+	// ErrSignature is returned when the persisted state is not signed by the expected key.
+	ErrSignature = errors.New("signature verification failed")
+
+	// ErrStale is returned when the persisted state is older than the TTL set by WithTTL.
+	ErrStale = errors.New("state is stale")
+
+	// ErrInvalid is returned when the state is rejected by validation.
+	ErrInvalid = errors.New("state is invalid")
+
+	// ErrTooLarge is returned when the state file exceeds the size set by WithMaxSize.
+	ErrTooLarge = errors.New("state is too large")
+
+	// ErrReadOnly is returned by operations changing the state of a manager created WithReadOnly.
+	ErrReadOnly = errors.New("state is read-only")
+)
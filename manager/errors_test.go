@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestErrNotFound ensures missing state and keys are reported as ErrNotFound.
+func TestErrNotFound(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	err := sm.Load(&TestStruct{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.ErrorIs(t, sm.Snapshot("x"), ErrNotFound)
+	assert.ErrorIs(t, sm.LoadAt(time.Now(), &TestStruct{}), ErrNotFound)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.ErrorIs(t, sm.Rollback("x"), ErrNotFound)
+
+	var v string
+	assert.ErrorIs(t, sm.Get("missing", &v), ErrNotFound)
+}
+
+// TestErrUnsupportedFormat ensures unknown and unsupported formats are reported.
+func TestErrUnsupportedFormat(t *testing.T) {
+	sm := setupTempStateManager(t, SerializationType("unknown"))
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), ErrUnsupportedFormat)
+
+	assert.NoError(t, os.WriteFile(sm.FilePath, []byte("x"), 0600))
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrUnsupportedFormat)
+
+	bin := setupTempStateManager(t, BIN)
+	assert.ErrorIs(t, bin.Set("name", "x"), ErrUnsupportedFormat)
+}
+
+// TestErrLocked ensures lock timeouts are reported as ErrLocked.
+func TestErrLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	holder, err := NewStateManager(WithFilePath(path), WithFileLock())
+	assert.NoError(t, err)
+
+	waiter, err := NewStateManager(WithFilePath(path), WithLockTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		data := &TestStruct{}
+		_ = holder.Update(data, func() error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+
+	<-locked
+	assert.ErrorIs(t, waiter.Save(&TestStruct{}), ErrLocked)
+	close(release)
+	<-done
+
+	assert.NoError(t, waiter.Save(&TestStruct{Name: "b"}))
+}
@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// eventLogSuffix is appended to the state file path to name the event log
+const eventLogSuffix = ".events"
+
+// eventRecord wraps the event so its concrete type is encoded with it.
+type eventRecord struct {
+	Event interface{}
+}
+
+// Append adds the event to the event log kept next to the state file. Events
+// are gob encoded, so their types must be registered with RegisterTypes.
+// Combined with Save and ClearEvents the log holds the changes since the last snapshot.
+func (s *StateManager) Append(event interface{}) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(&eventRecord{Event: event}); err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+
+	if s.createDirs {
+		if err := os.MkdirAll(filepath.Dir(s.FilePath), 0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(s.eventLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, s.fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close event log: %w", err)
+	}
+
+	return nil
+}
+
+// Replay calls apply with every event of the log in the order they were appended,
+// stopping at the first error. An empty or missing log replays nothing.
+// A partially written last event is reported as ErrCorrupted.
+func (s *StateManager) Replay(apply func(event interface{}) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.Open(s.eventLogPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for i := 0; ; i++ {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("%w: event %d: %v", ErrCorrupted, i, err)
+		}
+
+		b := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, b); err != nil {
+			return fmt.Errorf("%w: event %d: %v", ErrCorrupted, i, err)
+		}
+
+		var record eventRecord
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&record); err != nil {
+			return fmt.Errorf("failed to decode event %d: %w", i, err)
+		}
+
+		if err := apply(record.Event); err != nil {
+			return err
+		}
+	}
+}
+
+// ClearEvents removes the event log, e.g. after saving a snapshot of the replayed state.
+func (s *StateManager) ClearEvents() error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(s.eventLogPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove event log: %w", err)
+	}
+
+	return nil
+}
+
+// eventLogPath returns the path of the event log.
+func (s *StateManager) eventLogPath() string {
+	return s.FilePath + eventLogSuffix
+}
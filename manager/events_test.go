@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type deposited struct {
+	Amount int
+}
+
+type withdrawn struct {
+	Amount int
+}
+
+// TestAppendReplay ensures events are replayed in order with their types.
+func TestAppendReplay(t *testing.T) {
+	RegisterTypes(deposited{}, withdrawn{})
+	sm := setupTempStateManager(t, JSON)
+
+	count := 0
+	assert.NoError(t, sm.Replay(func(interface{}) error {
+		count++
+		return nil
+	}))
+	assert.Zero(t, count)
+
+	assert.NoError(t, sm.Append(deposited{Amount: 100}))
+	assert.NoError(t, sm.Append(withdrawn{Amount: 30}))
+	assert.NoError(t, sm.Append(deposited{Amount: 5}))
+
+	balance := 0
+	assert.NoError(t, sm.Replay(func(event interface{}) error {
+		switch e := event.(type) {
+		case deposited:
+			balance += e.Amount
+		case withdrawn:
+			balance -= e.Amount
+		default:
+			return errors.New("unexpected event")
+		}
+		return nil
+	}))
+	assert.Equal(t, 75, balance)
+
+	stop := errors.New("stop")
+	assert.ErrorIs(t, sm.Replay(func(interface{}) error { return stop }), stop)
+
+	assert.NoError(t, sm.ClearEvents())
+	assert.NoError(t, sm.Replay(func(interface{}) error {
+		count++
+		return nil
+	}))
+	assert.Zero(t, count)
+}
+
+// TestReplayCorrupted ensures a partially written event is reported.
+func TestReplayCorrupted(t *testing.T) {
+	RegisterTypes(deposited{})
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Append(deposited{Amount: 1}))
+
+	c, err := os.ReadFile(sm.eventLogPath())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(sm.eventLogPath(), c[:len(c)-2], 0600))
+
+	assert.ErrorIs(t, sm.Replay(func(interface{}) error { return nil }), ErrCorrupted)
+	assert.Error(t, sm.Append(func() {}))
+}
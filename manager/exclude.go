@@ -0,0 +1,308 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// excludedPathCache caches the index paths of the excluded fields by type.
+var excludedPathCache sync.Map
+
+// excludedField reports whether the field is annotated with `state:"-"`, so
+// it is never persisted in any format. Like encoding/json, `state:"-,"`
+// persists the field under the key "-" instead.
+func excludedField(field reflect.StructField) bool {
+	return field.Tag.Get(StateAnnotationKey) == "-"
+}
+
+// excludedPaths returns the index paths of the excluded fields of the struct
+// type, including those of nested structs and struct pointers.
+func excludedPaths(t reflect.Type) [][]int {
+	if paths, ok := excludedPathCache.Load(t); ok {
+		return paths.([][]int)
+	}
+
+	var paths [][]int
+	if t.Kind() == reflect.Struct {
+		findExcluded(t, nil, map[reflect.Type]bool{}, &paths)
+	}
+	actual, _ := excludedPathCache.LoadOrStore(t, paths)
+	return actual.([][]int)
+}
+
+// findExcluded appends the paths of the excluded fields of the struct type
+// below the prefix, skipping the types already being walked.
+func findExcluded(t reflect.Type, prefix []int, walking map[reflect.Type]bool, paths *[][]int) {
+	if walking[t] || t == timeType || decodesItself(t) || encodesItself(t) {
+		return
+	}
+	walking[t] = true
+	defer delete(walking, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		path := append(append([]int{}, prefix...), i)
+		if excludedField(field) {
+			*paths = append(*paths, path)
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			findExcluded(ft, path, walking, paths)
+		}
+	}
+}
+
+// excludeFields returns a copy of the struct pointed to by data with the
+// excluded fields cleared, so no format persists them. Nested struct pointers
+// leading to such fields are copied too, so data is left untouched. Data
+// itself is returned when it has no such fields.
+func excludeFields(data interface{}) interface{} {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() || len(excludedPaths(v.Type().Elem())) == 0 {
+		return data
+	}
+
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(v.Elem())
+
+	copied := make(map[uintptr]bool)
+	for _, path := range excludedPaths(v.Type().Elem()) {
+		fv, ok := excludedValue(c.Elem(), path, copied)
+		if ok && fv.CanSet() {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+	return c.Interface()
+}
+
+// keepExcluded remembers the excluded fields of the struct pointed to by data
+// and returns a function restoring them, so decoding leaves them untouched.
+func keepExcluded(data interface{}) func() {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return func() {}
+	}
+	paths := excludedPaths(v.Elem().Type())
+	if len(paths) == 0 {
+		return func() {}
+	}
+
+	kept := make(map[int]reflect.Value, len(paths))
+	for i, path := range paths {
+		if fv, ok := excludedValue(v.Elem(), path, nil); ok && fv.CanSet() {
+			val := reflect.New(fv.Type()).Elem()
+			val.Set(fv)
+			kept[i] = val
+		}
+	}
+
+	return func() {
+		for i, path := range paths {
+			fv, ok := excludedValue(v.Elem(), path, nil)
+			if !ok || !fv.CanSet() {
+				continue
+			}
+			if val, ok := kept[i]; ok {
+				fv.Set(val)
+			} else {
+				fv.Set(reflect.Zero(fv.Type()))
+			}
+		}
+	}
+}
+
+// excludedValue returns the field at the index path of the struct, or false
+// when a struct pointer on the way is nil. Pointers on the way are replaced
+// by copies of their structs once when copied is given.
+func excludedValue(v reflect.Value, path []int, copied map[uintptr]bool) (reflect.Value, bool) {
+	for _, i := range path[:len(path)-1] {
+		v = v.Field(i)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			if copied != nil && !copied[v.Pointer()] && v.CanSet() {
+				c := reflect.New(v.Type().Elem())
+				c.Elem().Set(v.Elem())
+				v.Set(c)
+				copied[c.Pointer()] = true
+			}
+			v = v.Elem()
+		}
+	}
+	return v.Field(path[len(path)-1]), true
+}
+
+// dropExcluded removes the keys of the excluded fields of the data from the
+// encoded JSON, YAML or XML document, which would otherwise persist them cleared.
+func dropExcluded(st SerializationType, data interface{}, b []byte) ([]byte, error) {
+	if st != JSON && st != YAML && st != XML {
+		return b, nil
+	}
+
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return b, nil
+	}
+	paths := excludedPaths(t)
+	if len(paths) == 0 {
+		return b, nil
+	}
+
+	var keys [][]string
+	for _, path := range paths {
+		if k, ok := excludedKeys(st, t, path); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	if st == XML {
+		var err error
+		for _, k := range keys {
+			if b, err = dropXMLKey(b, k); err != nil {
+				return nil, fmt.Errorf("failed to encode data: %w", err)
+			}
+		}
+		return b, nil
+	}
+
+	if st == JSON {
+		var err error
+		for _, k := range keys {
+			if b, err = dropJSONKey(b, k); err != nil {
+				return nil, fmt.Errorf("failed to encode data: %w", err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", defaultJSONIndent); err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return b, nil
+	}
+	for _, k := range keys {
+		dropYAMLKey(doc.Content[0], k)
+	}
+	return yaml.Marshal(&doc)
+}
+
+// excludedKeys returns the keys leading to the field at the index path of
+// the struct type, or false when the field is not persisted by the format.
+func excludedKeys(st SerializationType, t reflect.Type, path []int) ([]string, bool) {
+	var keys []string
+	for _, i := range path {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		field := t.Field(i)
+
+		key, inline := FieldKey(st, field)
+		if !inline {
+			if key == "" {
+				return nil, false
+			}
+			if st == XML {
+				keys = append(keys, strings.Split(key, ">")...)
+			} else {
+				keys = append(keys, key)
+			}
+		}
+		t = field.Type
+	}
+	return keys, len(keys) > 0
+}
+
+// dropJSONKey removes the key path from the JSON object, keeping the order
+// of the other keys. The result is compact.
+func dropJSONKey(b []byte, keys []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return b, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return nil, err
+		}
+		if key == keys[0] {
+			if len(keys) == 1 {
+				continue
+			}
+			if val, err = dropJSONKey(val, keys[1:]); err != nil {
+				return nil, err
+			}
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// dropYAMLKey removes the key path from the YAML mapping.
+func dropYAMLKey(n *yaml.Node, keys []string) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value != keys[0] {
+			continue
+		}
+		if len(keys) == 1 {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return
+		}
+		dropYAMLKey(n.Content[i+1], keys[1:])
+		return
+	}
+}
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type excludedSession struct {
+	ID    string `json:"id" yaml:"id" state:"id"`
+	Token string `json:"token" yaml:"token" state:"-"`
+}
+
+type excludedState struct {
+	Name     string           `json:"name" yaml:"name" state:"name"`
+	Password string           `json:"password" yaml:"password" state:"-"`
+	Session  *excludedSession `json:"session" yaml:"session" state:"session"`
+	Dash     string           `json:"dash" yaml:"dash" state:"-,"`
+}
+
+// TestExcludedFields ensures fields annotated with "-" are persisted in no format and left untouched on load.
+func TestExcludedFields(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE, BSON, XML} {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state")
+			sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st))
+			assert.NoError(t, err)
+
+			data := &excludedState{Name: "a", Password: "secret", Session: &excludedSession{ID: "s1", Token: "token"}, Dash: "dash"}
+			assert.NoError(t, sm.Save(data))
+			assert.Equal(t, "secret", data.Password)
+			assert.Equal(t, "token", data.Session.Token)
+
+			c, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NotContains(t, string(c), "secret")
+			assert.NotContains(t, string(c), "token")
+			if st != BIN {
+				assert.NotContains(t, string(c), "password")
+			}
+			assert.Contains(t, string(c), "dash")
+
+			loaded := &excludedState{Password: "kept"}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, &excludedState{Name: "a", Password: "kept", Session: &excludedSession{ID: "s1"}, Dash: "dash"}, loaded)
+		})
+	}
+}
+
+// TestExcludedFieldsOnLoad ensures keys of excluded fields in a file are ignored.
+func TestExcludedFieldsOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"name": "a", "password": "edited"}`), 0600))
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON))
+	assert.NoError(t, err)
+
+	loaded := &excludedState{Password: "kept"}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+	assert.Equal(t, "kept", loaded.Password)
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"reflect"
+	"sync"
+)
+
+// stateField is the analysis of a struct field used by the STATE codec.
+type stateField struct {
+	index     int
+	name      string
+	key       string
+	named     bool
+	tagged    bool
+	exported  bool
+	omitEmpty bool
+	required  bool
+	excluded  bool
+	// inline fields persist the fields of the struct as keys of the parent
+	inline bool
+	// embedded fields are untagged embedded structs, inlined by mappers
+	embedded bool
+}
+
+// keyFor returns the key of the field, naming fields without a key in their
+// annotation by the mapper when given.
+func (f stateField) keyFor(names FieldNameMapper) string {
+	if names == nil || f.named {
+		return f.key
+	}
+	return names(f.name)
+}
+
+// persisted reports whether the field is persisted under its own key.
+func (f stateField) persisted(names FieldNameMapper) bool {
+	return (f.tagged || names != nil) && f.exported && !f.excluded
+}
+
+// inlined reports whether the fields of the struct are persisted as keys of the parent.
+func (f stateField) inlined(names FieldNameMapper) bool {
+	return f.inline || names != nil && f.embedded
+}
+
+// stateType is the cached analysis of a struct type used by the STATE codec.
+type stateType struct {
+	fields []stateField
+	// tagged reports whether any field has a state annotation
+	tagged bool
+}
+
+// stateTypes caches the analysis of struct types by reflect.Type, so the
+// struct tags are parsed once per type rather than on every save and load.
+var stateTypes sync.Map
+
+// stateTypeOf returns the analysis of the struct type.
+func stateTypeOf(t reflect.Type) *stateType {
+	if st, ok := stateTypes.Load(t); ok {
+		return st.(*stateType)
+	}
+
+	st := &stateType{fields: make([]stateField, 0, t.NumField())}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseStateTag(field)
+
+		st.fields = append(st.fields, stateField{
+			index:     i,
+			name:      field.Name,
+			key:       tag.key(field),
+			named:     tag.name != "",
+			tagged:    tag.tagged(),
+			exported:  field.IsExported(),
+			omitEmpty: tag.has(tagOmitEmpty),
+			required:  tag.has(tagRequired),
+			excluded:  excludedField(field),
+			inline:    tag.inline(field),
+			embedded:  tag.embedded(field),
+		})
+		st.tagged = st.tagged || tag.tagged() || tag.inline(field)
+	}
+
+	actual, _ := stateTypes.LoadOrStore(t, st)
+	return actual.(*stateType)
+}
@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStateTypeOf ensures the field analysis is computed once per type.
+func TestStateTypeOf(t *testing.T) {
+	type cached struct {
+		Name     string   `state:"name,required"`
+		Tags     []string `state:",omitempty"`
+		Other    string
+		internal string
+	}
+
+	st := stateTypeOf(reflect.TypeOf(cached{}))
+	assert.True(t, st.tagged)
+	assert.Equal(t, []stateField{
+		{index: 0, name: "Name", key: "name", named: true, tagged: true, exported: true, required: true},
+		{index: 1, name: "Tags", key: "tags", tagged: true, exported: true, omitEmpty: true},
+		{index: 2, name: "Other", key: "other", exported: true},
+		{index: 3, name: "internal", key: "internal"},
+	}, st.fields)
+	assert.Same(t, st, stateTypeOf(reflect.TypeOf(cached{})))
+
+	assert.False(t, stateTypeOf(reflect.TypeOf(struct{ A int }{})).tagged)
+}
+
+// BenchmarkStateMarshal measures the STATE encoding of a small struct.
+func BenchmarkStateMarshal(b *testing.B) {
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	for i := 0; i < b.N; i++ {
+		if _, err := stateMarshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkStateUnmarshal measures the STATE decoding of a small struct.
+func BenchmarkStateUnmarshal(b *testing.B) {
+	payload, err := stateMarshal(&TestStruct{"Alice", 30, 98.6, true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if err := stateUnmarshal(payload, &TestStruct{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
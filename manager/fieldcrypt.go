@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// encryptedFieldPrefix marks the string values of fields encrypted with WithFieldEncryption
+const encryptedFieldPrefix = "enc:"
+
+// WithFieldEncryption encrypts the string fields annotated with the encrypt
+// option, e.g. `state:"token,encrypt"`, with AES-256-GCM using the given 32 byte
+// key, leaving the rest of the state readable. The values are stored as
+// "enc:" followed by the base64 encoded random nonce and ciphertext. The Go
+// path of the field, e.g. "Creds.Token", is authenticated with the value, so
+// values moved to another field fail to load. Plain text values of annotated
+// fields are rejected on load unless WithFieldEncryptionMigration is set.
+func WithFieldEncryption(key []byte) StateOption {
+	return func(s *StateManager) {
+		if len(key) != EncryptionKeySize {
+			s.optionErr = fmt.Errorf("invalid field encryption key size %d, expected %d bytes", len(key), EncryptionKeySize)
+			return
+		}
+		s.fieldKey = append([]byte(nil), key...)
+	}
+}
+
+// WithFieldEncryptionMigration loads plain text values of fields annotated
+// with encrypt as they are, so existing state can be migrated to
+// WithFieldEncryption. The values are encrypted on the next save.
+func WithFieldEncryptionMigration() StateOption {
+	return func(s *StateManager) {
+		s.fieldMigrate = true
+	}
+}
+
+// sealFields returns a copy of the struct with the encrypt annotated fields
+// encrypted, or the data itself when there are none.
+func (s *StateManager) sealFields(data interface{}) (interface{}, error) {
+	return copyTagged(data, []string{tagEncrypt}, func(path string, field reflect.StructField, v reflect.Value) error {
+		return s.cryptValue(path, field, v, true)
+	})
+}
+
+// openFields decrypts the encrypt annotated fields of the struct in place.
+func (s *StateManager) openFields(data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() || !hasTaggedFields(v.Type().Elem(), []string{tagEncrypt}, nil) {
+		return nil
+	}
+
+	return walkTagged(v.Elem(), "", []string{tagEncrypt}, false, func(path string, field reflect.StructField, v reflect.Value) error {
+		return s.cryptValue(path, field, v, false)
+	})
+}
+
+// cryptValue encrypts or decrypts the value of the field at the path.
+func (s *StateManager) cryptValue(path string, field reflect.StructField, v reflect.Value, seal bool) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("field %s annotated with encrypt must be a string", field.Name)
+	}
+
+	val, err := s.cryptField(path, v.String(), seal)
+	if err != nil {
+		return fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	v.SetString(val)
+	return nil
+}
+
+// cryptField encrypts or decrypts a single value, authenticating the field
+// path with it. Empty values are kept and values without the prefix are only
+// loaded as plain text while migrating.
+func (s *StateManager) cryptField(path, val string, seal bool) (string, error) {
+	if val == "" {
+		return val, nil
+	}
+	if !seal && !strings.HasPrefix(val, encryptedFieldPrefix) {
+		if s.fieldMigrate {
+			return val, nil
+		}
+		return "", fmt.Errorf("%w: value is not encrypted", ErrDecryption)
+	}
+
+	if s.fieldKey == nil && seal {
+		return "", errors.New("no field encryption key is configured")
+	}
+	if s.fieldKey == nil {
+		return "", fmt.Errorf("%w: no field encryption key is configured", ErrDecryption)
+	}
+
+	gcm, err := newGCM(s.fieldKey)
+	if err != nil {
+		return "", err
+	}
+
+	if seal {
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		return encryptedFieldPrefix + base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(val), []byte(path))), nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(val, encryptedFieldPrefix))
+	if err != nil || len(b) < gcm.NonceSize() {
+		return "", fmt.Errorf("%w: invalid encrypted value", ErrDecryption)
+	}
+
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], []byte(path))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+
+	return string(plain), nil
+}
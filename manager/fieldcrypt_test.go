@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type credentials struct {
+	Token string `json:"token" yaml:"token" state:"token,encrypt"`
+}
+
+type secretState struct {
+	Name   string       `json:"name" yaml:"name" state:"name"`
+	APIKey string       `json:"api_key" yaml:"api_key" state:"api_key,encrypt"`
+	Creds  credentials  `json:"creds" yaml:"creds" state:"creds"`
+	Backup *credentials `json:"backup" yaml:"backup" state:"backup"`
+}
+
+// TestWithFieldEncryption ensures only annotated fields are encrypted.
+func TestWithFieldEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	_, err := NewStateManager(WithFilePath(path), WithFieldEncryption([]byte("short")))
+	assert.Error(t, err)
+
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithFieldEncryption(testKey(1)))
+		assert.NoError(t, err)
+
+		data := &secretState{
+			Name:   "prod",
+			APIKey: "s3cr3t",
+			Creds:  credentials{Token: "nested-token"},
+			Backup: &credentials{Token: "backup-token"},
+		}
+		assert.NoError(t, sm.Save(data))
+
+		// the saved struct is left untouched
+		assert.Equal(t, "s3cr3t", data.APIKey)
+		assert.Equal(t, "backup-token", data.Backup.Token)
+
+		c, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Contains(t, string(c), "prod", st)
+		assert.Contains(t, string(c), encryptedFieldPrefix, st)
+		assert.NotContains(t, string(c), "s3cr3t", st)
+		assert.NotContains(t, string(c), "nested-token", st)
+		assert.NotContains(t, string(c), "backup-token", st)
+
+		// every save uses fresh nonces
+		assert.NoError(t, sm.Save(data))
+		again, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotEqual(t, c, again)
+
+		loaded := &secretState{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, data, loaded, st)
+
+		wrong, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithFieldEncryption(testKey(2)))
+		assert.NoError(t, err)
+		assert.ErrorIs(t, wrong.Load(&secretState{}), ErrDecryption)
+	}
+}
+
+// TestFieldEncryptionErrors ensures misconfiguration is reported and plain values load only while migrating.
+func TestFieldEncryptionErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	plain, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON))
+	assert.NoError(t, err)
+	assert.Error(t, plain.Save(&secretState{APIKey: "s3cr3t"}))
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"name":"prod","api_key":"s3cr3t"}`), 0600))
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithFieldEncryption(testKey(1)))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Load(&secretState{}), ErrDecryption)
+
+	migrate, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithFieldEncryption(testKey(1)), WithFieldEncryptionMigration())
+	assert.NoError(t, err)
+
+	loaded := &secretState{}
+	assert.NoError(t, migrate.Load(loaded))
+	assert.Equal(t, "s3cr3t", loaded.APIKey)
+	assert.NoError(t, migrate.Save(loaded))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "s3cr3t", loaded.APIKey)
+
+	type invalid struct {
+		Port int `json:"port" state:"port,encrypt"`
+	}
+	assert.Error(t, sm.Save(&invalid{Port: 1}))
+
+	assert.NoError(t, sm.Save(&secretState{APIKey: "s3cr3t"}))
+	assert.ErrorIs(t, plain.Load(&secretState{}), ErrDecryption)
+}
+
+// TestFieldEncryptionSwapped ensures encrypted values moved to another field fail to load.
+func TestFieldEncryptionSwapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithFieldEncryption(testKey(1)))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&secretState{APIKey: "s3cr3t", Creds: credentials{Token: "token"}}))
+
+	var values map[string]interface{}
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(c, &values))
+	values["creds"].(map[string]interface{})["token"] = values["api_key"]
+	c, err = json.Marshal(values)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, c, 0600))
+
+	assert.ErrorIs(t, sm.Load(&secretState{}), ErrDecryption)
+}
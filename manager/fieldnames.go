@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// FieldNameMapper maps the Go name of a field to the key it is persisted
+// under when its annotation does not name one.
+type FieldNameMapper func(name string) string
+
+// SnakeCase maps field names like UserID to user_id.
+func SnakeCase(name string) string {
+	return strings.ToLower(strings.Join(nameWords(name), "_"))
+}
+
+// KebabCase maps field names like UserID to user-id.
+func KebabCase(name string) string {
+	return strings.ToLower(strings.Join(nameWords(name), "-"))
+}
+
+// CamelCase maps field names like UserID to userID.
+func CamelCase(name string) string {
+	words := nameWords(name)
+	if len(words) == 0 {
+		return ""
+	}
+	words[0] = strings.ToLower(words[0])
+	return strings.Join(words, "")
+}
+
+// WithFieldNameMapper persists the exported fields of structs in the STATE
+// format under the key the mapper returns for their Go name, e.g.
+// WithFieldNameMapper(SnakeCase), unless their annotation names the key.
+// Fields without an annotation are persisted too, and embedded structs
+// without one are inlined, the same way on save and load. Fields annotated
+// with `state:"-"` and types encoding themselves are left as they are.
+func WithFieldNameMapper(mapper FieldNameMapper) StateOption {
+	return func(s *StateManager) {
+		if mapper == nil {
+			s.optionErr = errors.New("field name mapper must not be nil")
+			return
+		}
+		s.fieldNames = mapper
+	}
+}
+
+// nameWords splits the Go name into its words, keeping acronyms and digits
+// together, e.g. HTTPServer2Addr into HTTP, Server2 and Addr.
+func nameWords(name string) []string {
+	runes := []rune(name)
+
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, r := runes[i-1], runes[i]
+		boundary := unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) ||
+			unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) ||
+			r == '_'
+		if !boundary {
+			continue
+		}
+		if word := strings.Trim(string(runes[start:i]), "_"); word != "" {
+			words = append(words, word)
+		}
+		start = i
+	}
+	if word := strings.Trim(string(runes[start:]), "_"); word != "" {
+		words = append(words, word)
+	}
+	return words
+}
+
+// fieldKeyNamed returns the key of the field like FieldKey, naming the STATE
+// keys of fields without one in their annotation by the mapper.
+func fieldKeyNamed(st SerializationType, field reflect.StructField, names FieldNameMapper) (string, bool) {
+	if st != STATE || names == nil {
+		return FieldKey(st, field)
+	}
+	if !field.IsExported() && !field.Anonymous {
+		return "", false
+	}
+
+	tag := parseStateTag(field)
+	if tag.inline(field) || tag.embedded(field) {
+		return "", true
+	}
+	if !field.IsExported() || excludedField(field) {
+		return "", false
+	}
+	if tag.name != "" {
+		return tag.name, false
+	}
+	return names(field.Name), false
+}
+
+// marshal encodes the data in the serialization type, naming the STATE keys
+// by the field name mapper.
+func (s *StateManager) marshal(st SerializationType, data interface{}) ([]byte, error) {
+	if st == STATE && s.fieldNames != nil {
+		return stateMarshalNamed(data, s.fieldNames)
+	}
+	return Marshal(st, data)
+}
+
+// demarshal decodes the payload in the serialization type, naming the STATE
+// keys by the field name mapper.
+func (s *StateManager) demarshal(st SerializationType, b []byte, data interface{}) error {
+	if st == STATE && s.fieldNames != nil {
+		return stateUnmarshalNamed(b, data, s.fieldNames)
+	}
+	return Unmarshal(st, b, data)
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mappedServer struct {
+	HostName string
+	Port     int
+}
+
+type MappedBase struct {
+	CreatedBy string
+}
+
+type mappedState struct {
+	MappedBase
+	UserID    string
+	Name      string `state:"display"`
+	MaxItems  int    `state:",omitempty"`
+	APIServer *mappedServer
+	Secret    string `state:"-"`
+	internal  string
+}
+
+// TestFieldNameMappers ensures the built-in mappers split Go names into words.
+func TestFieldNameMappers(t *testing.T) {
+	for name, expected := range map[string][3]string{
+		"Name":           {"name", "name", "name"},
+		"UserID":         {"user_id", "user-id", "userID"},
+		"HTTPServerAddr": {"http_server_addr", "http-server-addr", "httpServerAddr"},
+		"Port2Value":     {"port2_value", "port2-value", "port2Value"},
+		"ID":             {"id", "id", "id"},
+	} {
+		assert.Equal(t, expected[0], SnakeCase(name), name)
+		assert.Equal(t, expected[1], KebabCase(name), name)
+		assert.Equal(t, expected[2], CamelCase(name), name)
+	}
+}
+
+// TestWithFieldNameMapper ensures untagged fields round trip under mapped keys in the STATE format.
+func TestWithFieldNameMapper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(STATE),
+		WithFieldNameMapper(SnakeCase), WithStrictDecoding())
+	assert.NoError(t, err)
+
+	data := &mappedState{
+		MappedBase: MappedBase{CreatedBy: "ops"},
+		UserID:     "u1",
+		Name:       "name",
+		APIServer:  &mappedServer{HostName: "localhost", Port: 80},
+		Secret:     "secret",
+		internal:   "internal",
+	}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	for _, key := range []string{"created_by:", "user_id:", "display:", "api_server:", "host_name:"} {
+		assert.Contains(t, string(c), key)
+	}
+	for _, key := range []string{"max_items", "secret", "internal", "mapped_base"} {
+		assert.NotContains(t, string(c), key)
+	}
+
+	loaded := &mappedState{}
+	assert.NoError(t, sm.Load(loaded))
+	data.Secret, data.internal = "", ""
+	assert.Equal(t, data, loaded)
+
+	kebab, err := NewStateManager(WithFilePath(path), WithSerializationType(STATE), WithFieldNameMapper(KebabCase))
+	assert.NoError(t, err)
+	assert.NoError(t, kebab.Save(data))
+	c, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(c), "user-id:")
+
+	custom, err := NewStateManager(WithFilePath(path), WithSerializationType(STATE), WithFieldNameMapper(strings.ToUpper))
+	assert.NoError(t, err)
+	assert.NoError(t, custom.Save(data))
+	loaded = &mappedState{}
+	assert.NoError(t, custom.Load(loaded))
+	assert.Equal(t, data, loaded)
+}
+
+// TestWithFieldNameMapperNil ensures a nil mapper is rejected.
+func TestWithFieldNameMapperNil(t *testing.T) {
+	_, err := NewStateManager(WithFieldNameMapper(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FS is the file system the state, its backups and snapshots are persisted to.
+// The methods behave like their counterparts in the os package.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	MkdirAll(path string, perm os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	Chmod(name string, mode os.FileMode) error
+	ReadDir(name string) ([]os.DirEntry, error)
+}
+
+// osFS is the FS of the operating system.
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFS) WriteFile(name string, b []byte, perm os.FileMode) error {
+	return os.WriteFile(name, b, perm)
+}
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Stat(name string) (os.FileInfo, error)        { return os.Stat(name) }
+func (osFS) Chmod(name string, mode os.FileMode) error    { return os.Chmod(name, mode) }
+func (osFS) ReadDir(name string) ([]os.DirEntry, error)   { return os.ReadDir(name) }
+
+// WithFS persists the state to the given file system instead of the one of
+// the operating system, e.g. an in-memory one in tests (see statetest.MemFS)
+// or ReadOnlyFS over an embed.FS. File locks and the event log always use the
+// operating system, so WithFileLock has no effect with a custom FS other than
+// a store implementing StoreLocker.
+func WithFS(fsys FS) StateOption {
+	return func(s *StateManager) {
+		if fsys == nil {
+			s.optionErr = errors.New("file system must not be nil")
+			return
+		}
+		s.fs = fsys
+	}
+}
+
+// files returns the file system of the manager.
+func (s *StateManager) files() FS {
+	if s.fs == nil {
+		return osFS{}
+	}
+	return s.fs
+}
+
+// osFiles checks if the manager uses the file system of the operating system.
+func (s *StateManager) osFiles() bool {
+	_, ok := s.files().(osFS)
+	return ok
+}
+
+// readOnlyFS adapts an fs.FS to FS, failing every write.
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+// ReadOnlyFS returns an FS reading from fsys, e.g. an embed.FS, on which every
+// write fails with fs.ErrPermission. Paths are cleaned and made relative, so
+// WithFilePath("/state.json") reads "state.json" from fsys.
+func ReadOnlyFS(fsys fs.FS) FS {
+	return &readOnlyFS{fsys: fsys}
+}
+
+// name turns the file path into a valid fs.FS path.
+func (r *readOnlyFS) name(name string) string {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (r *readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(r.fsys, r.name(name))
+}
+
+func (r *readOnlyFS) Stat(name string) (os.FileInfo, error) {
+	return fs.Stat(r.fsys, r.name(name))
+}
+
+func (r *readOnlyFS) ReadDir(name string) ([]os.DirEntry, error) {
+	return fs.ReadDir(r.fsys, r.name(name))
+}
+
+func (r *readOnlyFS) WriteFile(name string, _ []byte, _ os.FileMode) error {
+	return r.denied("write", name)
+}
+
+func (r *readOnlyFS) Rename(oldpath, _ string) error {
+	return r.denied("rename", oldpath)
+}
+
+func (r *readOnlyFS) Remove(name string) error {
+	return r.denied("remove", name)
+}
+
+func (r *readOnlyFS) MkdirAll(name string, _ os.FileMode) error {
+	return r.denied("mkdir", name)
+}
+
+func (r *readOnlyFS) Chmod(name string, _ os.FileMode) error {
+	return r.denied("chmod", name)
+}
+
+// denied returns the error of a write operation.
+func (r *readOnlyFS) denied(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("%w: read-only file system", fs.ErrPermission)}
+}
@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadOnlyFS ensures embedded state is loaded but never written.
+func TestReadOnlyFS(t *testing.T) {
+	fsys := fstest.MapFS{"defaults/state.json": {Data: []byte(`{"name": "embedded"}`)}}
+
+	_, err := NewStateManager(WithFS(nil))
+	assert.Error(t, err)
+
+	sm, err := NewStateManager(WithFS(ReadOnlyFS(fsys)), WithFilePath("/defaults/state.json"), WithSerializationType(JSON))
+	assert.NoError(t, err)
+	assert.True(t, sm.Exists())
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "embedded", loaded.Name)
+
+	assert.ErrorIs(t, sm.Save(&TestStruct{Name: "changed"}), fs.ErrPermission)
+	assert.ErrorIs(t, sm.Delete(), fs.ErrPermission)
+
+	missing, err := NewStateManager(WithFS(ReadOnlyFS(fsys)), WithFilePath("other.json"))
+	assert.NoError(t, err)
+	assert.False(t, missing.Exists())
+	assert.ErrorIs(t, missing.Load(&TestStruct{}), ErrNotFound)
+}
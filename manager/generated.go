@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The functions in this file support the MarshalState and UnmarshalState
+// methods generated by cmd/stategen, which encode the same documents as the
+// STATE codec without analyzing the struct by reflection.
+
+// EncodeStateValues encodes the values of the top-level keys as a STATE document.
+func EncodeStateValues(values map[string]interface{}) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	encoder := yaml.NewEncoder(buf)
+	if err := encoder.Encode(values); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// DecodeStateValues decodes a STATE document into the values of its top-level keys.
+func DecodeStateValues(b []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+	return values, nil
+}
+
+// FieldValue returns the value the STATE codec encodes for the field the
+// pointer points to and false when the field is omitted as empty.
+func FieldValue(field interface{}, omitEmpty bool) (interface{}, bool, error) {
+	v := reflect.ValueOf(field)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, false, errors.New("field must be a non-nil pointer")
+	}
+
+	if omitEmpty && isEmptyValue(v.Elem()) {
+		return nil, false, nil
+	}
+
+	val, err := stateValue(v.Elem(), nil)
+	return val, err == nil, err
+}
+
+// SetFieldValue sets the field the pointer points to from its decoded value
+// like the STATE codec. Values of the wrong type leave the field as is, so
+// only a *MissingFieldError of a nested struct is returned.
+func SetFieldValue(field interface{}, value interface{}, prefix string) error {
+	v := reflect.ValueOf(field)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("field must be a non-nil pointer")
+	}
+
+	err := setStateField(v.Elem(), value, prefix, nil)
+	var missing *MissingFieldError
+	if errors.As(err, &missing) {
+		return err
+	}
+	return nil
+}
+
+// IntValue converts the decoded value of an integer field.
+func IntValue(value interface{}) (int64, bool) {
+	num, err := strconv.ParseInt(fmt.Sprintf("%v", value), 10, 64)
+	return num, err == nil
+}
+
+// UintValue converts the decoded value of an unsigned integer field.
+func UintValue(value interface{}) (uint64, bool) {
+	num, err := strconv.ParseUint(fmt.Sprintf("%v", value), 10, 64)
+	return num, err == nil
+}
+
+// FloatValue converts the decoded value of a floating-point field.
+func FloatValue(value interface{}) (float64, bool) {
+	num, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64)
+	return num, err == nil
+}
+
+// BoolValue converts the decoded value of a boolean field.
+func BoolValue(value interface{}) (bool, bool) {
+	boolean, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+	return boolean, err == nil
+}
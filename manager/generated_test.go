@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFieldValue ensures single fields are encoded like the STATE codec.
+func TestFieldValue(t *testing.T) {
+	d := 2 * time.Second
+	v, ok, err := FieldValue(&d, false)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2s", v)
+
+	var tags []string
+	_, ok, err = FieldValue(&tags, true)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = FieldValue(d, false)
+	assert.Error(t, err)
+}
+
+// TestSetFieldValue ensures single fields are decoded like the STATE codec.
+func TestSetFieldValue(t *testing.T) {
+	var d time.Duration
+	assert.NoError(t, SetFieldValue(&d, "1m", ""))
+	assert.Equal(t, time.Minute, d)
+
+	// values of the wrong type are ignored
+	assert.NoError(t, SetFieldValue(&d, "soon", ""))
+	assert.Equal(t, time.Minute, d)
+
+	type nested struct {
+		Name string `state:"name,required"`
+	}
+	var n nested
+	var missing *MissingFieldError
+	assert.ErrorAs(t, SetFieldValue(&n, map[string]interface{}{}, "parent."), &missing)
+	assert.Equal(t, "parent.name", missing.Key)
+
+	assert.Error(t, SetFieldValue(n, nil, ""))
+}
+
+// TestStateValues ensures documents round-trip through the value maps.
+func TestStateValues(t *testing.T) {
+	b, err := EncodeStateValues(map[string]interface{}{"name": "alice", "age": 30})
+	assert.NoError(t, err)
+	assert.Equal(t, "age: 30\nname: alice\n", string(b))
+
+	values, err := DecodeStateValues(b)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "alice", "age": 30}, values)
+
+	n, ok := IntValue(values["age"])
+	assert.True(t, ok)
+	assert.Equal(t, int64(30), n)
+	_, ok = BoolValue("maybe")
+	assert.False(t, ok)
+}
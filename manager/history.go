@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistorySource identifies where a historical version of the state is kept
+type HistorySource string
+
+const (
+	// History sources
+	HistoryCurrent  HistorySource = "current"
+	HistoryBackup   HistorySource = "backup"
+	HistorySnapshot HistorySource = "snapshot"
+)
+
+// HistoryEntry describes a single persisted version of the state.
+type HistoryEntry struct {
+	Source     HistorySource
+	Label      string
+	Generation int
+	Timestamp  time.Time
+	Size       int64
+	Checksum   string
+
+	path string
+}
+
+// History returns the metadata of the current state, its backups and snapshots
+// ordered from the newest to the oldest. The timestamp is taken from the envelope
+// when present and from the file modification time otherwise.
+func (s *StateManager) History() ([]HistoryEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.history()
+}
+
+// LoadAt reads the newest version of the state persisted at or before the given time.
+func (s *StateManager) LoadAt(at time.Time, data interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries, err := s.history()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Timestamp.After(at) {
+			continue
+		}
+
+		c, err := s.readFile(e.path)
+		if err != nil {
+			return err
+		}
+		return s.decode(c, data)
+	}
+
+	return fmt.Errorf("%w: no state persisted at or before %s", ErrNotFound, at.Format(time.RFC3339))
+}
+
+// history collects the history entries. Caller must hold the read lock.
+func (s *StateManager) history() ([]HistoryEntry, error) {
+	list := make([]HistoryEntry, 0)
+
+	add := func(e HistoryEntry) error {
+		c, err := s.files().ReadFile(e.path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+
+		env, payload, err := unwrapEnvelope(c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.path, err)
+		}
+
+		e.Size = int64(len(c))
+		if env != nil {
+			e.Timestamp = env.Timestamp
+			e.Checksum = env.Checksum
+		} else {
+			info, err := s.files().Stat(e.path)
+			if err != nil {
+				return fmt.Errorf("failed to stat file: %w", err)
+			}
+			e.Timestamp = info.ModTime()
+			e.Checksum = checksum(payload)
+		}
+
+		list = append(list, e)
+		return nil
+	}
+
+	if err := add(HistoryEntry{Source: HistoryCurrent, path: s.FilePath}); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= s.backups; i++ {
+		if err := add(HistoryEntry{Source: HistoryBackup, Generation: i, path: s.backupPath(i)}); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := s.files().ReadDir(s.snapshotDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() || strings.HasSuffix(f.Name(), ".tmp") {
+			continue
+		}
+		e := HistoryEntry{
+			Source: HistorySnapshot,
+			Label:  f.Name(),
+			path:   filepath.Join(s.snapshotDir(), f.Name()),
+		}
+		if err := add(e); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Timestamp.After(list[j].Timestamp)
+	})
+
+	return list, nil
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHistoryAndLoadAt ensures backups and snapshots are listed and readable by time.
+func TestHistoryAndLoadAt(t *testing.T) {
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithEnvelope(),
+		WithBackups(2),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "v1"}))
+	assert.NoError(t, sm.Snapshot("first"))
+	time.Sleep(10 * time.Millisecond)
+	afterFirst := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "v2"}))
+
+	entries, err := sm.History()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, HistoryCurrent, entries[0].Source)
+
+	sources := map[HistorySource]HistoryEntry{}
+	for _, e := range entries {
+		assert.NotEmpty(t, e.Checksum)
+		assert.NotZero(t, e.Size)
+		sources[e.Source] = e
+	}
+	assert.Equal(t, 1, sources[HistoryBackup].Generation)
+	assert.Equal(t, "first", sources[HistorySnapshot].Label)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.LoadAt(afterFirst, loaded))
+	assert.Equal(t, "v1", loaded.Name)
+
+	assert.NoError(t, sm.LoadAt(time.Now(), loaded))
+	assert.Equal(t, "v2", loaded.Name)
+
+	assert.Error(t, sm.LoadAt(afterFirst.Add(-time.Hour), loaded))
+}
+
+// TestHistoryWithoutEnvelope ensures file modification time is used without an envelope.
+func TestHistoryWithoutEnvelope(t *testing.T) {
+	sm := setupBackupStateManager(t, 1)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "old"}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "new"}))
+
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(sm.backupPath(1), old, old))
+
+	entries, err := sm.History()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, HistoryBackup, entries[1].Source)
+	assert.WithinDuration(t, old, entries[1].Timestamp, time.Second)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.LoadAt(old.Add(time.Minute), loaded))
+	assert.Equal(t, "old", loaded.Name)
+}
+
+// TestHistoryEmpty ensures no entries are returned when nothing was saved.
+func TestHistoryEmpty(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	entries, err := sm.History()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
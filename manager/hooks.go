@@ -0,0 +1,53 @@
+package manager
+
+// Hook is invoked with the state struct around persistence events.
+// Hooks run while the manager lock is held, so they must not call back into
+// the manager. Returning an error aborts the operation.
+type Hook func(data interface{}) error
+
+// hooks holds the registered lifecycle hooks.
+type hooks struct {
+	beforeSave []Hook
+	afterSave  []Hook
+	beforeLoad []Hook
+	afterLoad  []Hook
+}
+
+// OnBeforeSave registers a hook invoked before the state is validated and saved.
+// The hook may mutate the state, e.g. to stamp an UpdatedAt field, or veto the save.
+func (s *StateManager) OnBeforeSave(fn Hook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hooks.beforeSave = append(s.hooks.beforeSave, fn)
+}
+
+// OnAfterSave registers a hook invoked after the state was written to the file.
+func (s *StateManager) OnAfterSave(fn Hook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hooks.afterSave = append(s.hooks.afterSave, fn)
+}
+
+// OnBeforeLoad registers a hook invoked with the target before the file is read.
+func (s *StateManager) OnBeforeLoad(fn Hook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hooks.beforeLoad = append(s.hooks.beforeLoad, fn)
+}
+
+// OnAfterLoad registers a hook invoked after the state was decoded and validated.
+func (s *StateManager) OnAfterLoad(fn Hook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hooks.afterLoad = append(s.hooks.afterLoad, fn)
+}
+
+// runHooks invokes the hooks in registration order stopping on the first error.
+func runHooks(list []Hook, data interface{}) error {
+	for _, fn := range list {
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
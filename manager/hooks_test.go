@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLifecycleHooks ensures hooks run in order and can mutate the state.
+func TestLifecycleHooks(t *testing.T) {
+	type Stamped struct {
+		Name      string    `json:"name"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	sm := setupTempStateManager(t, JSON)
+	events := make([]string, 0)
+
+	stamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sm.OnBeforeSave(func(data interface{}) error {
+		events = append(events, "before-save")
+		data.(*Stamped).UpdatedAt = stamp
+		return nil
+	})
+	sm.OnAfterSave(func(_ interface{}) error {
+		events = append(events, "after-save")
+		return nil
+	})
+	sm.OnBeforeLoad(func(_ interface{}) error {
+		events = append(events, "before-load")
+		return nil
+	})
+	sm.OnAfterLoad(func(_ interface{}) error {
+		events = append(events, "after-load")
+		return nil
+	})
+
+	assert.NoError(t, sm.Save(&Stamped{Name: "a"}))
+
+	loaded := &Stamped{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, stamp, loaded.UpdatedAt)
+	assert.Equal(t, []string{"before-save", "after-save", "before-load", "after-load"}, events)
+}
+
+// TestLifecycleHooksVeto ensures hook errors abort the operation.
+func TestLifecycleHooksVeto(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	veto := errors.New("veto")
+
+	sm.OnBeforeSave(func(data interface{}) error {
+		if data.(*TestStruct).Name == "" {
+			return veto
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), veto)
+	assert.False(t, sm.Exists())
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+
+	sm.OnBeforeLoad(func(_ interface{}) error {
+		return veto
+	})
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), veto)
+}
@@ -0,0 +1,391 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overlaySuffix separates the state file name from the sequence number of
+// its overlay records, e.g. .state.overlay.3.
+const overlaySuffix = ".overlay."
+
+// WithIncrementalSaves makes Save write only the top-level keys which changed
+// since the previous save as an overlay record next to the state file, e.g.
+// .state.overlay.1, instead of rewriting the whole file. Load applies the
+// records over the state file in order. Once compactEvery records were
+// written, the next save rewrites the state file in full and removes them.
+// Records left behind by another version of the state file, e.g. one brought
+// back by Restore, are ignored. Set and Patch write records as well.
+// Supported with JSON, YAML and STATE.
+func WithIncrementalSaves(compactEvery int) StateOption {
+	return func(s *StateManager) {
+		if compactEvery < 1 {
+			s.optionErr = errors.New("incremental saves require compacting after at least one record")
+			return
+		}
+		s.compactEvery = compactEvery
+	}
+}
+
+// overlayCache is what the last incremental save wrote, so the next one
+// does not need to read the records again.
+type overlayCache struct {
+	base string
+	seq  int
+	sums map[string]string
+}
+
+// writeIncremental writes the keys of the payload which changed since the
+// previous save as an overlay record, or the whole payload when it is time
+// to compact. Caller must hold the lock.
+func (s *StateManager) writeIncremental(payload []byte) error {
+	st := s.SerializationType
+	if st != JSON && st != YAML && st != STATE {
+		return fmt.Errorf("%w: incremental saves require json, yaml or state, got %s", ErrUnsupportedFormat, st)
+	}
+	if len(s.splits) > 0 || len(s.shards) > 0 {
+		return errors.New("incremental saves do not support split files or shards")
+	}
+
+	parts, err := splitStates(st, payload)
+	if err != nil {
+		return err
+	}
+
+	seqs, err := s.overlayRecords()
+	if err != nil {
+		return err
+	}
+
+	cache := s.overlays
+	if !s.overlayCurrent(seqs) {
+		if cache, err = s.readOverlays(seqs); err != nil {
+			return err
+		}
+	}
+
+	if cache == nil || len(seqs) >= s.compactEvery {
+		return s.compact(payload, parts, seqs)
+	}
+
+	set := make(map[string][]byte)
+	unset := []string{}
+	for name, part := range parts {
+		if cache.sums[name] != partSum(st, part) {
+			set[name] = part
+		}
+	}
+	for name := range cache.sums {
+		if _, ok := parts[name]; !ok {
+			unset = append(unset, name)
+		}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		s.overlays = cache
+		return nil
+	}
+	sort.Strings(unset)
+
+	record, err := s.overlayRecord(cache.base, set, unset)
+	if err != nil {
+		return err
+	}
+
+	b, err := s.seal(record)
+	if err != nil {
+		return err
+	}
+
+	seq := cache.seq + 1
+	if err := s.write(s.overlayPath(seq), b); err != nil {
+		return err
+	}
+
+	cache.seq = seq
+	cache.sums = partSums(s.SerializationType, parts)
+	s.overlays = cache
+	return nil
+}
+
+// compact writes the whole payload to the state file and removes the overlay
+// records. Caller must hold the lock.
+func (s *StateManager) compact(payload []byte, parts map[string][]byte, seqs []int) error {
+	s.overlays = nil
+
+	b, err := s.seal(payload)
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(s.FilePath, b); err != nil {
+		return err
+	}
+
+	if err := s.removeOverlays(seqs); err != nil {
+		return err
+	}
+
+	s.overlays = &overlayCache{
+		base: checksum(b),
+		sums: partSums(s.SerializationType, parts),
+	}
+	return nil
+}
+
+// overlayCurrent reports whether the cache of the last save still describes
+// the state file and its records. The state file is compared by content, as
+// Set, Restore or another process may have rewritten it since.
+func (s *StateManager) overlayCurrent(seqs []int) bool {
+	if s.overlays == nil {
+		return false
+	}
+
+	last := 0
+	if len(seqs) > 0 {
+		last = seqs[len(seqs)-1]
+	}
+	if last != s.overlays.seq {
+		return false
+	}
+
+	c, err := s.readFile(s.FilePath)
+	return err == nil && checksum(c) == s.overlays.base
+}
+
+// readOverlays reads the state file and its records into a cache, or nil when
+// there is no state file or records of another version of it need compacting.
+func (s *StateManager) readOverlays(seqs []int) (*overlayCache, error) {
+	c, err := s.readFile(s.FilePath)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parts, applied, err := s.applyOverlays(c, seqs)
+	if err != nil {
+		return nil, err
+	}
+	if applied < len(seqs) {
+		return nil, nil
+	}
+
+	cache := &overlayCache{
+		base: checksum(c),
+		sums: partSums(s.SerializationType, parts),
+	}
+	if len(seqs) > 0 {
+		cache.seq = seqs[len(seqs)-1]
+	}
+	return cache, nil
+}
+
+// readIncremental returns the payload of the state file content with the
+// overlay records applied. Caller must hold the read lock.
+func (s *StateManager) readIncremental(c []byte) ([]byte, SerializationType, error) {
+	seqs, err := s.overlayRecords()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(seqs) == 0 {
+		return s.open(c)
+	}
+
+	parts, _, err := s.applyOverlays(c, seqs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	payload, err := joinStates(s.SerializationType, parts)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, s.SerializationType, nil
+}
+
+// decodeIncremental applies the overlay records to the content of the state
+// file and decodes the result into the given struct. Caller must hold the read lock.
+func (s *StateManager) decodeIncremental(c []byte, data interface{}) error {
+	seqs, err := s.overlayRecords()
+	if err != nil {
+		return err
+	}
+
+	if len(seqs) == 0 {
+		return s.decodeSplit(c, data)
+	}
+
+	parts, _, err := s.applyOverlays(c, seqs)
+	if err != nil {
+		return err
+	}
+
+	payload, err := joinStates(s.SerializationType, parts)
+	if err != nil {
+		return err
+	}
+
+	return s.decodePayload(s.SerializationType, payload, data)
+}
+
+// applyOverlays returns the top-level keys of the state file content with the
+// records of the sequence numbers applied in order and the number of records
+// which apply to this content.
+func (s *StateManager) applyOverlays(c []byte, seqs []int) (map[string][]byte, int, error) {
+	payload, st, err := s.open(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	parts, err := splitStates(st, payload)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	base := checksum(c)
+	applied := 0
+	for _, seq := range seqs {
+		path := s.overlayPath(seq)
+		rc, err := s.readFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		record, st, err := s.open(rc)
+		if err != nil {
+			return nil, 0, fmt.Errorf("overlay record %d: %w", seq, err)
+		}
+
+		ok, err := applyOverlay(st, parts, base, record)
+		if err != nil {
+			return nil, 0, fmt.Errorf("overlay record %d: %w", seq, err)
+		}
+		if ok {
+			applied++
+		}
+	}
+	return parts, applied, nil
+}
+
+// overlayRecord encodes the changed and removed keys as a record applying to
+// the state file content with the base checksum.
+func (s *StateManager) overlayRecord(base string, set map[string][]byte, unset []string) ([]byte, error) {
+	st := s.SerializationType
+
+	setDoc, err := joinStates(st, set)
+	if err != nil {
+		return nil, err
+	}
+	unsetDoc, err := marshalDocument(st, unset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+	baseDoc, err := marshalDocument(st, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	return joinStates(st, map[string][]byte{"base": baseDoc, "set": setDoc, "unset": unsetDoc})
+}
+
+// applyOverlay applies the record to the parts and reports whether it applies
+// to the state file content with the base checksum.
+func applyOverlay(st SerializationType, parts map[string][]byte, base string, record []byte) (bool, error) {
+	rec, err := splitStates(st, record)
+	if err != nil {
+		return false, err
+	}
+
+	var recordBase string
+	if err := yaml.Unmarshal(rec["base"], &recordBase); err != nil || recordBase != base {
+		return false, nil
+	}
+
+	set, err := splitStates(st, rec["set"])
+	if err != nil {
+		return false, err
+	}
+	var unset []string
+	if err := yaml.Unmarshal(rec["unset"], &unset); err != nil {
+		return false, fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	for name, part := range set {
+		parts[name] = part
+	}
+	for _, name := range unset {
+		delete(parts, name)
+	}
+	return true, nil
+}
+
+// partSums returns the checksums of the encoded top-level keys.
+func partSums(st SerializationType, parts map[string][]byte) map[string]string {
+	sums := make(map[string]string, len(parts))
+	for name, part := range parts {
+		sums[name] = partSum(st, part)
+	}
+	return sums
+}
+
+// partSum returns the checksum of the encoded top-level key regardless of
+// the indentation of the document it was split off.
+func partSum(st SerializationType, part []byte) string {
+	if st == JSON {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, part); err == nil {
+			return checksum(buf.Bytes())
+		}
+	}
+	return checksum(part)
+}
+
+// overlayPath returns the path of the overlay record with the sequence number.
+func (s *StateManager) overlayPath(seq int) string {
+	return s.FilePath + overlaySuffix + strconv.Itoa(seq)
+}
+
+// overlayRecords returns the sequence numbers of the overlay records in order.
+func (s *StateManager) overlayRecords() ([]int, error) {
+	entries, err := s.files().ReadDir(filepath.Dir(s.FilePath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	prefix := filepath.Base(s.FilePath) + overlaySuffix
+	var seqs []int
+	for _, e := range entries {
+		n, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if seq, err := strconv.Atoi(n); err == nil && seq > 0 {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// removeOverlays removes the overlay records with the sequence numbers.
+func (s *StateManager) removeOverlays(seqs []int) error {
+	for _, seq := range seqs {
+		if err := s.files().Remove(s.overlayPath(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove overlay record: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type incrementalState struct {
+	Name  string            `json:"name" yaml:"name" state:"name"`
+	Items map[string]int    `json:"items" yaml:"items" state:"items"`
+	Note  string            `json:"note,omitempty" yaml:"note,omitempty" state:"note,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty" yaml:"tags,omitempty" state:"tags,omitempty"`
+}
+
+// TestWithIncrementalSaves ensures saves write overlay records of the changed keys and compact.
+func TestWithIncrementalSaves(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state")
+			sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithIncrementalSaves(2))
+			assert.NoError(t, err)
+
+			data := &incrementalState{Name: "a", Items: map[string]int{"x": 1}, Note: "note"}
+			assert.NoError(t, sm.Save(data))
+			full, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NoFileExists(t, path+".overlay.1")
+
+			data.Name = "b"
+			data.Note = ""
+			assert.NoError(t, sm.Save(data))
+			record, err := os.ReadFile(path + ".overlay.1")
+			assert.NoError(t, err)
+			assert.Contains(t, string(record), "note")
+			assert.NotContains(t, string(record), "items")
+
+			// unchanged content is not recorded
+			assert.NoError(t, sm.Save(data))
+			assert.NoFileExists(t, path+".overlay.2")
+
+			data.Items["y"] = 2
+			assert.NoError(t, sm.Save(data))
+			assert.FileExists(t, path+".overlay.2")
+
+			c, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, full, c)
+
+			loaded := &incrementalState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, data, loaded)
+
+			// a fresh manager continues from the records on disk
+			other, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithIncrementalSaves(2))
+			assert.NoError(t, err)
+			data.Name = "c"
+			assert.NoError(t, other.Save(data))
+			assert.NoFileExists(t, path+".overlay.1")
+			assert.NoFileExists(t, path+".overlay.2")
+
+			loaded = &incrementalState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, data, loaded)
+
+			assert.NoError(t, sm.Delete())
+			assert.NoFileExists(t, path)
+		})
+	}
+}
+
+// TestWithIncrementalSavesStale ensures records of another version of the state file are ignored.
+func TestWithIncrementalSavesStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithIncrementalSaves(5))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&incrementalState{Name: "a"}))
+	assert.NoError(t, sm.Save(&incrementalState{Name: "b"}))
+	assert.FileExists(t, path+".overlay.1")
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"name": "edited"}`), 0600))
+
+	loaded := &incrementalState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "edited", loaded.Name)
+
+	assert.NoError(t, sm.Save(&incrementalState{Name: "c"}))
+	assert.NoFileExists(t, path+".overlay.1")
+
+	loaded = &incrementalState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "c", loaded.Name)
+}
+
+// TestWithIncrementalSavesSet ensures Set and Patch keep the changes of incremental saves and the other way around.
+func TestWithIncrementalSavesSet(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state")
+			sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithIncrementalSaves(5))
+			assert.NoError(t, err)
+
+			assert.NoError(t, sm.Save(&TestStruct{Name: "a", Age: 1}))
+			assert.NoError(t, sm.Save(&TestStruct{Name: "a", Age: 2}))
+			assert.NoError(t, sm.Set("age", 7))
+
+			loaded := &TestStruct{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, &TestStruct{Name: "a", Age: 7}, loaded)
+
+			assert.NoError(t, sm.Save(&TestStruct{Name: "b", Age: 3}))
+			loaded = &TestStruct{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, &TestStruct{Name: "b", Age: 3}, loaded)
+
+			assert.NoError(t, sm.Patch([]byte(`{"name": "c"}`)))
+			assert.NoError(t, sm.Save(&TestStruct{Name: "c", Age: 4}))
+			loaded = &TestStruct{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, &TestStruct{Name: "c", Age: 4}, loaded)
+		})
+	}
+}
+
+// TestWithIncrementalSavesRewritten ensures a state file rewritten with the same size is not mistaken for the cached one.
+func TestWithIncrementalSavesRewritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithIncrementalSaves(5))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a", Age: 1}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a", Age: 2}))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, bytes.Replace(c, []byte(`"a"`), []byte(`"z"`), 1), 0600))
+	assert.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "b", Age: 3}))
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, &TestStruct{Name: "b", Age: 3}, loaded)
+}
+
+// TestWithIncrementalSavesInvalid ensures unsupported settings are rejected.
+func TestWithIncrementalSavesInvalid(t *testing.T) {
+	_, err := NewStateManager(WithIncrementalSaves(0))
+	assert.Error(t, err)
+
+	sm, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state")), WithSerializationType(BIN), WithIncrementalSaves(2))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Save(&incrementalState{Name: "a"}), ErrUnsupportedFormat)
+}
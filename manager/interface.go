@@ -0,0 +1,22 @@
+package manager
+
+// Saver persists state.
+type Saver interface {
+	Save(data interface{}) error
+}
+
+// Loader reads persisted state.
+type Loader interface {
+	Load(data interface{}) error
+}
+
+// Manager is the subset of StateManager most code depends on, so it can be
+// replaced in tests, e.g. by statetest.Fake.
+type Manager interface {
+	Saver
+	Loader
+	Exists() bool
+	Delete() error
+}
+
+var _ Manager = (*StateManager)(nil)
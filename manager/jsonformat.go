@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultJSONIndent is the indent per level of the JSON state unless configured otherwise
+const defaultJSONIndent = "  "
+
+// WithJSONIndent indents the JSON state by the given string of spaces and
+// tabs per level instead of two spaces.
+func WithJSONIndent(indent string) StateOption {
+	return func(s *StateManager) {
+		if strings.Trim(indent, " \t") != "" {
+			s.optionErr = errors.New("JSON indent must only contain spaces and tabs")
+			return
+		}
+		s.jsonIndent = &indent
+	}
+}
+
+// WithCompactJSON writes the JSON state on a single line without any
+// insignificant white space, e.g. to keep payloads sent to stores small.
+func WithCompactJSON() StateOption {
+	return func(s *StateManager) {
+		compact := ""
+		s.jsonIndent = &compact
+		s.compactJSON = true
+	}
+}
+
+// formatJSON lays out the encoded JSON payload as configured.
+func (s *StateManager) formatJSON(payload []byte) ([]byte, error) {
+	if s.SerializationType != JSON || s.jsonIndent == nil {
+		return payload, nil
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if s.compactJSON {
+		err = json.Compact(&buf, payload)
+	} else {
+		err = json.Indent(&buf, payload, "", *s.jsonIndent)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonLayout is the white space of JSON written piece by piece, e.g. when streaming.
+type jsonLayout struct {
+	indent  string
+	compact bool
+}
+
+// jsonLayout returns the configured layout of the JSON state.
+func (s *StateManager) jsonLayout() jsonLayout {
+	if s.jsonIndent == nil {
+		return jsonLayout{indent: defaultJSONIndent}
+	}
+	return jsonLayout{indent: *s.jsonIndent, compact: s.compactJSON}
+}
+
+// newline returns the line break followed by the indentation, nothing when compact.
+func (l jsonLayout) newline(indent string) string {
+	if l.compact {
+		return ""
+	}
+	return "\n" + indent
+}
+
+// colon returns the separator of object keys and values.
+func (l jsonLayout) colon() string {
+	if l.compact {
+		return ":"
+	}
+	return ": "
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithCompactJSON ensures the JSON state is written on a single line and loads back.
+func TestWithCompactJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithCompactJSON())
+	assert.NoError(t, err)
+
+	data := &TestStruct{Name: "alice", Age: 30}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"alice","age":30,"temp":0,"flag":false}`, string(c))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, data, loaded)
+}
+
+// TestWithJSONIndent ensures the JSON state is indented as configured.
+func TestWithJSONIndent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithJSONIndent("\t"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n\t\"name\": \"alice\",\n\t\"age\": 0,\n\t\"temp\": 0,\n\t\"flag\": false\n}", string(c))
+
+	_, err = NewStateManager(WithJSONIndent("--"))
+	assert.Error(t, err)
+}
+
+// TestWithCompactJSONKeyValue ensures Set and Patch keep the configured layout.
+func TestWithCompactJSONKeyValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithCompactJSON())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	assert.NoError(t, sm.Set("age", 30))
+	assert.NoError(t, sm.Patch([]byte(`{"flag": true}`)))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"age":30,"flag":true,"name":"alice","temp":0}`, string(c))
+
+	indented, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithJSONIndent("\t"))
+	assert.NoError(t, err)
+	assert.NoError(t, indented.Set("age", 31))
+
+	c, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n\t\"age\": 31,\n\t\"flag\": true,\n\t\"name\": \"alice\",\n\t\"temp\": 0\n}", string(c))
+}
+
+// TestWithJSONLayoutStreaming ensures streamed JSON is written in the configured layout.
+func TestWithJSONLayoutStreaming(t *testing.T) {
+	for _, option := range []StateOption{WithCompactJSON(), WithJSONIndent("\t")} {
+		path := filepath.Join(t.TempDir(), "state.json")
+		regular, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), option)
+		assert.NoError(t, err)
+		streamed, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), option, WithStreaming())
+		assert.NoError(t, err)
+
+		state := newStreamState(3)
+		assert.NoError(t, regular.Save(state))
+		expected, err := os.ReadFile(path)
+		assert.NoError(t, err)
+
+		assert.NoError(t, streamed.Save(state))
+		c, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(c))
+
+		loaded := &streamState{}
+		assert.NoError(t, streamed.Load(loaded))
+		assert.Equal(t, state, loaded)
+	}
+}
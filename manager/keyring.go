@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	// keyringGet and keyringSet access the OS keychain, replaced in tests
+	keyringGet = osKeyringGet
+	keyringSet = osKeyringSet
+)
+
+// WithKeyFromKeyring encrypts the state with the key stored in the OS keychain
+// (macOS Keychain, Windows Credential Manager or the Secret Service on Linux)
+// under the given service and account, see StoreKeyInKeyring.
+func WithKeyFromKeyring(service, account string) StateOption {
+	return func(s *StateManager) {
+		secret, err := keyringGet(service, account)
+		if err != nil {
+			s.optionErr = fmt.Errorf("failed to read key from keyring: %w", err)
+			return
+		}
+
+		key, err := ParseKey(secret)
+		if err != nil {
+			s.optionErr = fmt.Errorf("invalid key in keyring: %w", err)
+			return
+		}
+
+		WithEncryptionKey(key)(s)
+	}
+}
+
+// StoreKeyInKeyring saves the hex encoded encryption key in the OS keychain
+// under the given service and account, replacing the existing one.
+func StoreKeyInKeyring(service, account string, key []byte) error {
+	if len(key) != EncryptionKeySize {
+		return fmt.Errorf("invalid encryption key size %d, expected %d bytes", len(key), EncryptionKeySize)
+	}
+
+	if err := keyringSet(service, account, hex.EncodeToString(key)); err != nil {
+		return fmt.Errorf("failed to store key in keyring: %w", err)
+	}
+
+	return nil
+}
+
+// ParseKey decodes a hex or base64 encoded encryption key.
+func ParseKey(s string) ([]byte, error) {
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return nil, errors.New("encryption key must be hex or base64 encoded")
+}
@@ -0,0 +1,38 @@
+//go:build darwin
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// osKeyringGet reads the generic password from the macOS Keychain.
+func osKeyringGet(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// 44 is returned when the item could not be found
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", fmt.Errorf("%w: keychain item %s/%s", ErrNotFound, service, account)
+		}
+		return "", fmt.Errorf("failed to run security: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// osKeyringSet writes the generic password to the macOS Keychain. The command
+// is passed on stdin so the secret does not show up in the process list.
+func osKeyringSet(service, account, secret string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n", service, account, secret))
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run security: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package manager
+
+import (
+	"errors"
+)
+
+// errNoKeyring is returned on platforms without a supported keychain.
+var errNoKeyring = errors.New("keyring is not supported on this platform")
+
+// osKeyringGet is not supported on this platform.
+func osKeyringGet(_, _ string) (string, error) {
+	return "", errNoKeyring
+}
+
+// osKeyringSet is not supported on this platform.
+func osKeyringSet(_, _, _ string) error {
+	return errNoKeyring
+}
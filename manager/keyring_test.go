@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeyring replaces the OS keychain for the duration of the test.
+func fakeKeyring(t *testing.T) map[string]string {
+	t.Helper()
+
+	secrets := make(map[string]string)
+	get, set := keyringGet, keyringSet
+	t.Cleanup(func() {
+		keyringGet, keyringSet = get, set
+	})
+
+	keyringGet = func(service, account string) (string, error) {
+		s, ok := secrets[service+"/"+account]
+		if !ok {
+			return "", fmt.Errorf("%w: %s/%s", ErrNotFound, service, account)
+		}
+		return s, nil
+	}
+	keyringSet = func(service, account, secret string) error {
+		secrets[service+"/"+account] = secret
+		return nil
+	}
+
+	return secrets
+}
+
+// TestWithKeyFromKeyring ensures the key is read from the keychain.
+func TestWithKeyFromKeyring(t *testing.T) {
+	secrets := fakeKeyring(t)
+	path := setupTempStateManager(t, JSON).FilePath
+
+	_, err := NewStateManager(WithFilePath(path), WithKeyFromKeyring("app", "user"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Error(t, StoreKeyInKeyring("app", "user", []byte("short")))
+	assert.NoError(t, StoreKeyInKeyring("app", "user", testKey(3)))
+
+	sm, err := NewStateManager(WithFilePath(path), WithKeyFromKeyring("app", "user"))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+
+	direct, err := NewStateManager(WithFilePath(path), WithEncryptionKey(testKey(3)))
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, direct.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+
+	secrets["app/user"] = "not a key"
+	_, err = NewStateManager(WithFilePath(path), WithKeyFromKeyring("app", "user"))
+	assert.Error(t, err)
+}
+
+// TestParseKey ensures hex and base64 keys are decoded.
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey("0102")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, key)
+
+	key, err = ParseKey("AQI=")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, key)
+
+	_, err = ParseKey("!")
+	assert.Error(t, err)
+}
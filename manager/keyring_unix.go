@@ -0,0 +1,37 @@
+//go:build unix && !darwin
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// osKeyringGet looks the secret up in the Secret Service using secret-tool.
+func osKeyringGet(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) == 0 {
+			return "", fmt.Errorf("%w: secret %s/%s", ErrNotFound, service, account)
+		}
+		return "", fmt.Errorf("failed to run secret-tool: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// osKeyringSet stores the secret in the Secret Service using secret-tool,
+// passing it on stdin so it does not show up in the process list.
+func osKeyringSet(service, account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run secret-tool: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+//go:build windows
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+// credential mirrors the CREDENTIALW structure of the Credential Manager.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialTarget returns the name of the generic credential.
+func credentialTarget(service, account string) (*uint16, error) {
+	return windows.UTF16PtrFromString(service + ":" + account)
+}
+
+// osKeyringGet reads the generic credential from the Windows Credential Manager.
+func osKeyringGet(service, account string) (string, error) {
+	target, err := credentialTarget(service, account)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return "", fmt.Errorf("%w: credential %s:%s", ErrNotFound, service, account)
+		}
+		return "", fmt.Errorf("failed to read credential: %w", err)
+	}
+	defer func() {
+		_, _, _ = procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	}()
+
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+// osKeyringSet writes the generic credential to the Windows Credential Manager.
+func osKeyringSet(service, account, secret string) error {
+	target, err := credentialTarget(service, account)
+	if err != nil {
+		return err
+	}
+
+	user, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return fmt.Errorf("failed to write credential: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Get decodes the value persisted under the given top-level key into out.
+// The key is the name as it appears in the file (e.g. the json, yaml or state tag).
+// Supported for JSON, YAML and STATE serialization types.
+func (s *StateManager) Get(key string, out interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	values, err := s.loadValues()
+	if err != nil {
+		return err
+	}
+
+	v, ok := values[key]
+	if !ok {
+		return fmt.Errorf("%w: key %q", ErrNotFound, key)
+	}
+
+	return s.convertValue(v, out)
+}
+
+// Values returns all persisted top-level keys as generic values.
+// Supported for JSON, YAML and STATE serialization types.
+func (s *StateManager) Values() (map[string]interface{}, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return s.loadValues()
+}
+
+// Set persists val under the given top-level key leaving all other keys untouched.
+// Supported for JSON, YAML and STATE serialization types.
+func (s *StateManager) Set(key string, val interface{}) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{})
+	if s.exists(s.FilePath) {
+		if values, err = s.loadValues(); err != nil {
+			return err
+		}
+	}
+
+	var v interface{}
+	if err := s.convertValue(val, &v); err != nil {
+		return err
+	}
+	values[key] = v
+
+	return s.writeValues(values)
+}
+
+// loadValues reads the persisted state as a map of top-level keys. Caller must hold the read lock.
+func (s *StateManager) loadValues() (map[string]interface{}, error) {
+	payload, st, err := s.readValues()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	switch st {
+	case JSON:
+		err = json.Unmarshal(payload, &values)
+	case YAML, STATE:
+		err = yaml.Unmarshal(payload, &values)
+	case BSON:
+		err = bsonUnmarshal(payload, &values)
+	default:
+		return nil, fmt.Errorf("%w: key access not supported for %s serialization", ErrUnsupportedFormat, st)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	return values, nil
+}
+
+// readValues reads the persisted payload like Load does, from the files of
+// the directory in directory mode. Caller must hold the read lock.
+func (s *StateManager) readValues() ([]byte, SerializationType, error) {
+	if s.directory {
+		payload, err := s.readDirectory()
+		return payload, s.SerializationType, err
+	}
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if s.compactEvery > 0 {
+		return s.readIncremental(c)
+	}
+	return s.open(c)
+}
+
+// writeValues persists the map of top-level keys like Save does, as an
+// overlay record with incremental saves and a file per key in directory
+// mode. Caller must hold the lock.
+func (s *StateManager) writeValues(values map[string]interface{}) error {
+	payload, err := s.marshalValues(values)
+	if err != nil {
+		return err
+	}
+
+	// the state no longer matches the last save
+	s.lastChecksum = ""
+
+	switch {
+	case s.directory:
+		return s.writeDirectory(payload)
+	case s.compactEvery > 0:
+		return s.writeIncremental(payload)
+	}
+
+	b, err := s.seal(payload)
+	if err != nil {
+		return err
+	}
+
+	return s.write(s.FilePath, b)
+}
+
+// marshalValues encodes the map of top-level keys in the manager format.
+func (s *StateManager) marshalValues(values map[string]interface{}) ([]byte, error) {
+	switch s.SerializationType {
+	case JSON:
+		b, err := json.MarshalIndent(values, "", defaultJSONIndent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
+		return s.formatJSON(b)
+	case YAML, STATE:
+		return yaml.Marshal(values)
+	case BSON:
+		return bsonMarshal(values)
+	default:
+		return nil, fmt.Errorf("%w: key access not supported for %s serialization", ErrUnsupportedFormat, s.SerializationType)
+	}
+}
+
+// convertValue copies the generic value into out by round-tripping it through the manager format.
+func (s *StateManager) convertValue(v interface{}, out interface{}) error {
+	switch s.SerializationType {
+	case JSON:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode value: %w", err)
+		}
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+	case YAML, STATE:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode value: %w", err)
+		}
+		if err := yaml.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+	case BSON:
+		// BSON documents hold no bare values, wrap them in one
+		b, err := bsonMarshal(map[string]interface{}{"v": v})
+		if err != nil {
+			return fmt.Errorf("failed to encode value: %w", err)
+		}
+		if err := bsonUnmarshalKey(b, "v", out); err != nil {
+			return fmt.Errorf("failed to decode value: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: key access not supported for %s serialization", ErrUnsupportedFormat, s.SerializationType)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetAndSet ensures individual keys can be read and written in text formats.
+func TestGetAndSet(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupTempStateManager(t, st)
+			assert.NoError(t, sm.Save(&TestStruct{"Alice", 30, 98.6, true}))
+
+			var name string
+			assert.NoError(t, sm.Get("name", &name))
+			assert.Equal(t, "Alice", name)
+
+			assert.NoError(t, sm.Set("age", 31))
+
+			var age int
+			assert.NoError(t, sm.Get("age", &age))
+			assert.Equal(t, 31, age)
+
+			loaded := &TestStruct{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, &TestStruct{"Alice", 31, 98.6, true}, loaded)
+
+			assert.Error(t, sm.Get("missing", &name))
+		})
+	}
+}
+
+// TestSetWithoutFile ensures Set creates the state when nothing was saved yet.
+func TestSetWithoutFile(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	assert.NoError(t, sm.Set("tags", []string{"a", "b"}))
+
+	var tags []string
+	assert.NoError(t, sm.Get("tags", &tags))
+	assert.Equal(t, []string{"a", "b"}, tags)
+}
+
+// TestGetAndSetBinary ensures key access reports that binary state is not supported.
+func TestGetAndSetBinary(t *testing.T) {
+	sm := setupTempStateManager(t, BIN)
+	assert.Error(t, sm.Set("name", "x"))
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "x"}))
+	var name string
+	assert.Error(t, sm.Get("name", &name))
+	assert.Error(t, sm.Set("name", "y"))
+}
+
+// TestValues ensures all top-level keys are returned.
+func TestValues(t *testing.T) {
+	sm := setupTempStateManager(t, YAML)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice", Age: 30}))
+
+	values, err := sm.Values()
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice", values["name"])
+	assert.Equal(t, 30, values["age"])
+}
@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// KDFKeyWrap is the key derivation recorded in the envelope of state encrypted
+// with a data key wrapped by a KeyWrapper
+const KDFKeyWrap = "keywrap"
+
+// KeyWrapper encrypts and decrypts the data key of the state, typically with
+// a key held by a KMS (AWS KMS, GCP Cloud KMS, Azure Key Vault) that never
+// leaves it. See KeyWrapperFuncs to adapt a KMS client.
+type KeyWrapper interface {
+	// WrapKey encrypts the data key.
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey decrypts the data key wrapped by WrapKey.
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// KeyWrapperFuncs adapts a pair of functions to the KeyWrapper interface,
+// e.g. the Encrypt and Decrypt calls of a KMS client:
+//
+//	manager.KeyWrapperFuncs{
+//		Wrap: func(key []byte) ([]byte, error) {
+//			out, err := client.Encrypt(ctx, &kms.EncryptInput{KeyId: &keyID, Plaintext: key})
+//			if err != nil {
+//				return nil, err
+//			}
+//			return out.CiphertextBlob, nil
+//		},
+//		Unwrap: ...,
+//	}
+type KeyWrapperFuncs struct {
+	Wrap   func(key []byte) ([]byte, error)
+	Unwrap func(wrapped []byte) ([]byte, error)
+}
+
+// WrapKey calls Wrap.
+func (f KeyWrapperFuncs) WrapKey(key []byte) ([]byte, error) {
+	return f.Wrap(key)
+}
+
+// UnwrapKey calls Unwrap.
+func (f KeyWrapperFuncs) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return f.Unwrap(wrapped)
+}
+
+// aesKeyWrapper wraps data keys locally with AES-256-GCM.
+type aesKeyWrapper struct {
+	kek []byte
+}
+
+// NewAESKeyWrapper returns a KeyWrapper encrypting the data keys with the
+// 32 byte key encryption key using AES-256-GCM, for keys managed outside a KMS.
+func NewAESKeyWrapper(kek []byte) (KeyWrapper, error) {
+	if len(kek) != EncryptionKeySize {
+		return nil, fmt.Errorf("invalid key encryption key size %d, expected %d bytes", len(kek), EncryptionKeySize)
+	}
+	return &aesKeyWrapper{kek: append([]byte(nil), kek...)}, nil
+}
+
+// WrapKey encrypts the data key with the key encryption key.
+func (w *aesKeyWrapper) WrapKey(key []byte) ([]byte, error) {
+	gcm, err := newGCM(w.kek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, key, nil), nil
+}
+
+// UnwrapKey decrypts the data key with the key encryption key.
+func (w *aesKeyWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	gcm, err := newGCM(w.kek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+
+	return gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], nil)
+}
+
+// dataKey caches the data key of the state and its wrapped form, so the
+// KeyWrapper is only called when the key changes.
+type dataKey struct {
+	wrapper KeyWrapper
+	mutex   sync.Mutex
+	key     []byte
+	wrapped []byte
+}
+
+// WithKeyWrapper encrypts the state with a random data key that is wrapped by
+// the KeyWrapper and stored in the envelope (envelope encryption). The data
+// key is reused until the manager is recreated. It takes precedence over
+// WithEncryptionKey when saving.
+func WithKeyWrapper(w KeyWrapper) StateOption {
+	return func(s *StateManager) {
+		if w == nil {
+			s.optionErr = errors.New("key wrapper must not be nil")
+			return
+		}
+		s.dataKey = &dataKey{wrapper: w}
+	}
+}
+
+// seal returns the data key and its wrapped form, generating and wrapping a new key when none is cached.
+func (d *dataKey) seal() ([]byte, []byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.key != nil {
+		return d.key, d.wrapped, nil
+	}
+
+	key := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := d.wrapper.WrapKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	d.key, d.wrapped = key, wrapped
+	return key, wrapped, nil
+}
+
+// open returns the data key of the wrapped key, unwrapping it unless cached.
+func (d *dataKey) open(wrapped []byte) ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.key != nil && bytes.Equal(d.wrapped, wrapped) {
+		return d.key, nil
+	}
+
+	key, err := d.wrapper.UnwrapKey(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to unwrap data key: %v", ErrDecryption, err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("%w: invalid data key size %d", ErrDecryption, len(key))
+	}
+
+	d.key, d.wrapped = key, append([]byte(nil), wrapped...)
+	return key, nil
+}
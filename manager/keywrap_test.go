@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingWrapper counts the calls to the wrapped KeyWrapper.
+type countingWrapper struct {
+	KeyWrapper
+	wraps, unwraps int
+}
+
+func (c *countingWrapper) WrapKey(key []byte) ([]byte, error) {
+	c.wraps++
+	return c.KeyWrapper.WrapKey(key)
+}
+
+func (c *countingWrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	c.unwraps++
+	return c.KeyWrapper.UnwrapKey(wrapped)
+}
+
+// TestWithKeyWrapper ensures the data key is wrapped in the envelope and reused.
+func TestWithKeyWrapper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	_, err := NewAESKeyWrapper([]byte("short"))
+	assert.Error(t, err)
+	_, err = NewStateManager(WithFilePath(path), WithKeyWrapper(nil))
+	assert.Error(t, err)
+
+	kek, err := NewAESKeyWrapper(testKey(1))
+	assert.NoError(t, err)
+	w := &countingWrapper{KeyWrapper: kek}
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithKeyWrapper(w))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Bob"}))
+	assert.Equal(t, 1, w.wraps)
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(c), "Bob")
+
+	env, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, EncryptionAES256GCM, env.Encryption)
+	assert.Equal(t, KDFKeyWrap, env.KDF)
+	assert.NotEmpty(t, env.WrappedKey)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "Bob", loaded.Name)
+	assert.Equal(t, 0, w.unwraps)
+
+	other := &countingWrapper{KeyWrapper: kek}
+	reader, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithKeyWrapper(other))
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Load(loaded))
+	assert.NoError(t, reader.Load(loaded))
+	assert.Equal(t, 1, other.unwraps)
+
+	wrongKEK, err := NewAESKeyWrapper(testKey(2))
+	assert.NoError(t, err)
+	wrong, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithKeyWrapper(wrongKEK))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, wrong.Load(&TestStruct{}), ErrDecryption)
+
+	key, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithEncryptionKey(testKey(1)))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, key.Load(&TestStruct{}), ErrDecryption)
+}
+
+// TestKeyWrapperFuncs ensures wrapping failures are reported.
+func TestKeyWrapperFuncs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+	failing := KeyWrapperFuncs{
+		Wrap:   func([]byte) ([]byte, error) { return nil, errors.New("kms unavailable") },
+		Unwrap: func([]byte) ([]byte, error) { return nil, errors.New("kms unavailable") },
+	}
+
+	sm, err := NewStateManager(WithFilePath(path), WithKeyWrapper(failing))
+	assert.NoError(t, err)
+	assert.ErrorContains(t, sm.Save(&TestStruct{Name: "a"}), "kms unavailable")
+}
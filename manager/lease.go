@@ -0,0 +1,183 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Leaser is implemented by shared stores able to grant time-limited leases
+// on a key to a single owner, used by LockState. Acquire and Renew fail with
+// an error matching ErrLocked while another owner holds an unexpired lease.
+type Leaser interface {
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) error
+	Renew(ctx context.Context, key, owner string, ttl time.Duration) error
+	Release(ctx context.Context, key, owner string) error
+}
+
+// lease is a lease on the state held by the manager.
+type lease struct {
+	leaser Leaser
+	key    string
+	owner  string
+	cancel context.CancelCauseFunc
+	ctx    context.Context
+	done   chan struct{}
+}
+
+// LockState acquires a lease on the state in the store so that only one
+// instance mutates it at a time, blocking until it is granted or ctx is done.
+// The lease is renewed every third of the ttl until Unlock. The returned
+// context is canceled once the lease is released or lost, with ErrLocked as
+// its cause when a renewal failed. Once the lease is lost, Save, Update and
+// the other writes fail with ErrLocked until Unlock. Requires a store
+// implementing Leaser.
+func (s *StateManager) LockState(ctx context.Context, ttl time.Duration) (context.Context, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		return nil, errors.New("lease ttl must be positive")
+	}
+
+	f, ok := s.files().(*storeFS)
+	if !ok {
+		return nil, errors.New("leases require a store, use WithStore")
+	}
+	leaser, ok := f.store.(Leaser)
+	if !ok {
+		return nil, fmt.Errorf("store %T does not support leases", f.store)
+	}
+
+	l := &lease{leaser: leaser, key: f.key(s.FilePath), owner: leaseOwner(), done: make(chan struct{})}
+	l.ctx, l.cancel = context.WithCancelCause(context.Background())
+
+	s.leaseMutex.Lock()
+	if s.lease != nil {
+		s.leaseMutex.Unlock()
+		return nil, errors.New("state is already locked by this manager")
+	}
+	s.lease = l
+	s.leaseMutex.Unlock()
+
+	if err := s.acquireLease(ctx, l, ttl); err != nil {
+		s.leaseMutex.Lock()
+		if s.lease == l {
+			s.lease = nil
+		}
+		s.leaseMutex.Unlock()
+
+		l.cancel(err)
+		close(l.done)
+		return nil, err
+	}
+
+	go s.renew(l, ttl)
+	return l.ctx, nil
+}
+
+// acquireLease waits for the lease to be granted.
+func (s *StateManager) acquireLease(ctx context.Context, l *lease, ttl time.Duration) error {
+	for {
+		err := l.leaser.Acquire(ctx, l.key, l.owner, ttl)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrLocked) {
+			return fmt.Errorf("failed to acquire lease: %w", err)
+		}
+
+		s.debug("waiting for lease", "ttl", ttl)
+		if !s.sleep(ctx, min(ttl/4, time.Second)) {
+			return fmt.Errorf("failed to acquire lease: %w: %w", ErrLocked, context.Cause(ctx))
+		}
+	}
+
+	// Unlock was called while waiting
+	if l.ctx.Err() != nil {
+		_ = l.leaser.Release(context.Background(), l.key, l.owner)
+		return errors.New("state was unlocked while acquiring the lease")
+	}
+	return nil
+}
+
+// Unlock releases the lease acquired with LockState. It fails with an error
+// matching ErrLocked when the lease was lost before.
+func (s *StateManager) Unlock() error {
+	s.leaseMutex.Lock()
+	l := s.lease
+	s.lease = nil
+	s.leaseMutex.Unlock()
+
+	if l == nil {
+		return errors.New("state is not locked by this manager")
+	}
+
+	l.cancel(nil)
+	<-l.done
+
+	if cause := context.Cause(l.ctx); !errors.Is(cause, context.Canceled) {
+		return fmt.Errorf("lease was lost: %w", cause)
+	}
+	if err := l.leaser.Release(context.Background(), l.key, l.owner); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
+// leaseErr returns an error matching ErrLocked once the lease acquired with
+// LockState was lost, so writes never happen without holding it.
+func (s *StateManager) leaseErr() error {
+	s.leaseMutex.Lock()
+	l := s.lease
+	s.leaseMutex.Unlock()
+
+	if l == nil {
+		return nil
+	}
+	if cause := context.Cause(l.ctx); cause != nil {
+		return fmt.Errorf("lease was lost: %w", cause)
+	}
+	return nil
+}
+
+// renew renews the lease until it is canceled, canceling it when a renewal fails.
+func (s *StateManager) renew(l *lease, ttl time.Duration) {
+	defer close(l.done)
+
+	t := s.timers().NewTicker(ttl / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		if err := l.leaser.Renew(l.ctx, l.key, l.owner, ttl); err != nil {
+			if l.ctx.Err() != nil {
+				return
+			}
+			s.debug("lost lease", "error", err)
+			if !errors.Is(err, ErrLocked) {
+				err = fmt.Errorf("%w: %w", ErrLocked, err)
+			}
+			l.cancel(err)
+			return
+		}
+	}
+}
+
+// leaseOwner returns a unique lease owner id.
+func leaseOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
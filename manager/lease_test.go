@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// leaseStore is a mapStore granting leases, which can be revoked to simulate expiry.
+type leaseStore struct {
+	mapStore
+	mutex  sync.Mutex
+	owners map[string]string
+	renews int
+}
+
+func (l *leaseStore) Acquire(_ context.Context, key, owner string, _ time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if o, ok := l.owners[key]; ok && o != owner {
+		return ErrLocked
+	}
+	l.owners[key] = owner
+	return nil
+}
+
+func (l *leaseStore) Renew(_ context.Context, key, owner string, _ time.Duration) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.renews++
+	if l.owners[key] != owner {
+		return errors.New("lease expired")
+	}
+	return nil
+}
+
+func (l *leaseStore) Release(_ context.Context, key, owner string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.owners[key] == owner {
+		delete(l.owners, key)
+	}
+	return nil
+}
+
+func (l *leaseStore) revoke(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.owners, key)
+}
+
+func (l *leaseStore) renewCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.renews
+}
+
+// TestLockState ensures only one manager holds the lease and it is renewed until Unlock.
+func TestLockState(t *testing.T) {
+	store := &leaseStore{mapStore: mapStore{}, owners: make(map[string]string)}
+	first, err := NewStateManager(WithStore(store), WithFilePath("/state"))
+	assert.NoError(t, err)
+	second, err := NewStateManager(WithStore(store), WithFilePath("/state"))
+	assert.NoError(t, err)
+
+	leaseCtx, err := first.LockState(context.Background(), 30*time.Millisecond)
+	assert.NoError(t, err)
+	_, err = first.LockState(context.Background(), time.Second)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = second.LockState(ctx, time.Second)
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Eventually(t, func() bool { return store.renewCount() > 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, leaseCtx.Err())
+
+	assert.NoError(t, first.Unlock())
+	assert.Error(t, leaseCtx.Err())
+	assert.Error(t, first.Unlock())
+
+	_, err = second.LockState(context.Background(), time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, second.Unlock())
+}
+
+// TestLockStateLost ensures a failed renewal cancels the lease context.
+func TestLockStateLost(t *testing.T) {
+	store := &leaseStore{mapStore: mapStore{}, owners: make(map[string]string)}
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"))
+	assert.NoError(t, err)
+
+	leaseCtx, err := sm.LockState(context.Background(), 15*time.Millisecond)
+	assert.NoError(t, err)
+
+	store.revoke("/state")
+	<-leaseCtx.Done()
+	assert.ErrorIs(t, context.Cause(leaseCtx), ErrLocked)
+	assert.ErrorIs(t, sm.Unlock(), ErrLocked)
+}
+
+// TestLockStateLostWrites ensures writes fail once the lease was lost.
+func TestLockStateLostWrites(t *testing.T) {
+	store := &leaseStore{mapStore: mapStore{}, owners: make(map[string]string)}
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	data := &config{"alice"}
+	leaseCtx, err := sm.LockState(context.Background(), 15*time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(data))
+
+	store.revoke("/state")
+	<-leaseCtx.Done()
+	assert.ErrorIs(t, sm.Save(data), ErrLocked)
+	assert.ErrorIs(t, sm.Update(data, func() error { return nil }), ErrLocked)
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	assert.ErrorIs(t, sm.Unlock(), ErrLocked)
+	assert.NoError(t, sm.Save(data))
+}
+
+// TestLockStateUnsupported ensures leases require a store implementing Leaser.
+func TestLockStateUnsupported(t *testing.T) {
+	sm, err := NewStateManager(WithFilePath(t.TempDir() + "/state.json"))
+	assert.NoError(t, err)
+	_, err = sm.LockState(context.Background(), time.Second)
+	assert.Error(t, err)
+
+	sm, err = NewStateManager(WithStore(mapStore{}))
+	assert.NoError(t, err)
+	_, err = sm.LockState(context.Background(), time.Second)
+	assert.Error(t, err)
+	_, err = sm.LockState(context.Background(), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// List returns the paths of the files in dir whose names match the pattern,
+// in the syntax of filepath.Match (e.g. "*.json"), sorted by name.
+// A missing dir fails with ErrNotFound.
+func List(dir, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read directory: %w: %w", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, e.Name()); ok {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// LoadAll loads every file matched by List into a value returned by newState,
+// a pointer to a struct, and returns them keyed by file name. Each file is
+// loaded by a manager created with the options, e.g. to set the format or the
+// encryption key. The first file failing to load fails the call.
+func LoadAll[T any](dir, pattern string, newState func() T, options ...StateOption) (map[string]T, error) {
+	paths, err := List(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]T, len(paths))
+	for _, path := range paths {
+		sm, err := NewStateManager(append(options[:len(options):len(options)], WithFilePath(path))...)
+		if err != nil {
+			return nil, err
+		}
+
+		state := newState()
+		if err := sm.Load(state); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		states[filepath.Base(path)] = state
+	}
+
+	return states, nil
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestList ensures the files matching the pattern are listed in order.
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.json", "a.json", "c.yaml"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600))
+	}
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "d.json"), 0700))
+
+	paths, err := List(dir, "*.json")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")}, paths)
+
+	_, err = List(dir, "[")
+	assert.Error(t, err)
+
+	_, err = List(filepath.Join(dir, "missing"), "*")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// TestLoadAll ensures every matching file is decoded into its own state.
+func TestLoadAll(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"alice", "bob"} {
+		sm, err := NewStateManager(WithFilePath(filepath.Join(dir, name+".json")), WithSerializationType(JSON))
+		assert.NoError(t, err)
+		assert.NoError(t, sm.Save(&TestStruct{Name: name}))
+	}
+
+	states, err := LoadAll(dir, "*.json", func() *TestStruct { return &TestStruct{} }, WithSerializationType(JSON))
+	assert.NoError(t, err)
+	assert.Len(t, states, 2)
+	assert.Equal(t, "alice", states["alice.json"].Name)
+	assert.Equal(t, "bob", states["bob.json"].Name)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600))
+	_, err = LoadAll(dir, "*.json", func() *TestStruct { return &TestStruct{} }, WithSerializationType(JSON))
+	assert.ErrorContains(t, err, "broken.json")
+}
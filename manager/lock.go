@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const (
+	// lockFileSuffix is appended to the file path to form the cross-process lock file
+	lockFileSuffix = ".lock"
+
+	// lockRetryInterval is the delay between lock attempts when a lock timeout is set
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+// WithFileLock guards state access with an advisory lock on a sidecar lock
+// file so that multiple processes can safely share the same state file.
+func WithFileLock() StateOption {
+	return func(s *StateManager) {
+		s.fileLock = true
+	}
+}
+
+// WithLockTimeout enables the file lock and limits how long operations wait
+// for it before failing with ErrLocked. Without a timeout they block until
+// the lock is released.
+func WithLockTimeout(d time.Duration) StateOption {
+	return func(s *StateManager) {
+		s.fileLock = true
+		s.lockTimeout = d
+	}
+}
+
+// lockFile acquires the cross-process lock when enabled and returns the
+// function releasing it. Caller must hold the mutex.
+func (s *StateManager) lockFile(exclusive bool) (func(), error) {
+	if !s.fileLock {
+		return func() {}, nil
+	}
+
+	if unlock, ok, err := s.lockStore(s.FilePath, exclusive); ok {
+		return unlock, err
+	}
+
+	if !s.osFiles() {
+		return func() {}, nil
+	}
+
+	f, err := os.OpenFile(s.FilePath+lockFileSuffix, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := s.acquire(f, exclusive); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		_ = unlockHandle(f)
+		f.Close()
+	}, nil
+}
+
+// acquire locks the file, waiting up to the lock timeout when one is set.
+func (s *StateManager) acquire(f *os.File, exclusive bool) error {
+	ok, err := tryLockHandle(f, exclusive)
+	if err != nil {
+		return fmt.Errorf("failed to acquire file lock: %w", err)
+	}
+	if ok {
+		return nil
+	}
+
+	start := time.Now()
+	s.debug("waiting for file lock", slog.Bool("exclusive", exclusive), slog.Duration("timeout", s.lockTimeout))
+
+	if s.lockTimeout <= 0 {
+		if err := lockHandle(f, exclusive); err != nil {
+			return fmt.Errorf("failed to acquire file lock: %w", err)
+		}
+		s.debug("acquired file lock", slog.Duration("waited", time.Since(start)))
+		return nil
+	}
+
+	deadline := start.Add(s.lockTimeout)
+	for {
+		if time.Now().After(deadline) {
+			s.debug("timed out waiting for file lock", slog.Duration("waited", time.Since(start)))
+			return fmt.Errorf("%w: timed out after %s", ErrLocked, s.lockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+
+		ok, err := tryLockHandle(f, exclusive)
+		if err != nil {
+			return fmt.Errorf("failed to acquire file lock: %w", err)
+		}
+		if ok {
+			s.debug("acquired file lock", slog.Duration("waited", time.Since(start)))
+			return nil
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix && !windows
+
+package manager
+
+import (
+	"os"
+)
+
+// lockHandle is a no-op on platforms without file locking support.
+func lockHandle(_ *os.File, _ bool) error {
+	return nil
+}
+
+// unlockHandle is a no-op on platforms without file locking support.
+func unlockHandle(_ *os.File) error {
+	return nil
+}
+
+// tryLockHandle always succeeds on platforms without file locking support.
+func tryLockHandle(_ *os.File, _ bool) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package manager
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockHandle blocks until the advisory lock on the file is acquired.
+func lockHandle(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+// unlockHandle releases the advisory lock on the file.
+func unlockHandle(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// tryLockHandle attempts to acquire the advisory lock without blocking.
+func tryLockHandle(f *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"log/slog"
+)
+
+// WithLogger logs saves, loads, lock waits and other background activity of
+// the manager at debug level.
+func WithLogger(l *slog.Logger) StateOption {
+	return func(s *StateManager) {
+		s.logger = l
+	}
+}
+
+// debug logs the message with the state file path when a logger is set.
+func (s *StateManager) debug(msg string, args ...interface{}) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.Debug(msg, append([]interface{}{slog.String("path", s.FilePath)}, args...)...)
+}
+
+// logObservation logs the outcome of a save or load.
+func (s *StateManager) logObservation(o Observation) {
+	args := []interface{}{
+		slog.String("format", string(o.Format)),
+		slog.String("backend", o.Backend),
+		slog.Int("size", o.Size),
+		slog.Duration("duration", o.Duration),
+	}
+
+	if o.Err != nil {
+		s.debug("failed to "+o.Operation+" state", append(args, slog.Any("error", o.Err))...)
+		return
+	}
+
+	switch o.Operation {
+	case OperationSave:
+		s.debug("state saved", args...)
+	case OperationLoad:
+		s.debug("state loaded", args...)
+	}
+}
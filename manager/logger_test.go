@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithLogger ensures saves and loads are logged at debug level.
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithLogger(logger),
+	)
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.True(t, errors.Is(sm.Load(loaded), ErrNotFound))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+	assert.NoError(t, sm.Load(loaded))
+
+	out := buf.String()
+	assert.Contains(t, out, `level=DEBUG msg="failed to load state"`)
+	assert.Contains(t, out, `msg="state saved" path=`+sm.FilePath+" format=json backend=file")
+	assert.Contains(t, out, `msg="state loaded"`)
+	assert.Contains(t, out, "error=")
+}
+
+// TestWithLoggerLevel ensures nothing is logged above debug level.
+func TestWithLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithLogger(logger),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+	assert.Empty(t, buf.String())
+}
+
+// TestWithLoggerLockWait ensures waiting for the file lock is logged.
+func TestWithLoggerLockWait(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	holder, err := NewStateManager(WithFilePath(path), WithFileLock())
+	assert.NoError(t, err)
+
+	waiter, err := NewStateManager(WithFilePath(path), WithLockTimeout(20*time.Millisecond), WithLogger(logger))
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_ = holder.Update(&TestStruct{}, func() error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+
+	<-locked
+	assert.ErrorIs(t, waiter.Save(&TestStruct{}), ErrLocked)
+	close(release)
+	<-done
+
+	assert.Contains(t, buf.String(), `msg="waiting for file lock"`)
+	assert.Contains(t, buf.String(), `msg="timed out waiting for file lock"`)
+}
@@ -1,17 +1,19 @@
 package manager
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/ed25519"
 	"encoding/gob"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"reflect"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -25,6 +27,9 @@ const (
 	YAML  SerializationType = "yaml"
 	BIN   SerializationType = "bin"
 	STATE SerializationType = "state"
+	PROTO SerializationType = "proto"
+	BSON  SerializationType = "bson"
+	XML   SerializationType = "xml"
 
 	// StateAnnotationKey is the key used to define custom field names
 	StateAnnotationKey = "state"
@@ -32,6 +37,7 @@ const (
 	// Default values
 	SerializationTypeDefault = BIN
 	DefaultStateFileName     = ".state"
+	DefaultFileMode          = os.FileMode(0600)
 )
 
 // StateManager handles persisting state to a file.
@@ -39,7 +45,80 @@ type StateManager struct {
 	FilePath          string
 	SerializationType SerializationType
 
-	mutex sync.Mutex
+	config
+
+	mutex        sync.RWMutex
+	optionErr    error
+	appDir       string
+	lastChecksum string
+	named        map[string]*StateManager
+	pending      *pendingSave
+	flushTimer   Timer
+	flushErr     error
+	signalFlush  bool
+	life         lifecycle
+	overlays     *overlayCache
+	shardFiles   map[string]shardFile
+
+	leaseMutex sync.Mutex
+	lease      *lease
+
+	baseMutex         sync.Mutex
+	mergeBase         []byte
+	mergeBaseRevision int64
+}
+
+// config holds the optional settings shared by a manager and its named states.
+type config struct {
+	fileMode      os.FileMode
+	createDirs    bool
+	envelope      bool
+	schemaVersion int
+	backups       int
+	dirtyTracking bool
+	fileLock      bool
+	lockTimeout   time.Duration
+	envPrefix     string
+	strict        bool
+	detectFormat  bool
+	encryptionKey []byte
+	passphrase    *passphrase
+	age           *ageKeys
+	dataKey       *dataKey
+	fieldKey      []byte
+	fieldMigrate  bool
+	signingKey    ed25519.PrivateKey
+	verifyKey     ed25519.PublicKey
+	fs            FS
+	baseline      []byte
+	mergeOnLoad   bool
+	clock         Clock
+	ttl           time.Duration
+	deleteStale   bool
+	metrics       Metrics
+	logger        *slog.Logger
+	schema        DocumentValidator
+	debounce      time.Duration
+	validators    []func(data interface{}) error
+	hooks         hooks
+	retryPolicy   *RetryPolicy
+	merger        Merger
+	canonical     bool
+	directory     bool
+	splits        map[string]string
+	autoPrune     *PrunePolicy
+	maxSize       int64
+	warnSize      int64
+	sizeWarning   SizeWarning
+	streaming     bool
+	compactEvery  int
+	shards        map[string]int
+	durable       bool
+	readOnly      bool
+	fieldNames    FieldNameMapper
+	yamlComments  bool
+	jsonIndent    *string
+	compactJSON   bool
 }
 
 // StateOption defines a functional option for configuring StateManager
@@ -68,6 +147,29 @@ func WithStateKey(key string) StateOption {
 	}
 }
 
+// WithFileMode sets the permissions of the state file, 0600 by default.
+// More permissive modes of existing files are tightened on Load.
+func WithFileMode(mode os.FileMode) StateOption {
+	return func(s *StateManager) {
+		s.fileMode = mode.Perm()
+	}
+}
+
+// WithCreateDirs creates the missing parent directories of the state file on Save
+func WithCreateDirs() StateOption {
+	return func(s *StateManager) {
+		s.createDirs = true
+	}
+}
+
+// WithDirtyTracking makes Save a no-op when the encoded state is identical
+// to the one written by the previous Save
+func WithDirtyTracking() StateOption {
+	return func(s *StateManager) {
+		s.dirtyTracking = true
+	}
+}
+
 // NewStateManager initializes a new State with functional options.
 func NewStateManager(options ...StateOption) (*StateManager, error) {
 	homeDir, err := os.UserHomeDir()
@@ -78,97 +180,648 @@ func NewStateManager(options ...StateOption) (*StateManager, error) {
 	s := &StateManager{
 		FilePath:          filepath.Join(homeDir, DefaultStateFileName),
 		SerializationType: SerializationTypeDefault,
+		config: config{
+			fileMode: DefaultFileMode,
+		},
 	}
 
 	for _, option := range options {
 		option(s)
 	}
 
+	if s.optionErr != nil {
+		return nil, s.optionErr
+	}
+
+	if s.streaming {
+		if err := s.streamable(); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.appDir != "" {
+		if err := s.files().MkdirAll(s.appDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create app directory: %w", err)
+		}
+	}
+
+	if s.signalFlush {
+		s.handleSignals()
+	}
+
 	return s, nil
 }
 
 // Save persists the given struct to the file.
 func (s *StateManager) Save(data interface{}) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	file, err := os.Create(s.FilePath)
+	if s.debounce > 0 {
+		return s.deferSave(data)
+	}
+
+	unlock, err := s.lockFile(true)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return err
 	}
-	defer file.Close()
+	defer unlock()
 
-	var b []byte
+	return s.save(data)
+}
 
-	switch s.SerializationType {
-	case BIN:
-		b, err = binaryMarshal(data)
-	case JSON:
-		b, err = json.MarshalIndent(data, "", "  ")
-	case YAML:
-		b, err = yaml.Marshal(data)
-	case STATE:
-		b, err = stateMarshal(data)
-	default:
-		err = fmt.Errorf("unsupported serialization format")
+// Load reads the struct from the file.
+func (s *StateManager) Load(data interface{}) error {
+	unlock, err := s.lockLoad()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.load(data)
+}
+
+// LoadOrCreate reads the struct from the file. When no state was persisted yet,
+// data is set to the value returned by init (a struct or a pointer to one of
+// the same type as data) and saved. A nil init saves data as is.
+func (s *StateManager) LoadOrCreate(data interface{}, init func() interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	err = s.load(data)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	if init != nil {
+		if err := assign(data, init()); err != nil {
+			return err
+		}
+	}
+
+	return s.save(data)
+}
+
+// assign copies the value, or the value it points to, into the target pointer.
+func assign(target, value interface{}) error {
+	tv := reflect.ValueOf(target)
+	if tv.Kind() != reflect.Ptr || tv.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer")
+	}
+
+	vv := reflect.Indirect(reflect.ValueOf(value))
+	if !vv.IsValid() || !vv.Type().AssignableTo(tv.Elem().Type()) {
+		return fmt.Errorf("cannot assign %T to %s", value, tv.Elem().Type())
+	}
+
+	tv.Elem().Set(vv)
+	return nil
+}
+
+// Update loads the persisted state into data, applies fn and saves the
+// result while holding the lock, so that no other Save can interleave.
+// A missing state file leaves data as is. When fn returns an error nothing is saved.
+func (s *StateManager) Update(data interface{}, fn func() error) error {
+	if err := s.writable(); err != nil {
+		return err
 	}
 
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
 	if err != nil {
-		return fmt.Errorf("failed to encode data: %w", err)
+		return err
+	}
+	defer unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	if s.exists(s.FilePath) {
+		if err := s.load(data); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return s.save(data)
+}
+
+// save persists the given struct. Caller must hold the lock.
+func (s *StateManager) save(data interface{}) error {
+	return s.persist(data, s.dirtyTracking)
+}
+
+// persist persists the given struct, skipping the write when skipUnchanged
+// is set and the encoded state matches the last write. Caller must hold the lock.
+func (s *StateManager) persist(data interface{}, skipUnchanged bool) (err error) {
+	// streamed saves observe themselves
+	if s.streaming {
+		return s.persistStream(data, skipUnchanged)
+	}
+
+	start := s.now()
+	var payload []byte
+	defer func() { s.observe(OperationSave, start, len(payload), err) }()
+
+	payload, err = s.prepare(data)
+	if err != nil {
+		return err
+	}
+
+	return s.commit(data, payload, skipUnchanged)
+}
+
+// prepare runs the before save hooks and validation and encodes the given struct.
+func (s *StateManager) prepare(data interface{}) ([]byte, error) {
+	if err := runHooks(s.hooks.beforeSave, data); err != nil {
+		return nil, err
+	}
+
+	if err := s.validate(data); err != nil {
+		return nil, err
+	}
+
+	return s.encode(data)
+}
+
+// commit writes the encoded struct and runs the after save hooks. Caller must hold the lock.
+func (s *StateManager) commit(data interface{}, payload []byte, skipUnchanged bool) error {
+	written, err := s.writePayload(data, payload, skipUnchanged)
+	if err != nil || !written {
+		return err
+	}
+
+	return runHooks(s.hooks.afterSave, data)
+}
+
+// writePayload writes the encoded struct and reports whether it was written,
+// false when it is unchanged and skipped. Caller must hold the lock.
+func (s *StateManager) writePayload(data interface{}, payload []byte, skipUnchanged bool) (bool, error) {
+	sum := checksum(payload)
+	if skipUnchanged && sum == s.lastChecksum && s.exists(s.FilePath) {
+		return false, nil
+	}
+
+	if s.directory {
+		if err := s.writeDirectory(payload); err != nil {
+			return false, err
+		}
+	} else if s.compactEvery > 0 {
+		if err := s.writeIncremental(payload); err != nil {
+			return false, err
+		}
+	} else if err := s.writeSplit(data, payload); err != nil {
+		return false, err
+	}
+
+	s.lastChecksum = sum
+
+	if s.autoPrune != nil {
+		if _, err := s.prune(*s.autoPrune); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// load reads the struct from the file. Caller must hold the read lock.
+func (s *StateManager) load(data interface{}) (err error) {
+	if s.streaming {
+		return s.loadStream(data)
+	}
+
+	start := s.now()
+	var c []byte
+	defer func() { s.observe(OperationLoad, start, len(c), err) }()
+
+	if err := runHooks(s.hooks.beforeLoad, data); err != nil {
+		return err
+	}
+
+	if s.directory {
+		if c, err = s.loadDirectory(data); err != nil {
+			return err
+		}
+		return runHooks(s.hooks.afterLoad, data)
+	}
+
+	c, err = s.readFile(s.FilePath)
+	if err != nil && (s.baseline == nil || !errors.Is(err, ErrNotFound)) {
+		return err
+	}
+
+	if err == nil {
+		if err := s.expire(c); err != nil {
+			return err
+		}
+		s.fixFileMode(s.FilePath)
+	}
+
+	if s.compactEvery > 0 && c != nil {
+		err = s.decodeIncremental(c, data)
+	} else {
+		err = s.decodeSplit(c, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	return runHooks(s.hooks.afterLoad, data)
+}
+
+// encode serializes the given struct into the payload.
+func (s *StateManager) encode(data interface{}) ([]byte, error) {
+	data, err := s.sealFields(data)
+	if err != nil {
+		return nil, err
+	}
+	data = excludeFields(data)
+
+	b, err := s.marshal(s.SerializationType, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	if b, err = dropExcluded(s.SerializationType, data, b); err != nil {
+		return nil, err
+	}
+
+	if s.yamlComments && (s.SerializationType == YAML || s.SerializationType == STATE) {
+		b = s.keepComments(b)
+	}
+
+	if s.canonical {
+		if b, err = canonicalize(s.SerializationType, b); err != nil {
+			return nil, fmt.Errorf("failed to encode data: %w", err)
+		}
 	}
 
 	// Ensure something is written to file
 	if len(b) == 0 {
-		return fmt.Errorf("no data was encoded")
+		return nil, fmt.Errorf("no data was encoded")
+	}
+
+	if s.baseline != nil {
+		if b, err = s.delta(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.formatJSON(b)
+}
+
+// seal turns the encoded payload into the file content.
+func (s *StateManager) seal(payload []byte) ([]byte, error) {
+	if !s.envelope && !s.encrypted() && s.signingKey == nil {
+		return payload, nil
+	}
+
+	env := &Envelope{
+		Format:        s.SerializationType,
+		SchemaVersion: s.schemaVersion,
+		Revision:      s.revision() + 1,
+		Timestamp:     s.now().UTC(),
+	}
+
+	if s.encrypted() {
+		var err error
+		if payload, err = s.encrypt(env, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.sign(env, payload); err != nil {
+		return nil, err
+	}
+
+	return wrapEnvelope(env, payload)
+}
+
+// open turns the file content into the encoded payload and the format to decode it with.
+func (s *StateManager) open(c []byte) ([]byte, SerializationType, error) {
+	env, payload, err := unwrapEnvelope(c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.verify(env); err != nil {
+		return nil, "", err
+	}
+
+	if env != nil {
+		if env.Encryption != "" {
+			if payload, err = s.decrypt(env, payload); err != nil {
+				return nil, "", err
+			}
+		}
+		if env.Format == s.SerializationType {
+			return payload, env.Format, nil
+		}
+		if s.detectFormat {
+			return payload, env.Format, nil
+		}
+		return nil, "", fmt.Errorf("%w: file format %q does not match manager format %q", ErrFormatMismatch, env.Format, s.SerializationType)
+	}
+
+	if s.detectFormat && builtinFormat(s.SerializationType) {
+		if detected := DetectFormat(payload); !compatibleFormat(detected, s.SerializationType) {
+			return payload, detected, nil
+		}
+	}
+
+	return payload, s.SerializationType, nil
+}
+
+// decode deserializes the file content into the given struct.
+func (s *StateManager) decode(c []byte, data interface{}) error {
+	var err error
+
+	// a missing state file decodes the baseline alone
+	st := s.SerializationType
+	if c != nil || s.baseline == nil {
+		if c, st, err = s.open(c); err != nil {
+			return err
+		}
+	}
+
+	return s.decodePayload(st, c, data)
+}
+
+// decodePayload deserializes the opened payload of the format into the given struct.
+func (s *StateManager) decodePayload(st SerializationType, c []byte, data interface{}) error {
+	var err error
+
+	if s.baseline != nil {
+		if c, err = s.overlay(st, c); err != nil {
+			return err
+		}
+	}
+
+	if s.strict {
+		if err := checkUnknownKeys(st, c, data, s.fieldNames); err != nil {
+			return err
+		}
+	}
+
+	if s.schema != nil {
+		if err := s.validateDocument(st, c); err != nil {
+			return err
+		}
+	}
+
+	restore := keepExcluded(data)
+	err = s.unmarshal(st, c, data)
+	restore()
+	if err != nil {
+		if detected := DetectFormat(c); builtinFormat(st) && !compatibleFormat(detected, st) {
+			return fmt.Errorf("failed to decode data: %w", mismatchError(detected, st))
+		}
+		// A payload cut short is corruption rather than a type mismatch
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to decode data: %w: %w", ErrCorrupted, err)
+		}
+		return fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	return s.complete(data)
+}
+
+// complete decrypts the annotated fields of the decoded struct, applies the
+// defaults and environment overrides and validates it.
+func (s *StateManager) complete(data interface{}) error {
+	if err := s.openFields(data); err != nil {
+		return err
+	}
+
+	if err := applyDefaults(data); err != nil {
+		return err
+	}
+
+	if err := applyEnvOverrides(data, s.envPrefix); err != nil {
+		return err
+	}
+
+	return s.validate(data)
+}
+
+// write atomically replaces the file at path with the given content.
+func (s *StateManager) write(path string, b []byte) error {
+	return s.writeFile(path, b, s.fileMode)
+}
+
+// writeFile atomically replaces the file at path with the given content and mode.
+func (s *StateManager) writeFile(path string, b []byte, mode os.FileMode) error {
+	if err := s.checkWriteSize(path, int64(len(b))); err != nil {
+		return err
+	}
+
+	if path == s.FilePath {
+		// a newer write supersedes the debounced save
+		s.lastChecksum = ""
+		s.pending = nil
+	}
+
+	if s.createDirs {
+		if err := s.files().MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	// Stores replace values atomically
+	if _, ok := s.files().(*storeFS); ok {
+		if path == s.FilePath && s.backups > 0 {
+			if err := s.rotateBackups(); err != nil {
+				return err
+			}
+		}
+		if err := s.retry("write", func() error { return s.files().WriteFile(path, b, mode) }); err != nil {
+			return fmt.Errorf("failed to write to store: %w", err)
+		}
+		return nil
 	}
 
 	// Write to a temporary file first
-	tempFile := s.FilePath + ".tmp"
-	if err := os.WriteFile(tempFile, b, 0600); err != nil {
+	tempFile := path + ".tmp"
+	writeTemp := s.files().WriteFile
+	if s.durableWrites() {
+		writeTemp = writeSynced
+	}
+	if err := s.retry("write", func() error { return writeTemp(tempFile, b, mode) }); err != nil {
 		return fmt.Errorf("failed to write to temp file: %w", err)
 	}
 
+	// Enforce the mode regardless of umask or a leftover temp file
+	if err := s.files().Chmod(tempFile, mode); err != nil {
+		_ = s.files().Remove(tempFile)
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+
+	if path == s.FilePath && s.backups > 0 {
+		if err := s.rotateBackups(); err != nil {
+			_ = s.files().Remove(tempFile)
+			return err
+		}
+	}
+
 	// Atomically move temp file to actual file
-	if err := os.Rename(tempFile, s.FilePath); err != nil {
+	if err := s.retry("rename", func() error { return s.files().Rename(tempFile, path) }); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	if s.durableWrites() {
+		return syncParent(path)
+	}
+
 	return nil
 }
 
-// Load reads the struct from the file.
-func (s *StateManager) Load(data interface{}) error {
+// Delete removes the state file. When backups are enabled the file is rotated
+// into the backups instead so it can be brought back with Restore.
+// Deleting a state which does not exist is not an error.
+func (s *StateManager) Delete() error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	c, err := os.ReadFile(s.FilePath)
+	unlock, err := s.lockFile(true)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	switch s.SerializationType {
-	case BIN:
-		err = binaryUnmarshal(c, data)
-	case JSON:
-		err = json.Unmarshal(c, data)
-	case YAML:
-		err = yaml.Unmarshal(c, data)
-	case STATE:
-		err = stateUnmarshal(c, data)
-	default:
-		err = fmt.Errorf("unsupported serialization format")
+		return err
 	}
+	defer unlock()
 
-	if err != nil {
-		return fmt.Errorf("failed to decode data: %w", err)
+	s.lastChecksum = ""
+	s.pending = nil
+
+	if s.compactEvery > 0 {
+		s.overlays = nil
+		seqs, err := s.overlayRecords()
+		if err != nil {
+			return err
+		}
+		if err := s.removeOverlays(seqs); err != nil {
+			return err
+		}
+	}
+
+	if err := s.removeAllShards(); err != nil {
+		return err
+	}
+
+	if s.backups > 0 {
+		return s.rotateBackups()
+	}
+
+	if s.directory {
+		return s.removeDirectory()
+	}
+
+	if err := s.files().Remove(s.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove file: %w", err)
 	}
 
 	return nil
 }
 
+// Clear resets the given struct to its zero value and persists it.
+func (s *StateManager) Clear(data interface{}) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("clear target must be a non-nil pointer")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v.Elem().Set(reflect.Zero(v.Elem().Type()))
+
+	return s.save(data)
+}
+
 // Exists checks if the file exists.
 func (s *StateManager) Exists() bool {
-	if _, err := os.Stat(s.FilePath); os.IsNotExist(err) {
+	return s.exists(s.FilePath)
+}
+
+// fixFileMode tightens the permissions of an existing file which are more
+// permissive than the configured mode. This is best effort as the file may
+// be owned by another user.
+func (s *StateManager) fixFileMode(path string) {
+	if s.readOnly {
+		return
+	}
+
+	info, err := s.files().Stat(path)
+	if err != nil || info.Mode().Perm()&^s.fileMode == 0 {
+		return
+	}
+	_ = s.files().Chmod(path, info.Mode().Perm()&s.fileMode)
+}
+
+// readFile reads the file at path reporting a missing file as ErrNotFound.
+func (s *StateManager) readFile(path string) ([]byte, error) {
+	if err := s.checkReadSize(path); err != nil {
+		return nil, err
+	}
+
+	var c []byte
+	err := s.retry("read", func() (err error) {
+		c, err = s.files().ReadFile(path)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read file: %w: %w", ErrNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if s.maxSize > 0 && int64(len(c)) > s.maxSize {
+		return nil, fmt.Errorf("%w: %s has %d bytes, exceeding the limit of %d", ErrTooLarge, path, len(c), s.maxSize)
+	}
+	return c, nil
+}
+
+// exists checks if the file at path exists.
+func (s *StateManager) exists(path string) bool {
+	if _, err := s.files().Stat(path); errors.Is(err, os.ErrNotExist) {
 		return false
 	}
 	return true
@@ -176,75 +829,309 @@ func (s *StateManager) Exists() bool {
 
 // stateMarshal handles struct serialization using field tags
 func stateMarshal(data interface{}) ([]byte, error) {
-	values := make(map[string]interface{})
-	t := reflect.TypeOf(data)
-	v := reflect.ValueOf(data)
+	return stateMarshalNamed(data, nil)
+}
+
+// stateMarshalNamed serializes the struct or map, naming the keys of fields without
+// one in their annotation by the mapper when given.
+func stateMarshalNamed(data interface{}, names FieldNameMapper) ([]byte, error) {
+	if m, ok := data.(Marshaler); ok {
+		return m.MarshalState()
+	}
 
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		key := field.Tag.Get(StateAnnotationKey)
+	if v.Kind() == reflect.Map {
+		values, err := stateMapValues(v, names)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeStateValues(values)
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("marshal source must be a struct or map")
+	}
+
+	values, err := stateValues(v, names)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeStateValues(values)
+}
+
+// stateValues collects the values of the tagged fields, recursing into nested structs
+func stateValues(v reflect.Value, names FieldNameMapper) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
 
-		// Only include fields that have the state tag
-		if key == "" {
+	var inline []stateField
+	for _, field := range stateTypeOf(v.Type()).fields {
+		if field.inlined(names) {
+			inline = append(inline, field)
 			continue
 		}
 
-		values[key] = v.Field(i).Interface() // Preserve original types
+		// Only include exported fields that have the state tag
+		if !field.persisted(names) {
+			continue
+		}
+
+		if field.omitEmpty && isEmptyValue(v.Field(field.index)) {
+			continue
+		}
+
+		val, err := stateValue(v.Field(field.index), names)
+		if err != nil {
+			return nil, err
+		}
+		values[field.keyFor(names)] = val
+	}
+
+	// Keys of the parent take precedence over those of inlined structs
+	for _, field := range inline {
+		fv := v.Field(field.index)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+
+		nested, err := stateValues(fv, names)
+		if err != nil {
+			return nil, err
+		}
+		for k, val := range nested {
+			if _, ok := values[k]; !ok {
+				values[k] = val
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// stateValue returns the value to encode for a single field
+func stateValue(v reflect.Value, names FieldNameMapper) (interface{}, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+
+	if v.Kind() == reflect.Interface {
+		if val, ok, err := stateTypedValue(v, names); ok {
+			return val, err
+		}
+	}
+
+	if m, ok := marshalerOf(v); ok {
+		b, err := m.MarshalState()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", v.Type(), err)
+		}
+		return string(b), nil
 	}
 
-	return yaml.Marshal(values)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if isStateStruct(v.Type(), names) {
+		return stateValues(v, names)
+	}
+
+	if val, ok, err := stateElemValues(v, names); ok {
+		return val, err
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return val.String(), nil
+	default:
+		return val, nil // Preserve original types
+	}
+}
+
+// isStateStruct checks if the type is a struct with at least one state tagged field
+// or any struct of its own when fields are named by a mapper
+func isStateStruct(t reflect.Type, names FieldNameMapper) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	if names != nil {
+		return t != timeType && !encodesItself(t) && !decodesItself(t)
+	}
+	return stateTypeOf(t).tagged
 }
 
 func stateUnmarshal(data []byte, v interface{}) error {
-	if reflect.TypeOf(v).Kind() != reflect.Ptr {
-		return fmt.Errorf("unmarshal target must be a pointer to a struct")
+	return stateUnmarshalNamed(data, v, nil)
+}
+
+// stateUnmarshalNamed deserializes the struct or map, naming the keys of fields
+// without one in their annotation by the mapper when given.
+func stateUnmarshalNamed(data []byte, v interface{}, names FieldNameMapper) error {
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalState(data)
 	}
 
-	values := make(map[string]interface{})
-	if err := yaml.Unmarshal(data, &values); err != nil {
-		return fmt.Errorf("failed to unmarshal YAML: %w", err)
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || (t.Elem().Kind() != reflect.Struct && t.Elem().Kind() != reflect.Map) {
+		return fmt.Errorf("unmarshal target must be a pointer to a struct or map")
 	}
 
-	vt := reflect.TypeOf(v).Elem()
-	vv := reflect.ValueOf(v).Elem()
+	values, err := DecodeStateValues(data)
+	if err != nil {
+		return err
+	}
+
+	if t.Elem().Kind() == reflect.Map {
+		return setStateMap(reflect.ValueOf(v).Elem(), values, names)
+	}
 
-	for i := 0; i < vt.NumField(); i++ {
-		field := vt.Field(i)
-		key := field.Tag.Get(StateAnnotationKey)
-		if key == "" {
-			key = strings.ToLower(field.Name)
+	return setStateValues(reflect.ValueOf(v).Elem(), values, "", names)
+}
+
+// setStateValues populates the struct fields from the decoded values
+func setStateValues(vv reflect.Value, values map[string]interface{}, prefix string, names FieldNameMapper) error {
+	for _, field := range stateTypeOf(vv.Type()).fields {
+		if field.inlined(names) {
+			if err := setInlineValues(vv.Field(field.index), values, prefix, names); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.excluded {
+			continue
 		}
 
+		key := field.keyFor(names)
 		value, ok := values[key]
 		if !ok {
+			if field.required {
+				return &MissingFieldError{Key: prefix + key}
+			}
 			continue
 		}
 
-		fieldValue := vv.Field(i)
+		fieldValue := vv.Field(field.index)
 		if !fieldValue.CanSet() {
 			continue
 		}
 
-		// Handle pointer fields
-		if fieldValue.Kind() == reflect.Ptr {
-			elemType := fieldValue.Type().Elem()
-			newVal := reflect.New(elemType)
-			if err := setReflectValue(newVal.Elem(), value); err == nil {
-				fieldValue.Set(newVal)
+		if err := setStateField(fieldValue, value, prefix+key+".", names); err != nil {
+			var missing *MissingFieldError
+			if errors.As(err, &missing) {
+				return err
 			}
-		} else {
-			_ = setReflectValue(fieldValue, value)
 		}
 	}
 
 	return nil
 }
 
+// setInlineValues populates the fields of an inlined struct from the decoded
+// values of its parent. A nil pointer is only allocated when any of its keys
+// is present.
+func setInlineValues(field reflect.Value, values map[string]interface{}, prefix string, names FieldNameMapper) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			if !field.CanSet() || !hasStateKeys(field.Type().Elem(), values, names) {
+				return nil
+			}
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	return setStateValues(field, values, prefix, names)
+}
+
+// hasStateKeys reports whether any key of the fields of the struct type,
+// including those of inlined structs, is present in the decoded values.
+func hasStateKeys(t reflect.Type, values map[string]interface{}, names FieldNameMapper) bool {
+	for _, field := range stateTypeOf(t).fields {
+		if field.inlined(names) {
+			ft := t.Field(field.index).Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if hasStateKeys(ft, values, names) {
+				return true
+			}
+			continue
+		}
+		if _, ok := values[field.keyFor(names)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// setStateField sets a single field from its decoded value
+func setStateField(field reflect.Value, value interface{}, prefix string, names FieldNameMapper) error {
+	// Null values reset the field
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	// Interfaces decode discriminated values into their registered types
+	if ok, err := setStateTyped(field, value, prefix, names); ok {
+		return err
+	}
+
+	if u, ok := unmarshalerOf(field); ok {
+		str, ok := value.(string)
+		if !ok {
+			str = fmt.Sprintf("%v", value)
+		}
+		return u.UnmarshalState([]byte(str))
+	}
+
+	// Handle pointer fields
+	if field.Kind() == reflect.Ptr {
+		newVal := reflect.New(field.Type().Elem())
+		if err := setStateField(newVal.Elem(), value, prefix, names); err != nil {
+			return err
+		}
+		field.Set(newVal)
+		return nil
+	}
+
+	// Nested structs honor their own state tags
+	if isStateStruct(field.Type(), names) {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected map for %s, got %T", field.Type(), value)
+		}
+		return setStateValues(field, m, prefix, names)
+	}
+
+	switch field.Type() {
+	case timeType:
+		return setTimeValue(field, value)
+	case durationType:
+		return setDurationValue(field, value)
+	}
+
+	if err := setReflectValue(field, value); err == nil {
+		return nil
+	}
+
+	// Everything else round-trips through YAML
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s value: %w", field.Type(), err)
+	}
+	return yaml.Unmarshal(b, field.Addr().Interface())
+}
+
 func setReflectValue(field reflect.Value, value interface{}) error {
 	switch field.Kind() {
 	case reflect.String:
@@ -252,19 +1139,19 @@ func setReflectValue(field reflect.Value, value interface{}) error {
 			field.SetString(str)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if num, err := strconv.ParseInt(fmt.Sprintf("%v", value), 10, 64); err == nil {
+		if num, ok := IntValue(value); ok {
 			field.SetInt(num)
 		}
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if num, err := strconv.ParseUint(fmt.Sprintf("%v", value), 10, 64); err == nil {
+		if num, ok := UintValue(value); ok {
 			field.SetUint(num)
 		}
 	case reflect.Float32, reflect.Float64:
-		if num, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64); err == nil {
+		if num, ok := FloatValue(value); ok {
 			field.SetFloat(num)
 		}
 	case reflect.Bool:
-		if boolean, err := strconv.ParseBool(fmt.Sprintf("%v", value)); err == nil {
+		if boolean, ok := BoolValue(value); ok {
 			field.SetBool(boolean)
 		}
 	default:
@@ -273,24 +1160,81 @@ func setReflectValue(field reflect.Value, value interface{}) error {
 	return nil
 }
 
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// setTimeValue sets a time.Time field from a timestamp or RFC3339 string
+func setTimeValue(field reflect.Value, value interface{}) error {
+	switch val := value.(type) {
+	case time.Time:
+		field.Set(reflect.ValueOf(val))
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil {
+			return fmt.Errorf("failed to parse time: %w", err)
+		}
+		field.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("unsupported time value: %T", value)
+	}
+	return nil
+}
+
+// setDurationValue sets a time.Duration field from a duration string or nanoseconds
+func setDurationValue(field reflect.Value, value interface{}) error {
+	switch val := value.(type) {
+	case string:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse duration: %w", err)
+		}
+		field.SetInt(int64(d))
+	case int:
+		field.SetInt(int64(val))
+	case int64:
+		field.SetInt(val)
+	default:
+		return fmt.Errorf("unsupported duration value: %T", value)
+	}
+	return nil
+}
+
 // binaryMarshal handles struct serialization using binary encoding
 func binaryMarshal(data interface{}) ([]byte, error) {
-	var buf bytes.Buffer
-	encoder := gob.NewEncoder(&buf)
+	if m, ok := data.(Marshaler); ok {
+		return m.MarshalState()
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	encoder := gob.NewEncoder(buf)
 	if err := encoder.Encode(data); err != nil {
 		return nil, fmt.Errorf("failed to encode data: %w", err)
 	}
-	return buf.Bytes(), nil
+	return bytes.Clone(buf.Bytes()), nil
 }
 
 // binaryUnmarshal handles struct deserialization using binary encoding
 func binaryUnmarshal(data []byte, v interface{}) error {
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalState(data)
+	}
+
 	if reflect.TypeOf(v).Kind() != reflect.Ptr {
 		return errors.New("unmarshal target must be a pointer to a struct")
 	}
 
-	buf := bytes.NewBuffer(data)
-	decoder := gob.NewDecoder(buf)
+	if bytes.HasPrefix(data, binStreamMagic) {
+		if err := decodeBinStream(bufio.NewReader(bytes.NewReader(data)), v); err != nil {
+			return fmt.Errorf("failed to decode binary data: %w", err)
+		}
+		return nil
+	}
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(v); err != nil {
 		return fmt.Errorf("failed to decode binary data: %w", err)
 	}
@@ -1,10 +1,13 @@
 package manager
 
 import (
+	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -285,3 +288,460 @@ func TestMarshalUnmarshalUsingStateAnnotation(t *testing.T) {
 		t.Errorf("Expected '', got %+v", decoded.Other)
 	}
 }
+
+// TestDirtyTrackingSkipsIdenticalSave ensures unchanged state is not rewritten.
+func TestDirtyTrackingSkipsIdenticalSave(t *testing.T) {
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithEnvelope(),
+		WithDirtyTracking(),
+	)
+	assert.NoError(t, err)
+
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	assert.NoError(t, sm.Save(data))
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(sm.FilePath, old, old))
+
+	assert.NoError(t, sm.Save(data))
+	info, err := os.Stat(sm.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, old, info.ModTime())
+
+	data.Age = 31
+	assert.NoError(t, sm.Save(data))
+	info, err = os.Stat(sm.FilePath)
+	assert.NoError(t, err)
+	assert.NotEqual(t, old, info.ModTime())
+
+	// removed file is always rewritten
+	assert.NoError(t, os.Remove(sm.FilePath))
+	assert.NoError(t, sm.Save(data))
+	assert.FileExists(t, sm.FilePath)
+}
+
+// TestUpdate ensures Update loads, mutates and saves the state.
+func TestUpdate(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	data := &TestStruct{Name: "initial"}
+	assert.NoError(t, sm.Update(data, func() error {
+		data.Age++
+		return nil
+	}))
+
+	assert.NoError(t, sm.Update(data, func() error {
+		data.Age++
+		return nil
+	}))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "initial", loaded.Name)
+	assert.Equal(t, 2, loaded.Age)
+
+	// errors from fn prevent the save
+	assert.Error(t, sm.Update(data, func() error {
+		data.Age = 100
+		return assert.AnError
+	}))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, 2, loaded.Age)
+}
+
+// TestConcurrentUpdateWithFileLock ensures managers sharing a file do not lose updates.
+func TestConcurrentUpdateWithFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	managers := make([]*StateManager, 2)
+	for i := range managers {
+		sm, err := NewStateManager(
+			WithFilePath(path),
+			WithSerializationType(JSON),
+			WithFileLock(),
+		)
+		assert.NoError(t, err)
+		managers[i] = sm
+	}
+
+	var wg sync.WaitGroup
+	iterations := 25
+
+	for i := 0; i < iterations; i++ {
+		for _, sm := range managers {
+			wg.Add(1)
+			go func(sm *StateManager) {
+				defer wg.Done()
+				data := &TestStruct{}
+				assert.NoError(t, sm.Update(data, func() error {
+					data.Age++
+					return nil
+				}))
+			}(sm)
+		}
+	}
+
+	wg.Wait()
+
+	loaded := &TestStruct{}
+	assert.NoError(t, managers[0].Load(loaded))
+	assert.Equal(t, iterations*len(managers), loaded.Age)
+}
+
+// TestStateNestedStructs ensures nested structs round-trip honoring their state tags.
+func TestStateNestedStructs(t *testing.T) {
+	type Address struct {
+		City  string `state:"city"`
+		Zip   int    `state:"zip"`
+		Other string
+	}
+
+	type Plain struct {
+		Label string
+	}
+
+	type Person struct {
+		Name    string         `state:"name"`
+		Home    Address        `state:"home"`
+		Plain   Plain          `state:"plain"`
+		Tags    []string       `state:"tags"`
+		Scores  map[string]int `state:"scores"`
+		private string         `state:"private"`
+	}
+
+	original := Person{
+		Name:    "Alice",
+		Home:    Address{City: "Portland", Zip: 97201, Other: "skip"},
+		Plain:   Plain{Label: "x"},
+		Tags:    []string{"a", "b"},
+		Scores:  map[string]int{"one": 1},
+		private: "hidden",
+	}
+
+	data, err := stateMarshal(&original)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "city: Portland")
+	assert.NotContains(t, string(data), "skip")
+	assert.NotContains(t, string(data), "hidden")
+
+	var decoded Person
+	assert.NoError(t, stateUnmarshal(data, &decoded))
+	assert.Equal(t, "Alice", decoded.Name)
+	assert.Equal(t, Address{City: "Portland", Zip: 97201}, decoded.Home)
+	assert.Equal(t, original.Plain, decoded.Plain)
+	assert.Equal(t, original.Tags, decoded.Tags)
+	assert.Equal(t, original.Scores, decoded.Scores)
+	assert.Empty(t, decoded.private)
+}
+
+type embeddedBase struct {
+	ID   string `json:"id" state:"id"`
+	Kind string `json:"kind" state:"kind"`
+}
+
+type EmbeddedAudit struct {
+	Owner string `json:"owner" state:"owner"`
+}
+
+type EmbeddedMeta struct {
+	Version int `json:"version" state:"version"`
+}
+
+type embeddedState struct {
+	embeddedBase
+	*EmbeddedAudit `state:",inline"`
+	EmbeddedMeta   `json:"meta" state:"meta"`
+	Extra          EmbeddedMeta `json:"extra" state:",inline"`
+	Kind           string       `json:"kind" state:"kind"`
+}
+
+// TestStateEmbeddedStructs ensures embedded structs are inlined or nested by their annotation.
+func TestStateEmbeddedStructs(t *testing.T) {
+	original := embeddedState{
+		embeddedBase:  embeddedBase{ID: "a", Kind: "shadowed"},
+		EmbeddedAudit: &EmbeddedAudit{Owner: "alice"},
+		EmbeddedMeta:  EmbeddedMeta{Version: 2},
+		Extra:         EmbeddedMeta{Version: 3},
+		Kind:          "parent",
+	}
+
+	data, err := stateMarshal(&original)
+	assert.NoError(t, err)
+	assert.Equal(t, "id: a\nkind: parent\nmeta:\n    version: 2\nowner: alice\nversion: 3\n", string(data))
+
+	var decoded embeddedState
+	assert.NoError(t, stateUnmarshal(data, &decoded))
+	assert.Equal(t, "a", decoded.ID)
+	assert.Equal(t, "parent", decoded.Kind)
+	assert.Equal(t, "parent", decoded.embeddedBase.Kind)
+	assert.Equal(t, &EmbeddedAudit{Owner: "alice"}, decoded.EmbeddedAudit)
+	assert.Equal(t, 2, decoded.EmbeddedMeta.Version)
+	assert.Equal(t, 3, decoded.Extra.Version)
+
+	// nil inlined pointers stay nil without any of their keys
+	decoded = embeddedState{}
+	assert.NoError(t, stateUnmarshal([]byte("id: b\n"), &decoded))
+	assert.Nil(t, decoded.EmbeddedAudit)
+
+	for _, st := range []SerializationType{JSON, STATE} {
+		sm, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state")), WithSerializationType(st), WithStrictDecoding())
+		assert.NoError(t, err)
+		assert.NoError(t, sm.Save(&original))
+
+		loaded := embeddedState{}
+		assert.NoError(t, sm.Load(&loaded), st)
+		assert.Equal(t, "a", loaded.ID, st)
+		assert.Equal(t, &EmbeddedAudit{Owner: "alice"}, loaded.EmbeddedAudit, st)
+	}
+}
+
+// TestStateMarshalNonStruct ensures non-struct values are rejected.
+func TestStateMarshalNonStruct(t *testing.T) {
+	_, err := stateMarshal(42)
+	assert.Error(t, err)
+
+	var n int
+	assert.Error(t, stateUnmarshal([]byte("a: 1"), &n))
+}
+
+// TestStateTimeAndDuration ensures time.Time and time.Duration fields round-trip.
+func TestStateTimeAndDuration(t *testing.T) {
+	type Timed struct {
+		Created  time.Time     `state:"created"`
+		Timeout  time.Duration `state:"timeout"`
+		Interval time.Duration `state:"interval"`
+	}
+
+	created := time.Date(2024, 5, 1, 12, 30, 15, 500, time.UTC)
+	original := Timed{
+		Created: created,
+		Timeout: 90 * time.Second,
+	}
+
+	data, err := stateMarshal(original)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "timeout: 1m30s")
+	assert.Contains(t, string(data), "2024-05-01T12:30:15.0000005Z")
+
+	var decoded Timed
+	assert.NoError(t, stateUnmarshal(data, &decoded))
+	assert.True(t, created.Equal(decoded.Created))
+	assert.Equal(t, original.Timeout, decoded.Timeout)
+
+	// plain YAML timestamps and nanoseconds are accepted too
+	assert.NoError(t, stateUnmarshal([]byte("created: 2024-05-01T12:30:15Z\ninterval: 1000\n"), &decoded))
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC), decoded.Created.UTC())
+	assert.Equal(t, time.Microsecond, decoded.Interval)
+}
+
+// TestStatePointerFields ensures pointer fields, including pointers to structs, round-trip.
+func TestStatePointerFields(t *testing.T) {
+	type Inner struct {
+		Value string `state:"value"`
+	}
+
+	type Optional struct {
+		Text    *string    `state:"text"`
+		Number  *int       `state:"number"`
+		Inner   *Inner     `state:"inner"`
+		Expires *time.Time `state:"expires"`
+		Missing *string    `state:"missing"`
+	}
+
+	text := "hello"
+	number := 7
+	expires := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	original := Optional{
+		Text:    &text,
+		Number:  &number,
+		Inner:   &Inner{Value: "nested"},
+		Expires: &expires,
+	}
+
+	data, err := stateMarshal(&original)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "value: nested")
+
+	decoded := Optional{Missing: &text}
+	assert.NoError(t, stateUnmarshal(data, &decoded))
+	assert.Equal(t, "hello", *decoded.Text)
+	assert.Equal(t, 7, *decoded.Number)
+	assert.Equal(t, "nested", decoded.Inner.Value)
+	assert.True(t, expires.Equal(*decoded.Expires))
+	assert.Nil(t, decoded.Missing)
+}
+
+// TestLoadOrCreate ensures the default state is created when missing and loaded afterwards.
+func TestLoadOrCreate(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	calls := 0
+	init := func() interface{} {
+		calls++
+		return &TestStruct{Name: "default", Age: 1}
+	}
+
+	data := &TestStruct{}
+	assert.NoError(t, sm.LoadOrCreate(data, init))
+	assert.Equal(t, &TestStruct{Name: "default", Age: 1}, data)
+	assert.True(t, sm.Exists())
+
+	data.Age = 2
+	assert.NoError(t, sm.Save(data))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.LoadOrCreate(loaded, init))
+	assert.Equal(t, 2, loaded.Age)
+	assert.Equal(t, 1, calls)
+}
+
+// TestLoadOrCreateInit ensures nil and mismatched init values are handled.
+func TestLoadOrCreateInit(t *testing.T) {
+	sm := setupTempStateManager(t, YAML)
+
+	prepopulated := &TestStruct{Name: "prepopulated"}
+	assert.NoError(t, sm.LoadOrCreate(prepopulated, nil))
+	assert.True(t, sm.Exists())
+
+	other := setupTempStateManager(t, YAML)
+	assert.Error(t, other.LoadOrCreate(&TestStruct{}, func() interface{} {
+		return "not a struct"
+	}))
+	assert.False(t, other.Exists())
+
+	assert.NoError(t, other.LoadOrCreate(&TestStruct{}, func() interface{} {
+		return TestStruct{Name: "value"}
+	}))
+}
+
+// TestDelete ensures the state file is removed and deleting twice is fine.
+func TestDelete(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.True(t, sm.Exists())
+
+	assert.NoError(t, sm.Delete())
+	assert.False(t, sm.Exists())
+	assert.NoError(t, sm.Delete())
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrNotFound)
+}
+
+// TestDeleteWithBackups ensures deleted state can be restored from backups.
+func TestDeleteWithBackups(t *testing.T) {
+	sm := setupBackupStateManager(t, 2)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+
+	assert.NoError(t, sm.Delete())
+	assert.False(t, sm.Exists())
+
+	assert.NoError(t, sm.Restore(1))
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+}
+
+// TestClear ensures the target is reset and the empty state persisted.
+func TestClear(t *testing.T) {
+	sm := setupTempStateManager(t, YAML)
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	assert.NoError(t, sm.Save(data))
+
+	assert.NoError(t, sm.Clear(data))
+	assert.Equal(t, &TestStruct{}, data)
+
+	loaded := &TestStruct{Name: "x"}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, &TestStruct{}, loaded)
+
+	assert.Error(t, sm.Clear(TestStruct{}))
+}
+
+// TestFileMode ensures new files default to 0600 and custom modes are applied.
+func TestFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions only")
+	}
+
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	info, err := os.Stat(sm.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	shared, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "shared")),
+		WithFileMode(0640),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, shared.Save(&TestStruct{Name: "a"}))
+	info, err = os.Stat(shared.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+// TestFileModeFixedOnLoad ensures overly permissive existing files are tightened.
+func TestFileModeFixedOnLoad(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions only")
+	}
+
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.NoError(t, os.Chmod(sm.FilePath, 0644))
+
+	assert.NoError(t, sm.Load(&TestStruct{}))
+	info, err := os.Stat(sm.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+// TestCreateDirs ensures missing parent directories are created only when enabled.
+func TestCreateDirs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "b", "test_state")
+
+	sm, err := NewStateManager(WithFilePath(path))
+	assert.NoError(t, err)
+	assert.Error(t, sm.Save(&TestStruct{Name: "a"}))
+
+	sm, err = NewStateManager(WithFilePath(path), WithCreateDirs())
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.FileExists(t, path)
+}
+
+// TestConcurrentLoadsDoNotSerialize ensures multiple Loads can run at the same time.
+func TestConcurrentLoadsDoNotSerialize(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+
+	readers := 3
+	var inside sync.WaitGroup
+	inside.Add(readers)
+	all := make(chan struct{})
+	go func() {
+		inside.Wait()
+		close(all)
+	}()
+
+	sm.OnBeforeLoad(func(_ interface{}) error {
+		inside.Done()
+		select {
+		case <-all:
+			return nil
+		case <-time.After(5 * time.Second):
+			return assert.AnError
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, sm.Load(&TestStruct{}))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"reflect"
+)
+
+// Marshaler is implemented by types that encode themselves for the STATE and BIN
+// serialization types. It is honored for whole structs in both formats and for
+// individual fields in the STATE format.
+type Marshaler interface {
+	MarshalState() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves from the output of
+// their MarshalState method.
+type Unmarshaler interface {
+	UnmarshalState([]byte) error
+}
+
+// marshalerOf returns the Marshaler implemented by the value or its address.
+func marshalerOf(v reflect.Value) (Marshaler, bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if m, ok := v.Interface().(Marshaler); ok {
+		return m, true
+	}
+	if v.CanAddr() {
+		if m, ok := v.Addr().Interface().(Marshaler); ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// unmarshalerOf returns the Unmarshaler implemented by the address of the value.
+func unmarshalerOf(v reflect.Value) (Unmarshaler, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	u, ok := v.Addr().Interface().(Unmarshaler)
+	return u, ok
+}
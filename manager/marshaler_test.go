@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Color is an enum persisted by name.
+type Color int
+
+const (
+	Red Color = iota
+	Green
+)
+
+func (c Color) MarshalState() ([]byte, error) {
+	switch c {
+	case Red:
+		return []byte("red"), nil
+	case Green:
+		return []byte("green"), nil
+	default:
+		return nil, fmt.Errorf("unknown color %d", c)
+	}
+}
+
+func (c *Color) UnmarshalState(b []byte) error {
+	switch string(b) {
+	case "red":
+		*c = Red
+	case "green":
+		*c = Green
+	default:
+		return fmt.Errorf("unknown color %q", b)
+	}
+	return nil
+}
+
+// Pair encodes itself as a single line for the whole struct.
+type Pair struct {
+	Key   string
+	Value string
+}
+
+func (p *Pair) MarshalState() ([]byte, error) {
+	return []byte(p.Key + "=" + p.Value), nil
+}
+
+func (p *Pair) UnmarshalState(b []byte) error {
+	parts := strings.SplitN(string(b), "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid pair %q", b)
+	}
+	p.Key, p.Value = parts[0], parts[1]
+	return nil
+}
+
+// TestStateFieldMarshaler ensures field level Marshaler and Unmarshaler are honored.
+func TestStateFieldMarshaler(t *testing.T) {
+	type Palette struct {
+		Primary   Color  `state:"primary"`
+		Secondary *Color `state:"secondary"`
+	}
+
+	green := Green
+	original := &Palette{Primary: Green, Secondary: &green}
+
+	data, err := stateMarshal(original)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "primary: green")
+
+	var decoded Palette
+	assert.NoError(t, stateUnmarshal(data, &decoded))
+	assert.Equal(t, Green, decoded.Primary)
+	assert.Equal(t, Green, *decoded.Secondary)
+
+	_, err = stateMarshal(&Palette{Primary: Color(9)})
+	assert.Error(t, err)
+}
+
+// TestWholeStructMarshaler ensures struct level Marshaler is used by STATE and BIN.
+func TestWholeStructMarshaler(t *testing.T) {
+	for _, st := range []SerializationType{STATE, BIN} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupTempStateManager(t, st)
+			assert.NoError(t, sm.Save(&Pair{Key: "a", Value: "b"}))
+
+			c, err := os.ReadFile(sm.FilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, "a=b", string(c))
+
+			var loaded Pair
+			assert.NoError(t, sm.Load(&loaded))
+			assert.Equal(t, Pair{Key: "a", Value: "b"}, loaded)
+		})
+	}
+}
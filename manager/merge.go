@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"reflect"
+)
+
+// WithMergeOnLoad merges the persisted state into the struct passed to Load
+// instead of relying on the format to leave absent fields alone: fields
+// missing from the file keep their current value, nested structs and maps are
+// merged and all other present values replace the current ones. BIN state has
+// no notion of absent fields, so only its non-zero values are merged. PROTO
+// messages are decoded as is.
+func WithMergeOnLoad() StateOption {
+	return func(s *StateManager) {
+		s.mergeOnLoad = true
+	}
+}
+
+// unmarshal decodes the payload into data, merging it when configured.
+func (s *StateManager) unmarshal(st SerializationType, payload []byte, data interface{}) error {
+	v := reflect.ValueOf(data)
+
+	// maps kept as state are replaced rather than merged into
+	if !s.mergeOnLoad && v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Map {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+
+	if !s.mergeOnLoad || st == PROTO || v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct || decodesItself(v.Elem().Type()) {
+		return s.demarshal(st, payload, data)
+	}
+
+	decoded := reflect.New(v.Elem().Type())
+	if err := s.demarshal(st, payload, decoded.Interface()); err != nil {
+		return err
+	}
+
+	// the keys of the document tell which fields are present
+	var doc map[string]interface{}
+	if st == JSON || st == YAML || st == STATE {
+		doc, _ = parseDocument(st, payload)
+	}
+
+	mergeStruct(st, s.fieldNames, v.Elem(), decoded.Elem(), doc)
+	return nil
+}
+
+// mergeStruct copies the fields of src present in the document into dst.
+// Without a document the non-zero fields are present.
+func mergeStruct(st SerializationType, names FieldNameMapper, dst, src reflect.Value, doc map[string]interface{}) {
+	t := dst.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, inline := fieldKeyNamed(st, field, names)
+
+		if inline && field.Type.Kind() == reflect.Struct {
+			mergeStruct(st, names, dst.Field(i), src.Field(i), doc)
+			continue
+		}
+		if key == "" || !dst.Field(i).CanSet() {
+			continue
+		}
+
+		var sub interface{}
+		present := !src.Field(i).IsZero()
+		if doc != nil {
+			sub, present = doc[key]
+		}
+
+		if present {
+			mergeValue(st, names, dst.Field(i), src.Field(i), sub, doc != nil)
+		}
+	}
+}
+
+// mergeValue merges src into dst, recursing into nested structs and maps.
+func mergeValue(st SerializationType, names FieldNameMapper, dst, src reflect.Value, sub interface{}, hasDoc bool) {
+	subDoc, isMap := sub.(map[string]interface{})
+	nested := isMap || !hasDoc
+
+	switch {
+	case dst.Kind() == reflect.Struct && nested && !decodesItself(dst.Type()):
+		mergeStruct(st, names, dst, src, subDoc)
+	case dst.Kind() == reflect.Ptr && !dst.IsNil() && !src.IsNil() && nested &&
+		dst.Elem().Kind() == reflect.Struct && !decodesItself(dst.Elem().Type()):
+		mergeStruct(st, names, dst.Elem(), src.Elem(), subDoc)
+	case dst.Kind() == reflect.Map && !dst.IsNil() && !src.IsNil():
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), iter.Value())
+		}
+	default:
+		dst.Set(src)
+	}
+}
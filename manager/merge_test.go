@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mergeNested struct {
+	Host string `json:"host" yaml:"host" state:"host"`
+	Port int    `json:"port" yaml:"port" state:"port"`
+}
+
+type mergeState struct {
+	Name    string            `json:"name" yaml:"name" state:"name"`
+	Retries int               `json:"retries" yaml:"retries" state:"retries"`
+	Server  mergeNested       `json:"server" yaml:"server" state:"server"`
+	Backup  *mergeNested      `json:"backup" yaml:"backup" state:"backup"`
+	Labels  map[string]string `json:"labels" yaml:"labels" state:"labels"`
+}
+
+// TestWithMergeOnLoad ensures fields absent from the file keep their values.
+func TestWithMergeOnLoad(t *testing.T) {
+	files := map[SerializationType]string{
+		JSON:  `{"name": "saved", "server": {"host": "example.com"}, "backup": {"port": 2}, "labels": {"b": "2"}}`,
+		YAML:  "name: saved\nserver:\n  host: example.com\nbackup:\n  port: 2\nlabels:\n  b: \"2\"\n",
+		STATE: "name: saved\nserver:\n  host: example.com\nbackup:\n  port: 2\nlabels:\n  b: \"2\"\n",
+	}
+
+	for st, content := range files {
+		path := setupTempStateManager(t, st).FilePath
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithMergeOnLoad())
+		assert.NoError(t, err)
+
+		data := &mergeState{
+			Name:    "default",
+			Retries: 3,
+			Server:  mergeNested{Host: "localhost", Port: 8080},
+			Backup:  &mergeNested{Host: "backup", Port: 1},
+			Labels:  map[string]string{"a": "1"},
+		}
+		assert.NoError(t, sm.Load(data), st)
+		assert.Equal(t, &mergeState{
+			Name:    "saved",
+			Retries: 3,
+			Server:  mergeNested{Host: "example.com", Port: 8080},
+			Backup:  &mergeNested{Host: "backup", Port: 2},
+			Labels:  map[string]string{"a": "1", "b": "2"},
+		}, data, st)
+	}
+}
+
+// TestMergeOnLoadBinary ensures only non-zero values are merged for BIN.
+func TestMergeOnLoadBinary(t *testing.T) {
+	path := setupTempStateManager(t, BIN).FilePath
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(BIN), WithMergeOnLoad())
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&mergeState{Name: "saved", Server: mergeNested{Host: "example.com"}}))
+
+	data := &mergeState{Retries: 3, Server: mergeNested{Port: 8080}}
+	assert.NoError(t, sm.Load(data))
+	assert.Equal(t, "saved", data.Name)
+	assert.Equal(t, 3, data.Retries)
+	assert.Equal(t, mergeNested{Host: "example.com", Port: 8080}, data.Server)
+}
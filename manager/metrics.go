@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"time"
+)
+
+// Operations reported to Metrics.
+const (
+	OperationSave = "save"
+	OperationLoad = "load"
+)
+
+// Observation describes a single save or load of the state.
+type Observation struct {
+	Operation string
+	Format    SerializationType
+	Backend   string
+	Duration  time.Duration
+	Size      int
+	Err       error
+}
+
+// Metrics records the observations of the manager, see WithMetrics and the
+// metrics package for a collector exporting them in the Prometheus format.
+type Metrics interface {
+	Observe(o Observation)
+}
+
+// MetricsFunc adapts a function to Metrics.
+type MetricsFunc func(o Observation)
+
+// Observe calls the function.
+func (f MetricsFunc) Observe(o Observation) {
+	f(o)
+}
+
+// WithMetrics reports every save and load with its duration, size and error.
+// Debounced saves are reported once they are written.
+func WithMetrics(m Metrics) StateOption {
+	return func(s *StateManager) {
+		s.metrics = m
+	}
+}
+
+// observe reports the operation started at the given time to the metrics and the logger.
+func (s *StateManager) observe(operation string, start time.Time, size int, err error) {
+	if s.metrics == nil && s.logger == nil {
+		return
+	}
+
+	o := Observation{
+		Operation: operation,
+		Format:    s.SerializationType,
+		Backend:   s.backend(),
+		Duration:  s.now().Sub(start),
+		Size:      size,
+		Err:       err,
+	}
+
+	if s.metrics != nil {
+		s.metrics.Observe(o)
+	}
+	s.logObservation(o)
+}
+
+// backend returns the name of the storage of the state.
+func (s *StateManager) backend() string {
+	switch s.files().(type) {
+	case osFS:
+		return "file"
+	case *storeFS:
+		return "store"
+	}
+	return "fs"
+}
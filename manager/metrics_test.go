@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithMetrics ensures saves and loads are reported with their size and error.
+func TestWithMetrics(t *testing.T) {
+	var observed []Observation
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(YAML),
+		WithMetrics(MetricsFunc(func(o Observation) { observed = append(observed, o) })),
+	)
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.True(t, errors.Is(sm.Load(loaded), ErrNotFound))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+	assert.NoError(t, sm.Load(loaded))
+
+	assert.Len(t, observed, 3)
+	for i, op := range []string{OperationLoad, OperationSave, OperationLoad} {
+		assert.Equal(t, op, observed[i].Operation)
+		assert.Equal(t, YAML, observed[i].Format)
+		assert.Equal(t, "file", observed[i].Backend)
+	}
+	assert.True(t, errors.Is(observed[0].Err, ErrNotFound))
+	assert.Zero(t, observed[0].Size)
+	assert.NoError(t, observed[1].Err)
+	assert.Positive(t, observed[1].Size)
+	assert.Equal(t, observed[1].Size, observed[2].Size)
+}
+
+// TestWithMetricsDebounce ensures debounced saves are reported once written.
+func TestWithMetricsDebounce(t *testing.T) {
+	saves := 0
+	sm := setupDebounceStateManager(t, time.Hour, WithMetrics(MetricsFunc(func(o Observation) {
+		if o.Operation == OperationSave {
+			saves++
+		}
+	})))
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Bob"}))
+	assert.Zero(t, saves)
+
+	assert.NoError(t, sm.Flush())
+	assert.Equal(t, 1, saves)
+}
+
+// TestWithMetricsStreaming ensures streamed saves and loads are reported once with their size.
+func TestWithMetricsStreaming(t *testing.T) {
+	var observed []Observation
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithStreaming(),
+		WithMetrics(MetricsFunc(func(o Observation) { observed = append(observed, o) })),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+	assert.NoError(t, sm.Load(&TestStruct{}))
+
+	assert.Len(t, observed, 2)
+	assert.Equal(t, OperationSave, observed[0].Operation)
+	assert.Positive(t, observed[0].Size)
+	assert.Equal(t, OperationLoad, observed[1].Operation)
+}
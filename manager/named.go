@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Named returns the manager of the named state. Each named state is persisted
+// to its own file next to FilePath, using the same naming as WithStateKey,
+// and shares the options of this manager.
+func (s *StateManager) Named(name string) (*StateManager, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid state name: %q", name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n, ok := s.named[name]; ok {
+		return n, nil
+	}
+
+	if s.named == nil {
+		s.named = make(map[string]*StateManager)
+	}
+
+	n := &StateManager{
+		FilePath:          fmt.Sprintf("%s-%s", s.FilePath, name),
+		SerializationType: s.SerializationType,
+		config:            s.config,
+	}
+	s.named[name] = n
+
+	return n, nil
+}
+
+// SaveNamed persists the given struct as the named state.
+func (s *StateManager) SaveNamed(name string, data interface{}) error {
+	n, err := s.Named(name)
+	if err != nil {
+		return err
+	}
+	return n.Save(data)
+}
+
+// LoadNamed reads the named state into the given struct.
+func (s *StateManager) LoadNamed(name string, data interface{}) error {
+	n, err := s.Named(name)
+	if err != nil {
+		return err
+	}
+	return n.Load(data)
+}
+
+// validName checks that the name can be safely used as a file name.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
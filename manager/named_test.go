@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSaveAndLoadNamed ensures named states are persisted independently.
+func TestSaveAndLoadNamed(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	session := &TestStruct{Name: "session", Age: 1}
+	prefs := &TestStruct{Name: "prefs", Flag: true}
+
+	assert.NoError(t, sm.SaveNamed("session", session))
+	assert.NoError(t, sm.SaveNamed("prefs", prefs))
+	assert.False(t, sm.Exists())
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.LoadNamed("session", loaded))
+	assert.Equal(t, session, loaded)
+
+	loaded = &TestStruct{}
+	assert.NoError(t, sm.LoadNamed("prefs", loaded))
+	assert.Equal(t, prefs, loaded)
+
+	n, err := sm.Named("prefs")
+	assert.NoError(t, err)
+	assert.True(t, n.Exists())
+	assert.Equal(t, sm.FilePath+"-prefs", n.FilePath)
+	assert.Equal(t, sm.SerializationType, n.SerializationType)
+
+	same, err := sm.Named("prefs")
+	assert.NoError(t, err)
+	assert.Same(t, n, same)
+}
+
+// TestNamedInvalid ensures invalid names are rejected.
+func TestNamedInvalid(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		_, err := sm.Named(name)
+		assert.Error(t, err, name)
+		assert.Error(t, sm.SaveNamed(name, &TestStruct{}), name)
+		assert.Error(t, sm.LoadNamed(name, &TestStruct{}), name)
+	}
+}
@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// KDFArgon2id is the key derivation recorded in the envelope of passphrase protected state
+	KDFArgon2id = "argon2id"
+
+	// saltSize is the size of the random per-file salt in bytes
+	saltSize = 16
+
+	// maxArgon2Memory bounds the memory in KiB a file can make key derivation use
+	maxArgon2Memory = 4 * 1024 * 1024
+)
+
+// Argon2Params are the Argon2id parameters deriving the key from a
+// passphrase. They are recorded in the envelope next to the salt, so files
+// stay readable when the parameters are tuned later.
+type Argon2Params struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultArgon2Params are the second recommended option of RFC 9106: three
+// passes over 64 MiB of memory with four lanes. Files saved without
+// parameters in their envelope were derived with these.
+var DefaultArgon2Params = Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// passphrase derives the encryption keys and caches the one of the last salt.
+type passphrase struct {
+	secret []byte
+	params Argon2Params
+	mutex  sync.Mutex
+	salt   []byte
+	used   Argon2Params
+	key    []byte
+}
+
+// WithPassphrase encrypts the state with a key derived from the passphrase
+// using Argon2id and a random per-file salt stored in the envelope.
+// It takes precedence over WithEncryptionKey when saving.
+func WithPassphrase(secret string) StateOption {
+	return func(s *StateManager) {
+		if secret == "" {
+			s.optionErr = errors.New("passphrase must not be empty")
+			return
+		}
+		params := DefaultArgon2Params
+		if s.passphrase != nil {
+			params = s.passphrase.params
+		}
+		s.passphrase = &passphrase{secret: []byte(secret), params: params}
+	}
+}
+
+// WithArgon2Params sets the Argon2id parameters keys are derived from the
+// passphrase with on save, DefaultArgon2Params unless set. Files are read
+// with the parameters recorded in their envelope. Use after WithPassphrase.
+func WithArgon2Params(params Argon2Params) StateOption {
+	return func(s *StateManager) {
+		if s.passphrase == nil {
+			s.optionErr = errors.New("argon2 parameters require a passphrase, use WithPassphrase")
+			return
+		}
+		if err := params.validate(); err != nil {
+			s.optionErr = err
+			return
+		}
+		s.passphrase.params = params
+	}
+}
+
+// validate checks the parameters are usable, also when read from a file.
+func (p Argon2Params) validate() error {
+	if p.Time < 1 || p.Threads < 1 || p.Memory < 8*uint32(p.Threads) || p.Memory > maxArgon2Memory {
+		return fmt.Errorf("invalid argon2 parameters: time %d, memory %d KiB, threads %d", p.Time, p.Memory, p.Threads)
+	}
+	return nil
+}
+
+// derive returns the encryption key for the salt and parameters.
+func (p *passphrase) derive(salt []byte, params Argon2Params) []byte {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.key != nil && bytes.Equal(p.salt, salt) && p.used == params {
+		return p.key
+	}
+
+	p.key = argon2.IDKey(p.secret, salt, params.Time, params.Memory, params.Threads, EncryptionKeySize)
+	p.salt = append([]byte(nil), salt...)
+	p.used = params
+	return p.key
+}
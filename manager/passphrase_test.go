@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithPassphrase ensures the state is encrypted with a passphrase-derived key.
+func TestWithPassphrase(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+
+	_, err := NewStateManager(WithFilePath(path), WithPassphrase(""))
+	assert.Error(t, err)
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithPassphrase("secret"))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(c), "Alice")
+
+	env, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, KDFArgon2id, env.KDF)
+	assert.Len(t, env.Salt, saltSize)
+
+	// the salt is kept across saves
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Bob"}))
+	next, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, env.Salt, next.Salt)
+
+	other, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithPassphrase("secret"))
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, other.Load(loaded))
+	assert.Equal(t, "Bob", loaded.Name)
+
+	wrong, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithPassphrase("wrong"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, wrong.Load(&TestStruct{}), ErrDecryption)
+
+	key, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithEncryptionKey(testKey(1)))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, key.Load(&TestStruct{}), ErrDecryption)
+}
+
+// TestWithArgon2Params ensures the parameters are recorded in the envelope and files are read with theirs.
+func TestWithArgon2Params(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+	light := Argon2Params{Time: 1, Memory: 64, Threads: 1}
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithPassphrase("secret"), WithArgon2Params(light))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	env, err := sm.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, &light, env.Argon2)
+
+	// tuned parameters still read files saved with others
+	tuned, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithPassphrase("secret"))
+	assert.NoError(t, err)
+	loaded := &TestStruct{}
+	assert.NoError(t, tuned.Load(loaded))
+	assert.Equal(t, "Alice", loaded.Name)
+
+	assert.NoError(t, tuned.Save(loaded))
+	next, err := tuned.Envelope()
+	assert.NoError(t, err)
+	assert.Equal(t, &DefaultArgon2Params, next.Argon2)
+	assert.NotEqual(t, env.Salt, next.Salt)
+	assert.NoError(t, sm.Load(&TestStruct{}))
+
+	_, err = NewStateManager(WithArgon2Params(light))
+	assert.Error(t, err)
+	_, err = NewStateManager(WithPassphrase("secret"), WithArgon2Params(Argon2Params{Time: 1, Memory: 64}))
+	assert.Error(t, err)
+}
+
+// TestWithPassphraseLegacyParams ensures files without parameters in the envelope are read with the defaults.
+func TestWithPassphraseLegacyParams(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithPassphrase("secret"))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	env, payload, err := unwrapEnvelope(c)
+	assert.NoError(t, err)
+	env.Argon2 = nil
+	c, err = wrapEnvelope(env, payload)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, c, 0600))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "Alice", loaded.Name)
+
+	// parameters in the file are bounded
+	env.Argon2 = &Argon2Params{Time: 1, Memory: 64}
+	c, err = wrapEnvelope(env, payload)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, c, 0600))
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrDecryption)
+}
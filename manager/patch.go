@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Patch applies the RFC 7386 JSON Merge Patch to the persisted state: objects
+// are merged recursively, null removes a key and any other value replaces it.
+// Like Set, it works on the persisted keys without decoding them into a struct
+// and is supported for JSON, YAML and STATE serialization types.
+func (s *StateManager) Patch(patch []byte) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return fmt.Errorf("failed to decode patch: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{})
+	if s.exists(s.FilePath) {
+		if values, err = s.loadValues(); err != nil {
+			return err
+		}
+	}
+
+	merged, ok := mergePatch(values, p).(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("patch must be an object, got %T", p)
+	}
+
+	return s.writeValues(merged)
+}
+
+// mergePatch applies the merge patch to the target value.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+
+	return t
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// patchState has a nested object to merge.
+type patchState struct {
+	Name  string            `json:"name" yaml:"name"`
+	Age   int               `json:"age" yaml:"age"`
+	Attrs map[string]string `json:"attrs" yaml:"attrs"`
+}
+
+// TestPatch ensures merge patches are applied to the persisted state.
+func TestPatch(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupTempStateManager(t, st)
+			assert.NoError(t, sm.Save(&patchState{Name: "a", Age: 1, Attrs: map[string]string{"x": "1", "y": "2"}}))
+
+			assert.NoError(t, sm.Patch([]byte(`{"age": 2, "attrs": {"x": null, "z": "3"}}`)))
+
+			loaded := &patchState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, &patchState{Name: "a", Age: 2, Attrs: map[string]string{"y": "2", "z": "3"}}, loaded)
+
+			assert.Error(t, sm.Patch([]byte(`[1]`)))
+			assert.Error(t, sm.Patch([]byte(`{`)))
+		})
+	}
+}
+
+// TestPatchWithoutFile ensures a patch creates the state when nothing was saved yet.
+func TestPatchWithoutFile(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.NoError(t, sm.Patch([]byte(`{"name": "a", "gone": null}`)))
+
+	loaded := &patchState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+
+	bin := setupTempStateManager(t, BIN)
+	assert.ErrorIs(t, bin.Patch([]byte(`{"name": "a"}`)), ErrUnsupportedFormat)
+}
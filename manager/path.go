@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// AppStateFileName is the name of the state file inside the application directory
+	AppStateFileName = "state"
+)
+
+// WithAppName stores the state in the platform specific state directory of the application:
+// $XDG_STATE_HOME/<app>/state (~/.local/state/<app>/state) on Linux and other unix systems,
+// ~/Library/Application Support/<app>/state on macOS and %LOCALAPPDATA%\<app>\state on Windows.
+// The application directory is created by NewStateManager.
+func WithAppName(app string) StateOption {
+	return func(s *StateManager) {
+		if !validName(app) {
+			s.optionErr = fmt.Errorf("invalid app name: %q", app)
+			return
+		}
+
+		dir, err := appStateDir(app)
+		if err != nil {
+			s.optionErr = err
+			return
+		}
+
+		s.FilePath = filepath.Join(dir, AppStateFileName)
+		s.appDir = dir
+	}
+}
+
+// appStateDir returns the platform specific state directory of the application.
+func appStateDir(app string) (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		dir := os.Getenv("LOCALAPPDATA")
+		if dir == "" {
+			return "", errors.New("LOCALAPPDATA is not defined")
+		}
+		return filepath.Join(dir, app), nil
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return filepath.Join(home, "Library", "Application Support", app), nil
+	default:
+		if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, app), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return filepath.Join(home, ".local", "state", app), nil
+	}
+}
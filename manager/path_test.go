@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithAppName ensures the XDG state directory is used and created.
+func TestWithAppName(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("XDG layout only")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+
+	sm, err := NewStateManager(
+		WithAppName("myapp"),
+		WithSerializationType(JSON),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "myapp", AppStateFileName), sm.FilePath)
+	assert.DirExists(t, filepath.Join(dir, "myapp"))
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.FileExists(t, sm.FilePath)
+}
+
+// TestWithAppNameFallback ensures ~/.local/state is used without XDG_STATE_HOME.
+func TestWithAppNameFallback(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("XDG layout only")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+
+	sm, err := NewStateManager(WithAppName("myapp"), WithStateKey("dev"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".local", "state", "myapp", AppStateFileName+"-dev"), sm.FilePath)
+}
+
+// TestWithAppNameInvalid ensures invalid names fail the manager creation.
+func TestWithAppNameInvalid(t *testing.T) {
+	_, err := NewStateManager(WithAppName("../escape"))
+	assert.Error(t, err)
+}
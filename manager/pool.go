@@ -0,0 +1,29 @@
+package manager
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity above which buffers are not returned to
+// the pool, so a single huge state does not pin its memory.
+const maxPooledBuffer = 4 << 20
+
+// bufferPool holds the buffers reused by the codecs.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns the buffer to the pool. Its content must not be used afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPooledEncoding ensures encoded payloads do not share the pooled buffers.
+func TestPooledEncoding(t *testing.T) {
+	for _, st := range []SerializationType{BIN, STATE} {
+		first, err := Marshal(st, &TestStruct{Name: "alice"})
+		assert.NoError(t, err)
+		saved := bytes.Clone(first)
+
+		_, err = Marshal(st, &TestStruct{Name: "bob", Age: 30})
+		assert.NoError(t, err)
+		assert.Equal(t, saved, first, st)
+	}
+}
+
+// TestPutBuffer ensures oversized buffers are not kept in the pool.
+func TestPutBuffer(t *testing.T) {
+	buf := getBuffer()
+	buf.Grow(maxPooledBuffer + 1)
+	putBuffer(buf)
+
+	buf = getBuffer()
+	assert.Zero(t, buf.Len())
+	putBuffer(buf)
+}
+
+// BenchmarkBinaryMarshal measures the BIN encoding of a small struct.
+func BenchmarkBinaryMarshal(b *testing.B) {
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := binaryMarshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
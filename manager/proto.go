@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// protoMessage is implemented by protobuf messages generated with their own
+// Marshal and Unmarshal methods, e.g. by gogo/protobuf.
+type protoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal(b []byte) error
+}
+
+// NewProtoCodec creates a PROTO codec from the functions of another protobuf
+// runtime, for messages that are neither a proto.Message nor generated with
+// their own Marshal and Unmarshal methods:
+//
+//	manager.RegisterCodec(manager.PROTO, manager.NewProtoCodec(runtime.Marshal, runtime.Unmarshal))
+//
+// Data which is not an M fails with ErrUnsupportedFormat.
+func NewProtoCodec[M any](marshal func(M) ([]byte, error), unmarshal func([]byte, M) error) Codec {
+	return codecFuncs{
+		encode: func(data interface{}) ([]byte, error) {
+			m, ok := data.(M)
+			if !ok {
+				return nil, notProtoMessage(data)
+			}
+			return marshal(m)
+		},
+		decode: func(b []byte, data interface{}) error {
+			m, ok := data.(M)
+			if !ok {
+				return notProtoMessage(data)
+			}
+			return unmarshal(b, m)
+		},
+	}
+}
+
+// protoMarshal encodes a proto.Message, with map entries in a deterministic
+// order, or a message implementing its own Marshal method.
+func protoMarshal(data interface{}) ([]byte, error) {
+	switch m := data.(type) {
+	case proto.Message:
+		return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	case protoMessage:
+		return m.Marshal()
+	default:
+		return nil, notProtoMessage(data)
+	}
+}
+
+// protoUnmarshal decodes a proto.Message or a message implementing its own
+// Unmarshal method.
+func protoUnmarshal(b []byte, data interface{}) error {
+	switch m := data.(type) {
+	case proto.Message:
+		return proto.Unmarshal(b, m)
+	case protoMessage:
+		return m.Unmarshal(b)
+	default:
+		return notProtoMessage(data)
+	}
+}
+
+// notProtoMessage describes data which the PROTO codec can not encode.
+func notProtoMessage(data interface{}) error {
+	return fmt.Errorf("%w: %T is not a protobuf message, register NewProtoCodec for generated messages", ErrUnsupportedFormat, data)
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchmarny/state/manager/internal/testpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+// testProto is a message with a string field 1 and a varint field 2 encoding
+// itself like gogo/protobuf generated code.
+type testProto struct {
+	Name string
+	Age  int64
+}
+
+func (m *testProto) Marshal() ([]byte, error) {
+	b := []byte{0x0a}
+	b = binary.AppendUvarint(b, uint64(len(m.Name)))
+	b = append(b, m.Name...)
+	b = append(b, 0x10)
+	return binary.AppendUvarint(b, uint64(m.Age)), nil
+}
+
+func (m *testProto) Unmarshal(b []byte) error {
+	*m = testProto{}
+	for len(b) > 0 {
+		tag := b[0]
+		v, n := binary.Uvarint(b[1:])
+		if n <= 0 {
+			return errors.New("invalid varint")
+		}
+		b = b[1+n:]
+
+		switch tag {
+		case 0x0a:
+			m.Name, b = string(b[:v]), b[v:]
+		case 0x10:
+			m.Age = int64(v)
+		default:
+			return fmt.Errorf("unknown tag %x", tag)
+		}
+	}
+	return nil
+}
+
+// TestProto ensures messages are persisted in the protobuf wire format.
+func TestProto(t *testing.T) {
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(PROTO),
+		WithMergeOnLoad(),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&testProto{Name: "Al", Age: 30}))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x0a, 0x02, 'A', 'l', 0x10, 30}, c)
+
+	loaded := &testProto{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, &testProto{Name: "Al", Age: 30}, loaded)
+
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), ErrUnsupportedFormat)
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrUnsupportedFormat)
+}
+
+// TestProtoMessage ensures generated proto.Message types are persisted in the protobuf wire format.
+func TestProtoMessage(t *testing.T) {
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(PROTO),
+	)
+	assert.NoError(t, err)
+
+	data := &testpb.Settings{
+		Name:   "Al",
+		Age:    30,
+		Tags:   []string{"a", "b"},
+		Labels: map[string]string{"env": "prod", "app": "state"},
+	}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+	want, err := proto.MarshalOptions{Deterministic: true}.Marshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, want, c)
+
+	loaded := &testpb.Settings{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.True(t, proto.Equal(data, loaded))
+}
+
+// message stands in for proto.Message of the protobuf runtime.
+type message interface {
+	Marshal() ([]byte, error)
+}
+
+// TestNewProtoCodec ensures codecs built from runtime functions accept only messages.
+func TestNewProtoCodec(t *testing.T) {
+	c := NewProtoCodec(func(m message) ([]byte, error) {
+		return m.Marshal()
+	}, func(b []byte, m message) error {
+		return m.(*testProto).Unmarshal(b)
+	})
+
+	b, err := c.Encode(&testProto{Name: "Al"})
+	assert.NoError(t, err)
+
+	loaded := &testProto{}
+	assert.NoError(t, c.Decode(b, loaded))
+	assert.Equal(t, "Al", loaded.Name)
+
+	_, err = c.Encode(&TestStruct{})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.ErrorIs(t, c.Decode(b, &TestStruct{}), ErrUnsupportedFormat)
+}
@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// PrunePolicy selects the backups and snapshots removed by Prune.
+// Zero values do not limit retention.
+type PrunePolicy struct {
+	// MaxAge removes backups and snapshots persisted longer ago.
+	MaxAge time.Duration
+	// KeepBackups is the number of most recent backup generations kept.
+	KeepBackups int
+	// KeepSnapshots is the number of most recent snapshots kept.
+	KeepSnapshots int
+}
+
+// WithAutoPrune prunes the backups and snapshots with the policy after every Save.
+func WithAutoPrune(policy PrunePolicy) StateOption {
+	return func(s *StateManager) {
+		if policy.MaxAge < 0 || policy.KeepBackups < 0 || policy.KeepSnapshots < 0 {
+			s.optionErr = errors.New("prune policy must not be negative")
+			return
+		}
+		s.autoPrune = &policy
+	}
+}
+
+// Prune removes the backups and snapshots beyond the retention of the policy
+// and returns the number of files removed. The current state is never removed.
+func (s *StateManager) Prune(policy PrunePolicy) (int, error) {
+	if err := s.writable(); err != nil {
+		return 0, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return s.prune(policy)
+}
+
+// prune removes the files selected by the policy. Caller must hold the lock.
+func (s *StateManager) prune(policy PrunePolicy) (int, error) {
+	entries, err := s.history()
+	if err != nil {
+		return 0, err
+	}
+
+	removed, snapshots := 0, 0
+	for _, e := range entries {
+		expired := policy.MaxAge > 0 && s.now().Sub(e.Timestamp) > policy.MaxAge
+
+		switch e.Source {
+		case HistoryBackup:
+			if !expired && (policy.KeepBackups == 0 || e.Generation <= policy.KeepBackups) {
+				continue
+			}
+		case HistorySnapshot:
+			// entries are ordered from the newest
+			snapshots++
+			if !expired && (policy.KeepSnapshots == 0 || snapshots <= policy.KeepSnapshots) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if err := s.files().Remove(e.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove %s: %w", e.path, err)
+		}
+		s.debug("pruned state file", slog.String("path", e.path))
+		removed++
+	}
+
+	return removed, nil
+}
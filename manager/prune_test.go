@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupPruneStateManager saves the state several times and takes snapshots.
+func setupPruneStateManager(t *testing.T, options ...StateOption) *StateManager {
+	t.Helper()
+
+	sm, err := NewStateManager(append([]StateOption{WithFilePath(filepath.Join(t.TempDir(), "state")),
+		WithSerializationType(JSON), WithBackups(5)}, options...)...)
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, sm.Save(&TestStruct{Age: i}))
+	}
+	for i, label := range []string{"a", "b", "c"} {
+		assert.NoError(t, sm.Snapshot(label))
+		old := time.Now().Add(-time.Duration(i) * time.Minute)
+		assert.NoError(t, os.Chtimes(filepath.Join(sm.snapshotDir(), label), old, old))
+	}
+
+	return sm
+}
+
+// TestPrune ensures backups and snapshots beyond the retention are removed.
+func TestPrune(t *testing.T) {
+	sm := setupPruneStateManager(t)
+
+	removed, err := sm.Prune(PrunePolicy{KeepBackups: 1, KeepSnapshots: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	assert.FileExists(t, sm.backupPath(1))
+	assert.NoFileExists(t, sm.backupPath(2))
+	assert.FileExists(t, filepath.Join(sm.snapshotDir(), "a"))
+	assert.FileExists(t, filepath.Join(sm.snapshotDir(), "b"))
+	assert.NoFileExists(t, filepath.Join(sm.snapshotDir(), "c"))
+	assert.FileExists(t, sm.FilePath)
+
+	removed, err = sm.Prune(PrunePolicy{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+}
+
+// TestPruneMaxAge ensures files persisted longer ago than the age are removed.
+func TestPruneMaxAge(t *testing.T) {
+	sm := setupPruneStateManager(t)
+
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(sm.backupPath(3), old, old))
+	assert.NoError(t, os.Chtimes(sm.FilePath, old, old))
+
+	removed, err := sm.Prune(PrunePolicy{MaxAge: 90 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.NoFileExists(t, sm.backupPath(3))
+	assert.FileExists(t, sm.backupPath(2))
+	assert.FileExists(t, filepath.Join(sm.snapshotDir(), "a"))
+	assert.FileExists(t, filepath.Join(sm.snapshotDir(), "b"))
+	assert.NoFileExists(t, filepath.Join(sm.snapshotDir(), "c"))
+	assert.FileExists(t, sm.FilePath)
+}
+
+// TestWithAutoPrune ensures every save prunes with the policy.
+func TestWithAutoPrune(t *testing.T) {
+	sm := setupPruneStateManager(t, WithAutoPrune(PrunePolicy{KeepBackups: 2}))
+
+	assert.FileExists(t, sm.backupPath(2))
+	assert.NoFileExists(t, sm.backupPath(3))
+
+	_, err := NewStateManager(WithAutoPrune(PrunePolicy{KeepBackups: -1}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,20 @@
+package manager
+
+// WithReadOnly makes the manager observe the state without ever changing it:
+// Load, Exists, Stat and the other reads work as usual while Save, Delete and
+// every other operation writing the state, its backups, snapshots or event
+// log fail with ErrReadOnly. Loads leave the file as is rather than tighten
+// its mode or remove it when stale.
+func WithReadOnly() StateOption {
+	return func(s *StateManager) {
+		s.readOnly = true
+	}
+}
+
+// writable returns ErrReadOnly when the manager must not change the state.
+func (s *StateManager) writable() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.leaseErr()
+}
@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithReadOnly ensures reads work while every write fails with ErrReadOnly.
+func TestWithReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	writer, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithBackups(1))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Save(&TestStruct{Name: "alice"}))
+	assert.NoError(t, os.Chmod(path, 0644))
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithBackups(1), WithReadOnly())
+	assert.NoError(t, err)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+	assert.True(t, sm.Exists())
+	_, err = sm.Stat()
+	assert.NoError(t, err)
+
+	// the mode is left as is
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	assert.ErrorIs(t, sm.Save(loaded), ErrReadOnly)
+	assert.ErrorIs(t, sm.Update(loaded, func() error { return nil }), ErrReadOnly)
+	assert.ErrorIs(t, sm.Clear(loaded), ErrReadOnly)
+	assert.ErrorIs(t, sm.Delete(), ErrReadOnly)
+	assert.ErrorIs(t, sm.Restore(1), ErrReadOnly)
+	assert.ErrorIs(t, sm.Snapshot("label"), ErrReadOnly)
+	assert.ErrorIs(t, sm.Set("name", "bob"), ErrReadOnly)
+	assert.ErrorIs(t, sm.Append("event"), ErrReadOnly)
+	_, err = sm.Prune(PrunePolicy{KeepBackups: 1})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	named, err := sm.Named("other")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, named.Save(loaded), ErrReadOnly)
+
+	loaded = &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+}
+
+// TestWithReadOnlyMissing ensures LoadOrCreate does not create a missing state.
+func TestWithReadOnlyMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithReadOnly())
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.LoadOrCreate(&TestStruct{}, nil), ErrReadOnly)
+	assert.NoFileExists(t, path)
+}
+
+// TestWithReadOnlyStale ensures a stale state is reported rather than removed.
+func TestWithReadOnlyStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"name": "alice"}`), 0600))
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(path, old, old))
+
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithTTL(time.Minute), WithDeleteStale(), WithReadOnly())
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrStale)
+	assert.FileExists(t, path)
+}
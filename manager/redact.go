@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// RedactedValue replaces the string fields annotated with redact on export
+const RedactedValue = "***"
+
+// SaveRedacted writes the given struct to path in the manager format with the
+// fields annotated with redact or encrypt, e.g. `state:",redact"`, replaced by
+// RedactedValue, so the state can be shared in bug reports. Fields other than
+// strings are cleared. The file is written without envelope or encryption and
+// must not be the state file itself.
+func (s *StateManager) SaveRedacted(path string, data interface{}) error {
+	if path == s.FilePath {
+		return errors.New("redacted state must not replace the state file")
+	}
+
+	var buf bytes.Buffer
+	if err := s.DumpRedacted(&buf, data); err != nil {
+		return err
+	}
+
+	return s.write(path, buf.Bytes())
+}
+
+// DumpRedacted writes the given struct to the writer in the manager format
+// with the sensitive fields redacted, see SaveRedacted.
+func (s *StateManager) DumpRedacted(w io.Writer, data interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	data, err := copyTagged(data, []string{tagRedact, tagEncrypt}, func(_ string, _ reflect.StructField, v reflect.Value) error {
+		if v.Kind() == reflect.String {
+			v.SetString(RedactedValue)
+			return nil
+		}
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := s.marshal(s.SerializationType, data)
+	if err != nil {
+		return fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redactedState struct {
+	Name     string       `json:"name" yaml:"name" state:"name"`
+	Password string       `json:"password" yaml:"password" state:"password,redact"`
+	PIN      int          `json:"pin" yaml:"pin" state:"pin,redact"`
+	Creds    *credentials `json:"creds" yaml:"creds" state:"creds"`
+}
+
+// TestDumpRedacted ensures sensitive fields are replaced in every format.
+func TestDumpRedacted(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		sm := setupTempStateManager(t, st)
+		data := &redactedState{Name: "prod", Password: "hunter2", PIN: 1234, Creds: &credentials{Token: "tok"}}
+
+		var buf bytes.Buffer
+		assert.NoError(t, sm.DumpRedacted(&buf, data))
+		assert.Contains(t, buf.String(), "prod", st)
+		assert.Contains(t, buf.String(), RedactedValue, st)
+		assert.NotContains(t, buf.String(), "hunter2", st)
+		assert.NotContains(t, buf.String(), "1234", st)
+		assert.NotContains(t, buf.String(), "tok\n", st)
+
+		// the struct is left untouched
+		assert.Equal(t, "hunter2", data.Password)
+		assert.Equal(t, "tok", data.Creds.Token)
+
+		loaded := &redactedState{}
+		assert.NoError(t, Unmarshal(st, buf.Bytes(), loaded))
+		assert.Equal(t, RedactedValue, loaded.Password)
+		assert.Equal(t, 0, loaded.PIN)
+		assert.Equal(t, RedactedValue, loaded.Creds.Token)
+	}
+}
+
+// TestSaveRedacted ensures the redacted copy is written next to the state.
+func TestSaveRedacted(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	path := filepath.Join(t.TempDir(), "redacted.json")
+
+	assert.Error(t, sm.SaveRedacted(sm.FilePath, &redactedState{}))
+	assert.NoError(t, sm.SaveRedacted(path, &redactedState{Name: "prod", Password: "hunter2"}))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(c), RedactedValue)
+	assert.NotContains(t, string(c), "hunter2")
+	assert.False(t, sm.Exists())
+}
@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how transient failures of reads and writes of the
+// state are retried. Zero fields take their defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 3 by default.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry, 50ms by default.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts, 5s by default.
+	MaxBackoff time.Duration
+
+	// Jitter randomizes each delay by up to the fraction of it, 0.2 by default.
+	Jitter float64
+
+	// Context stops retrying once done. Delays never extend past its deadline.
+	Context context.Context
+
+	// Retryable reports whether the error is worth retrying, IsTransient by default.
+	Retryable func(err error) bool
+
+	// Cleanup is called when the disk is full, e.g. to prune old snapshots.
+	// Writes failing with ENOSPC are only retried when it is set and succeeds.
+	Cleanup func() error
+}
+
+// WithRetry retries reads and writes of the state failing with transient
+// errors, e.g. network blips of remote stores or EBUSY, with jittered
+// exponential backoff.
+func WithRetry(policy RetryPolicy) StateOption {
+	return func(s *StateManager) {
+		if policy.MaxAttempts < 0 || policy.InitialBackoff < 0 || policy.MaxBackoff < 0 ||
+			policy.Jitter < 0 || policy.Jitter > 1 {
+			s.optionErr = errors.New("retry policy must not have negative values and jitter must be at most 1")
+			return
+		}
+		if policy.MaxAttempts == 0 {
+			policy.MaxAttempts = 3
+		}
+		if policy.InitialBackoff == 0 {
+			policy.InitialBackoff = 50 * time.Millisecond
+		}
+		if policy.MaxBackoff == 0 {
+			policy.MaxBackoff = 5 * time.Second
+		}
+		if policy.Jitter == 0 {
+			policy.Jitter = 0.2
+		}
+		if policy.Retryable == nil {
+			policy.Retryable = IsTransient
+		}
+		s.retryPolicy = &policy
+	}
+}
+
+// IsTransient reports whether the error is likely to go away on retry:
+// busy or interrupted system calls, network timeouts and dropped connections.
+func IsTransient(err error) bool {
+	for _, e := range []error{syscall.EBUSY, syscall.EAGAIN, syscall.EINTR, syscall.ETIMEDOUT,
+		syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// retry calls fn until it succeeds, fails with a permanent error or the
+// policy gives up, and returns the last error.
+func (s *StateManager) retry(op string, fn func() error) error {
+	p := s.retryPolicy
+	if p == nil {
+		return fn()
+	}
+
+	ctx := p.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !s.retryable(err) {
+			return err
+		}
+
+		d := backoff + time.Duration((rand.Float64()*2-1)*p.Jitter*float64(backoff))
+		if deadline, ok := ctx.Deadline(); ok && s.now().Add(d).After(deadline) {
+			return err
+		}
+
+		s.debug("retrying "+op, "attempt", attempt, "delay", d, "error", err)
+		if !s.sleep(ctx, d) {
+			return err
+		}
+
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// retryable checks the error against the policy, running the cleanup when the disk is full.
+func (s *StateManager) retryable(err error) bool {
+	if errors.Is(err, syscall.ENOSPC) {
+		if s.retryPolicy.Cleanup == nil {
+			return false
+		}
+		if cerr := s.retryPolicy.Cleanup(); cerr != nil {
+			s.debug("failed to free disk space", "error", cerr)
+			return false
+		}
+		return true
+	}
+	return s.retryPolicy.Retryable(err)
+}
+
+// sleep waits for the duration on the clock of the manager and reports
+// whether it elapsed before the context was done.
+func (s *StateManager) sleep(ctx context.Context, d time.Duration) bool {
+	done := make(chan struct{})
+	t := s.timers().AfterFunc(d, func() { close(done) })
+	defer t.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStore fails the given number of Get and Put calls with err.
+type flakyStore struct {
+	mapStore
+	failures int
+	calls    int
+	err      error
+}
+
+func (f *flakyStore) fail() error {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyStore) Get(key string) ([]byte, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.mapStore.Get(key)
+}
+
+func (f *flakyStore) Put(key string, value []byte) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.mapStore.Put(key, value)
+}
+
+// TestWithRetry ensures transient failures are retried up to the attempt limit.
+func TestWithRetry(t *testing.T) {
+	store := &flakyStore{mapStore: mapStore{}, failures: 2, err: syscall.EBUSY}
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON),
+		WithRetry(RetryPolicy{InitialBackoff: time.Millisecond}))
+	assert.NoError(t, err)
+
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	assert.NoError(t, sm.Save(data))
+	assert.Equal(t, 3, store.calls)
+
+	store.calls, store.failures = 0, 2
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, data, loaded)
+
+	store.calls, store.failures = 0, 3
+	assert.ErrorIs(t, sm.Save(data), syscall.EBUSY)
+	assert.Equal(t, 3, store.calls)
+
+	store.calls, store.failures, store.err = 0, 1, errors.New("permanent")
+	assert.Error(t, sm.Save(data))
+	assert.Equal(t, 1, store.calls)
+}
+
+// TestWithRetryCleanup ensures a full disk is only retried after a successful cleanup.
+func TestWithRetryCleanup(t *testing.T) {
+	store := &flakyStore{mapStore: mapStore{}, failures: 1, err: syscall.ENOSPC}
+	cleanups := 0
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON),
+		WithRetry(RetryPolicy{InitialBackoff: time.Millisecond, Cleanup: func() error {
+			cleanups++
+			return nil
+		}}))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{}))
+	assert.Equal(t, 1, cleanups)
+
+	store.failures = 1
+	sm, err = NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON),
+		WithRetry(RetryPolicy{InitialBackoff: time.Millisecond}))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), syscall.ENOSPC)
+}
+
+// TestWithRetryContext ensures retries stop once the context is done or its deadline is too close.
+func TestWithRetryContext(t *testing.T) {
+	store := &flakyStore{mapStore: mapStore{}, failures: 5, err: syscall.ETIMEDOUT}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON),
+		WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, Context: ctx}))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), syscall.ETIMEDOUT)
+	assert.Equal(t, 1, store.calls)
+
+	store.calls = 0
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	sm, err = NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON),
+		WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, Context: ctx}))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), syscall.ETIMEDOUT)
+	assert.Equal(t, 1, store.calls)
+}
+
+// TestWithRetryInvalid ensures invalid policies are rejected.
+func TestWithRetryInvalid(t *testing.T) {
+	_, err := NewStateManager(WithRetry(RetryPolicy{Jitter: 2}))
+	assert.Error(t, err)
+	_, err = NewStateManager(WithRetry(RetryPolicy{MaxAttempts: -1}))
+	assert.Error(t, err)
+}
+
+// TestIsTransient ensures busy and network errors are transient.
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(syscall.EBUSY))
+	assert.True(t, IsTransient(&timeoutError{}))
+	assert.False(t, IsTransient(ErrNotFound))
+	assert.False(t, IsTransient(syscall.ENOSPC))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"errors"
+)
+
+// Revision returns the revision of the persisted state recorded in the envelope.
+// The revision is incremented on every write and is 0 when nothing was saved yet
+// or the state was saved without an envelope.
+func (s *StateManager) Revision() (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return s.revision(), nil
+}
+
+// LoadVersion reads the struct from the file and returns its revision,
+// to be passed to SaveIfVersion.
+func (s *StateManager) LoadVersion(data interface{}) (int64, error) {
+	unlock, err := s.lockLoad()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := s.load(data); err != nil {
+		return 0, err
+	}
+
+	s.rememberBase()
+	return s.revision(), nil
+}
+
+// SaveIfVersion persists the given struct only when the revision of the
+// persisted state is still the expected one and fails with ErrConflict otherwise.
+// Use 0 to save only when nothing was saved yet. Requires WithEnvelope.
+// With WithConflictMerge the concurrent changes are merged into data instead.
+func (s *StateManager) SaveIfVersion(data interface{}, expected int64) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	if !s.envelope {
+		return errors.New("revisions require the envelope, use WithEnvelope")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	if rev := s.revision(); rev != expected {
+		if err := s.resolveConflict(data, expected, rev); err != nil {
+			return err
+		}
+	}
+
+	if err := s.save(data); err != nil {
+		return err
+	}
+
+	s.rememberBase()
+	return nil
+}
+
+// revision reads the revision of the persisted state. Caller must hold the read lock.
+func (s *StateManager) revision() int64 {
+	if env := s.header(); env != nil {
+		return env.Revision
+	}
+	return 0
+}
+
+// header reads the envelope header of the persisted state without verifying
+// the payload. Caller must hold the read lock.
+func (s *StateManager) header() *Envelope {
+	c, err := s.files().ReadFile(s.FilePath)
+	if err != nil {
+		return nil
+	}
+	return envelopeHeader(c)
+}
+
+// reseal moves the enveloped content of a backup or snapshot to the next
+// revision, so that restoring it does not move the revision backwards.
+func (s *StateManager) reseal(b []byte) ([]byte, error) {
+	env, payload, err := unwrapEnvelope(b)
+	if err != nil || env == nil {
+		return b, err
+	}
+
+	env.Revision = s.revision() + 1
+	env.Timestamp = s.now().UTC()
+
+	if err := s.sign(env, payload); err != nil {
+		return nil, err
+	}
+
+	return wrapEnvelope(env, payload)
+}
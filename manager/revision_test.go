@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRevision ensures every write moves the revision forward.
+func TestRevision(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	WithBackups(2)(sm)
+
+	rev, err := sm.Revision()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rev)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "b"}))
+	assert.NoError(t, sm.Set("age", 3))
+
+	rev, err = sm.Revision()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rev)
+
+	// restoring an older version does not move the revision backwards
+	assert.NoError(t, sm.Restore(2))
+
+	loaded := &TestStruct{}
+	rev, err = sm.LoadVersion(loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), rev)
+	assert.Equal(t, "a", loaded.Name)
+}
+
+// TestSaveIfVersion ensures concurrent changes are detected.
+func TestSaveIfVersion(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	other, err := NewStateManager(WithFilePath(sm.FilePath), WithSerializationType(JSON), WithEnvelope())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.SaveIfVersion(&TestStruct{Name: "a"}, 0))
+	assert.ErrorIs(t, sm.SaveIfVersion(&TestStruct{Name: "a"}, 0), ErrConflict)
+
+	mine := &TestStruct{}
+	rev, err := sm.LoadVersion(mine)
+	assert.NoError(t, err)
+
+	theirs := &TestStruct{}
+	theirRev, err := other.LoadVersion(theirs)
+	assert.NoError(t, err)
+	assert.Equal(t, rev, theirRev)
+
+	theirs.Name = "theirs"
+	assert.NoError(t, other.SaveIfVersion(theirs, theirRev))
+
+	mine.Name = "mine"
+	assert.ErrorIs(t, sm.SaveIfVersion(mine, rev), ErrConflict)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "theirs", loaded.Name)
+}
+
+// TestSaveIfVersionWithoutEnvelope ensures revisions require the envelope.
+func TestSaveIfVersionWithoutEnvelope(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.Error(t, sm.SaveIfVersion(&TestStruct{}, 0))
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentValidator validates the persisted state decoded into generic values,
+// e.g. a JSON Schema generated by the schema package.
+type DocumentValidator interface {
+	ValidateDocument(doc interface{}) error
+}
+
+// WithSchemaValidation validates JSON, YAML and STATE files against the schema
+// on Load, before they are decoded into the struct. Failures wrap ErrInvalid.
+func WithSchemaValidation(schema DocumentValidator) StateOption {
+	return func(s *StateManager) {
+		s.schema = schema
+	}
+}
+
+// validateDocument validates the payload against the schema.
+func (s *StateManager) validateDocument(st SerializationType, payload []byte) error {
+	var doc interface{}
+	var err error
+
+	switch st {
+	case JSON:
+		err = json.Unmarshal(payload, &doc)
+	case YAML, STATE:
+		err = yaml.Unmarshal(payload, &doc)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	if err := s.schema.ValidateDocument(doc); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,412 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// shardDirSuffix names the directory next to the state file holding the shards.
+const shardDirSuffix = ".shards"
+
+// WithShards persists the map or slice field with the given Go name across n
+// files instead of the state file, like the field option
+// `state:"items,shards=16"`. Map entries are assigned to a shard by the hash of
+// their key and slice elements by their index, and a save rewrites only the
+// shards whose entries changed, so updating a few entries of a huge
+// collection does not rewrite all of it. The shards are kept in the directory
+// FilePath.shards and are not rotated into backups. Supported with JSON, YAML
+// and STATE.
+func WithShards(field string, n int) StateOption {
+	return func(s *StateManager) {
+		if field == "" || n < 1 {
+			s.optionErr = errors.New("sharded field must not be empty and have at least one shard")
+			return
+		}
+		shards := make(map[string]int, len(s.shards)+1)
+		for k, v := range s.shards {
+			shards[k] = v
+		}
+		shards[field] = n
+		s.shards = shards
+	}
+}
+
+// shardSpec describes how a top-level key is sharded.
+type shardSpec struct {
+	n    int
+	list bool
+}
+
+// shardFile is a shard as last written or read, so unchanged shards are not
+// compared with their file again.
+type shardFile struct {
+	sum     string
+	modTime time.Time
+	size    int64
+}
+
+// shardFields returns the sharded top-level keys of data.
+func (s *StateManager) shardFields(data interface{}) (map[string]shardSpec, error) {
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var shards map[string]shardSpec
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		n := s.shards[field.Name]
+		if v := parseStateTag(field).value(tagShards); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid shards of field %s: %q", field.Name, v)
+			}
+		}
+		if n == 0 {
+			continue
+		}
+
+		key, _ := fieldKeyNamed(s.SerializationType, field, s.fieldNames)
+		if key == "" {
+			continue
+		}
+		if !validName(key) {
+			return nil, fmt.Errorf("invalid shard file name: %q", key)
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Map && ft.Kind() != reflect.Slice && ft.Kind() != reflect.Array {
+			return nil, fmt.Errorf("sharded field %s must be a map or slice", field.Name)
+		}
+
+		if shards == nil {
+			shards = make(map[string]shardSpec)
+		}
+		shards[key] = shardSpec{n: n, list: ft.Kind() != reflect.Map}
+	}
+	return shards, nil
+}
+
+// writeShards moves the sharded keys out of the parts into their shards,
+// writing only the shards which changed. Caller must hold the lock.
+func (s *StateManager) writeShards(shards map[string]shardSpec, parts map[string][]byte) error {
+	st := s.SerializationType
+
+	keys := make([]string, 0, len(shards))
+	for key := range shards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		spec := shards[key]
+
+		entries := map[string][]byte{}
+		if part, ok := parts[key]; ok {
+			delete(parts, key)
+
+			var err error
+			if entries, err = shardEntries(st, part, spec.list); err != nil {
+				return fmt.Errorf("sharded key %q: %w", key, err)
+			}
+		}
+
+		buckets := make([]map[string][]byte, spec.n)
+		for i := range buckets {
+			buckets[i] = make(map[string][]byte)
+		}
+		for name, entry := range entries {
+			buckets[shardOf(name, spec)][name] = entry
+		}
+
+		for i, bucket := range buckets {
+			if err := s.writeShard(s.shardPath(key, i), bucket); err != nil {
+				return err
+			}
+		}
+
+		if err := s.removeShards(key, spec.n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeShard writes the entries of a shard to path unless they are unchanged.
+// A shard without entries is removed. Caller must hold the lock.
+func (s *StateManager) writeShard(path string, entries map[string][]byte) error {
+	var doc []byte
+	sum := ""
+	if len(entries) > 0 {
+		var err error
+		if doc, err = joinStates(s.SerializationType, entries); err != nil {
+			return err
+		}
+		sum = checksum(doc)
+	}
+
+	if s.shardUnchanged(path, sum) {
+		return nil
+	}
+
+	if doc == nil {
+		if err := s.files().Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove shard: %w", err)
+		}
+	} else {
+		b, err := s.seal(doc)
+		if err != nil {
+			return err
+		}
+		if err := s.files().MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := s.write(path, b); err != nil {
+			return err
+		}
+	}
+
+	s.rememberShard(path, sum)
+	return nil
+}
+
+// shardUnchanged reports whether the shard at path holds the document with
+// the checksum, an empty one standing for a missing shard.
+func (s *StateManager) shardUnchanged(path, sum string) bool {
+	if f, ok := s.shardFiles[path]; ok && f.sum == sum {
+		info, err := s.files().Stat(path)
+		if sum == "" && errors.Is(err, os.ErrNotExist) {
+			return true
+		}
+		if err == nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+			return true
+		}
+	}
+
+	// compare with the shard changed since or written by another process
+	c, err := s.readFile(path)
+	if errors.Is(err, ErrNotFound) {
+		return sum == ""
+	}
+	if err != nil {
+		return false
+	}
+	doc, _, err := s.open(c)
+	if err != nil || checksum(doc) != sum {
+		return false
+	}
+
+	s.rememberShard(path, sum)
+	return true
+}
+
+// rememberShard records the shard at path as holding the document with the checksum.
+func (s *StateManager) rememberShard(path, sum string) {
+	if s.shardFiles == nil {
+		s.shardFiles = make(map[string]shardFile)
+	}
+
+	f := shardFile{sum: sum}
+	if info, err := s.files().Stat(path); err == nil {
+		f.modTime, f.size = info.ModTime(), info.Size()
+	}
+	s.shardFiles[path] = f
+}
+
+// readShards merges the entries of the shards into the parts. Keys without
+// any shard are left as they are, e.g. as persisted before sharding.
+func (s *StateManager) readShards(st SerializationType, shards map[string]shardSpec, parts map[string][]byte) error {
+	for key, spec := range shards {
+		entries := make(map[string][]byte)
+		for i := 0; i < spec.n; i++ {
+			path := s.shardPath(key, i)
+
+			c, err := s.readFile(path)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			doc, st, err := s.open(c)
+			if err != nil {
+				return fmt.Errorf("shard %s: %w", path, err)
+			}
+			shard, err := splitStates(st, doc)
+			if err != nil {
+				return fmt.Errorf("shard %s: %w", path, err)
+			}
+			for name, entry := range shard {
+				entries[name] = entry
+			}
+		}
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		var part []byte
+		var err error
+		if spec.list {
+			part, err = joinList(st, entries)
+		} else {
+			part, err = joinStates(st, entries)
+		}
+		if err != nil {
+			return fmt.Errorf("sharded key %q: %w", key, err)
+		}
+		parts[key] = part
+	}
+	return nil
+}
+
+// removeShards removes the shards of the key from the index n on, left over
+// from a larger number of shards. Caller must hold the lock.
+func (s *StateManager) removeShards(key string, n int) error {
+	entries, err := s.files().ReadDir(s.FilePath + shardDirSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, e := range entries {
+		index, ok := strings.CutPrefix(e.Name(), key+".")
+		if !ok {
+			continue
+		}
+		if i, err := strconv.Atoi(index); err != nil || i < n {
+			continue
+		}
+		path := filepath.Join(s.FilePath+shardDirSuffix, e.Name())
+		if err := s.files().Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove shard: %w", err)
+		}
+		delete(s.shardFiles, path)
+	}
+	return nil
+}
+
+// removeAllShards removes the shard directory. Caller must hold the lock.
+func (s *StateManager) removeAllShards() error {
+	dir := s.FilePath + shardDirSuffix
+	entries, err := s.files().ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, e := range entries {
+		if err := s.files().Remove(filepath.Join(dir, e.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove shard: %w", err)
+		}
+	}
+	s.shardFiles = nil
+
+	_ = s.files().Remove(dir)
+	return nil
+}
+
+// shardPath returns the path of the shard with the index of the key.
+func (s *StateManager) shardPath(key string, i int) string {
+	return filepath.Join(s.FilePath+shardDirSuffix, key+"."+strconv.Itoa(i))
+}
+
+// shardOf returns the index of the shard holding the entry with the name,
+// the key of a map entry or the index of a slice element.
+func shardOf(name string, spec shardSpec) int {
+	if spec.list {
+		i, _ := strconv.Atoi(name)
+		return i % spec.n
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(spec.n))
+}
+
+// shardEntries splits the encoded map or slice into its entries, keyed by the
+// map key or the slice index.
+func shardEntries(st SerializationType, part []byte, list bool) (map[string][]byte, error) {
+	if !list {
+		return splitStates(st, part)
+	}
+
+	entries := make(map[string][]byte)
+	switch st {
+	case JSON:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(part, &raw); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+		for i, b := range raw {
+			entries[strconv.Itoa(i)] = b
+		}
+	default:
+		var nodes []yaml.Node
+		if err := yaml.Unmarshal(part, &nodes); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+		for i := range nodes {
+			b, err := yaml.Marshal(&nodes[i])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data: %w", err)
+			}
+			entries[strconv.Itoa(i)] = b
+		}
+	}
+	return entries, nil
+}
+
+// joinList encodes the slice elements keyed by their index as a list.
+func joinList(st SerializationType, entries map[string][]byte) ([]byte, error) {
+	indexes := make([]int, 0, len(entries))
+	for name := range entries {
+		i, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slice index %q", name)
+		}
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	switch st {
+	case JSON:
+		raw := make([]json.RawMessage, 0, len(indexes))
+		for _, i := range indexes {
+			raw = append(raw, entries[strconv.Itoa(i)])
+		}
+		return Marshal(JSON, raw)
+	default:
+		nodes := make([]*yaml.Node, 0, len(indexes))
+		for _, i := range indexes {
+			var n yaml.Node
+			if err := yaml.Unmarshal(entries[strconv.Itoa(i)], &n); err != nil {
+				return nil, fmt.Errorf("failed to encode data: %w", err)
+			}
+			nodes = append(nodes, n.Content[0])
+		}
+		return yaml.Marshal(nodes)
+	}
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type shardedState struct {
+	Name    string            `json:"name" yaml:"name" state:"name"`
+	Entries map[string]string `json:"entries" yaml:"entries" state:"entries,shards=4"`
+	List    []int             `json:"list" yaml:"list" state:"list"`
+}
+
+// TestWithShards ensures sharded fields round trip and only changed shards are rewritten.
+func TestWithShards(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state")
+			options := []StateOption{WithFilePath(path), WithSerializationType(st), WithShards("List", 3)}
+			if st != STATE {
+				options = append(options, WithShards("Entries", 4))
+			}
+			sm, err := NewStateManager(options...)
+			assert.NoError(t, err)
+
+			data := &shardedState{Name: "a", Entries: map[string]string{}}
+			for i := 0; i < 100; i++ {
+				data.Entries[fmt.Sprintf("key-%d", i)] = fmt.Sprintf("value-%d", i)
+				data.List = append(data.List, i)
+			}
+			assert.NoError(t, sm.Save(data))
+
+			c, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NotContains(t, string(c), "key-1")
+
+			files, err := os.ReadDir(path + ".shards")
+			assert.NoError(t, err)
+			assert.Len(t, files, 7)
+
+			loaded := &shardedState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, data, loaded)
+
+			// only the shard of the changed entry is rewritten
+			before := modTimes(t, path+".shards")
+			for _, f := range files {
+				old := before[f.Name()].Add(-1)
+				assert.NoError(t, os.Chtimes(filepath.Join(path+".shards", f.Name()), old, old))
+			}
+			before = modTimes(t, path+".shards")
+
+			data.Entries["key-1"] = "changed"
+			assert.NoError(t, sm.Save(data))
+			after := modTimes(t, path+".shards")
+			changed := 0
+			for name, mod := range after {
+				if !mod.Equal(before[name]) {
+					changed++
+				}
+			}
+			assert.Equal(t, 1, changed)
+
+			// a fresh manager reads back the shards
+			other, err := NewStateManager(options...)
+			assert.NoError(t, err)
+			loaded = &shardedState{}
+			assert.NoError(t, other.Load(loaded))
+			assert.Equal(t, data, loaded)
+
+			data.List = data.List[:1]
+			assert.NoError(t, other.Save(data))
+			loaded = &shardedState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, []int{0}, loaded.List)
+
+			assert.NoError(t, sm.Delete())
+			assert.NoDirExists(t, path+".shards")
+		})
+	}
+}
+
+// TestWithShardsInvalid ensures invalid shard settings are rejected.
+func TestWithShardsInvalid(t *testing.T) {
+	_, err := NewStateManager(WithShards("Entries", 0))
+	assert.Error(t, err)
+
+	sm, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state")), WithSerializationType(JSON), WithShards("Name", 2))
+	assert.NoError(t, err)
+	assert.Error(t, sm.Save(&shardedState{Name: "a"}))
+
+	sm, err = NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state")), WithSerializationType(BIN), WithShards("List", 2))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sm.Save(&shardedState{Name: "a"}), ErrUnsupportedFormat)
+}
+
+// modTimes returns the modification times of the files in dir by name.
+func modTimes(t *testing.T, dir string) map[string]time.Time {
+	t.Helper()
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+
+	times := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		info, err := f.Info()
+		assert.NoError(t, err)
+		times[f.Name()] = info.ModTime()
+	}
+	return times
+}
@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+)
+
+// WithSigningKey signs the envelope of every save with the ed25519 private key
+// and verifies the signature on load with its public key. The signature covers
+// the envelope header, including the SHA-256 of the payload, so any change to
+// the file is detected. Signed state is always written with the envelope.
+func WithSigningKey(key ed25519.PrivateKey) StateOption {
+	return func(s *StateManager) {
+		if len(key) != ed25519.PrivateKeySize {
+			s.optionErr = fmt.Errorf("invalid signing key size %d, expected %d bytes", len(key), ed25519.PrivateKeySize)
+			return
+		}
+		s.signingKey = key
+		if s.verifyKey == nil {
+			s.verifyKey = key.Public().(ed25519.PublicKey)
+		}
+	}
+}
+
+// WithVerifyKey requires the persisted state to be signed by the private key
+// of the ed25519 public key, failing the load with ErrSignature otherwise.
+func WithVerifyKey(key ed25519.PublicKey) StateOption {
+	return func(s *StateManager) {
+		if len(key) != ed25519.PublicKeySize {
+			s.optionErr = fmt.Errorf("invalid verify key size %d, expected %d bytes", len(key), ed25519.PublicKeySize)
+			return
+		}
+		s.verifyKey = key
+	}
+}
+
+// sign records the payload integrity in the envelope and signs it.
+func (s *StateManager) sign(env *Envelope, payload []byte) error {
+	if s.signingKey == nil {
+		return nil
+	}
+
+	env.Checksum = checksum(payload)
+	env.Size = len(payload)
+
+	msg, err := signedHeader(env)
+	if err != nil {
+		return err
+	}
+
+	env.Signature = ed25519.Sign(s.signingKey, msg)
+	return nil
+}
+
+// verify checks the signature of the verified envelope.
+func (s *StateManager) verify(env *Envelope) error {
+	if s.verifyKey == nil {
+		return nil
+	}
+
+	if env == nil || len(env.Signature) == 0 {
+		return fmt.Errorf("%w: state is not signed", ErrSignature)
+	}
+
+	msg, err := signedHeader(env)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(s.verifyKey, msg, env.Signature) {
+		return fmt.Errorf("%w: signature does not match", ErrSignature)
+	}
+
+	return nil
+}
+
+// signedHeader returns the envelope header without the signature.
+func signedHeader(env *Envelope) ([]byte, error) {
+	unsigned := *env
+	unsigned.Signature = nil
+
+	b, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope: %w", err)
+	}
+
+	return b, nil
+}
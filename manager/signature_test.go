@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithSigningKey ensures signed state is verified on load.
+func TestWithSigningKey(t *testing.T) {
+	path := setupTempStateManager(t, JSON).FilePath
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	_, err = NewStateManager(WithFilePath(path), WithSigningKey(priv[:10]))
+	assert.Error(t, err)
+	_, err = NewStateManager(WithFilePath(path), WithVerifyKey(pub[:10]))
+	assert.Error(t, err)
+
+	signer, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithSigningKey(priv))
+	assert.NoError(t, err)
+	assert.NoError(t, signer.Save(&TestStruct{Name: "Alice", Age: 30}))
+
+	env, err := signer.Envelope()
+	assert.NoError(t, err)
+	assert.Len(t, env.Signature, ed25519.SignatureSize)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, signer.Load(loaded))
+	assert.Equal(t, "Alice", loaded.Name)
+
+	verifier, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithVerifyKey(pub))
+	assert.NoError(t, err)
+	assert.NoError(t, verifier.Load(loaded))
+	assert.NoError(t, verifier.Verify())
+
+	wrong, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithVerifyKey(otherPub))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, wrong.Load(&TestStruct{}), ErrSignature)
+
+	// a modified header fails the signature even with a matching checksum
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, bytes.Replace(c, []byte(`"revision":1`), []byte(`"revision":7`), 1), 0600))
+	assert.ErrorIs(t, verifier.Load(&TestStruct{}), ErrSignature)
+
+	// unsigned state is rejected
+	plain, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON))
+	assert.NoError(t, err)
+	assert.NoError(t, plain.Save(&TestStruct{Name: "Mallory"}))
+	assert.ErrorIs(t, verifier.Load(&TestStruct{}), ErrSignature)
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SizeWarning is invoked with the path and size of a state file written
+// beyond the limit set by WithSizeWarning.
+type SizeWarning func(path string, size int64)
+
+// WithMaxSize limits the size of the state files to n bytes: writing a larger
+// file fails with ErrTooLarge and so does reading one, before it is read into
+// memory, so a runaway or corrupted state can not exhaust the memory.
+func WithMaxSize(n int64) StateOption {
+	return func(s *StateManager) {
+		if n <= 0 {
+			s.optionErr = errors.New("max size must be positive")
+			return
+		}
+		s.maxSize = n
+	}
+}
+
+// WithSizeWarning invokes fn whenever a state file larger than n bytes is
+// written, without failing the write, e.g. to log the growth of the state.
+func WithSizeWarning(n int64, fn SizeWarning) StateOption {
+	return func(s *StateManager) {
+		if n <= 0 || fn == nil {
+			s.optionErr = errors.New("size warning requires a positive size and a function")
+			return
+		}
+		s.warnSize = n
+		s.sizeWarning = fn
+	}
+}
+
+// checkWriteSize enforces the size limits on the content written to path.
+func (s *StateManager) checkWriteSize(path string, size int64) error {
+	if s.maxSize > 0 && size > s.maxSize {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d", ErrTooLarge, size, s.maxSize)
+	}
+	if s.sizeWarning != nil && size > s.warnSize {
+		s.sizeWarning(path, size)
+	}
+	return nil
+}
+
+// checkReadSize enforces the size limit on the file at path before it is
+// read. Files of stores are checked once read as their size is not known
+// beforehand.
+func (s *StateManager) checkReadSize(path string) error {
+	if s.maxSize <= 0 || !s.osFiles() {
+		return nil
+	}
+
+	info, err := s.files().Stat(path)
+	if err != nil {
+		// reported by the read
+		return nil
+	}
+	if info.Size() > s.maxSize {
+		return fmt.Errorf("%w: %s has %d bytes, exceeding the limit of %d", ErrTooLarge, path, info.Size(), s.maxSize)
+	}
+	return nil
+}
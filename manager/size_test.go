@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithMaxSize ensures larger state files are neither written nor read.
+func TestWithMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithMaxSize(100))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	assert.ErrorIs(t, sm.Save(&TestStruct{Name: strings.Repeat("a", 100)}), ErrTooLarge)
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Repeat(" ", 101)), 0600))
+	assert.ErrorIs(t, sm.Load(loaded), ErrTooLarge)
+
+	_, err = NewStateManager(WithMaxSize(0))
+	assert.Error(t, err)
+}
+
+// TestWithMaxSizeStore ensures the limit applies to stores once read.
+func TestWithMaxSizeStore(t *testing.T) {
+	store := mapStore{}
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"), WithSerializationType(JSON), WithMaxSize(100))
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Put("/state", []byte(strings.Repeat(" ", 101))))
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrTooLarge)
+}
+
+// TestWithSizeWarning ensures larger state files are reported but still written.
+func TestWithSizeWarning(t *testing.T) {
+	var warned int64
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON),
+		WithSizeWarning(10, func(p string, size int64) {
+			assert.Equal(t, path, p)
+			warned = size
+		}))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), warned)
+
+	_, err = NewStateManager(WithSizeWarning(10, nil))
+	assert.Error(t, err)
+}
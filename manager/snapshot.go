@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	// snapshotDirSuffix is appended to the file path to form the sidecar snapshot directory
+	snapshotDirSuffix = ".snapshots"
+)
+
+// snapshotDir returns the sidecar directory holding the labeled snapshots.
+func (s *StateManager) snapshotDir() string {
+	return s.FilePath + snapshotDirSuffix
+}
+
+// snapshotPath validates the label and returns the path of its snapshot file.
+func (s *StateManager) snapshotPath(label string) (string, error) {
+	if !validName(label) {
+		return "", fmt.Errorf("invalid snapshot label: %q", label)
+	}
+	return filepath.Join(s.snapshotDir(), label), nil
+}
+
+// Snapshot keeps a labeled copy of the currently persisted state.
+// An existing snapshot with the same label is replaced.
+func (s *StateManager) Snapshot(label string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	path, err := s.snapshotPath(label)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := s.readFile(s.FilePath)
+	if err != nil {
+		return err
+	}
+
+	if err := s.files().MkdirAll(s.snapshotDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	return s.write(path, b)
+}
+
+// Rollback replaces the persisted state with the labeled snapshot.
+func (s *StateManager) Rollback(label string) error {
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	path, err := s.snapshotPath(label)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b, err := s.readFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	if b, err = s.reseal(b); err != nil {
+		return err
+	}
+
+	return s.write(s.FilePath, b)
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnapshotAndRollback ensures labeled snapshots can be restored.
+func TestSnapshotAndRollback(t *testing.T) {
+	sm := setupTempStateManager(t, YAML)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "before"}))
+	assert.NoError(t, sm.Snapshot("v1"))
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "after"}))
+	assert.NoError(t, sm.Snapshot("v2"))
+
+	assert.NoError(t, sm.Rollback("v1"))
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "before", loaded.Name)
+
+	assert.NoError(t, sm.Rollback("v2"))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "after", loaded.Name)
+}
+
+// TestSnapshotErrors ensures invalid labels and missing state are reported.
+func TestSnapshotErrors(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	assert.Error(t, sm.Snapshot("missing"))
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "x"}))
+	for _, label := range []string{"", ".", "..", "a/b", `a\b`} {
+		assert.Error(t, sm.Snapshot(label), label)
+		assert.Error(t, sm.Rollback(label), label)
+	}
+
+	assert.Error(t, sm.Rollback("unknown"))
+}
+
+// TestSnapshotFileLock ensures Snapshot, Rollback and Restore wait for the file lock.
+func TestSnapshotFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_state")
+
+	holder, err := NewStateManager(WithFilePath(path), WithFileLock(), WithBackups(1))
+	assert.NoError(t, err)
+	assert.NoError(t, holder.Save(&TestStruct{Name: "a"}))
+	assert.NoError(t, holder.Save(&TestStruct{Name: "b"}))
+	assert.NoError(t, holder.Snapshot("v1"))
+
+	waiter, err := NewStateManager(WithFilePath(path), WithBackups(1), WithLockTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_ = holder.Update(&TestStruct{}, func() error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+
+	<-locked
+	assert.ErrorIs(t, waiter.Snapshot("v2"), ErrLocked)
+	assert.ErrorIs(t, waiter.Rollback("v1"), ErrLocked)
+	assert.ErrorIs(t, waiter.Restore(1), ErrLocked)
+	close(release)
+	<-done
+
+	assert.NoError(t, waiter.Rollback("v1"))
+}
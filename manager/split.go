@@ -0,0 +1,218 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// WithSplit persists the top-level field with the given Go name to the file
+// at path, relative to the directory of the state file, instead of the state
+// file, like the field option `state:",file=secrets.yaml"`. Split files hold
+// a document of their keys in the manager format, are never more permissive
+// than 0600 and are saved and loaded together with the state file. Supported
+// with JSON, YAML and STATE.
+func WithSplit(field, path string) StateOption {
+	return func(s *StateManager) {
+		if field == "" || path == "" {
+			s.optionErr = errors.New("split field and path must not be empty")
+			return
+		}
+		splits := make(map[string]string, len(s.splits)+1)
+		for k, v := range s.splits {
+			splits[k] = v
+		}
+		splits[field] = path
+		s.splits = splits
+	}
+}
+
+// splitFiles returns the paths of the split files keyed by the top-level
+// keys persisted to them.
+func (s *StateManager) splitFiles(data interface{}) map[string]string {
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var files map[string]string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		path := s.splits[field.Name]
+		if file := parseStateTag(field).value(tagFile); file != "" {
+			path = file
+		}
+		if path == "" {
+			continue
+		}
+
+		key, _ := fieldKeyNamed(s.SerializationType, field, s.fieldNames)
+		if key == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(s.FilePath), path)
+		}
+
+		if files == nil {
+			files = make(map[string]string)
+		}
+		files[key] = path
+	}
+	return files
+}
+
+// writeSplit writes the payload to the state file, moving the keys of split
+// fields to their files. Caller must hold the lock.
+func (s *StateManager) writeSplit(data interface{}, payload []byte) error {
+	files := s.splitFiles(data)
+	shards, err := s.shardFields(data)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(shards) == 0 {
+		b, err := s.seal(payload)
+		if err != nil {
+			return err
+		}
+		return s.write(s.FilePath, b)
+	}
+
+	if !splittable(s.SerializationType) {
+		return fmt.Errorf("%w: split files and shards require json, yaml or state, got %s", ErrUnsupportedFormat, s.SerializationType)
+	}
+
+	parts, err := splitStates(s.SerializationType, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := s.writeShards(shards, parts); err != nil {
+		return err
+	}
+
+	// every split file is written, even when its keys were omitted
+	docs := map[string]map[string][]byte{s.FilePath: {}}
+	for _, path := range files {
+		docs[path] = make(map[string][]byte)
+	}
+	for key, part := range parts {
+		path, ok := files[key]
+		if !ok {
+			path = s.FilePath
+		}
+		docs[path][key] = part
+	}
+
+	paths := make([]string, 0, len(docs))
+	for path := range docs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		doc, err := joinStates(s.SerializationType, docs[path])
+		if err != nil {
+			return err
+		}
+		if s.canonical {
+			if doc, err = canonicalize(s.SerializationType, doc); err != nil {
+				return err
+			}
+		}
+
+		b, err := s.seal(doc)
+		if err != nil {
+			return err
+		}
+
+		mode := s.fileMode
+		if path != s.FilePath {
+			mode &= 0600
+		}
+		if err := s.writeFile(path, b, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeSplit deserializes the state file content into the given struct like
+// decode, merging in the keys persisted to split files and shards. Missing
+// split files and shards leave their keys unset.
+func (s *StateManager) decodeSplit(c []byte, data interface{}) error {
+	files := s.splitFiles(data)
+	shards, err := s.shardFields(data)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(shards) == 0 || c == nil || !splittable(s.SerializationType) {
+		return s.decode(c, data)
+	}
+
+	payload, st, err := s.open(c)
+	if err != nil {
+		return err
+	}
+
+	parts, err := splitStates(st, payload)
+	if err != nil {
+		return err
+	}
+
+	read := make(map[string]bool)
+	for _, path := range files {
+		if read[path] {
+			continue
+		}
+		read[path] = true
+
+		c, err := s.readFile(path)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		doc, st, err := s.open(c)
+		if err != nil {
+			return fmt.Errorf("split file %s: %w", path, err)
+		}
+		split, err := splitStates(st, doc)
+		if err != nil {
+			return fmt.Errorf("split file %s: %w", path, err)
+		}
+		for key, part := range split {
+			if files[key] == path {
+				parts[key] = part
+			}
+		}
+	}
+
+	if err := s.readShards(st, shards, parts); err != nil {
+		return err
+	}
+
+	if payload, err = joinStates(st, parts); err != nil {
+		return err
+	}
+
+	return s.decodePayload(st, payload, data)
+}
+
+// splittable reports whether the serialization type has top-level keys to split.
+func splittable(st SerializationType) bool {
+	switch st {
+	case JSON, YAML, STATE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// splitState is a state with a field annotated to be persisted to its own file.
+type splitState struct {
+	Name    string            `json:"name" yaml:"name" state:"name"`
+	Secrets map[string]string `json:"secrets" yaml:"secrets" state:"secrets,file=secrets.yaml"`
+}
+
+// TestSplitFile ensures annotated fields are persisted to a private file and
+// loaded back together with the state file.
+func TestSplitFile(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, STATE} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "state")
+		sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st), WithFileMode(0644))
+		assert.NoError(t, err)
+
+		state := &splitState{Name: "app", Secrets: map[string]string{"token": "s3cr3t"}}
+		assert.NoError(t, sm.Save(state), st)
+
+		main, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Contains(t, string(main), "app", st)
+		assert.NotContains(t, string(main), "s3cr3t", st)
+
+		secrets, err := os.ReadFile(filepath.Join(dir, "secrets.yaml"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(secrets), "s3cr3t", st)
+
+		info, err := os.Stat(filepath.Join(dir, "secrets.yaml"))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), st)
+		info, err = os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), st)
+
+		loaded := &splitState{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, state, loaded, st)
+
+		// a missing split file leaves its fields unset
+		assert.NoError(t, os.Remove(filepath.Join(dir, "secrets.yaml")))
+		loaded = &splitState{}
+		assert.NoError(t, sm.Load(loaded), st)
+		assert.Equal(t, &splitState{Name: "app"}, loaded, st)
+	}
+}
+
+// TestWithSplit ensures fields can be split off by name without annotations.
+func TestWithSplit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithEnvelope(),
+		WithSplit("Name", "name.json"))
+	assert.NoError(t, err)
+
+	state := &TestStruct{"Alice", 30, 98.6, true}
+	assert.NoError(t, sm.Save(state))
+
+	b, err := os.ReadFile(filepath.Join(dir, "name.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "Alice")
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, state, loaded)
+
+	_, err = NewStateManager(WithSplit("", "name.json"))
+	assert.Error(t, err)
+}
+
+// TestSplitFileFormat ensures formats without top-level keys cannot be split.
+func TestSplitFileFormat(t *testing.T) {
+	sm := setupTempStateManager(t, BIN)
+	assert.ErrorIs(t, sm.Save(&splitState{Name: "app"}), ErrUnsupportedFormat)
+}
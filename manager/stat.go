@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+)
+
+// StateInfo describes the persisted state file.
+type StateInfo struct {
+	Size          int64             `json:"size"`
+	ModTime       time.Time         `json:"mod_time"`
+	Format        SerializationType `json:"format"`
+	SchemaVersion int               `json:"schema_version"`
+	Revision      int64             `json:"revision"`
+	Encryption    string            `json:"encryption,omitempty"`
+	Checksum      string            `json:"checksum"`
+}
+
+// Stat returns the metadata of the persisted state without decoding or
+// verifying the payload. The format, schema version, revision and checksum
+// come from the envelope when present. Otherwise the format is sniffed from
+// the content and the checksum is the SHA-256 of the whole file.
+func (s *StateManager) Stat() (*StateInfo, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := s.files().Stat(s.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	info := &StateInfo{
+		Size:    int64(len(c)),
+		ModTime: fi.ModTime(),
+	}
+
+	if env := envelopeHeader(c); env != nil {
+		info.Format = env.Format
+		info.SchemaVersion = env.SchemaVersion
+		info.Revision = env.Revision
+		info.Encryption = env.Encryption
+		info.Checksum = env.Checksum
+		return info, nil
+	}
+
+	info.Format = DetectFormat(c)
+	info.Checksum = checksum(c)
+
+	return info, nil
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStat ensures the metadata of enveloped and plain state is reported.
+func TestStat(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, YAML)
+	assert.NoError(t, sm.Save(&TestStruct{"Alice", 30, 98.6, true}))
+	assert.NoError(t, sm.Save(&TestStruct{"Bob", 31, 98.6, true}))
+
+	info, err := sm.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, YAML, info.Format)
+	assert.Equal(t, 3, info.SchemaVersion)
+	assert.Equal(t, int64(2), info.Revision)
+	assert.Empty(t, info.Encryption)
+	assert.NotEmpty(t, info.Checksum)
+	assert.False(t, info.ModTime.IsZero())
+
+	fi, err := os.Stat(sm.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, fi.Size(), info.Size)
+
+	plain, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "plain")), WithSerializationType(JSON))
+	assert.NoError(t, err)
+	assert.NoError(t, plain.Save(&TestStruct{Name: "Alice"}))
+
+	c, err := os.ReadFile(plain.FilePath)
+	assert.NoError(t, err)
+
+	info, err = plain.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, JSON, info.Format)
+	assert.Equal(t, checksum(c), info.Checksum)
+	assert.Zero(t, info.Revision)
+}
+
+// TestStatNotFound ensures Stat reports missing state.
+func TestStatNotFound(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	_, err := sm.Stat()
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	// generic state, e.g. map[string]interface{}, nests these in BIN
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// stateMapValues returns the values to encode for the entries of a map kept
+// as state without a struct. The entries are encoded like struct fields and
+// written in the order of their keys.
+func stateMapValues(v reflect.Value, names FieldNameMapper) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := stateMapKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		val, err := stateValue(iter.Value(), names)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = val
+	}
+	return values, nil
+}
+
+// setStateMap sets the entries of the map kept as state from the decoded
+// values, keeping other entries like encoding/json does.
+func setStateMap(m reflect.Value, values map[string]interface{}, names FieldNameMapper) error {
+	t := m.Type()
+	if m.IsNil() {
+		m.Set(reflect.MakeMapWithSize(t, len(values)))
+	}
+	for k, val := range values {
+		key := reflect.New(t.Key()).Elem()
+		if t.Key().Kind() == reflect.String {
+			key.SetString(k)
+		} else if err := yaml.Unmarshal([]byte(k), key.Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid %s map key %q: %w", t.Key(), k, err)
+		}
+
+		elem := reflect.New(t.Elem()).Elem()
+		if err := setStateField(elem, val, k+".", names); err != nil {
+			return err
+		}
+		m.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// stateMapKey returns the STATE key of the map key.
+func stateMapKey(k reflect.Value) (string, error) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("unsupported map key type: %s", k.Type())
+	}
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSaveMap ensures generic map state round trips in all formats.
+func TestSaveMap(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			sm, err := NewStateManager(WithFilePath(filepath.Join(t.TempDir(), "state")), WithSerializationType(st))
+			assert.NoError(t, err)
+
+			data := map[string]interface{}{
+				"name":    "tool",
+				"enabled": true,
+				"nested":  map[string]interface{}{"list": []interface{}{"a", "b"}},
+			}
+			assert.NoError(t, sm.Save(data))
+
+			loaded := map[string]interface{}{"stale": 1}
+			assert.NoError(t, sm.Load(&loaded))
+			assert.Equal(t, data, loaded)
+
+			counts := map[string]int{"b": 2, "a": 1}
+			assert.NoError(t, sm.Save(counts))
+			var loadedCounts map[string]int
+			assert.NoError(t, sm.Load(&loadedCounts))
+			assert.Equal(t, counts, loadedCounts)
+		})
+	}
+}
+
+// TestSaveMapState ensures maps in the STATE format are ordered by key and encode their entries like fields.
+func TestSaveMapState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(STATE))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(map[string]int{"c": 3, "a": 1, "b": 2}))
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1\nb: 2\nc: 3\n", string(c))
+
+	users := map[int]TestStruct{1: {Name: "alice", Age: 30}}
+	assert.NoError(t, sm.Save(users))
+	var loaded map[int]TestStruct
+	assert.NoError(t, sm.Load(&loaded))
+	assert.Equal(t, users, loaded)
+
+	assert.Error(t, sm.Save(map[float64]string{1.5: "a"}))
+}
@@ -0,0 +1,196 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store is a key-value backend the state, its backups and snapshots are
+// persisted to, see WithStore. Keys are the slash separated, cleaned file
+// paths the manager would otherwise write. Get fails with an error matching
+// ErrNotFound or fs.ErrNotExist for missing keys and Delete of a missing key
+// succeeds. See store/bolt and store/badgerdb for embedded databases.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+	List(prefix string) ([]string, error)
+}
+
+// Renamer is implemented by stores able to move a value between keys in a
+// single transaction. Other stores are written with Put and Delete.
+type Renamer interface {
+	Rename(oldKey, newKey string) error
+}
+
+// StoreLocker is implemented by stores guarding keys with their own locks,
+// used by WithFileLock in place of the lock file. Lock blocks until the lock
+// is acquired or, with a positive timeout, fails with ErrLocked once it passed.
+type StoreLocker interface {
+	Lock(key string, exclusive bool, timeout time.Duration) (unlock func(), err error)
+}
+
+// WithStore persists the state to the key-value store instead of files.
+// Values are replaced with a single Put, so stores with cheap writes suit
+// state saved many times per second. WithFileLock uses the locks of stores
+// implementing StoreLocker and has no effect otherwise. Without modification
+// times in the store, WithTTL needs WithEnvelope.
+func WithStore(store Store) StateOption {
+	return func(s *StateManager) {
+		if store == nil {
+			s.optionErr = errors.New("store must not be nil")
+			return
+		}
+		s.fs = StoreFS(store)
+	}
+}
+
+// storeFS adapts a Store to FS.
+type storeFS struct {
+	store Store
+}
+
+// StoreFS returns an FS persisting files as the values of the store.
+// Directories and file modes are not kept.
+func StoreFS(store Store) FS {
+	return &storeFS{store: store}
+}
+
+// key turns the file path into the store key.
+func (f *storeFS) key(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+func (f *storeFS) ReadFile(name string) ([]byte, error) {
+	b, err := f.store.Get(f.key(name))
+	if err != nil {
+		return nil, f.pathError("read", name, err)
+	}
+	return b, nil
+}
+
+func (f *storeFS) WriteFile(name string, data []byte, _ os.FileMode) error {
+	if err := f.store.Put(f.key(name), data); err != nil {
+		return f.pathError("write", name, err)
+	}
+	return nil
+}
+
+func (f *storeFS) Rename(oldpath, newpath string) error {
+	if r, ok := f.store.(Renamer); ok {
+		if err := r.Rename(f.key(oldpath), f.key(newpath)); err != nil {
+			return f.pathError("rename", oldpath, err)
+		}
+		return nil
+	}
+
+	b, err := f.ReadFile(oldpath)
+	if err != nil {
+		return err
+	}
+	if err := f.WriteFile(newpath, b, 0); err != nil {
+		return err
+	}
+	return f.Remove(oldpath)
+}
+
+func (f *storeFS) Remove(name string) error {
+	if err := f.store.Delete(f.key(name)); err != nil {
+		return f.pathError("remove", name, err)
+	}
+	return nil
+}
+
+func (f *storeFS) MkdirAll(_ string, _ os.FileMode) error {
+	return nil
+}
+
+func (f *storeFS) Chmod(_ string, _ os.FileMode) error {
+	return nil
+}
+
+func (f *storeFS) Stat(name string) (os.FileInfo, error) {
+	b, err := f.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return storeInfo{name: path.Base(f.key(name)), size: int64(len(b))}, nil
+}
+
+func (f *storeFS) ReadDir(name string) ([]os.DirEntry, error) {
+	prefix := f.key(name) + "/"
+	if prefix == "//" {
+		prefix = "/"
+	}
+
+	keys, err := f.store.List(prefix)
+	if err != nil {
+		return nil, f.pathError("readdir", name, err)
+	}
+
+	entries := make([]os.DirEntry, 0, len(keys))
+	for _, k := range keys {
+		rest := strings.TrimPrefix(k, prefix)
+		if rest == "" || strings.Contains(rest, "/") {
+			continue
+		}
+		b, err := f.store.Get(k)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(storeInfo{name: rest, size: int64(len(b))}))
+	}
+	if len(entries) == 0 {
+		return nil, f.pathError("readdir", name, fs.ErrNotExist)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// lockStore takes the lock of the key in stores implementing StoreLocker.
+func (s *StateManager) lockStore(name string, exclusive bool) (func(), bool, error) {
+	f, ok := s.files().(*storeFS)
+	if !ok {
+		return nil, false, nil
+	}
+
+	l, ok := f.store.(StoreLocker)
+	if !ok {
+		return func() {}, true, nil
+	}
+
+	unlock, err := l.Lock(f.key(name), exclusive, s.lockTimeout)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to acquire store lock: %w", err)
+	}
+	return unlock, true, nil
+}
+
+// pathError wraps the store error, mapping ErrNotFound to fs.ErrNotExist.
+func (f *storeFS) pathError(op, name string, err error) error {
+	if errors.Is(err, ErrNotFound) && !errors.Is(err, fs.ErrNotExist) {
+		err = fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// storeInfo describes a value of the store as a file.
+type storeInfo struct {
+	name string
+	size int64
+}
+
+func (i storeInfo) Name() string       { return i.name }
+func (i storeInfo) Size() int64        { return i.size }
+func (i storeInfo) Mode() os.FileMode  { return DefaultFileMode }
+func (i storeInfo) ModTime() time.Time { return time.Time{} }
+func (i storeInfo) IsDir() bool        { return false }
+func (i storeInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapStore is a Store without Rename.
+type mapStore map[string][]byte
+
+func (m mapStore) Get(key string) ([]byte, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (m mapStore) Put(key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapStore) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m mapStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// TestWithStore ensures the state round-trips through a store without temp keys left behind.
+func TestWithStore(t *testing.T) {
+	store := mapStore{}
+	var backend string
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/data/../app/state"), WithSerializationType(YAML),
+		WithEnvelope(), WithTTL(time.Hour), WithMetrics(MetricsFunc(func(o Observation) { backend = o.Backend })))
+	assert.NoError(t, err)
+
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	assert.NoError(t, sm.Save(data))
+	assert.Equal(t, "store", backend)
+	assert.Len(t, store, 1)
+	assert.Contains(t, store, "/app/state")
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, data, loaded)
+
+	info, err := sm.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(store["/app/state"])), info.Size)
+}
+
+// TestStoreFS ensures missing keys surface as fs.ErrNotExist and ErrNotFound.
+func TestStoreFS(t *testing.T) {
+	f := StoreFS(mapStore{"/a/b": []byte("x"), "/a/c/d": []byte("yz")})
+
+	_, err := f.ReadFile("/a/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	entries, err := f.ReadDir("/a")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "b", entries[0].Name())
+
+	_, err = f.ReadDir("/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.NoError(t, f.Rename("/a/b", "/a/e"))
+	_, err = f.Stat("/a/b")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	info, err := f.Stat("/a/e")
+	assert.NoError(t, err)
+	assert.Equal(t, "e", info.Name())
+	assert.Equal(t, int64(1), info.Size())
+}
+
+// TestWithStoreNil ensures a nil store is rejected.
+func TestWithStoreNil(t *testing.T) {
+	_, err := NewStateManager(WithStore(nil))
+	assert.Error(t, err)
+}
+
+// TestWithStoreVersion ensures optimistic concurrency works on stores.
+func TestWithStoreVersion(t *testing.T) {
+	store := mapStore{}
+	sm, err := NewStateManager(WithStore(store), WithFilePath("/state"), WithEnvelope())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.SaveIfVersion(&TestStruct{Name: "Alice"}, 0))
+	assert.ErrorIs(t, sm.SaveIfVersion(&TestStruct{Name: "Bob"}, 0), ErrConflict)
+
+	loaded := &TestStruct{}
+	v, err := sm.LoadVersion(loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	assert.NoError(t, sm.SaveIfVersion(&TestStruct{Name: "Bob"}, v))
+	assert.Len(t, store, 1)
+}
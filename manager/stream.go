@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+)
+
+// SaveTo encodes the given struct to the writer using the manager format and
+// options (validation, envelope) without touching the state file.
+func (s *StateManager) SaveTo(w io.Writer, data interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if err := s.validate(data); err != nil {
+		return err
+	}
+
+	payload, err := s.encode(data)
+	if err != nil {
+		return err
+	}
+
+	b, err := s.seal(payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+
+	return nil
+}
+
+// Encode returns the file content Save would write for the given struct,
+// running the before save hooks, validation, field encryption, envelope,
+// encryption and signing, without writing anything. The after save hooks are
+// not run. Use it to preview changes or to assert on the serialized state.
+func (s *StateManager) Encode(data interface{}) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	payload, err := s.prepare(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.seal(payload)
+}
+
+// LoadFrom decodes the struct from the reader using the manager format and
+// options (envelope, defaults, validation) without touching the state file.
+func (s *StateManager) LoadFrom(r io.Reader, data interface{}) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	c, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+
+	return s.decode(c, data)
+}
@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(_ []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+// TestSaveToAndLoadFrom ensures state round-trips through streams in all formats.
+func TestSaveToAndLoadFrom(t *testing.T) {
+	for _, st := range []SerializationType{JSON, YAML, BIN, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			sm := setupTempStateManager(t, st)
+			data := &TestStruct{"Alice", 30, 98.6, true}
+
+			var buf bytes.Buffer
+			assert.NoError(t, sm.SaveTo(&buf, data))
+			assert.NotZero(t, buf.Len())
+			assert.False(t, sm.Exists())
+
+			loaded := &TestStruct{}
+			assert.NoError(t, sm.LoadFrom(&buf, loaded))
+			assert.Equal(t, data, loaded)
+		})
+	}
+}
+
+// TestSaveToErrors ensures writer and decode errors are returned.
+func TestSaveToErrors(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	assert.Error(t, sm.SaveTo(failingWriter{}, &TestStruct{}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, sm.SaveTo(&buf, &TestStruct{Name: "a"}))
+	truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-2])
+	assert.ErrorIs(t, sm.LoadFrom(truncated, &TestStruct{}), ErrCorrupted)
+}
+
+// TestEncode ensures Encode returns what Save writes without writing it.
+func TestEncode(t *testing.T) {
+	sm := setupEnvelopeStateManager(t, JSON)
+	hooked := 0
+	sm.OnBeforeSave(func(data interface{}) error {
+		hooked++
+		data.(*TestStruct).Age = 42
+		return nil
+	})
+
+	data := &TestStruct{Name: "Alice"}
+	b, err := sm.Encode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hooked)
+	assert.Contains(t, string(b), `"age": 42`)
+	assert.False(t, sm.Exists())
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.LoadFrom(bytes.NewReader(b), loaded))
+	assert.Equal(t, data, loaded)
+
+	sm.OnBeforeSave(func(_ interface{}) error { return errors.New("rejected") })
+	_, err = sm.Encode(data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,767 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// streamChunkSize is the number of slice elements or map entries written
+// per segment of a streamed BIN file.
+const streamChunkSize = 1024
+
+// binStreamMagic prefixes BIN files written by streaming managers, which
+// hold a sequence of gob segments instead of a single gob value.
+var binStreamMagic = []byte("\x00gobstream\x01")
+
+// WithStreaming encodes the state directly into the file and decodes it
+// while reading the file, so saving and loading a state of hundreds of
+// megabytes does not hold the whole file in memory. JSON is written and read
+// field by field with the elements of top-level slices and maps one at a
+// time and produces the same file as without streaming. BIN is written as a
+// sequence of gob segments, splitting slices and maps into chunks, which
+// managers without streaming read as well. Streaming requires the file
+// system of the operating system and supports neither YAML nor STATE nor the
+// options which process the whole content, such as envelopes, encryption,
+// signatures, baselines, canonical output, directories, split files, shards,
+// incremental saves, debouncing and document validation.
+func WithStreaming() StateOption {
+	return func(s *StateManager) {
+		s.streaming = true
+	}
+}
+
+// streamable returns an error when the manager settings can not be streamed.
+func (s *StateManager) streamable() error {
+	switch {
+	case s.SerializationType != JSON && s.SerializationType != BIN:
+		return fmt.Errorf("%w: streaming supports JSON and BIN, not %q", ErrUnsupportedFormat, s.SerializationType)
+	case !s.osFiles():
+		return errors.New("streaming requires the file system of the operating system")
+	case s.envelope || s.encrypted() || s.signingKey != nil || s.verifyKey != nil:
+		return errors.New("streaming does not support envelopes, encryption or signatures")
+	case s.baseline != nil || s.canonical || s.directory || len(s.splits) > 0 || len(s.shards) > 0 || s.compactEvery > 0 || s.debounce > 0 || s.schema != nil:
+		return errors.New("streaming does not support baselines, canonical output, directories, split files, shards, incremental saves, debouncing or document validation")
+	}
+	return nil
+}
+
+// persistStream encodes the given struct directly into the state file.
+// Caller must hold the lock.
+func (s *StateManager) persistStream(data interface{}, skipUnchanged bool) (err error) {
+	start := s.now()
+	var size int64
+	defer func() { s.observe(OperationSave, start, int(size), err) }()
+
+	if err := runHooks(s.hooks.beforeSave, data); err != nil {
+		return err
+	}
+
+	if err := s.validate(data); err != nil {
+		return err
+	}
+
+	sealed, err := s.sealFields(data)
+	if err != nil {
+		return err
+	}
+
+	if s.createDirs {
+		if err := os.MkdirAll(filepath.Dir(s.FilePath), 0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	tempFile := s.FilePath + ".tmp"
+	sum, size, err := s.writeStream(tempFile, sealed)
+	if err != nil {
+		_ = os.Remove(tempFile)
+		return err
+	}
+
+	if skipUnchanged && sum == s.lastChecksum && s.exists(s.FilePath) {
+		return os.Remove(tempFile)
+	}
+
+	if err := s.checkWriteSize(s.FilePath, size); err != nil {
+		_ = os.Remove(tempFile)
+		return err
+	}
+
+	if s.backups > 0 {
+		if err := s.rotateBackups(); err != nil {
+			_ = os.Remove(tempFile)
+			return err
+		}
+	}
+
+	if err := s.retry("rename", func() error { return os.Rename(tempFile, s.FilePath) }); err != nil {
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	if s.durable {
+		if err := syncParent(s.FilePath); err != nil {
+			return err
+		}
+	}
+
+	s.lastChecksum = sum
+	s.pending = nil
+
+	if s.autoPrune != nil {
+		if _, err := s.prune(*s.autoPrune); err != nil {
+			return err
+		}
+	}
+
+	return runHooks(s.hooks.afterSave, data)
+}
+
+// writeStream encodes the given struct into the file at path and returns the
+// checksum and size of the written content.
+func (s *StateManager) writeStream(path string, data interface{}) (string, int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write to temp file: %w", err)
+	}
+	defer f.Close()
+
+	// Enforce the mode regardless of umask or a leftover temp file
+	if err := f.Chmod(s.fileMode); err != nil {
+		return "", 0, fmt.Errorf("failed to set file mode: %w", err)
+	}
+
+	buf := bufio.NewWriter(f)
+	w := &streamWriter{w: buf, hash: sha256.New(), limit: s.maxSize}
+
+	if s.SerializationType == JSON {
+		err = encodeJSONStream(w, data, s.jsonLayout())
+	} else {
+		err = encodeBinStream(w, data)
+	}
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return "", 0, err
+		}
+		return "", 0, fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	if w.size == 0 {
+		return "", 0, fmt.Errorf("no data was encoded")
+	}
+
+	if err := buf.Flush(); err != nil {
+		return "", 0, fmt.Errorf("failed to write to temp file: %w", err)
+	}
+	if s.durable {
+		if err := f.Sync(); err != nil {
+			return "", 0, fmt.Errorf("failed to write to temp file: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write to temp file: %w", err)
+	}
+
+	return hex.EncodeToString(w.hash.Sum(nil)), w.size, nil
+}
+
+// loadStream decodes the struct while reading the state file. Caller must
+// hold the read lock.
+func (s *StateManager) loadStream(data interface{}) (err error) {
+	start := s.now()
+	var size int64
+	defer func() { s.observe(OperationLoad, start, int(size), err) }()
+
+	if err := runHooks(s.hooks.beforeLoad, data); err != nil {
+		return err
+	}
+
+	if err := s.checkReadSize(s.FilePath); err != nil {
+		return err
+	}
+
+	f, err := os.Open(s.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read file: %w: %w", ErrNotFound, err)
+		}
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	if err := s.expire(nil); err != nil {
+		return err
+	}
+	s.fixFileMode(s.FilePath)
+
+	r := &streamReader{r: f}
+	if s.SerializationType == JSON {
+		err = decodeJSONStream(bufio.NewReader(r), data, s.strict)
+	} else {
+		err = decodeBinStream(bufio.NewReader(r), data)
+	}
+	size = r.size
+	if err != nil {
+		var unknown *UnknownKeysError
+		if errors.As(err, &unknown) {
+			return err
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to decode data: %w: %w", ErrCorrupted, err)
+		}
+		return fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	if err := s.complete(data); err != nil {
+		return err
+	}
+
+	return runHooks(s.hooks.afterLoad, data)
+}
+
+// streamWriter hashes and counts the written content, failing with
+// ErrTooLarge once it exceeds the limit.
+type streamWriter struct {
+	w     io.Writer
+	hash  hash.Hash
+	size  int64
+	limit int64
+}
+
+// Write writes p to the underlying writer.
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	if w.limit > 0 && w.size > w.limit {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, w.limit)
+	}
+	w.hash.Write(p)
+	return w.w.Write(p)
+}
+
+// streamReader counts the content read.
+type streamReader struct {
+	r    io.Reader
+	size int64
+}
+
+// Read reads from the underlying reader.
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// streamFields returns the struct v points to and its exported fields, or
+// false when the struct must be encoded as a whole, e.g. as it marshals
+// itself or embeds other structs.
+func streamFields(v reflect.Value) (reflect.Value, []reflect.StructField, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || decodesItself(v.Type()) || encodesItself(v.Type()) {
+		return v, nil, false
+	}
+
+	t := v.Type()
+	fields := make([]reflect.StructField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			return v, nil, false
+		}
+		if !field.IsExported() || excludedField(field) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return v, fields, true
+}
+
+// encodesItself reports whether values of t implement their own JSON, text or gob encoding.
+func encodesItself(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	for _, i := range []reflect.Type{
+		reflect.TypeOf((*json.Marshaler)(nil)).Elem(),
+		reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+		reflect.TypeOf((*gob.GobEncoder)(nil)).Elem(),
+		reflect.TypeOf((*Marshaler)(nil)).Elem(),
+	} {
+		if t.Implements(i) || p.Implements(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeJSONStream writes the struct as JSON in the given layout, encoding
+// the elements of top-level slices and maps one at a time.
+func encodeJSONStream(w io.Writer, data interface{}, layout jsonLayout) error {
+	v, fields, ok := streamFields(reflect.ValueOf(data))
+	if !ok {
+		return writeJSON(w, data, "", layout)
+	}
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+
+	written := 0
+	for _, field := range fields {
+		key, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "-" && opts == "" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		fv := v.FieldByIndex(field.Index)
+		if hasOption(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		sep := "," + layout.newline(layout.indent)
+		if written == 0 {
+			sep = layout.newline(layout.indent)
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s", sep, name, layout.colon()); err != nil {
+			return err
+		}
+
+		if hasOption(opts, "string") {
+			err = writeJSON(w, fv.Interface(), layout.indent, layout)
+		} else {
+			err = encodeJSONValue(w, fv, layout.indent, layout)
+		}
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		written++
+	}
+
+	if written == 0 {
+		_, err := io.WriteString(w, "}")
+		return err
+	}
+	_, err := io.WriteString(w, layout.newline("")+"}")
+	return err
+}
+
+// encodeJSONValue writes the value at the given indentation, encoding the
+// elements of slices and maps with string keys one at a time.
+func encodeJSONValue(w io.Writer, v reflect.Value, indent string, layout jsonLayout) error {
+	if encodesItself(v.Type()) || (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return writeJSON(w, v.Interface(), indent, layout)
+	}
+
+	inner := indent + layout.indent
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		if v.Len() == 0 {
+			_, err := io.WriteString(w, "[]")
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			sep := ","
+			if i == 0 {
+				sep = "["
+			}
+			if _, err := io.WriteString(w, sep+layout.newline(inner)); err != nil {
+				return err
+			}
+			if err := writeJSON(w, v.Index(i).Interface(), inner, layout); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, layout.newline(indent)+"]")
+		return err
+
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && !encodesItself(v.Type().Key()):
+		if v.Len() == 0 {
+			_, err := io.WriteString(w, "{}")
+			return err
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for i, key := range keys {
+			name, err := json.Marshal(key.String())
+			if err != nil {
+				return err
+			}
+			sep := ","
+			if i == 0 {
+				sep = "{"
+			}
+			if _, err := fmt.Fprintf(w, "%s%s%s%s", sep, layout.newline(inner), name, layout.colon()); err != nil {
+				return err
+			}
+			if err := writeJSON(w, v.MapIndex(key).Interface(), inner, layout); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, layout.newline(indent)+"}")
+		return err
+	}
+
+	return writeJSON(w, v.Interface(), indent, layout)
+}
+
+// writeJSON writes the value as JSON in the layout at the given indentation.
+func writeJSON(w io.Writer, value interface{}, indent string, layout jsonLayout) error {
+	var b []byte
+	var err error
+	if layout.compact {
+		b, err = json.Marshal(value)
+	} else {
+		b, err = json.MarshalIndent(value, indent, layout.indent)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// hasOption reports whether the comma separated tag options contain option.
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSONStream decodes the struct from JSON, decoding the elements of
+// top-level slices and maps one at a time. Unknown keys fail with
+// UnknownKeysError when strict is set.
+func decodeJSONStream(r io.Reader, data interface{}, strict bool) error {
+	dec := json.NewDecoder(r)
+
+	v, fields, ok := streamFields(reflect.ValueOf(data))
+	if !ok || reflect.ValueOf(data).Kind() != reflect.Ptr {
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		return dec.Decode(data)
+	}
+
+	keys := make(map[string]reflect.StructField, len(fields))
+	for _, field := range fields {
+		key, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "-" && opts == "" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		keys[key] = field
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: v.Type()}
+	}
+
+	var unknown []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+
+		field, found := keys[key]
+		if !found {
+			for k, f := range keys {
+				if strings.EqualFold(k, key) {
+					field, found = f, true
+					break
+				}
+			}
+		}
+
+		if !found {
+			if strict {
+				unknown = append(unknown, key)
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, opts, _ := strings.Cut(field.Tag.Get("json"), ","); hasOption(opts, "string") {
+			err = dec.Decode(v.FieldByIndex(field.Index).Addr().Interface())
+		} else {
+			err = decodeJSONValue(dec, v.FieldByIndex(field.Index))
+		}
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return &UnknownKeysError{Keys: unknown}
+	}
+	return nil
+}
+
+// decodeJSONValue decodes the next value into v, decoding the elements of
+// slices and maps with string keys one at a time.
+func decodeJSONValue(dec *json.Decoder, v reflect.Value) error {
+	t := v.Type()
+	isSlice := t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8
+	isMap := t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+	if !isSlice && !isMap || decodesItself(t) || isMap && encodesItself(t.Key()) {
+		return dec.Decode(v.Addr().Interface())
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+
+	if isSlice {
+		if tok != json.Delim('[') {
+			return &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: t}
+		}
+		v.Set(reflect.MakeSlice(t, 0, 0))
+		for dec.More() {
+			elem := reflect.New(t.Elem())
+			if err := dec.Decode(elem.Interface()); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem.Elem()))
+		}
+	} else {
+		if tok != json.Delim('{') {
+			return &json.UnmarshalTypeError{Value: fmt.Sprint(tok), Type: t}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			elem := reflect.New(t.Elem())
+			if err := dec.Decode(elem.Interface()); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(tok.(string)).Convert(t.Key()), elem.Elem())
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// binSegment precedes the gob encoded value of a field in a streamed BIN
+// file. Chunk segments hold part of the elements of a slice or map.
+type binSegment struct {
+	Field string
+	Chunk bool
+}
+
+// encodeBinStream writes the struct as a sequence of gob segments, one per
+// field and one per chunk of the elements of slices and maps.
+func encodeBinStream(w io.Writer, data interface{}) error {
+	v, fields, ok := streamFields(reflect.ValueOf(data))
+	if !ok {
+		b, err := binaryMarshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	if _, err := w.Write(binStreamMagic); err != nil {
+		return err
+	}
+
+	enc := gob.NewEncoder(w)
+	for _, field := range fields {
+		fv := v.FieldByIndex(field.Index)
+		if fv.IsZero() || !gobbable(fv.Type()) {
+			continue
+		}
+
+		if err := encodeBinField(enc, field.Name, fv); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// gobbable reports whether gob encodes values of t, which excludes the types
+// it silently skips as struct fields.
+func gobbable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func:
+		return false
+	}
+	return true
+}
+
+// encodeBinField writes the segments of the field value.
+func encodeBinField(enc *gob.Encoder, name string, v reflect.Value) error {
+	t := v.Type()
+	chunked := (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 || t.Kind() == reflect.Map) &&
+		!encodesItself(t) && v.Len() > streamChunkSize
+	if !chunked {
+		if err := enc.Encode(binSegment{Field: name}); err != nil {
+			return err
+		}
+		return enc.EncodeValue(v)
+	}
+
+	if t.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i += streamChunkSize {
+			if err := enc.Encode(binSegment{Field: name, Chunk: true}); err != nil {
+				return err
+			}
+			if err := enc.EncodeValue(v.Slice(i, min(i+streamChunkSize, v.Len()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	chunk := reflect.MakeMapWithSize(t, streamChunkSize)
+	flush := func() error {
+		if err := enc.Encode(binSegment{Field: name, Chunk: true}); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(chunk); err != nil {
+			return err
+		}
+		chunk.Clear()
+		return nil
+	}
+	for iter := v.MapRange(); iter.Next(); {
+		chunk.SetMapIndex(iter.Key(), iter.Value())
+		if chunk.Len() == streamChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if chunk.Len() > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// decodeBinStream decodes the struct from a BIN file, either a sequence of
+// gob segments or, when the file was not streamed, a single gob value.
+func decodeBinStream(r *bufio.Reader, data interface{}) error {
+	if prefix, _ := r.Peek(len(binStreamMagic)); !bytes.Equal(prefix, binStreamMagic) {
+		if _, ok := data.(Unmarshaler); ok {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return binaryUnmarshal(b, data)
+		}
+		return gob.NewDecoder(r).Decode(data)
+	}
+	if _, err := r.Discard(len(binStreamMagic)); err != nil {
+		return err
+	}
+
+	v, fields, ok := streamFields(reflect.ValueOf(data))
+	if !ok || reflect.ValueOf(data).Kind() != reflect.Ptr {
+		return errors.New("streamed data must be decoded into a pointer to a struct")
+	}
+
+	byName := make(map[string]reflect.Value, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = v.FieldByIndex(field.Index)
+	}
+
+	dec := gob.NewDecoder(r)
+	started := make(map[string]bool)
+	for {
+		var seg binSegment
+		if err := dec.Decode(&seg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		fv, ok := byName[seg.Field]
+		if !ok {
+			// discard fields the struct does not define
+			if err := dec.DecodeValue(reflect.Value{}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !seg.Chunk {
+			if err := dec.DecodeValue(fv.Addr()); err != nil {
+				return fmt.Errorf("field %s: %w", seg.Field, err)
+			}
+			continue
+		}
+
+		chunk := reflect.New(fv.Type())
+		if err := dec.DecodeValue(chunk); err != nil {
+			return fmt.Errorf("field %s: %w", seg.Field, err)
+		}
+		if !started[seg.Field] {
+			started[seg.Field] = true
+			if fv.Kind() == reflect.Slice {
+				fv.Set(reflect.MakeSlice(fv.Type(), 0, 0))
+			} else if fv.IsNil() {
+				fv.Set(reflect.MakeMap(fv.Type()))
+			}
+		}
+		if fv.Kind() == reflect.Slice {
+			fv.Set(reflect.AppendSlice(fv, chunk.Elem()))
+			continue
+		}
+		for iter := chunk.Elem().MapRange(); iter.Next(); {
+			fv.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+}
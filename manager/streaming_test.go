@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type streamItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type streamState struct {
+	Name    string                `json:"name"`
+	Items   []streamItem          `json:"items"`
+	Index   map[string]streamItem `json:"index,omitempty"`
+	Counts  map[int]int           `json:"counts"`
+	Note    string                `json:"note,omitempty"`
+	Skipped string                `json:"-"`
+	Any     interface{}           `json:"any"`
+	hidden  string
+}
+
+// newStreamState returns a state with more elements than a segment holds.
+func newStreamState(n int) *streamState {
+	st := &streamState{Name: "big", Index: map[string]streamItem{}, Counts: map[int]int{1: 2}, Any: "value"}
+	for i := 0; i < n; i++ {
+		item := streamItem{ID: i, Name: fmt.Sprintf("item-%d", i)}
+		st.Items = append(st.Items, item)
+		st.Index[item.Name] = item
+	}
+	return st
+}
+
+// TestWithStreamingJSON ensures streamed JSON matches the regular encoding both ways.
+func TestWithStreamingJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithStreaming())
+	assert.NoError(t, err)
+
+	original := newStreamState(3000)
+	original.Skipped = "skipped"
+	original.hidden = "hidden"
+	assert.NoError(t, sm.Save(original))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	expected, err := json.MarshalIndent(original, "", "  ")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(c))
+
+	loaded := &streamState{}
+	assert.NoError(t, sm.Load(loaded))
+	original.Skipped, original.hidden = "", ""
+	assert.Equal(t, original, loaded)
+
+	regular, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON))
+	assert.NoError(t, err)
+	assert.NoError(t, regular.Save(&streamState{Name: "small", Items: []streamItem{}}))
+
+	loaded = &streamState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "small", loaded.Name)
+	assert.Equal(t, []streamItem{}, loaded.Items)
+	assert.Nil(t, loaded.Index)
+}
+
+// TestWithStreamingBIN ensures streamed BIN files are chunked and readable without streaming.
+func TestWithStreamingBIN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.bin")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(BIN), WithStreaming())
+	assert.NoError(t, err)
+
+	original := newStreamState(3000)
+	original.Skipped = "skipped"
+	assert.NoError(t, sm.Save(original))
+
+	loaded := &streamState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, original, loaded)
+
+	regular, err := NewStateManager(WithFilePath(path), WithSerializationType(BIN))
+	assert.NoError(t, err)
+	loaded = &streamState{}
+	assert.NoError(t, regular.Load(loaded))
+	assert.Equal(t, original, loaded)
+
+	assert.NoError(t, regular.Save(&TestStruct{Name: "alice", Age: 30}))
+	plain := &TestStruct{}
+	assert.NoError(t, sm.Load(plain))
+	assert.Equal(t, &TestStruct{Name: "alice", Age: 30}, plain)
+}
+
+// TestWithStreamingOptions ensures streaming honors the manager options.
+func TestWithStreamingOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(JSON), WithStreaming(),
+		WithDirtyTracking(), WithStrictDecoding(), WithMaxSize(200))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrNotFound)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chtimes(path, info.ModTime().Add(-1), info.ModTime().Add(-1)))
+	assert.NoError(t, sm.Save(&TestStruct{Name: "alice"}))
+	unchanged, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.ModTime().Add(-1), unchanged.ModTime())
+
+	assert.ErrorIs(t, sm.Save(newStreamState(100)), ErrTooLarge)
+	_, err = os.Stat(path + ".tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"name": "bob", "extra": 1}`), 0600))
+	var unknown *UnknownKeysError
+	assert.ErrorAs(t, sm.Load(&TestStruct{}), &unknown)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"name": "bob", "age": `), 0600))
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrCorrupted)
+}
+
+// TestWithStreamingUnsupported ensures settings which need the whole content are rejected.
+func TestWithStreamingUnsupported(t *testing.T) {
+	_, err := NewStateManager(WithSerializationType(YAML), WithStreaming())
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = NewStateManager(WithSerializationType(JSON), WithEnvelope(), WithStreaming())
+	assert.Error(t, err)
+
+	_, err = NewStateManager(WithSerializationType(JSON), WithStore(mapStore{}), WithStreaming())
+	assert.Error(t, err)
+}
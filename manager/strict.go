@@ -0,0 +1,214 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownKeysError is returned by Load in strict decoding mode when the
+// persisted state contains keys which are not defined by the target struct.
+type UnknownKeysError struct {
+	Keys []string
+}
+
+// Error returns the error message listing the unknown keys.
+func (e *UnknownKeysError) Error() string {
+	return fmt.Sprintf("unknown keys: %s", strings.Join(e.Keys, ", "))
+}
+
+// WithStrictDecoding makes JSON, YAML and STATE loads fail with UnknownKeysError
+// when the file contains keys which do not map to any field of the target.
+func WithStrictDecoding() StateOption {
+	return func(s *StateManager) {
+		s.strict = true
+	}
+}
+
+// keyFunc returns the key of the field in a given format and whether the
+// field is inlined into its parent.
+type keyFunc func(field reflect.StructField) (key string, inline bool)
+
+// checkUnknownKeys returns UnknownKeysError when the payload contains keys not defined by data.
+func checkUnknownKeys(st SerializationType, payload []byte, data interface{}, names FieldNameMapper) error {
+	t := reflect.TypeOf(data)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	values := make(map[string]interface{})
+	var keyOf keyFunc
+	fold := false
+
+	switch st {
+	case JSON:
+		if err := json.Unmarshal(payload, &values); err != nil {
+			return fmt.Errorf("failed to decode data: %w", err)
+		}
+		keyOf = jsonKey
+		fold = true
+	case YAML:
+		if err := yaml.Unmarshal(payload, &values); err != nil {
+			return fmt.Errorf("failed to decode data: %w", err)
+		}
+		keyOf = yamlKey
+	case STATE:
+		if err := yaml.Unmarshal(payload, &values); err != nil {
+			return fmt.Errorf("failed to decode data: %w", err)
+		}
+		keyOf = stateKey
+		if names != nil {
+			keyOf = func(field reflect.StructField) (string, bool) {
+				return fieldKeyNamed(STATE, field, names)
+			}
+		}
+	default:
+		return nil
+	}
+
+	unknown := unknownKeys(t.Elem(), values, keyOf, fold, "")
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+	return &UnknownKeysError{Keys: unknown}
+}
+
+// unknownKeys collects the keys of values which do not map to a field of t.
+func unknownKeys(t reflect.Type, values map[string]interface{}, keyOf keyFunc, fold bool, prefix string) []string {
+	if decodesItself(t) {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type)
+	collectKeys(t, keyOf, fold, fields)
+
+	list := make([]string, 0)
+	for k, v := range values {
+		lookup := k
+		if fold {
+			lookup = strings.ToLower(k)
+		}
+
+		ft, ok := fields[lookup]
+		if !ok {
+			list = append(list, prefix+k)
+			continue
+		}
+
+		list = append(list, unknownNestedKeys(ft, v, keyOf, fold, prefix+k)...)
+	}
+
+	return list
+}
+
+// unknownNestedKeys checks the value of a nested struct or a slice of structs.
+func unknownNestedKeys(t reflect.Type, v interface{}, keyOf keyFunc, fold bool, prefix string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if t.Kind() == reflect.Struct {
+			return unknownKeys(t, val, keyOf, fold, prefix+".")
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			list := make([]string, 0)
+			for i, item := range val {
+				list = append(list, unknownNestedKeys(t.Elem(), item, keyOf, fold, fmt.Sprintf("%s[%d]", prefix, i))...)
+			}
+			return list
+		}
+	}
+
+	return nil
+}
+
+// collectKeys adds the keys of all the fields of t, including inlined ones.
+func collectKeys(t reflect.Type, keyOf keyFunc, fold bool, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		key, inline := keyOf(field)
+		if inline {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectKeys(ft, keyOf, fold, fields)
+			}
+			continue
+		}
+
+		if key == "" {
+			continue
+		}
+
+		if fold {
+			key = strings.ToLower(key)
+		}
+		fields[key] = field.Type
+	}
+}
+
+// decodesItself checks if the type handles its own decoding.
+func decodesItself(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return p.Implements(reflect.TypeOf((*Unmarshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()) ||
+		t == timeType
+}
+
+// jsonKey returns the key of the field as used by encoding/json.
+func jsonKey(field reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" && field.Anonymous {
+		return "", true
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, false
+}
+
+// yamlKey returns the key of the field as used by gopkg.in/yaml.v3.
+func yamlKey(field reflect.StructField) (string, bool) {
+	name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, false
+}
+
+// stateKey returns the key of the field as used by the STATE format.
+func stateKey(field reflect.StructField) (string, bool) {
+	tag := parseStateTag(field)
+	if tag.inline(field) {
+		return "", true
+	}
+	if excludedField(field) {
+		return "", false
+	}
+	return tag.key(field), false
+}
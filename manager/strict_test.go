@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type strictInner struct {
+	Host string `json:"host" yaml:"host" state:"host"`
+}
+
+type strictStruct struct {
+	Name  string        `json:"name" yaml:"name" state:"name"`
+	Inner strictInner   `json:"inner" yaml:"inner" state:"inner"`
+	List  []strictInner `json:"list" yaml:"list" state:"list"`
+}
+
+// TestStrictDecoding ensures unknown keys are reported for all text formats.
+func TestStrictDecoding(t *testing.T) {
+	cases := map[SerializationType]string{
+		JSON:  `{"name": "a", "nmae": "b", "inner": {"host": "h", "port": 1}, "list": [{"hots": "x"}]}`,
+		YAML:  "name: a\nnmae: b\ninner:\n  host: h\n  port: 1\nlist:\n  - hots: x\n",
+		STATE: "name: a\nnmae: b\ninner:\n  host: h\n  port: 1\nlist:\n  - hots: x\n",
+	}
+
+	for st, content := range cases {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test_state")
+			assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+			sm, err := NewStateManager(
+				WithFilePath(path),
+				WithSerializationType(st),
+				WithStrictDecoding(),
+			)
+			assert.NoError(t, err)
+
+			var unknown *UnknownKeysError
+			assert.ErrorAs(t, sm.Load(&strictStruct{}), &unknown)
+			assert.Equal(t, []string{"inner.port", "list[0].hots", "nmae"}, unknown.Keys)
+			assert.Contains(t, unknown.Error(), "nmae")
+
+			lenient, err := NewStateManager(
+				WithFilePath(path),
+				WithSerializationType(st),
+			)
+			assert.NoError(t, err)
+			assert.NoError(t, lenient.Load(&strictStruct{}))
+		})
+	}
+}
+
+// TestStrictDecodingKnownKeys ensures valid files load in strict mode.
+func TestStrictDecodingKnownKeys(t *testing.T) {
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithStrictDecoding(),
+	)
+	assert.NoError(t, err)
+
+	data := &TestStruct{"Alice", 30, 98.6, true}
+	assert.NoError(t, sm.Save(data))
+
+	loaded := &TestStruct{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, data, loaded)
+}
@@ -0,0 +1,263 @@
+package manager
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// Tag options
+	tagOmitEmpty = "omitempty"
+	tagRequired  = "required"
+	tagEncrypt   = "encrypt"
+	tagRedact    = "redact"
+	tagFile      = "file"
+	tagShards    = "shards"
+	tagInline    = "inline"
+)
+
+// MissingFieldError is returned by Load when a required field is absent from the persisted state.
+type MissingFieldError struct {
+	Key string
+}
+
+// Error returns the error message.
+func (e *MissingFieldError) Error() string {
+	return fmt.Sprintf("required field %q is missing", e.Key)
+}
+
+// stateTag is the parsed state annotation of a field, e.g. `state:"name,omitempty"`.
+type stateTag struct {
+	name    string
+	options []string
+}
+
+// parseStateTag splits the state annotation of the field into its name and options.
+func parseStateTag(field reflect.StructField) stateTag {
+	parts := strings.Split(field.Tag.Get(StateAnnotationKey), ",")
+	return stateTag{
+		name:    parts[0],
+		options: parts[1:],
+	}
+}
+
+// has checks if the tag includes the given option.
+func (t stateTag) has(option string) bool {
+	for _, o := range t.options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of an option of the form option=value, e.g. file=secrets.yaml.
+func (t stateTag) value(option string) string {
+	for _, o := range t.options {
+		if v, ok := strings.CutPrefix(o, option+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// tagged checks if the field has a state annotation at all.
+func (t stateTag) tagged() bool {
+	return t.name != "" || len(t.options) > 0
+}
+
+// key returns the key under which the field is persisted.
+func (t stateTag) key(field reflect.StructField) string {
+	if t.name != "" {
+		return t.name
+	}
+	return strings.ToLower(field.Name)
+}
+
+// inline reports whether the fields of the struct field are persisted as
+// keys of its parent: when annotated with the inline option, e.g.
+// `state:",inline"`, or when embedded without an annotation, like
+// encoding/json does. Embedded structs with a key are nested under it.
+func (t stateTag) inline(field reflect.StructField) bool {
+	ft := field.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct || ft == timeType {
+		return false
+	}
+
+	if t.has(tagInline) {
+		return true
+	}
+	return field.Anonymous && !t.tagged() && hasStateTags(ft)
+}
+
+// embedded reports whether the field embeds a struct without an annotation,
+// which is inlined when fields are named by a mapper.
+func (t stateTag) embedded(field reflect.StructField) bool {
+	ft := field.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	return field.Anonymous && !t.tagged() && ft.Kind() == reflect.Struct && ft != timeType
+}
+
+// hasStateTags reports whether any field of the struct type has a state
+// annotation, without analyzing embedded structs which may embed t again.
+func hasStateTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup(StateAnnotationKey); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether the value is empty for the purpose of omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// FieldKey returns the key under which the field is persisted in the given
+// serialization type and whether the field is inlined into its parent.
+// An empty key means the field is not persisted. Other formats use the JSON keys.
+func FieldKey(st SerializationType, field reflect.StructField) (key string, inline bool) {
+	if !field.IsExported() && !field.Anonymous {
+		return "", false
+	}
+
+	switch st {
+	case YAML:
+		return yamlKey(field)
+	case XML:
+		return xmlKey(field)
+	case STATE:
+		tag := parseStateTag(field)
+		if tag.inline(field) {
+			return "", true
+		}
+		if !tag.tagged() || !field.IsExported() || excludedField(field) {
+			return "", false
+		}
+		return tag.key(field), false
+	default:
+		return jsonKey(field)
+	}
+}
+
+// FieldRequired reports whether the field is annotated as required, e.g. `state:"name,required"`.
+func FieldRequired(field reflect.StructField) bool {
+	return parseStateTag(field).has(tagRequired)
+}
+
+// hasAny checks if the tag includes any of the given options.
+func (t stateTag) hasAny(options ...string) bool {
+	for _, o := range options {
+		if t.has(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTaggedFields checks if the struct type has fields annotated with any of
+// the options, recursing into nested structs.
+func hasTaggedFields(t reflect.Type, options []string, seen map[reflect.Type]bool) bool {
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	if seen == nil {
+		seen = make(map[reflect.Type]bool)
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if parseStateTag(field).hasAny(options...) {
+			return true
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if hasTaggedFields(ft, options, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyTagged returns a copy of the struct pointed to by data in which fn has
+// been applied to the fields annotated with any of the options. Nested struct
+// pointers leading to such fields are copied too, so data is left untouched.
+// Data itself is returned when it has no such fields. The path passed to fn
+// is the dot separated Go name of the field, e.g. "Creds.Token".
+func copyTagged(data interface{}, options []string, fn func(path string, field reflect.StructField, v reflect.Value) error) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() || !hasTaggedFields(v.Type().Elem(), options, nil) {
+		return data, nil
+	}
+
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(v.Elem())
+	if err := walkTagged(c.Elem(), "", options, true, fn); err != nil {
+		return nil, err
+	}
+
+	return c.Interface(), nil
+}
+
+// walkTagged applies fn to the fields of the struct annotated with any of the
+// options, recursing into nested structs and copying the nested struct
+// pointers on the way when copy is set. Field paths start with the prefix.
+func walkTagged(v reflect.Value, prefix string, options []string, copy bool, fn func(path string, field reflect.StructField, v reflect.Value) error) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := prefix + field.Name
+
+		if parseStateTag(field).hasAny(options...) {
+			if err := fn(path, field, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch {
+		case fv.Kind() == reflect.Struct && hasTaggedFields(fv.Type(), options, nil):
+			if err := walkTagged(fv, path+".", options, copy, fn); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Ptr && !fv.IsNil() && hasTaggedFields(fv.Type().Elem(), options, nil):
+			if copy {
+				c := reflect.New(fv.Type().Elem())
+				c.Elem().Set(fv.Elem())
+				fv.Set(c)
+			}
+			if err := walkTagged(fv.Elem(), path+".", options, copy, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTagOmitEmpty ensures zero valued omitempty fields are not saved.
+func TestTagOmitEmpty(t *testing.T) {
+	type Token struct {
+		Token  string   `state:"token,omitempty"`
+		Scopes []string `state:"scopes,omitempty"`
+		Count  int      `state:"count"`
+		Name   string   `state:",omitempty"`
+	}
+
+	data, err := stateMarshal(&Token{Count: 0})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "token")
+	assert.NotContains(t, string(data), "scopes")
+	assert.Contains(t, string(data), "count: 0")
+
+	data, err = stateMarshal(&Token{Token: "abc", Name: "bob"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "token: abc")
+	assert.Contains(t, string(data), "name: bob")
+
+	var decoded Token
+	assert.NoError(t, stateUnmarshal(data, &decoded))
+	assert.Equal(t, "abc", decoded.Token)
+	assert.Equal(t, "bob", decoded.Name)
+}
+
+// TestTagRequired ensures missing required fields fail with MissingFieldError.
+func TestTagRequired(t *testing.T) {
+	type Inner struct {
+		ID string `state:"id,required"`
+	}
+
+	type Config struct {
+		Name  string `state:"name,required"`
+		Inner Inner  `state:"inner"`
+	}
+
+	var decoded Config
+	err := stateUnmarshal([]byte("inner:\n  id: x\n"), &decoded)
+	var missing *MissingFieldError
+	assert.True(t, errors.As(err, &missing))
+	assert.Equal(t, "name", missing.Key)
+
+	err = stateUnmarshal([]byte("name: a\ninner: {}\n"), &decoded)
+	assert.True(t, errors.As(err, &missing))
+	assert.Equal(t, "inner.id", missing.Key)
+
+	assert.NoError(t, stateUnmarshal([]byte("name: a\ninner:\n  id: x\n"), &decoded))
+	assert.Equal(t, Config{Name: "a", Inner: Inner{ID: "x"}}, decoded)
+}
+
+// TestLoadRequiredMissing ensures Load surfaces the MissingFieldError.
+func TestLoadRequiredMissing(t *testing.T) {
+	type Config struct {
+		Name string `state:"name,required"`
+		Port int    `state:"port"`
+	}
+
+	sm := setupTempStateManager(t, STATE)
+	assert.NoError(t, sm.Save(&struct {
+		Port int `state:"port"`
+	}{Port: 80}))
+
+	var missing *MissingFieldError
+	assert.ErrorAs(t, sm.Load(&Config{}), &missing)
+}
+
+// TestFieldKey ensures the keys match the ones used by each format.
+func TestFieldKey(t *testing.T) {
+	type keyed struct {
+		Name    string `json:"n" yaml:"nm" state:"name,required"`
+		Plain   string
+		Skipped string `json:"-" yaml:"-"`
+		hidden  string
+	}
+
+	typ := reflect.TypeOf(keyed{})
+	name, _ := typ.FieldByName("Name")
+	plain, _ := typ.FieldByName("Plain")
+	skipped, _ := typ.FieldByName("Skipped")
+	hidden, _ := typ.FieldByName("hidden")
+
+	for st, expected := range map[SerializationType][]string{
+		JSON:  {"n", "Plain", ""},
+		BIN:   {"n", "Plain", ""},
+		YAML:  {"nm", "plain", ""},
+		STATE: {"name", "", ""},
+	} {
+		for i, f := range []reflect.StructField{name, plain, skipped} {
+			key, inline := FieldKey(st, f)
+			assert.Equal(t, expected[i], key, st)
+			assert.False(t, inline)
+		}
+		key, _ := FieldKey(st, hidden)
+		assert.Empty(t, key)
+	}
+
+	assert.True(t, FieldRequired(name))
+	assert.False(t, FieldRequired(plain))
+}
+
+// TestFieldKeyInline ensures embedded and inline annotated structs are reported as inlined.
+func TestFieldKeyInline(t *testing.T) {
+	typ := reflect.TypeOf(embeddedState{})
+	for name, inline := range map[string]bool{
+		"embeddedBase":  true,
+		"EmbeddedAudit": true,
+		"EmbeddedMeta":  false,
+		"Extra":         true,
+		"Kind":          false,
+	} {
+		field, _ := typ.FieldByName(name)
+		_, actual := FieldKey(STATE, field)
+		assert.Equal(t, inline, actual, name)
+	}
+}
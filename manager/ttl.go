@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// WithTTL makes Load fail with ErrStale once the persisted state is older than
+// the given duration. The age is taken from the envelope timestamp when present
+// and from the file modification time otherwise, so saves skipped by
+// WithDirtyTracking do not refresh it.
+func WithTTL(d time.Duration) StateOption {
+	return func(s *StateManager) {
+		if d <= 0 {
+			s.optionErr = fmt.Errorf("invalid TTL: %s", d)
+			return
+		}
+		s.ttl = d
+	}
+}
+
+// WithDeleteStale removes the state file once it is older than the TTL set by
+// WithTTL. Load then fails with an error matching both ErrStale and ErrNotFound,
+// so LoadOrCreate starts over with fresh state.
+func WithDeleteStale() StateOption {
+	return func(s *StateManager) {
+		s.deleteStale = true
+	}
+}
+
+// Age returns how long ago the persisted state was saved.
+func (s *StateManager) Age() (time.Duration, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.stateAge(c)
+}
+
+// stateAge returns the age of the given content of the state file.
+func (s *StateManager) stateAge(c []byte) (time.Duration, error) {
+	if env := envelopeHeader(c); env != nil && !env.Timestamp.IsZero() {
+		return s.now().Sub(env.Timestamp), nil
+	}
+
+	info, err := s.files().Stat(s.FilePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return s.now().Sub(info.ModTime()), nil
+}
+
+// expire fails when the given content of the state file is older than the TTL,
+// removing the file with WithDeleteStale. Caller must hold the lock.
+func (s *StateManager) expire(c []byte) error {
+	if s.ttl <= 0 {
+		return nil
+	}
+
+	age, err := s.stateAge(c)
+	if err != nil {
+		return err
+	}
+	if age <= s.ttl {
+		return nil
+	}
+
+	if !s.deleteStale || s.readOnly {
+		return fmt.Errorf("%w: saved %s ago", ErrStale, age.Round(time.Second))
+	}
+
+	s.debug("removing stale state", slog.Duration("age", age), slog.Duration("ttl", s.ttl))
+	s.lastChecksum = ""
+	if err := s.files().Remove(s.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+
+	return fmt.Errorf("%w: %w: removed state saved %s ago", ErrNotFound, ErrStale, age.Round(time.Second))
+}
+
+// lockLoad takes the locks for Load, exclusively when stale state may be removed.
+func (s *StateManager) lockLoad() (func(), error) {
+	if s.ttl > 0 && s.deleteStale {
+		s.mutex.Lock()
+	} else {
+		s.mutex.RLock()
+	}
+
+	unlock, err := s.lockFile(s.ttl > 0 && s.deleteStale)
+	if err != nil {
+		s.unlockLoad()
+		return nil, err
+	}
+
+	return func() {
+		unlock()
+		s.unlockLoad()
+	}, nil
+}
+
+// unlockLoad releases the lock taken by lockLoad.
+func (s *StateManager) unlockLoad() {
+	if s.ttl > 0 && s.deleteStale {
+		s.mutex.Unlock()
+	} else {
+		s.mutex.RUnlock()
+	}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// offsetClock is the system clock shifted by an offset.
+type offsetClock struct {
+	systemClock
+	offset time.Duration
+}
+
+func (c *offsetClock) Now() time.Time { return time.Now().Add(c.offset) }
+
+// setupTTLStateManager creates a manager expiring state after an hour.
+func setupTTLStateManager(t *testing.T, clock Clock, options ...StateOption) *StateManager {
+	t.Helper()
+
+	options = append([]StateOption{
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(JSON),
+		WithTTL(time.Hour),
+		WithClock(clock),
+	}, options...)
+
+	sm, err := NewStateManager(options...)
+	assert.NoError(t, err)
+
+	return sm
+}
+
+// TestWithTTL ensures state older than the TTL is reported as stale.
+func TestWithTTL(t *testing.T) {
+	for name, options := range map[string][]StateOption{"envelope": {WithEnvelope()}, "plain": nil} {
+		t.Run(name, func(t *testing.T) {
+			clock := &offsetClock{}
+			sm := setupTTLStateManager(t, clock, options...)
+			assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+			age, err := sm.Age()
+			assert.NoError(t, err)
+			assert.Less(t, age, time.Minute)
+
+			loaded := &TestStruct{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, "Alice", loaded.Name)
+
+			clock.offset = 2 * time.Hour
+			age, err = sm.Age()
+			assert.NoError(t, err)
+			assert.Greater(t, age, time.Hour)
+
+			err = sm.Load(loaded)
+			assert.True(t, errors.Is(err, ErrStale))
+			assert.False(t, errors.Is(err, ErrNotFound))
+			assert.True(t, sm.Exists())
+		})
+	}
+}
+
+// TestWithDeleteStale ensures stale state is removed and recreated by LoadOrCreate.
+func TestWithDeleteStale(t *testing.T) {
+	clock := &offsetClock{}
+	sm := setupTTLStateManager(t, clock, WithEnvelope(), WithDeleteStale())
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Alice"}))
+
+	clock.offset = 2 * time.Hour
+	loaded := &TestStruct{}
+	err := sm.Load(loaded)
+	assert.True(t, errors.Is(err, ErrStale))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, sm.Exists())
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "Bob"}))
+	clock.offset = 4 * time.Hour
+	assert.NoError(t, sm.LoadOrCreate(loaded, func() interface{} { return &TestStruct{Name: "Carol"} }))
+	assert.Equal(t, "Carol", loaded.Name)
+}
+
+// TestAgeNotFound ensures Age reports missing state.
+func TestAgeNotFound(t *testing.T) {
+	sm := setupTTLStateManager(t, &offsetClock{})
+	_, err := sm.Age()
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+// TestWithTTLInvalid ensures non-positive TTLs are rejected.
+func TestWithTTLInvalid(t *testing.T) {
+	_, err := NewStateManager(WithTTL(0))
+	assert.Error(t, err)
+}
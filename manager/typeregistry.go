@@ -0,0 +1,509 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Keys of the discriminated value of an interface holding a registered type.
+const (
+	typeKey  = "$type"
+	valueKey = "$value"
+)
+
+var (
+	stateTypeMutex  sync.RWMutex
+	registeredTypes = map[string]reflect.Type{}
+	registeredNames = map[reflect.Type]string{}
+
+	// interfaceTypes caches whether a type holds interface values.
+	interfaceTypes sync.Map
+)
+
+// RegisterStateType registers the concrete type under the name so interface
+// fields holding it are encoded as {"$type": name, "$value": ...} in the
+// STATE and JSON formats and decoded back into the same type, like
+// gob.Register does for BIN. Register pointer types, e.g.
+// reflect.TypeOf(&Circle{}), for interfaces implemented by pointers. It panics
+// when the name or the type is registered twice with different counterparts.
+func RegisterStateType(name string, t reflect.Type) {
+	if name == "" || t == nil {
+		panic("state: registering empty name or nil type")
+	}
+
+	stateTypeMutex.Lock()
+	defer stateTypeMutex.Unlock()
+
+	if prev, ok := registeredTypes[name]; ok && prev != t {
+		panic(fmt.Sprintf("state: registering duplicate types for %q: %s != %s", name, prev, t))
+	}
+	if prev, ok := registeredNames[t]; ok && prev != name {
+		panic(fmt.Sprintf("state: registering duplicate names for %s: %q != %q", t, prev, name))
+	}
+	registeredTypes[name] = t
+	registeredNames[t] = name
+}
+
+// hasStateTypes reports whether any type is registered.
+func hasStateTypes() bool {
+	stateTypeMutex.RLock()
+	defer stateTypeMutex.RUnlock()
+	return len(registeredTypes) > 0
+}
+
+// stateTypeName returns the name the type is registered under.
+func stateTypeName(t reflect.Type) (string, bool) {
+	stateTypeMutex.RLock()
+	defer stateTypeMutex.RUnlock()
+	name, ok := registeredNames[t]
+	return name, ok
+}
+
+// typedValue returns a new value of the type registered under the name of the
+// discriminated value, or false when the value is not discriminated.
+func typedValue(field reflect.Value, value interface{}) (reflect.Value, interface{}, bool, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return reflect.Value{}, nil, false, nil
+	}
+	name, ok := m[typeKey].(string)
+	if !ok {
+		return reflect.Value{}, nil, false, nil
+	}
+
+	stateTypeMutex.RLock()
+	t, ok := registeredTypes[name]
+	stateTypeMutex.RUnlock()
+	if !ok {
+		return reflect.Value{}, nil, true, fmt.Errorf("unknown state type %q", name)
+	}
+	if !t.AssignableTo(field.Type()) {
+		return reflect.Value{}, nil, true, fmt.Errorf("state type %q does not implement %s", name, field.Type())
+	}
+	return reflect.New(t).Elem(), m[valueKey], true, nil
+}
+
+// hasInterfaces reports whether values of the type can hold interface values.
+func hasInterfaces(t reflect.Type) bool {
+	if found, ok := interfaceTypes.Load(t); ok {
+		return found.(bool)
+	}
+	found := findInterfaces(t, map[reflect.Type]bool{})
+	interfaceTypes.Store(t, found)
+	return found
+}
+
+// findInterfaces walks the type for interface values, skipping seen types.
+func findInterfaces(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findInterfaces(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if findInterfaces(t.Field(i).Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stateTypedValue returns the discriminated value of the interface when it
+// holds a registered type.
+func stateTypedValue(v reflect.Value, names FieldNameMapper) (interface{}, bool, error) {
+	if v.IsNil() {
+		return nil, false, nil
+	}
+	name, ok := stateTypeName(v.Elem().Type())
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := stateValue(v.Elem(), names)
+	if err != nil {
+		return nil, true, err
+	}
+	return map[string]interface{}{typeKey: name, valueKey: val}, true, nil
+}
+
+// stateElemValues returns the values to encode for the elements of a slice
+// or string keyed map of interfaces, so registered types are discriminated.
+func stateElemValues(v reflect.Value, names FieldNameMapper) (interface{}, bool, error) {
+	t := v.Type()
+	if !hasStateTypes() || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Map) ||
+		t.Elem().Kind() != reflect.Interface {
+		return nil, false, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || v.IsNil() {
+			return nil, false, nil
+		}
+		values := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			val, err := stateValue(iter.Value(), names)
+			if err != nil {
+				return nil, true, err
+			}
+			values[iter.Key().String()] = val
+		}
+		return values, true, nil
+	default:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return nil, false, nil
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			val, err := stateValue(v.Index(i), names)
+			if err != nil {
+				return nil, true, err
+			}
+			values[i] = val
+		}
+		return values, true, nil
+	}
+}
+
+// setStateTyped sets the interface field, or the elements of a slice or
+// string keyed map of interfaces, from discriminated values. It reports
+// whether the field was handled.
+func setStateTyped(field reflect.Value, value interface{}, prefix string, names FieldNameMapper) (bool, error) {
+	if !hasStateTypes() {
+		return false, nil
+	}
+
+	t := field.Type()
+	switch {
+	case t.Kind() == reflect.Interface:
+		elem, val, ok, err := typedValue(field, value)
+		if !ok || err != nil {
+			return ok, err
+		}
+		if err := setStateField(elem, val, prefix, names); err != nil {
+			return true, err
+		}
+		field.Set(elem)
+		return true, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Interface:
+		values, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		s := reflect.MakeSlice(t, len(values), len(values))
+		for i, val := range values {
+			if err := setStateField(s.Index(i), val, prefix+strconv.Itoa(i)+".", names); err != nil {
+				return true, err
+			}
+		}
+		field.Set(s)
+		return true, nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Interface:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		m := reflect.MakeMapWithSize(t, len(values))
+		for k, val := range values {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := setStateField(elem, val, prefix+k+".", names); err != nil {
+				return true, err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+		}
+		field.Set(m)
+		return true, nil
+	}
+	return false, nil
+}
+
+// jsonMarshal encodes the data as indented JSON, discriminating interfaces
+// holding registered types.
+func jsonMarshal(data interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(data, "", defaultJSONIndent)
+	if err != nil || data == nil || !hasStateTypes() || !hasInterfaces(reflect.TypeOf(data)) {
+		return b, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	tagged := false
+	doc = tagJSONTypes(reflect.ValueOf(data), doc, &tagged)
+	if !tagged {
+		return b, nil
+	}
+	return json.MarshalIndent(doc, "", defaultJSONIndent)
+}
+
+// tagJSONTypes replaces the encoded interfaces holding registered types in
+// the document of the value with their discriminated values.
+func tagJSONTypes(v reflect.Value, doc interface{}, tagged *bool) interface{} {
+	if !v.IsValid() || doc == nil || !hasInterfaces(v.Type()) || encodesItself(v.Type()) {
+		return doc
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return doc
+		}
+		val := tagJSONTypes(v.Elem(), doc, tagged)
+		if name, ok := stateTypeName(v.Elem().Type()); ok {
+			*tagged = true
+			return map[string]interface{}{typeKey: name, valueKey: val}
+		}
+		return val
+	case reflect.Ptr:
+		if v.IsNil() {
+			return doc
+		}
+		return tagJSONTypes(v.Elem(), doc, tagged)
+	case reflect.Struct:
+		if m, ok := doc.(map[string]interface{}); ok {
+			tagJSONFields(v, m, tagged)
+		}
+	case reflect.Slice, reflect.Array:
+		if list, ok := doc.([]interface{}); ok {
+			for i := range list {
+				if i < v.Len() {
+					list[i] = tagJSONTypes(v.Index(i), list[i], tagged)
+				}
+			}
+		}
+	case reflect.Map:
+		if m, ok := doc.(map[string]interface{}); ok {
+			iter := v.MapRange()
+			for iter.Next() {
+				if key, ok := jsonMapKey(iter.Key()); ok {
+					if val, ok := m[key]; ok {
+						m[key] = tagJSONTypes(iter.Value(), val, tagged)
+					}
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// tagJSONFields tags the encoded fields of the struct, including those of
+// embedded structs, in the document.
+func tagJSONFields(v reflect.Value, m map[string]interface{}, tagged *bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		fv := v.Field(i)
+		key, inline := jsonKey(field)
+		if inline {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				tagJSONFields(fv, m, tagged)
+				continue
+			}
+			key = field.Type.Name()
+		}
+
+		if val, ok := m[key]; ok && key != "" {
+			m[key] = tagJSONTypes(fv, val, tagged)
+		}
+	}
+}
+
+// jsonMapKey returns the encoded key of the map key as used by encoding/json.
+func jsonMapKey(k reflect.Value) (string, bool) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
+
+// jsonUnmarshal decodes the JSON into the data, decoding discriminated
+// values into interfaces as their registered types.
+func jsonUnmarshal(b []byte, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if !bytes.Contains(b, []byte(`"`+typeKey+`"`)) || !hasStateTypes() ||
+		v.Kind() != reflect.Ptr || v.IsNil() || !hasInterfaces(v.Type().Elem()) {
+		return json.Unmarshal(b, data)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		// report the error the way encoding/json does
+		return json.Unmarshal(b, data)
+	}
+	return decodeJSONTyped(v.Elem(), doc)
+}
+
+// decodeJSONTyped decodes the document into the value, letting encoding/json
+// decode everything which cannot hold interfaces.
+func decodeJSONTyped(v reflect.Value, doc interface{}) error {
+	if doc == nil || !hasInterfaces(v.Type()) || decodesItself(v.Type()) {
+		return decodeJSONDoc(v, doc)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		elem, val, ok, err := typedValue(v, doc)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return decodeJSONDoc(v, doc)
+		}
+		if err := decodeJSONTyped(elem, val); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeJSONTyped(v.Elem(), doc)
+	case reflect.Struct:
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return decodeJSONDoc(v, doc)
+		}
+		return decodeJSONFields(v, m)
+	case reflect.Slice:
+		list, ok := doc.([]interface{})
+		if !ok {
+			return decodeJSONDoc(v, doc)
+		}
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, val := range list {
+			if err := decodeJSONTyped(s.Index(i), val); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		list, ok := doc.([]interface{})
+		if !ok {
+			return decodeJSONDoc(v, doc)
+		}
+		for i := 0; i < v.Len() && i < len(list); i++ {
+			if err := decodeJSONTyped(v.Index(i), list[i]); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m, ok := doc.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return decodeJSONDoc(v, doc)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(m)))
+		}
+		for k, val := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeJSONTyped(elem, val); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+	default:
+		return decodeJSONDoc(v, doc)
+	}
+	return nil
+}
+
+// decodeJSONFields decodes the keys of the document into the fields of the
+// struct, including those of embedded structs, matching keys like
+// encoding/json does.
+func decodeJSONFields(v reflect.Value, m map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		fv := v.Field(i)
+		key, inline := jsonKey(field)
+		if inline {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						if !fv.CanSet() {
+							continue
+						}
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				if err := decodeJSONFields(fv, m); err != nil {
+					return err
+				}
+				continue
+			}
+			key = field.Type.Name()
+		}
+		if key == "" || !fv.CanSet() {
+			continue
+		}
+
+		val, ok := m[key]
+		if !ok {
+			for k, kv := range m {
+				if strings.EqualFold(k, key) {
+					val, ok = kv, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := decodeJSONTyped(fv, val); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeJSONDoc decodes the document into the value with encoding/json.
+func decodeJSONDoc(v reflect.Value, doc interface{}) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to decode data: %w", err)
+	}
+	return json.Unmarshal(b, v.Addr().Interface())
+}
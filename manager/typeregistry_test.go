@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testShape interface {
+	Area() float64
+}
+
+type testCircle struct {
+	Radius float64 `json:"radius" yaml:"radius" state:"radius"`
+}
+
+func (c testCircle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type testSquare struct {
+	Side float64 `json:"side" yaml:"side"`
+}
+
+func (s *testSquare) Area() float64 { return s.Side * s.Side }
+
+type shapesState struct {
+	Name   string               `json:"name" state:"name"`
+	Shape  testShape            `json:"shape" state:"shape"`
+	Shapes []testShape          `json:"shapes" state:"shapes"`
+	ByName map[string]testShape `json:"by_name" state:"by_name"`
+	Any    interface{}          `json:"any" state:"any"`
+	None   testShape            `json:"none" state:"none"`
+}
+
+func init() {
+	RegisterStateType("circle", reflect.TypeOf(testCircle{}))
+	RegisterStateType("square", reflect.TypeOf(&testSquare{}))
+}
+
+// TestRegisterStateType ensures interfaces holding registered types round trip in STATE and JSON.
+func TestRegisterStateType(t *testing.T) {
+	for _, st := range []SerializationType{JSON, STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state")
+			sm, err := NewStateManager(WithFilePath(path), WithSerializationType(st))
+			assert.NoError(t, err)
+
+			original := &shapesState{
+				Name:   "shapes",
+				Shape:  testCircle{Radius: 2},
+				Shapes: []testShape{&testSquare{Side: 3}, testCircle{Radius: 1}},
+				ByName: map[string]testShape{"big": &testSquare{Side: 10}},
+				Any:    testCircle{Radius: 5},
+			}
+			assert.NoError(t, sm.Save(original))
+
+			c, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Contains(t, string(c), typeKey)
+			assert.Contains(t, string(c), "square")
+
+			loaded := &shapesState{}
+			assert.NoError(t, sm.Load(loaded))
+			assert.Equal(t, original, loaded)
+		})
+	}
+}
+
+// TestRegisterStateTypeUnknown ensures discriminated values of unknown or mismatched types fail to decode.
+func TestRegisterStateTypeUnknown(t *testing.T) {
+	err := Unmarshal(JSON, []byte(`{"shape": {"$type": "triangle", "$value": {}}}`), &shapesState{})
+	assert.ErrorContains(t, err, "triangle")
+
+	RegisterStateType("test-name", reflect.TypeOf(""))
+	err = Unmarshal(JSON, []byte(`{"shape": {"$type": "test-name", "$value": "a"}}`), &shapesState{})
+	assert.ErrorContains(t, err, "does not implement")
+
+	loaded := &shapesState{}
+	assert.NoError(t, Unmarshal(JSON, []byte(`{"name": "plain", "any": {"$type": "circle", "$value": {"radius": 1}}}`), loaded))
+	assert.Equal(t, &shapesState{Name: "plain", Any: testCircle{Radius: 1}}, loaded)
+}
+
+// TestRegisterStateTypeDuplicate ensures conflicting registrations panic.
+func TestRegisterStateTypeDuplicate(t *testing.T) {
+	assert.NotPanics(t, func() { RegisterStateType("circle", reflect.TypeOf(testCircle{})) })
+	assert.Panics(t, func() { RegisterStateType("circle", reflect.TypeOf(testSquare{})) })
+	assert.Panics(t, func() { RegisterStateType("round", reflect.TypeOf(testCircle{})) })
+	assert.Panics(t, func() { RegisterStateType("", reflect.TypeOf(testCircle{})) })
+}
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"fmt"
+)
+
+// Validator is implemented by state structs which validate themselves.
+// Validate is called after Load and before Save.
+type Validator interface {
+	Validate() error
+}
+
+// WithValidator adds a validation function run after Load and before Save
+func WithValidator(fn func(data interface{}) error) StateOption {
+	return func(s *StateManager) {
+		if fn != nil {
+			s.validators = append(s.validators, fn)
+		}
+	}
+}
+
+// validate runs the Validate method of the data and all the registered validators.
+func (s *StateManager) validate(data interface{}) error {
+	if v, ok := data.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+	}
+
+	for _, fn := range s.validators {
+		if err := fn(data); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+	}
+
+	return nil
+}
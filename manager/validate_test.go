@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeStruct validates its own port range.
+type rangeStruct struct {
+	Port int `json:"port"`
+}
+
+func (r *rangeStruct) Validate() error {
+	if r.Port < 1 || r.Port > 65535 {
+		return errors.New("port out of range")
+	}
+	return nil
+}
+
+// TestValidateMethod ensures structs implementing Validator are checked on Save and Load.
+func TestValidateMethod(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+
+	err := sm.Save(&rangeStruct{Port: 0})
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.False(t, sm.Exists())
+
+	assert.NoError(t, sm.Save(&rangeStruct{Port: 80}))
+
+	// out of range value written by other means is rejected on load
+	assert.NoError(t, os.WriteFile(sm.FilePath, []byte(`{"port": 70000}`), 0600))
+	assert.ErrorIs(t, sm.Load(&rangeStruct{}), ErrInvalid)
+}
+
+// TestWithValidator ensures registered validators run on Save and Load.
+func TestWithValidator(t *testing.T) {
+	calls := 0
+	sm, err := NewStateManager(
+		WithFilePath(filepath.Join(t.TempDir(), "test_state")),
+		WithSerializationType(YAML),
+		WithValidator(func(data interface{}) error {
+			calls++
+			if d, ok := data.(*TestStruct); ok && d.Name == "" {
+				return errors.New("name is required")
+			}
+			return nil
+		}),
+	)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.Save(&TestStruct{}), ErrInvalid)
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.NoError(t, sm.Load(&TestStruct{}))
+	assert.Equal(t, 3, calls)
+}
+
+// documentValidator rejects documents without the name key.
+type documentValidator struct{}
+
+func (documentValidator) ValidateDocument(doc interface{}) error {
+	if m, ok := doc.(map[string]interface{}); !ok || m["name"] == nil {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+// TestWithSchemaValidation ensures documents are validated before decoding.
+func TestWithSchemaValidation(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	WithSchemaValidation(documentValidator{})(sm)
+
+	assert.NoError(t, sm.Save(&TestStruct{Name: "a"}))
+	assert.NoError(t, sm.Load(&TestStruct{}))
+
+	assert.NoError(t, os.WriteFile(sm.FilePath, []byte(`{"age": 1}`), 0600))
+	assert.ErrorIs(t, sm.Load(&TestStruct{}), ErrInvalid)
+}
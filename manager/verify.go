@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Verify checks the integrity of the persisted state without decoding it into
+// a struct. With the envelope the payload is matched against the recorded size
+// and SHA-256, returning ErrChecksumMismatch when it was modified or truncated,
+// and encrypted payloads are authenticated. Text payloads must also parse.
+func (s *StateManager) Verify() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c, err := s.readFile(s.FilePath)
+	if err != nil {
+		return err
+	}
+
+	payload, st, err := s.open(c)
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	switch st {
+	case JSON:
+		err = json.Unmarshal(payload, &v)
+	case YAML, STATE:
+		err = yaml.Unmarshal(payload, &v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package manager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestVerify ensures modified and truncated files are detected.
+func TestVerify(t *testing.T) {
+	sm := setupTempStateManager(t, JSON)
+	assert.ErrorIs(t, sm.Verify(), ErrNotFound)
+
+	env, err := NewStateManager(WithFilePath(sm.FilePath), WithSerializationType(JSON), WithEnvelope())
+	assert.NoError(t, err)
+	assert.NoError(t, env.Save(&TestStruct{Name: "Alice", Age: 30}))
+	assert.NoError(t, env.Verify())
+
+	c, err := os.ReadFile(sm.FilePath)
+	assert.NoError(t, err)
+
+	tampered := []byte(string(c[:len(c)-3]) + "31}")
+	assert.NoError(t, os.WriteFile(sm.FilePath, tampered, 0600))
+	assert.ErrorIs(t, env.Verify(), ErrChecksumMismatch)
+	assert.ErrorIs(t, env.Verify(), ErrCorrupted)
+	assert.ErrorIs(t, env.Load(&TestStruct{}), ErrChecksumMismatch)
+
+	assert.NoError(t, os.WriteFile(sm.FilePath, c[:len(c)-5], 0600))
+	assert.ErrorIs(t, env.Verify(), ErrChecksumMismatch)
+
+	// without the envelope the payload must still parse
+	assert.NoError(t, os.WriteFile(sm.FilePath, []byte(`{"name": "Bob"}`), 0600))
+	assert.NoError(t, sm.Verify())
+
+	assert.NoError(t, os.WriteFile(sm.FilePath, []byte(`{"name": "Bo`), 0600))
+	err = sm.Verify()
+	assert.ErrorIs(t, err, ErrCorrupted)
+	assert.NotErrorIs(t, err, ErrChecksumMismatch)
+}
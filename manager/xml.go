@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// xmlMarshal encodes the data as an indented XML document with a header.
+func xmlMarshal(data interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// xmlKey returns the key of the field as used by encoding/xml: the element
+// name, with parent elements separated by ">", or the attribute name
+// prefixed with "@". Embedded structs are inlined and fields holding the
+// character data, comments or inner XML of their parent have no key.
+func xmlKey(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("xml")
+	if tag == "-" || field.Name == "XMLName" {
+		return "", false
+	}
+
+	ft := field.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	if field.Anonymous && ft.Kind() == reflect.Struct {
+		return "", true
+	}
+	if !field.IsExported() {
+		return "", false
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if i := strings.LastIndexByte(name, ' '); i >= 0 {
+		// drop the namespace
+		name = name[i+1:]
+	}
+	if name == "" {
+		name = field.Name
+	}
+
+	for _, o := range strings.Split(opts, ",") {
+		switch o {
+		case "attr":
+			return "@" + name, false
+		case "chardata", "cdata", "innerxml", "comment", "any":
+			return "", false
+		}
+	}
+	return name, false
+}
+
+// dropXMLKey removes the elements at the key path below the root element
+// from the XML document, or the attribute when the last key starts with "@".
+// The rest of the document is kept as it is.
+func dropXMLKey(b []byte, keys []string) ([]byte, error) {
+	attr := ""
+	if last := keys[len(keys)-1]; strings.HasPrefix(last, "@") {
+		attr, keys = last[1:], keys[:len(keys)-1]
+	}
+
+	type cut struct{ start, end int }
+	var cuts []cut
+
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var path []string
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			if !equalKeys(path[1:], keys) {
+				continue
+			}
+			if attr != "" {
+				if loc := xmlAttrPattern(attr).FindIndex(b[start:dec.InputOffset()]); loc != nil {
+					cuts = append(cuts, cut{start + loc[0], start + loc[1]})
+				}
+				continue
+			}
+			if err := dec.Skip(); err != nil {
+				return nil, err
+			}
+			path = path[:len(path)-1]
+			cuts = append(cuts, cut{trimIndent(b, start), int(dec.InputOffset())})
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+
+	if len(cuts) == 0 {
+		return b, nil
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start < cuts[j].start })
+
+	out := make([]byte, 0, len(b))
+	prev := 0
+	for _, c := range cuts {
+		out = append(out, b[prev:c.start]...)
+		prev = c.end
+	}
+	return append(out, b[prev:]...), nil
+}
+
+// equalKeys reports whether the element path is the key path.
+func equalKeys(path, keys []string) bool {
+	if len(path) != len(keys) {
+		return false
+	}
+	for i := range path {
+		if path[i] != keys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlAttrPattern matches the attribute with its leading space in a start tag.
+func xmlAttrPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(`\s+(?:[^\s=<>]+:)?` + regexp.QuoteMeta(name) + `\s*=\s*(?:"[^"]*"|'[^']*')`)
+}
+
+// trimIndent moves the offset back over the indentation and line break
+// preceding it, so cutting an element leaves no empty line.
+func trimIndent(b []byte, i int) int {
+	for i > 0 && (b[i-1] == ' ' || b[i-1] == '\t') {
+		i--
+	}
+	if i > 0 && b[i-1] == '\n' {
+		i--
+	}
+	return i
+}
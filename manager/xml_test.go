@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type xmlAudit struct {
+	Actor string `xml:"actor"`
+	Token string `xml:"token" state:"-"`
+}
+
+type xmlState struct {
+	XMLName xml.Name  `xml:"config"`
+	ID      string    `xml:"id,attr"`
+	Key     string    `xml:"key,attr" state:"-"`
+	Name    string    `xml:"name"`
+	Port    int       `xml:"server>port"`
+	Secret  string    `xml:"server>secret" state:"-"`
+	Tags    []string  `xml:"tags>tag"`
+	Audit   *xmlAudit `xml:"audit"`
+	Skipped string    `xml:"-"`
+}
+
+// TestXMLRoundTrip ensures state saved as XML follows the xml tags and loads back.
+func TestXMLRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.xml")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(XML))
+	assert.NoError(t, err)
+
+	data := &xmlState{ID: "c1", Name: "alice", Port: 8080, Tags: []string{"a", "b"}, Audit: &xmlAudit{Actor: "bob"}, Skipped: "x"}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, XML, DetectFormat(c))
+	assert.Equal(t, xml.Header+`<config id="c1">
+  <name>alice</name>
+  <server>
+    <port>8080</port>
+  </server>
+  <tags>
+    <tag>a</tag>
+    <tag>b</tag>
+  </tags>
+  <audit>
+    <actor>bob</actor>
+  </audit>
+</config>`, string(c))
+
+	loaded := &xmlState{}
+	assert.NoError(t, sm.Load(loaded))
+	data.XMLName = xml.Name{Local: "config"}
+	data.Skipped = ""
+	assert.Equal(t, data, loaded)
+}
+
+// TestXMLExcludedFields ensures elements and attributes of fields annotated with "-" are not persisted.
+func TestXMLExcludedFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.xml")
+	sm, err := NewStateManager(WithFilePath(path), WithSerializationType(XML))
+	assert.NoError(t, err)
+
+	data := &xmlState{ID: "c1", Key: "key", Name: "alice", Secret: "secret", Audit: &xmlAudit{Actor: "bob", Token: "token"}}
+	assert.NoError(t, sm.Save(data))
+
+	c, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, xml.Header+`<config id="c1">
+  <name>alice</name>
+  <server>
+    <port>0</port>
+  </server>
+  <tags></tags>
+  <audit>
+    <actor>bob</actor>
+  </audit>
+</config>`, string(c))
+
+	loaded := &xmlState{Key: "kept", Secret: "kept"}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "kept", loaded.Key)
+	assert.Equal(t, "kept", loaded.Secret)
+	assert.Equal(t, "bob", loaded.Audit.Actor)
+	assert.Equal(t, "", loaded.Audit.Token)
+}
+
+// TestDropXMLKey ensures only the elements or attribute at the key path are removed.
+func TestDropXMLKey(t *testing.T) {
+	doc := []byte(`<r a="1" b='2'><x><y>1</y></x><y>2</y><y>3</y></r>`)
+
+	b, err := dropXMLKey(doc, []string{"y"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<r a="1" b='2'><x><y>1</y></x></r>`, string(b))
+
+	b, err = dropXMLKey(doc, []string{"x", "y"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<r a="1" b='2'><x></x><y>2</y><y>3</y></r>`, string(b))
+
+	b, err = dropXMLKey(doc, []string{"@b"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<r a="1"><x><y>1</y></x><y>2</y><y>3</y></r>`, string(b))
+
+	_, err = dropXMLKey([]byte(`<r><y>`), []string{"y"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+// Package metrics collects the observations of state managers into
+// Prometheus counters and histograms.
+package metrics
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	// DurationBuckets are the default upper bounds of the duration histogram in seconds.
+	DurationBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+	// SizeBuckets are the default upper bounds of the size histogram in bytes.
+	SizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216}
+
+	// labels are the labels of every metric
+	labels = []string{"operation", "format", "backend"}
+)
+
+// Collector is a manager.Metrics counting the operations and errors and
+// recording their durations and sizes by operation, format and backend.
+// It is a prometheus.Collector, so register it with the registry of the
+// application, or serve it on its own metrics endpoint.
+type Collector struct {
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	size       *prometheus.HistogramVec
+	registry   *prometheus.Registry
+}
+
+var _ manager.Metrics = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+var _ http.Handler = (*Collector)(nil)
+
+// NewCollector creates a collector prefixing the metric names with the
+// namespace, "state" when empty.
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "state"
+	}
+
+	c := &Collector{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operations_total",
+			Help:      "Number of state operations.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operation_errors_total",
+			Help:      "Number of failed state operations.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of state operations in seconds.",
+			Buckets:   DurationBuckets,
+		}, labels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_size_bytes",
+			Help:      "Size of the saved or loaded state in bytes.",
+			Buckets:   SizeBuckets,
+		}, labels),
+		registry: prometheus.NewRegistry(),
+	}
+	c.registry.MustRegister(c)
+
+	return c
+}
+
+// NewRegisteredCollector creates a collector like NewCollector and
+// registers it with the registerer, e.g. prometheus.DefaultRegisterer.
+func NewRegisteredCollector(r prometheus.Registerer, namespace string) (*Collector, error) {
+	c := NewCollector(namespace)
+	if err := r.Register(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Observe records the observation.
+func (c *Collector) Observe(o manager.Observation) {
+	values := []string{o.Operation, string(o.Format), o.Backend}
+
+	c.operations.WithLabelValues(values...).Inc()
+	errors := c.errors.WithLabelValues(values...)
+	if o.Err != nil {
+		errors.Inc()
+	}
+	c.duration.WithLabelValues(values...).Observe(o.Duration.Seconds())
+	c.size.WithLabelValues(values...).Observe(float64(o.Size))
+}
+
+// Describe sends the descriptors of the metrics.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.operations.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+	c.size.Describe(ch)
+}
+
+// Collect sends the current values of the metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.operations.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+	c.size.Collect(ch)
+}
+
+// ServeHTTP serves the metrics of the collector alone.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	families, err := c.registry.Gather()
+	if err != nil {
+		return 0, err
+	}
+
+	cw := &countingWriter{w: w}
+	enc := expfmt.NewEncoder(cw, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, f := range families {
+		if err := enc.Encode(f); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
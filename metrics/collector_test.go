@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCollector ensures observations are exported as counters and histograms.
+func TestCollector(t *testing.T) {
+	c := NewCollector("")
+	c.Observe(manager.Observation{Operation: manager.OperationSave, Format: manager.JSON, Backend: "file",
+		Duration: 2 * time.Millisecond, Size: 300})
+	c.Observe(manager.Observation{Operation: manager.OperationSave, Format: manager.JSON, Backend: "file",
+		Duration: time.Second, Size: 100, Err: errors.New("boom")})
+	c.Observe(manager.Observation{Operation: manager.OperationLoad, Format: manager.JSON, Backend: "file"})
+
+	var b strings.Builder
+	n, err := c.WriteTo(&b)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+
+	out := b.String()
+	save := `backend="file",format="json",operation="save"`
+	assert.Contains(t, out, "# TYPE state_operations_total counter\n")
+	assert.Contains(t, out, "state_operations_total{"+save+"} 2\n")
+	assert.Contains(t, out, `state_operations_total{backend="file",format="json",operation="load"} 1`+"\n")
+	assert.Contains(t, out, "state_operation_errors_total{"+save+"} 1\n")
+	assert.Contains(t, out, "# TYPE state_operation_duration_seconds histogram\n")
+	assert.Contains(t, out, "state_operation_duration_seconds_bucket{"+save+`,le="0.0025"} 1`+"\n")
+	assert.Contains(t, out, "state_operation_duration_seconds_bucket{"+save+`,le="+Inf"} 2`+"\n")
+	assert.Contains(t, out, "state_operation_duration_seconds_count{"+save+"} 2\n")
+	assert.Contains(t, out, "state_operation_size_bytes_bucket{"+save+`,le="256"} 1`+"\n")
+	assert.Contains(t, out, "state_operation_size_bytes_sum{"+save+"} 400\n")
+	assert.Less(t, strings.Index(out, `operation="load"`), strings.Index(out, `operation="save"`))
+}
+
+// TestCollectorManager ensures the collector records the operations of a manager over HTTP.
+func TestCollectorManager(t *testing.T) {
+	c := NewCollector("app")
+	sm, err := manager.NewStateManager(manager.WithFilePath(t.TempDir()+"/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithMetrics(c))
+	assert.NoError(t, err)
+	assert.NoError(t, sm.Save(&struct{ Name string }{"alice"}))
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, rec.Body.String(), `app_operations_total{backend="file",format="json",operation="save"} 1`)
+}
+
+// TestCollectorRegistry ensures the collector joins the metrics of an application registry.
+func TestCollectorRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	app := prometheus.NewCounter(prometheus.CounterOpts{Name: "app_requests_total", Help: "Requests."})
+	reg.MustRegister(app)
+
+	c, err := NewRegisteredCollector(reg, "")
+	assert.NoError(t, err)
+	c.Observe(manager.Observation{Operation: manager.OperationLoad, Format: manager.YAML, Backend: "file"})
+
+	n, err := testutil.GatherAndCount(reg, "app_requests_total", "state_operations_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.operations.WithLabelValues(manager.OperationLoad, "yaml", "file")))
+
+	_, err = NewRegisteredCollector(reg, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/remote/remotepb"
+	"google.golang.org/grpc"
+)
+
+// Client is a manager.Store keeping every state file as a state of the
+// server. The key / is the state of the server manager and any other key is
+// the named state of its path, with slashes escaped.
+type Client struct {
+	conn grpc.ClientConnInterface
+	rpc  remotepb.StateClient
+}
+
+var _ manager.Store = (*Client)(nil)
+
+// Dial connects to the server at the gRPC target. The options must include
+// the transport credentials, e.g. grpc.WithTransportCredentials, and the
+// token of the server, e.g. grpc.WithPerRPCCredentials(Token(token)).
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates a client on the gRPC connection.
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn, rpc: remotepb.NewStateClient(conn)}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	if closer, ok := c.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Get returns the payload of the state of the key.
+func (c *Client) Get(key string) ([]byte, error) {
+	reply, err := c.rpc.Get(context.Background(), &remotepb.GetRequest{Name: Name(key)})
+	if err != nil {
+		return nil, remoteError(err)
+	}
+	if !reply.GetFound() {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+	return reply.GetPayload(), nil
+}
+
+// Put saves the payload as the state of the key.
+func (c *Client) Put(key string, value []byte) error {
+	if _, err := c.rpc.Put(context.Background(), &remotepb.PutRequest{Name: Name(key), Payload: value}); err != nil {
+		return remoteError(err)
+	}
+	return nil
+}
+
+// Delete removes the state of the key.
+func (c *Client) Delete(key string) error {
+	if _, err := c.rpc.Delete(context.Background(), &remotepb.DeleteRequest{Name: Name(key)}); err != nil {
+		return remoteError(err)
+	}
+	return nil
+}
+
+// List returns the keys with the prefix of the states known to the server.
+func (c *Client) List(prefix string) ([]string, error) {
+	reply, err := c.rpc.List(context.Background(), &remotepb.ListRequest{})
+	if err != nil {
+		return nil, remoteError(err)
+	}
+
+	keys := make([]string, 0, len(reply.GetNames()))
+	for _, name := range reply.GetNames() {
+		if k := key(name); strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// History returns the history of the state of the key.
+func (c *Client) History(key string) ([]manager.HistoryEntry, error) {
+	reply, err := c.rpc.History(context.Background(), &remotepb.HistoryRequest{Name: Name(key)})
+	if err != nil {
+		return nil, remoteError(err)
+	}
+
+	entries := make([]manager.HistoryEntry, 0, len(reply.GetEntries()))
+	for _, e := range reply.GetEntries() {
+		entries = append(entries, manager.HistoryEntry{
+			Source:     manager.HistorySource(e.GetSource()),
+			Label:      e.GetLabel(),
+			Generation: int(e.GetGeneration()),
+			Timestamp:  e.GetTimestamp().AsTime(),
+			Size:       e.GetSize(),
+			Checksum:   e.GetChecksum(),
+		})
+	}
+	return entries, nil
+}
+
+// Watch calls fn with the payload of every change of the state of the key,
+// nil once it is deleted, until the context is done. Decode it with the
+// manager, e.g. by calling Load from fn.
+func (c *Client) Watch(ctx context.Context, key string, fn func(value []byte)) error {
+	stream, err := c.rpc.Watch(ctx, &remotepb.WatchRequest{Name: Name(key)})
+	if err != nil {
+		return remoteError(err)
+	}
+
+	// the first reply is the current state
+	if _, err := stream.Recv(); err != nil {
+		return watchError(ctx, err)
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			return watchError(ctx, err)
+		}
+		if !reply.GetFound() {
+			fn(nil)
+			continue
+		}
+		fn(reply.GetPayload())
+	}
+}
+
+// watchError returns the error of the context once it is done.
+func watchError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return remoteError(err)
+}
+
+// Name maps the key to the name of the state on the server.
+func Name(key string) string {
+	return url.PathEscape(strings.TrimLeft(key, "/"))
+}
+
+// key maps the name of the state on the server back to the key.
+func key(name string) string {
+	k, err := url.PathUnescape(name)
+	if err != nil {
+		k = name
+	}
+	return "/" + k
+}
@@ -0,0 +1,85 @@
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClientStore ensures a client manager persists its state on the server.
+func TestClientStore(t *testing.T) {
+	sm, s := newTestServer(t)
+	c := newTestClient(t, s)
+
+	local, err := manager.NewStateManager(manager.WithStore(c), manager.WithFilePath("/tools/cli"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, local.Load(&testState{}), manager.ErrNotFound)
+	assert.NoError(t, local.Save(&testState{Name: "a", Count: 1}))
+	assert.NoError(t, local.Save(&testState{Name: "b", Count: 2}))
+
+	remote := &testState{}
+	assert.NoError(t, sm.LoadNamed(Name("/tools/cli"), remote))
+	assert.Equal(t, "b", remote.Name)
+
+	loaded := &testState{}
+	assert.NoError(t, local.Load(loaded))
+	assert.Equal(t, 2, loaded.Count)
+
+	keys, err := c.List("/tools/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/tools/cli", "/tools/cli.1"}, keys)
+
+	history, err := c.History("/tools/cli")
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.False(t, history[0].Timestamp.IsZero())
+
+	err = c.Put("/tools/cli", []byte("not json"))
+	assert.ErrorIs(t, err, manager.ErrInvalid)
+
+	assert.NoError(t, local.Delete())
+	assert.ErrorIs(t, local.Load(loaded), manager.ErrNotFound)
+}
+
+// TestClientWatch ensures watchers receive changes until the context is done.
+func TestClientWatch(t *testing.T) {
+	sm, s := newTestServer(t, WithWatchInterval(10*time.Millisecond))
+	c := newTestClient(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	values := make(chan []byte, 4)
+	done := make(chan error)
+	go func() {
+		done <- c.Watch(ctx, "/shared", func(value []byte) { values <- value })
+	}()
+
+	writer := newTestClient(t, s)
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, writer.Put("/shared", []byte(`{"name": "a"}`)))
+		select {
+		case v := <-values:
+			assert.JSONEq(t, `{"name": "a", "count": 0}`, string(v))
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	for len(values) > 0 {
+		<-values
+	}
+
+	// changes saved around the server are picked up as well
+	assert.NoError(t, sm.SaveNamed("shared", &testState{Name: "b"}))
+	assert.JSONEq(t, `{"name": "b", "count": 0}`, string(<-values))
+
+	assert.NoError(t, writer.Delete("/shared"))
+	assert.Nil(t, <-values)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,112 @@
+// Package remote shares state managed by a central daemon with many local
+// tools. The server wraps a StateManager in a gRPC service with Get, Put,
+// Delete, List, Watch and History calls, and the client implements
+// manager.Store on top of it. The wire protocol is defined in
+// remotepb/remote.proto.
+//
+// States are addressed by name: the empty name is the state of the wrapped
+// manager and any other name is one of its named states. Payloads are
+// exchanged in the serialization type of the server manager, so clients
+// should use the same type and leave encryption and envelopes to the server.
+// Versions are the revisions of the states, so the server manager must use
+// manager.WithEnvelope.
+//
+// Calls are authenticated with a bearer token when the server is created
+// with WithToken and the client dialed with Token. Use TLS transport
+// credentials, e.g. grpc.Creds and grpc.WithTransportCredentials, whenever
+// the service is reachable from other hosts.
+package remote
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative remotepb/remote.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultWatchInterval is how often Watch checks the state for changes
+	// saved around the server, e.g. by other processes sharing the file
+	DefaultWatchInterval = time.Second
+
+	// authorizationKey is the metadata key holding the bearer token
+	authorizationKey = "authorization"
+
+	// bearerPrefix precedes the token in the authorization metadata
+	bearerPrefix = "Bearer "
+)
+
+// Token returns the credentials sending the bearer token with every call,
+// for use with grpc.WithPerRPCCredentials.
+func Token(token string) credentials.PerRPCCredentials {
+	return tokenCredentials(token)
+}
+
+// tokenCredentials sends the bearer token as call metadata.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: bearerPrefix + string(t)}, nil
+}
+
+// RequireTransportSecurity allows the token on local connections, e.g. unix
+// sockets, without TLS.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// statusCodes are the status codes manager errors are sent with.
+var statusCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{manager.ErrNotFound, codes.NotFound},
+	{manager.ErrConflict, codes.Aborted},
+	{manager.ErrInvalid, codes.InvalidArgument},
+	{manager.ErrLocked, codes.Unavailable},
+}
+
+// statusError converts the manager error to a gRPC status error.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, c := range statusCodes {
+		if errors.Is(err, c.err) {
+			return status.Error(c.code, err.Error())
+		}
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// remoteError maps the status returned by the server back to the manager
+// error it was sent for.
+func remoteError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("failed to call state server: %w", err)
+	}
+
+	for _, c := range statusCodes {
+		if st.Code() == c.code {
+			return fmt.Errorf("%w: %s", c.err, st.Message())
+		}
+	}
+	switch st.Code() {
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	return fmt.Errorf("state server returned an error: %w", err)
+}
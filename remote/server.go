@@ -0,0 +1,401 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/remote/remotepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// indexName is the named state holding the names of the states written
+// through the server, so List survives restarts. Clients cannot use it.
+const indexName = ".remote"
+
+// index is the persisted list of state names.
+type index struct {
+	Names []string `json:"names" yaml:"names" state:"names" bson:"names" xml:"name"`
+}
+
+// ServerOption configures the server.
+type ServerOption func(*Server)
+
+// WithToken requires the bearer token on every call.
+func WithToken(token string) ServerOption {
+	return func(s *Server) {
+		if token == "" {
+			s.optionErr = errors.New("token must not be empty")
+			return
+		}
+		s.token = token
+	}
+}
+
+// WithWatchInterval sets how often watchers check the state for changes
+// saved around the server, DefaultWatchInterval by default.
+func WithWatchInterval(d time.Duration) ServerOption {
+	return func(s *Server) {
+		if d <= 0 {
+			s.optionErr = fmt.Errorf("invalid watch interval %s", d)
+			return
+		}
+		s.interval = d
+	}
+}
+
+// Server serves the state of a StateManager.
+type Server struct {
+	manager   *manager.StateManager
+	newState  func() interface{}
+	token     string
+	interval  time.Duration
+	optionErr error
+
+	mutex   sync.Mutex
+	names   map[string]bool
+	changed chan struct{}
+}
+
+// NewServer creates a server for the states of the manager. The newState
+// func returns a pointer to a new zero value of the state struct, into which
+// payloads are decoded before they are saved.
+func NewServer(sm *manager.StateManager, newState func() interface{}, opts ...ServerOption) (*Server, error) {
+	if sm == nil || newState == nil {
+		return nil, errors.New("state manager and state constructor must not be nil")
+	}
+
+	s := &Server{
+		manager:  sm,
+		newState: newState,
+		interval: DefaultWatchInterval,
+		changed:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.optionErr != nil {
+		return nil, s.optionErr
+	}
+	return s, nil
+}
+
+// Notify wakes up the watchers of the named state after the daemon saved it
+// through the manager itself rather than through the service.
+func (s *Server) Notify(name string) error {
+	if err := s.setName(name, true); err != nil {
+		return err
+	}
+	s.wake()
+	return nil
+}
+
+// Register registers the service on the gRPC server.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	remotepb.RegisterStateServer(r, &service{s: s})
+}
+
+// Serve serves the service on the listener with a new gRPC server created
+// with the options, e.g. grpc.Creds for TLS.
+func (s *Server) Serve(l net.Listener, opts ...grpc.ServerOption) error {
+	r := grpc.NewServer(opts...)
+	s.Register(r)
+	return r.Serve(l)
+}
+
+// state returns the manager of the named state.
+func (s *Server) state(name string) (*manager.StateManager, error) {
+	if name == "" {
+		return s.manager, nil
+	}
+	if name == indexName {
+		return nil, fmt.Errorf("%w: state name %q is reserved", manager.ErrInvalid, name)
+	}
+	return s.manager.Named(name)
+}
+
+// authorize checks the bearer token of the call.
+func (s *Server) authorize(ctx context.Context) error {
+	if s.token == "" {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(authorizationKey) {
+		token, ok := strings.CutPrefix(v, bearerPrefix)
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+}
+
+// changes returns the channel closed on the next change through the server.
+func (s *Server) changes() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.changed
+}
+
+// wake wakes up watchers.
+func (s *Server) wake() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// setName records whether the named state exists in the index. Caller must
+// not hold the mutex.
+func (s *Server) setName(name string, exists bool) error {
+	if name == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadNames(); err != nil {
+		return err
+	}
+	if s.names[name] == exists {
+		return nil
+	}
+
+	if exists {
+		s.names[name] = true
+	} else {
+		delete(s.names, name)
+	}
+
+	idx := &index{Names: make([]string, 0, len(s.names))}
+	for n := range s.names {
+		idx.Names = append(idx.Names, n)
+	}
+	sort.Strings(idx.Names)
+
+	if err := s.manager.SaveNamed(indexName, idx); err != nil {
+		return fmt.Errorf("failed to save state index: %w", err)
+	}
+	return nil
+}
+
+// loadNames reads the index once. Caller must hold the mutex.
+func (s *Server) loadNames() error {
+	if s.names != nil {
+		return nil
+	}
+
+	idx := &index{}
+	if err := s.manager.LoadNamed(indexName, idx); err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return fmt.Errorf("failed to load state index: %w", err)
+	}
+
+	s.names = make(map[string]bool, len(idx.Names))
+	for _, n := range idx.Names {
+		s.names[n] = true
+	}
+	return nil
+}
+
+// get reads the state and its version. The version is read first, so a
+// change in between is reported again with the next version.
+func get(sm *manager.StateManager) (*remotepb.GetResponse, error) {
+	version, err := sm.Revision()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _, err := sm.Payload()
+	if errors.Is(err, manager.ErrNotFound) {
+		return &remotepb.GetResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &remotepb.GetResponse{Payload: payload, Version: version, Found: true}, nil
+}
+
+// service implements the gRPC service of the server.
+type service struct {
+	remotepb.UnimplementedStateServer
+	s *Server
+}
+
+// Get returns the payload of the named state.
+func (v *service) Get(ctx context.Context, req *remotepb.GetRequest) (*remotepb.GetResponse, error) {
+	if err := v.s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	sm, err := v.s.state(req.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	reply, err := get(sm)
+	return reply, statusError(err)
+}
+
+// Put decodes the payload and saves it as the named state.
+func (v *service) Put(ctx context.Context, req *remotepb.PutRequest) (*remotepb.PutResponse, error) {
+	if err := v.s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	sm, err := v.s.state(req.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	data := v.s.newState()
+	if err := sm.LoadFrom(bytes.NewReader(req.GetPayload()), data); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := sm.Save(data); err != nil {
+		return nil, statusError(err)
+	}
+	if err := v.s.setName(req.GetName(), true); err != nil {
+		return nil, statusError(err)
+	}
+	v.s.wake()
+
+	version, err := sm.Revision()
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &remotepb.PutResponse{Version: version}, nil
+}
+
+// Delete removes the named state.
+func (v *service) Delete(ctx context.Context, req *remotepb.DeleteRequest) (*remotepb.PutResponse, error) {
+	if err := v.s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	sm, err := v.s.state(req.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := sm.Delete(); err != nil {
+		return nil, statusError(err)
+	}
+	if err := v.s.setName(req.GetName(), false); err != nil {
+		return nil, statusError(err)
+	}
+	v.s.wake()
+
+	return &remotepb.PutResponse{}, nil
+}
+
+// List returns the names with the prefix of the states written through the
+// server, and the empty name when the state of the manager exists.
+func (v *service) List(ctx context.Context, req *remotepb.ListRequest) (*remotepb.ListResponse, error) {
+	if err := v.s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	reply := &remotepb.ListResponse{}
+	v.s.mutex.Lock()
+	err := v.s.loadNames()
+	for name := range v.s.names {
+		if strings.HasPrefix(name, req.GetPrefix()) {
+			reply.Names = append(reply.Names, name)
+		}
+	}
+	v.s.mutex.Unlock()
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	sort.Strings(reply.Names)
+	if req.GetPrefix() == "" && v.s.manager.Exists() {
+		reply.Names = append([]string{""}, reply.Names...)
+	}
+	return reply, nil
+}
+
+// Watch sends the named state and then every change of its version until
+// the call is cancelled.
+func (v *service) Watch(req *remotepb.WatchRequest, stream remotepb.State_WatchServer) error {
+	ctx := stream.Context()
+	if err := v.s.authorize(ctx); err != nil {
+		return err
+	}
+
+	sm, err := v.s.state(req.GetName())
+	if err != nil {
+		return statusError(err)
+	}
+
+	ticker := time.NewTicker(v.s.interval)
+	defer ticker.Stop()
+
+	var last *remotepb.GetResponse
+	for {
+		changed := v.s.changes()
+
+		reply, err := get(sm)
+		if err != nil {
+			return statusError(err)
+		}
+		if last == nil || reply.GetVersion() != last.GetVersion() || reply.GetFound() != last.GetFound() {
+			if err := stream.Send(reply); err != nil {
+				return err
+			}
+			last = reply
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// History returns the history of the named state.
+func (v *service) History(ctx context.Context, req *remotepb.HistoryRequest) (*remotepb.HistoryResponse, error) {
+	if err := v.s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	sm, err := v.s.state(req.GetName())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	entries, err := sm.History()
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	reply := &remotepb.HistoryResponse{}
+	for _, e := range entries {
+		reply.Entries = append(reply.Entries, &remotepb.HistoryEntry{
+			Source:     string(e.Source),
+			Label:      e.Label,
+			Generation: int64(e.Generation),
+			Timestamp:  timestamppb.New(e.Timestamp),
+			Size:       e.Size,
+			Checksum:   e.Checksum,
+		})
+	}
+	return reply, nil
+}
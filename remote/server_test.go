@@ -0,0 +1,141 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/remote/remotepb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testState struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newTestManager(t *testing.T, path string) *manager.StateManager {
+	sm, err := manager.NewStateManager(manager.WithFilePath(path),
+		manager.WithSerializationType(manager.JSON), manager.WithEnvelope(), manager.WithBackups(1))
+	assert.NoError(t, err)
+	return sm
+}
+
+func newTestServer(t *testing.T, opts ...ServerOption) (*manager.StateManager, *Server) {
+	sm := newTestManager(t, filepath.Join(t.TempDir(), "state.json"))
+
+	s, err := NewServer(sm, func() interface{} { return &testState{} }, opts...)
+	assert.NoError(t, err)
+	return sm, s
+}
+
+// newTestClient connects a client to the server over an in-memory listener.
+func newTestClient(t *testing.T, s *Server, opts ...grpc.DialOption) *Client {
+	l := bufconn.Listen(1 << 20)
+	r := grpc.NewServer()
+	s.Register(r)
+	go func() { _ = r.Serve(l) }()
+	t.Cleanup(r.Stop)
+
+	opts = append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }))
+	c, err := Dial("passthrough:///bufconn", opts...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// TestNewServer ensures the manager and state constructor are required and options are validated.
+func TestNewServer(t *testing.T) {
+	_, err := NewServer(nil, func() interface{} { return &testState{} })
+	assert.Error(t, err)
+
+	sm := newTestManager(t, filepath.Join(t.TempDir(), "state.json"))
+	_, err = NewServer(sm, func() interface{} { return &testState{} }, WithToken(""))
+	assert.Error(t, err)
+	_, err = NewServer(sm, func() interface{} { return &testState{} }, WithWatchInterval(0))
+	assert.Error(t, err)
+}
+
+// TestServerServe ensures clients can dial the server.
+func TestServerServe(t *testing.T) {
+	sm, s := newTestServer(t)
+	assert.NoError(t, sm.Save(&testState{Name: "a"}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go func() { _ = s.Serve(l) }()
+
+	c, err := Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer c.Close()
+
+	b, err := c.Get("/")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "a", "count": 0}`, string(b))
+
+	keys, err := c.List("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/"}, keys)
+}
+
+// TestServerToken ensures calls without the bearer token are rejected.
+func TestServerToken(t *testing.T) {
+	_, s := newTestServer(t, WithToken("s3cr3t"))
+
+	_, err := newTestClient(t, s).Get("/")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = newTestClient(t, s, grpc.WithPerRPCCredentials(Token("wrong"))).Get("/")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	raw := rawToken("s3cr3t")
+	_, err = newTestClient(t, s, grpc.WithPerRPCCredentials(raw)).Get("/")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	c := newTestClient(t, s, grpc.WithPerRPCCredentials(Token("s3cr3t")))
+	assert.NoError(t, c.Put("/a", []byte(`{"name": "a"}`)))
+	_, err = c.Get("/a")
+	assert.NoError(t, err)
+}
+
+// rawToken sends the token without the Bearer prefix.
+type rawToken string
+
+func (t rawToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: string(t)}, nil
+}
+
+func (t rawToken) RequireTransportSecurity() bool { return false }
+
+// TestServerRestart ensures versions and names survive a restart of the server.
+func TestServerRestart(t *testing.T) {
+	sm, s := newTestServer(t)
+	c := newTestClient(t, s)
+	assert.NoError(t, c.Put("/a", []byte(`{"name": "a"}`)))
+	assert.NoError(t, c.Put("/a", []byte(`{"name": "b"}`)))
+
+	restarted, err := NewServer(newTestManager(t, sm.FilePath), func() interface{} { return &testState{} })
+	assert.NoError(t, err)
+	v := &service{s: restarted}
+
+	reply, err := v.Get(context.Background(), &remotepb.GetRequest{Name: "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), reply.GetVersion())
+
+	list, err := v.List(context.Background(), &remotepb.ListRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, list.GetNames())
+
+	_, err = v.Get(context.Background(), &remotepb.GetRequest{Name: indexName})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,224 @@
+// Package schema generates JSON Schema documents from state structs and
+// validates persisted state against them.
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Draft is the JSON Schema dialect of the generated documents
+	Draft = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// JSON Schema types
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Schema is the subset of JSON Schema describing state structs.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+}
+
+// Types is the list of allowed JSON types, written as a single string when there is only one.
+type Types []string
+
+// MarshalJSON writes a single type as a string.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON reads a single type or a list of types.
+func (t *Types) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// has checks if the type is allowed.
+func (t Types) has(typ string) bool {
+	for _, v := range t {
+		if v == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate returns the JSON Schema of the struct, or pointer to one, as persisted
+// in the given serialization type: the property names are the keys used by that
+// format (state, json or yaml annotations), fields annotated as required are
+// required in STATE and the default annotation becomes the default value.
+func Generate(v interface{}, st manager.SerializationType) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema requires a struct, got %T", v)
+	}
+
+	s, err := generate(t, st)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Schema = Draft
+	s.Title = t.Name()
+	return s, nil
+}
+
+// generate returns the schema of the type.
+func generate(t reflect.Type, st manager.SerializationType) (*Schema, error) {
+	if t.Kind() == reflect.Ptr {
+		s, err := generate(t.Elem(), st)
+		if err != nil {
+			return nil, err
+		}
+		if len(s.Type) > 0 && !s.Type.has(TypeNull) {
+			s.Type = append(s.Type, TypeNull)
+		}
+		return s, nil
+	}
+
+	if encodesItself(t) {
+		if t == timeType {
+			return &Schema{Type: Types{TypeString}, Format: "date-time"}, nil
+		}
+		return &Schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &Schema{Type: Types{TypeObject}, Properties: make(map[string]*Schema)}
+		if err := addProperties(s, t, st); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case reflect.Map:
+		items, err := generate(t.Elem(), st)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: Types{TypeObject, TypeNull}, AdditionalProperties: items}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// byte slices are written as base64 by JSON and binary by YAML
+			return &Schema{}, nil
+		}
+		items, err := generate(t.Elem(), st)
+		if err != nil {
+			return nil, err
+		}
+		types := Types{TypeArray}
+		if t.Kind() == reflect.Slice {
+			types = append(types, TypeNull)
+		}
+		return &Schema{Type: types, Items: items}, nil
+	case reflect.String:
+		return &Schema{Type: Types{TypeString}}, nil
+	case reflect.Bool:
+		return &Schema{Type: Types{TypeBoolean}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t == durationType && (st == manager.YAML || st == manager.STATE) {
+			return &Schema{Type: Types{TypeString}, Format: "duration"}, nil
+		}
+		return &Schema{Type: Types{TypeInteger}}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{TypeNumber}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// addProperties adds the persisted fields of the struct, including inlined ones.
+func addProperties(s *Schema, t reflect.Type, st manager.SerializationType) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		key, inline := manager.FieldKey(st, field)
+		if inline {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addProperties(s, ft, st); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if key == "" {
+			continue
+		}
+
+		p, err := generate(field.Type, st)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		if def, ok := field.Tag.Lookup(manager.DefaultAnnotationKey); ok {
+			if err := yaml.Unmarshal([]byte(def), &p.Default); err != nil {
+				return fmt.Errorf("invalid default for field %s: %w", field.Name, err)
+			}
+		}
+
+		// required fields are only enforced by the STATE codec
+		if st == manager.STATE && manager.FieldRequired(field) {
+			s.Required = append(s.Required, key)
+		}
+
+		s.Properties[key] = p
+	}
+
+	return nil
+}
+
+// encodesItself checks if the type is written by its own encoding methods.
+func encodesItself(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return t == timeType ||
+		p.Implements(reflect.TypeOf((*manager.Marshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*yaml.Marshaler)(nil)).Elem()) ||
+		p.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem())
+}
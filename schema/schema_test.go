@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+type inner struct {
+	Host string `json:"host" yaml:"host" state:"host"`
+	Port int    `json:"port" yaml:"port" state:"port" default:"8080"`
+}
+
+type config struct {
+	Name    string            `json:"name" yaml:"name" state:"name,required"`
+	Ratio   float64           `json:"ratio" yaml:"ratio" state:"ratio"`
+	Tags    []string          `json:"tags" yaml:"tags" state:"tags"`
+	Labels  map[string]string `json:"labels" yaml:"labels" state:"labels"`
+	Server  inner             `json:"server" yaml:"server" state:"server"`
+	Backup  *inner            `json:"backup" yaml:"backup" state:"backup"`
+	Updated time.Time         `json:"updated" yaml:"updated" state:"updated"`
+	Timeout time.Duration     `json:"timeout" yaml:"timeout" state:"timeout"`
+	Ignored string            `json:"-" yaml:"-"`
+}
+
+// TestGenerate ensures the schema describes the persisted keys and types.
+func TestGenerate(t *testing.T) {
+	s, err := Generate(&config{}, manager.STATE)
+	assert.NoError(t, err)
+
+	assert.Equal(t, Draft, s.Schema)
+	assert.Equal(t, "config", s.Title)
+	assert.Equal(t, []string{"name"}, s.Required)
+	assert.Len(t, s.Properties, 8)
+	assert.Equal(t, Types{TypeString}, s.Properties["name"].Type)
+	assert.Equal(t, Types{TypeNumber}, s.Properties["ratio"].Type)
+	assert.Equal(t, Types{TypeArray, TypeNull}, s.Properties["tags"].Type)
+	assert.Equal(t, Types{TypeString}, s.Properties["labels"].AdditionalProperties.Type)
+	assert.Equal(t, Types{TypeInteger}, s.Properties["server"].Properties["port"].Type)
+	assert.Equal(t, 8080, s.Properties["server"].Properties["port"].Default)
+	assert.Equal(t, Types{TypeObject, TypeNull}, s.Properties["backup"].Type)
+	assert.Equal(t, "date-time", s.Properties["updated"].Format)
+	assert.Equal(t, "duration", s.Properties["timeout"].Format)
+
+	js, err := Generate(config{}, manager.JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, Types{TypeInteger}, js.Properties["timeout"].Type)
+	assert.Empty(t, js.Required)
+
+	b, err := json.Marshal(js.Properties["tags"])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":["array","null"],"items":{"type":"string"}}`, string(b))
+
+	var parsed Schema
+	assert.NoError(t, json.Unmarshal(b, &parsed))
+	assert.Equal(t, js.Properties["tags"], &parsed)
+
+	_, err = Generate("x", manager.JSON)
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists all the violations of the schema.
+type ValidationError struct {
+	Errors []string
+}
+
+// Error returns the error message listing the violations.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("schema validation failed: %s", strings.Join(e.Errors, "; "))
+}
+
+// ValidateDocument validates the state decoded into generic values (maps,
+// slices and scalars as produced by encoding/json or gopkg.in/yaml.v3).
+// It implements manager.DocumentValidator.
+func (s *Schema) ValidateDocument(doc interface{}) error {
+	errs := s.validate("", doc, nil)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+// validate appends the violations of the value at the given path.
+func (s *Schema) validate(path string, v interface{}, errs []string) []string {
+	if len(s.Type) > 0 {
+		typ := typeOf(v)
+		if !s.Type.has(typ) && !(typ == TypeInteger && s.Type.has(TypeNumber)) {
+			return append(errs, fmt.Sprintf("%s: expected %s, got %s", pathOf(path), strings.Join(s.Type, " or "), typ))
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := val[key]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", pathOf(path), key))
+			}
+		}
+
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := s.Properties[k]
+			if p == nil {
+				p = s.AdditionalProperties
+			}
+			if p != nil {
+				errs = p.validate(path+"/"+k, val[k], errs)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range val {
+				errs = s.Items.validate(path+"/"+strconv.Itoa(i), item, errs)
+			}
+		}
+	case string:
+		errs = s.validateFormat(path, val, errs)
+	}
+
+	return errs
+}
+
+// validateFormat appends the violation of the string format, if any.
+func (s *Schema) validateFormat(path, v string, errs []string) []string {
+	var err error
+	switch s.Format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, v)
+	case "duration":
+		_, err = time.ParseDuration(v)
+	}
+
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s: invalid %s %q", pathOf(path), s.Format, v))
+	}
+	return errs
+}
+
+// typeOf returns the JSON type of the generic value.
+func typeOf(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return TypeNull
+	case map[string]interface{}:
+		return TypeObject
+	case []interface{}:
+		return TypeArray
+	case string, time.Time:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return TypeInteger
+	case float32:
+		return numberType(float64(val))
+	case float64:
+		return numberType(val)
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// numberType returns integer for whole numbers, which JSON decodes as float64.
+func numberType(f float64) string {
+	if f == math.Trunc(f) && !math.IsInf(f, 0) {
+		return TypeInteger
+	}
+	return TypeNumber
+}
+
+// pathOf returns the JSON Pointer of the path, with / for the root.
+func pathOf(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateDocument ensures type, required and format violations are reported.
+func TestValidateDocument(t *testing.T) {
+	s, err := Generate(&config{}, manager.STATE)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.ValidateDocument(map[string]interface{}{
+		"name":    "a",
+		"ratio":   1,
+		"tags":    []interface{}{"x"},
+		"server":  map[string]interface{}{"port": 80.0},
+		"backup":  nil,
+		"timeout": "1s",
+		"unknown": true,
+	}))
+
+	err = s.ValidateDocument(map[string]interface{}{
+		"ratio":   "high",
+		"tags":    []interface{}{1},
+		"server":  map[string]interface{}{"port": 1.5},
+		"updated": "yesterday",
+	})
+
+	var verr *ValidationError
+	assert.True(t, errors.As(err, &verr))
+	assert.Equal(t, []string{
+		`/: missing required property "name"`,
+		"/ratio: expected number, got string",
+		"/server/port: expected integer, got number",
+		"/tags/0: expected string, got integer",
+		`/updated: invalid date-time "yesterday"`,
+	}, verr.Errors)
+}
+
+// TestWithSchemaValidation ensures hand-edited files are validated on load.
+func TestWithSchemaValidation(t *testing.T) {
+	for _, st := range []manager.SerializationType{manager.JSON, manager.YAML, manager.STATE} {
+		t.Run(string(st), func(t *testing.T) {
+			s, err := Generate(&config{}, st)
+			assert.NoError(t, err)
+
+			m, err := manager.NewStateManager(
+				manager.WithFilePath(filepath.Join(t.TempDir(), "state")),
+				manager.WithSerializationType(st),
+				manager.WithSchemaValidation(s),
+			)
+			assert.NoError(t, err)
+
+			assert.NoError(t, m.Save(&config{Name: "a", Server: inner{Port: 1}}))
+			assert.NoError(t, m.Load(&config{}))
+
+			assert.NoError(t, m.Set("ratio", "high"))
+			assert.ErrorIs(t, m.Load(&config{}), manager.ErrInvalid)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// Package server exposes the state of a StateManager over HTTP so a daemon
+// can serve it for inspection and remote updates.
+//
+// The handlers speak JSON regardless of the serialization type of the manager:
+//
+//	GET   /state          returns the persisted state
+//	PUT   /state          replaces the state with the request body
+//	PATCH /state          applies the request body as RFC 7386 JSON merge patch
+//	GET   /state/history  returns the history of the persisted state
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultPrefix is the path the handlers are mounted at
+	DefaultPrefix = "/state"
+
+	// maxBodySize limits the size of PUT and PATCH request bodies
+	maxBodySize = 10 << 20
+
+	// bearerPrefix starts the Authorization header of bearer tokens
+	bearerPrefix = "Bearer "
+)
+
+// Server serves the state of a StateManager.
+type Server struct {
+	manager  *manager.StateManager
+	newState func() interface{}
+	prefix   string
+	auth     func(r *http.Request) bool
+	readOnly bool
+
+	optionErr error
+}
+
+// Option configures the Server.
+type Option func(*Server)
+
+// WithPrefix sets the path the handlers are mounted at, DefaultPrefix by default.
+func WithPrefix(prefix string) Option {
+	return func(s *Server) {
+		s.prefix = "/" + strings.Trim(prefix, "/")
+	}
+}
+
+// WithAuth rejects requests for which fn returns false with 401 Unauthorized.
+func WithAuth(fn func(r *http.Request) bool) Option {
+	return func(s *Server) {
+		s.auth = fn
+	}
+}
+
+// WithBearerToken only accepts requests carrying the bearer token in an
+// "Authorization: Bearer <token>" header. The token must not be empty.
+func WithBearerToken(token string) Option {
+	return func(s *Server) {
+		if token == "" {
+			s.optionErr = errors.New("bearer token must not be empty")
+			return
+		}
+		s.auth = func(r *http.Request) bool {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+			return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+		}
+	}
+}
+
+// WithReadOnly rejects PUT and PATCH requests with 405 Method Not Allowed.
+func WithReadOnly() Option {
+	return func(s *Server) {
+		s.readOnly = true
+	}
+}
+
+// New creates a server for the state of the manager. The newState func
+// returns a pointer to a new zero value of the state struct, into which
+// requests are decoded before the state is saved.
+func New(sm *manager.StateManager, newState func() interface{}, options ...Option) (*Server, error) {
+	if sm == nil || newState == nil {
+		return nil, errors.New("state manager and state constructor must not be nil")
+	}
+
+	s := &Server{
+		manager:  sm,
+		newState: newState,
+		prefix:   DefaultPrefix,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.optionErr != nil {
+		return nil, s.optionErr
+	}
+
+	return s, nil
+}
+
+// Register mounts the handlers on the mux.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.Handle(s.prefix, s)
+	mux.Handle(s.prefix+"/history", s)
+}
+
+// ServeHTTP serves the state and history endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.auth != nil && !s.auth(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	switch r.URL.Path {
+	case s.prefix:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.get(w)
+		case http.MethodPut, http.MethodPatch:
+			if s.readOnly {
+				s.notAllowed(w, http.MethodGet)
+				return
+			}
+			s.put(w, r, r.Method == http.MethodPatch)
+		default:
+			s.notAllowed(w, http.MethodGet, http.MethodPut, http.MethodPatch)
+		}
+	case s.prefix + "/history":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			s.notAllowed(w, http.MethodGet)
+			return
+		}
+		s.history(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// get writes the persisted state.
+func (s *Server) get(w http.ResponseWriter) {
+	data := s.newState()
+	if err := s.manager.Load(data); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// put saves the request body as the state or, when patch is set, applies it
+// to the persisted state as JSON merge patch with manager Patch.
+func (s *Server) put(w http.ResponseWriter, r *http.Request, patch bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		code := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, code, fmt.Errorf("failed to read request: %w", err))
+		return
+	}
+
+	data := s.newState()
+	if patch {
+		if err = decodePatch(body); err == nil {
+			err = s.manager.Patch(body)
+		}
+		if err == nil {
+			err = s.manager.Load(data)
+		}
+	} else if err = decode(body, data); err == nil {
+		err = s.manager.Save(data)
+	}
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+// history writes the history entries of the state.
+func (s *Server) history(w http.ResponseWriter) {
+	entries, err := s.manager.History()
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// notAllowed rejects the request method.
+func (s *Server) notAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+// errBadRequest marks errors in the request body.
+var errBadRequest = errors.New("bad request")
+
+// decode decodes the JSON body into data rejecting unknown fields.
+func decode(body []byte, data interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.DisallowUnknownFields()
+	if err := d.Decode(data); err != nil {
+		return fmt.Errorf("%w: failed to decode request: %w", errBadRequest, err)
+	}
+	return nil
+}
+
+// decodePatch checks that the body is a JSON object, as merge patches of
+// the state must be.
+func decodePatch(body []byte) error {
+	var p map[string]interface{}
+	if err := json.Unmarshal(body, &p); err != nil || p == nil {
+		return fmt.Errorf("%w: merge patch must be a JSON object", errBadRequest)
+	}
+	return nil
+}
+
+// statusOf maps errors to HTTP status codes.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, manager.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, manager.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, manager.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, manager.ErrLocked):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, manager.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, manager.ErrReadOnly):
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the error as the JSON response.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
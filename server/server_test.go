@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+type testState struct {
+	Name  string            `json:"name"`
+	Count int               `json:"count"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+func newTestServer(t *testing.T, options ...Option) (*manager.StateManager, *httptest.Server) {
+	sm, err := manager.NewStateManager(manager.WithFilePath(filepath.Join(t.TempDir(), "state.json")),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(2))
+	assert.NoError(t, err)
+
+	s, err := New(sm, func() interface{} { return &testState{} }, options...)
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	s.Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return sm, srv
+}
+
+func request(t *testing.T, method, url, body string, header ...string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, strings.TrimSpace(string(b))
+}
+
+// TestNew ensures the manager and state constructor are required.
+func TestNew(t *testing.T) {
+	_, err := New(nil, func() interface{} { return &testState{} })
+	assert.Error(t, err)
+}
+
+// TestServer ensures the state can be read, replaced and patched.
+func TestServer(t *testing.T) {
+	sm, srv := newTestServer(t)
+
+	code, _ := request(t, http.MethodGet, srv.URL+"/state", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body := request(t, http.MethodPut, srv.URL+"/state", `{"name": "a", "count": 1, "tags": {"x": "1"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"name": "a", "count": 1, "tags": {"x": "1"}}`, body)
+
+	code, body = request(t, http.MethodPatch, srv.URL+"/state", `{"count": 2, "tags": {"y": "2"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"name": "a", "count": 2, "tags": {"x": "1", "y": "2"}}`, body)
+
+	loaded := &testState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, 2, loaded.Count)
+
+	code, body = request(t, http.MethodGet, srv.URL+"/state", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"name": "a", "count": 2, "tags": {"x": "1", "y": "2"}}`, body)
+
+	code, _ = request(t, http.MethodPut, srv.URL+"/state", `{"unknown": true}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// null removes the key as in RFC 7386
+	code, body = request(t, http.MethodPatch, srv.URL+"/state", `{"tags": {"x": null}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"name": "a", "count": 2, "tags": {"y": "2"}}`, body)
+
+	code, _ = request(t, http.MethodPatch, srv.URL+"/state", `[1]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = request(t, http.MethodPut, srv.URL+"/state", strings.Repeat(" ", maxBodySize+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	code, _ = request(t, http.MethodDelete, srv.URL+"/state", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, body = request(t, http.MethodGet, srv.URL+"/state/history", "")
+	assert.Equal(t, http.StatusOK, code)
+	var entries []manager.HistoryEntry
+	assert.NoError(t, json.Unmarshal([]byte(body), &entries))
+	assert.Len(t, entries, 3)
+}
+
+// TestServerAuth ensures requests without the token are rejected.
+func TestServerAuth(t *testing.T) {
+	_, srv := newTestServer(t, WithBearerToken("secret"), WithPrefix("/api/state/"), WithReadOnly())
+
+	code, _ := request(t, http.MethodGet, srv.URL+"/api/state/history", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = request(t, http.MethodGet, srv.URL+"/api/state/history", "", "Authorization", "Bearer wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// the token is only accepted as a bearer token
+	code, _ = request(t, http.MethodGet, srv.URL+"/api/state/history", "", "Authorization", "secret")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, body := request(t, http.MethodGet, srv.URL+"/api/state/history", "", "Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[]", body)
+
+	code, _ = request(t, http.MethodPut, srv.URL+"/api/state", `{}`, "Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+// TestWithBearerTokenEmpty ensures an empty token is rejected.
+func TestWithBearerTokenEmpty(t *testing.T) {
+	sm, err := manager.NewStateManager(manager.WithFilePath(filepath.Join(t.TempDir(), "state.json")))
+	assert.NoError(t, err)
+
+	_, err = New(sm, func() interface{} { return &testState{} }, WithBearerToken(""))
+	assert.Error(t, err)
+}
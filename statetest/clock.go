@@ -0,0 +1,138 @@
+package statetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// FakeClock is a manager.Clock that only moves when advanced, see manager.WithClock.
+// Timers and tickers due while advancing fire synchronously in time order.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+var _ manager.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a clock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Advance moves the clock forward, firing the timers and tickers due on the way.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	target := c.now.Add(d)
+
+	for {
+		next := c.next(target)
+		if next == nil {
+			c.now = target
+			c.mutex.Unlock()
+			return
+		}
+
+		c.now = next.when
+		if next.period > 0 {
+			select {
+			case next.ch <- c.now:
+			default:
+			}
+			next.when = next.when.Add(next.period)
+			continue
+		}
+
+		next.active = false
+		c.mutex.Unlock()
+		next.fn()
+		c.mutex.Lock()
+	}
+}
+
+// AfterFunc calls f once the clock advanced by d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) manager.Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), fn: f, active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// NewTicker delivers the time on the channel every time the clock advanced by d.
+func (c *FakeClock) NewTicker(d time.Duration) manager.Ticker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), period: d, ch: make(chan time.Time, 1), active: true}
+	c.timers = append(c.timers, t)
+	return fakeTicker{t}
+}
+
+// next returns the earliest active timer due by target. Caller must hold the lock.
+func (c *FakeClock) next(target time.Time) *fakeTimer {
+	var next *fakeTimer
+	for _, t := range c.timers {
+		if t.active && !t.when.After(target) && (next == nil || t.when.Before(next.when)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// fakeTimer is a timer or, with a period, a ticker of the FakeClock.
+type fakeTimer struct {
+	clock  *FakeClock
+	when   time.Time
+	fn     func()
+	period time.Duration
+	ch     chan time.Time
+	active bool
+}
+
+// Stop prevents the timer from firing, reporting whether it was active.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	active := t.active
+	t.active = false
+	return active
+}
+
+// Reset fires the timer once the clock advanced by d, reporting whether it was active.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	active := t.active
+	t.active = true
+	t.when = t.clock.now.Add(d)
+	return active
+}
+
+// fakeTicker is a periodic fakeTimer exposing its channel.
+type fakeTicker struct {
+	*fakeTimer
+}
+
+// C returns the channel of the ticker.
+func (t fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop turns off the ticker.
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
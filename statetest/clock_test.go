@@ -0,0 +1,79 @@
+package statetest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFakeClockDebounce ensures debounced saves are written only once the clock advanced.
+func TestFakeClockDebounce(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	mem := NewMemFS()
+
+	sm, err := manager.NewStateManager(manager.WithFS(mem), manager.WithFilePath(filepath.Join("/virtual", "state.json")),
+		manager.WithSerializationType(manager.JSON), manager.WithDebounce(time.Minute), manager.WithClock(clock))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&config{Name: "alice"}))
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, sm.Save(&config{Name: "bob"}))
+	clock.Advance(59 * time.Second)
+	assert.False(t, sm.Exists())
+
+	clock.Advance(time.Second)
+	assert.True(t, sm.Exists())
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+}
+
+// TestFakeClockTimers ensures timers fire in order and stopped timers do not fire.
+func TestFakeClockTimers(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	var fired []time.Time
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, clock.Now()) })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, clock.Now()) })
+	stopped := clock.AfterFunc(time.Second, func() { t.Fatal("stopped timer fired") })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, []time.Time{start.Add(time.Second), start.Add(2 * time.Second)}, fired)
+	assert.Equal(t, start.Add(5*time.Second), clock.Now())
+}
+
+// TestFakeClockAutoSave ensures auto-save ticks follow the clock.
+func TestFakeClockAutoSave(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	mem := NewMemFS()
+
+	sm, err := manager.NewStateManager(manager.WithFS(mem), manager.WithFilePath(filepath.Join("/virtual", "state.json")),
+		manager.WithSerializationType(manager.JSON), manager.WithClock(clock))
+	assert.NoError(t, err)
+
+	ticker := clock.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sm.AutoSave(ctx, &config{Name: "alice"}, time.Hour) }()
+
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+	case <-time.After(time.Second):
+		t.Fatal("ticker did not tick")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.True(t, sm.Exists())
+}
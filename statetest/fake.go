@@ -0,0 +1,117 @@
+// Package statetest provides test doubles for the manager package.
+package statetest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// Fake is an in-memory manager.Manager. Saved structs are encoded with the
+// serialization type, so loads return copies just like the real manager.
+// Setting SaveErr, LoadErr or DeleteErr makes the corresponding call fail.
+type Fake struct {
+	SerializationType manager.SerializationType
+
+	SaveErr   error
+	LoadErr   error
+	DeleteErr error
+
+	mutex sync.Mutex
+	data  []byte
+	saves int
+	loads int
+}
+
+var _ manager.Manager = (*Fake)(nil)
+
+// NewFake returns an empty fake using JSON serialization.
+func NewFake() *Fake {
+	return &Fake{SerializationType: manager.JSON}
+}
+
+// Save encodes and keeps the given struct.
+func (f *Fake) Save(data interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.saves++
+	if f.SaveErr != nil {
+		return f.SaveErr
+	}
+
+	b, err := manager.Marshal(f.serializationType(), data)
+	if err != nil {
+		return fmt.Errorf("failed to encode data: %w", err)
+	}
+
+	f.data = b
+	return nil
+}
+
+// Load decodes the last saved struct into data, returning manager.ErrNotFound when nothing was saved.
+func (f *Fake) Load(data interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.loads++
+	if f.LoadErr != nil {
+		return f.LoadErr
+	}
+
+	if f.data == nil {
+		return manager.ErrNotFound
+	}
+
+	if err := manager.Unmarshal(f.serializationType(), f.data, data); err != nil {
+		return fmt.Errorf("failed to decode data: %w", err)
+	}
+
+	return nil
+}
+
+// Exists checks if a struct was saved.
+func (f *Fake) Exists() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.data != nil
+}
+
+// Delete drops the saved struct.
+func (f *Fake) Delete() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.DeleteErr != nil {
+		return f.DeleteErr
+	}
+
+	f.data = nil
+	return nil
+}
+
+// Saves returns the number of Save calls.
+func (f *Fake) Saves() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.saves
+}
+
+// Loads returns the number of Load calls.
+func (f *Fake) Loads() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.loads
+}
+
+// serializationType defaults to JSON for a zero Fake.
+func (f *Fake) serializationType() manager.SerializationType {
+	if f.SerializationType == "" {
+		return manager.JSON
+	}
+	return f.SerializationType
+}
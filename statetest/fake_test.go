@@ -0,0 +1,67 @@
+package statetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+type config struct {
+	Name string `json:"name" yaml:"name" state:"name"`
+}
+
+// service depends on the interface rather than the concrete manager.
+type service struct {
+	store manager.Manager
+}
+
+func (s *service) rename(name string) error {
+	c := &config{}
+	if err := s.store.Load(c); err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return err
+	}
+	c.Name = name
+	return s.store.Save(c)
+}
+
+// TestFake ensures the fake behaves like the manager.
+func TestFake(t *testing.T) {
+	for _, f := range []*Fake{NewFake(), {}, {SerializationType: manager.YAML}} {
+		assert.False(t, f.Exists())
+		assert.ErrorIs(t, f.Load(&config{}), manager.ErrNotFound)
+
+		svc := &service{store: f}
+		assert.NoError(t, svc.rename("alice"))
+		assert.True(t, f.Exists())
+
+		loaded := &config{}
+		assert.NoError(t, f.Load(loaded))
+		assert.Equal(t, "alice", loaded.Name)
+		assert.Equal(t, 1, f.Saves())
+		assert.Equal(t, 3, f.Loads())
+
+		// loads return copies
+		loaded.Name = "changed"
+		assert.NoError(t, f.Load(loaded))
+		assert.Equal(t, "alice", loaded.Name)
+
+		assert.NoError(t, f.Delete())
+		assert.False(t, f.Exists())
+	}
+}
+
+// TestFakeErrors ensures injected errors are returned.
+func TestFakeErrors(t *testing.T) {
+	f := NewFake()
+	f.SaveErr = errors.New("disk full")
+	assert.ErrorIs(t, (&service{store: f}).rename("alice"), f.SaveErr)
+
+	f.SaveErr = nil
+	f.LoadErr = manager.ErrCorrupted
+	assert.ErrorIs(t, (&service{store: f}).rename("alice"), manager.ErrCorrupted)
+
+	f.DeleteErr = errors.New("denied")
+	assert.ErrorIs(t, f.Delete(), f.DeleteErr)
+}
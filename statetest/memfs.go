@@ -0,0 +1,198 @@
+package statetest
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// MemFS is an in-memory manager.FS for tests, see manager.WithFS.
+type MemFS struct {
+	mutex sync.Mutex
+	files map[string]*memFile
+	dirs  map[string]bool
+}
+
+var _ manager.FS = (*MemFS)(nil)
+
+// memFile is a file of the MemFS.
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// NewMemFS returns an empty in-memory file system.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: make(map[string]*memFile),
+		dirs:  make(map[string]bool),
+	}
+}
+
+// ReadFile returns a copy of the content of the file.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// WriteFile replaces the content of the file, creating it with perm if needed.
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	if m.dirs[name] {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	mode := perm
+	if f, ok := m.files[name]; ok {
+		mode = f.mode
+	}
+	m.files[name] = &memFile{data: append([]byte(nil), data...), mode: mode, modTime: time.Now()}
+	return nil
+}
+
+// Rename moves the file.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, ok := m.files[filepath.Clean(oldpath)]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	delete(m.files, filepath.Clean(oldpath))
+	m.files[filepath.Clean(newpath)] = f
+	return nil
+}
+
+// Remove deletes the file or empty directory.
+func (m *MemFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if m.dirs[name] {
+		delete(m.dirs, name)
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+// MkdirAll records the directory and its parents.
+func (m *MemFS) MkdirAll(path string, _ os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		m.dirs[dir] = true
+		if parent := filepath.Dir(dir); parent == dir {
+			return nil
+		}
+	}
+}
+
+// Stat describes the file or directory.
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.stat(filepath.Clean(name))
+}
+
+// Chmod changes the mode of the file.
+func (m *MemFS) Chmod(name string, mode os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	f.mode = mode
+	return nil
+}
+
+// ReadDir lists the files and directories directly inside the directory, sorted by name.
+func (m *MemFS) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name = filepath.Clean(name)
+	if !m.dirs[name] {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	var entries []os.DirEntry
+	add := func(path string) {
+		if filepath.Dir(path) == name && path != name {
+			info, _ := m.stat(path)
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+	for path := range m.files {
+		add(path)
+	}
+	for path := range m.dirs {
+		add(path)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Files returns the paths of all files, sorted.
+func (m *MemFS) Files() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	paths := make([]string, 0, len(m.files))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// stat describes the cleaned path. Caller must hold the lock.
+func (m *MemFS) stat(name string) (os.FileInfo, error) {
+	if f, ok := m.files[name]; ok {
+		return &memInfo{name: filepath.Base(name), size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}, nil
+	}
+	if m.dirs[name] {
+		return &memInfo{name: filepath.Base(name), mode: fs.ModeDir | 0700}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// memInfo describes a file or directory of the MemFS.
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() os.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return i.modTime }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,47 @@
+package statetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMemFS ensures the manager persists state, backups and snapshots in memory.
+func TestMemFS(t *testing.T) {
+	mem := NewMemFS()
+	path := filepath.Join("/virtual", "app", "state.json")
+
+	sm, err := manager.NewStateManager(manager.WithFS(mem), manager.WithFilePath(path),
+		manager.WithSerializationType(manager.JSON), manager.WithCreateDirs(), manager.WithBackups(2))
+	assert.NoError(t, err)
+	assert.False(t, sm.Exists())
+
+	assert.NoError(t, sm.Save(&config{Name: "alice"}))
+	assert.NoError(t, sm.Save(&config{Name: "bob"}))
+	assert.True(t, sm.Exists())
+	assert.NoError(t, sm.Snapshot("before"))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+
+	assert.NoError(t, sm.Restore(1))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	assert.NoError(t, sm.Rollback("before"))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+
+	history, err := sm.History()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, history)
+
+	assert.Contains(t, mem.Files(), path)
+	assert.NoFileExists(t, path)
+
+	assert.NoError(t, sm.Delete())
+	assert.False(t, sm.Exists())
+}
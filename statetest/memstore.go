@@ -0,0 +1,178 @@
+package statetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// MemStore is an in-memory manager.Store for tests, see manager.WithStore.
+type MemStore struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+	locks  map[string]*sync.RWMutex
+	leases map[string]memLease
+}
+
+// memLease is a lease granted by the MemStore.
+type memLease struct {
+	owner   string
+	expires time.Time
+}
+
+var _ manager.Store = (*MemStore)(nil)
+var _ manager.Renamer = (*MemStore)(nil)
+var _ manager.StoreLocker = (*MemStore)(nil)
+var _ manager.Leaser = (*MemStore)(nil)
+
+// NewMemStore returns an empty in-memory store.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		values: make(map[string][]byte),
+		locks:  make(map[string]*sync.RWMutex),
+		leases: make(map[string]memLease),
+	}
+}
+
+// Get returns a copy of the value of the key.
+func (m *MemStore) Get(key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	v, ok := m.values[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+	return append([]byte(nil), v...), nil
+}
+
+// Put sets the value of the key.
+func (m *MemStore) Put(key string, value []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete removes the key.
+func (m *MemStore) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.values, key)
+	return nil
+}
+
+// List returns the sorted keys with the prefix.
+func (m *MemStore) List(prefix string) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := make([]string, 0)
+	for k := range m.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Rename moves the value of the key.
+func (m *MemStore) Rename(oldKey, newKey string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	v, ok := m.values[oldKey]
+	if !ok {
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, oldKey)
+	}
+	m.values[newKey] = v
+	delete(m.values, oldKey)
+	return nil
+}
+
+// Lock takes the lock of the key, polling for it until the timeout passed.
+func (m *MemStore) Lock(key string, exclusive bool, timeout time.Duration) (func(), error) {
+	m.mutex.Lock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &sync.RWMutex{}
+		m.locks[key] = l
+	}
+	m.mutex.Unlock()
+
+	lock, tryLock, unlock := l.RLock, l.TryRLock, l.RUnlock
+	if exclusive {
+		lock, tryLock, unlock = l.Lock, l.TryLock, l.Unlock
+	}
+
+	if timeout <= 0 {
+		lock()
+		return unlock, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !tryLock() {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: timed out after %s", manager.ErrLocked, timeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return unlock, nil
+}
+
+// Acquire grants the lease on the key unless another owner holds it.
+func (m *MemStore) Acquire(_ context.Context, key, owner string, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if l, ok := m.leases[key]; ok && l.owner != owner && time.Now().Before(l.expires) {
+		return fmt.Errorf("%w: leased by %s", manager.ErrLocked, l.owner)
+	}
+	m.leases[key] = memLease{owner: owner, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Renew extends the lease of the owner on the key.
+func (m *MemStore) Renew(_ context.Context, key, owner string, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if l, ok := m.leases[key]; !ok || l.owner != owner || time.Now().After(l.expires) {
+		return fmt.Errorf("%w: lease on %s expired", manager.ErrLocked, key)
+	}
+	m.leases[key] = memLease{owner: owner, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release drops the lease of the owner on the key.
+func (m *MemStore) Release(_ context.Context, key, owner string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if l, ok := m.leases[key]; ok && l.owner == owner {
+		delete(m.leases, key)
+	}
+	return nil
+}
+
+// Expire ends all leases on the key, e.g. to simulate an owner losing it.
+func (m *MemStore) Expire(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.leases, key)
+}
+
+// Keys returns the sorted keys of the store.
+func (m *MemStore) Keys() []string {
+	keys, _ := m.List("")
+	return keys
+}
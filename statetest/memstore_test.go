@@ -0,0 +1,104 @@
+package statetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMemStore ensures the manager persists named states, backups and snapshots as keys.
+func TestMemStore(t *testing.T) {
+	store := NewMemStore()
+
+	sm, err := manager.NewStateManager(manager.WithStore(store), manager.WithFilePath("/app/state.json"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+	assert.False(t, sm.Exists())
+
+	assert.NoError(t, sm.Save(&config{Name: "alice"}))
+	assert.NoError(t, sm.Save(&config{Name: "bob"}))
+	assert.NoError(t, sm.SaveNamed("tokens", &config{Name: "carol"}))
+	assert.NoError(t, sm.Snapshot("before"))
+	assert.Equal(t, []string{"/app/state.json", "/app/state.json-tokens", "/app/state.json.1", "/app/state.json.snapshots/before"}, store.Keys())
+
+	loaded := &config{}
+	assert.NoError(t, sm.LoadNamed("tokens", loaded))
+	assert.Equal(t, "carol", loaded.Name)
+
+	assert.NoError(t, sm.Restore(1))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	history, err := sm.History()
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	assert.NoError(t, sm.Rollback("before"))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+
+	assert.NoError(t, sm.Delete())
+	assert.ErrorIs(t, sm.Load(loaded), manager.ErrNotFound)
+}
+
+// TestMemStoreLock ensures managers sharing a store exclude each other.
+func TestMemStoreLock(t *testing.T) {
+	store := NewMemStore()
+
+	holder, err := manager.NewStateManager(manager.WithStore(store), manager.WithFilePath("/state"), manager.WithFileLock())
+	assert.NoError(t, err)
+
+	waiter, err := manager.NewStateManager(manager.WithStore(store), manager.WithFilePath("/state"),
+		manager.WithLockTimeout(20*time.Millisecond))
+	assert.NoError(t, err)
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_ = holder.Update(&config{}, func() error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+
+	<-locked
+	assert.ErrorIs(t, waiter.Save(&config{Name: "bob"}), manager.ErrLocked)
+	close(release)
+	<-done
+
+	assert.NoError(t, waiter.Save(&config{Name: "bob"}))
+	assert.Equal(t, []string{"/state"}, store.Keys())
+}
+
+// TestMemStoreLease ensures managers take turns holding the lease and lose it on expiry.
+func TestMemStoreLease(t *testing.T) {
+	store := NewMemStore()
+	first, err := manager.NewStateManager(manager.WithStore(store), manager.WithFilePath("/app/state.json"))
+	assert.NoError(t, err)
+	second, err := manager.NewStateManager(manager.WithStore(store), manager.WithFilePath("/app/state.json"))
+	assert.NoError(t, err)
+
+	leaseCtx, err := first.LockState(context.Background(), 30*time.Millisecond)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = second.LockState(ctx, time.Second)
+	assert.ErrorIs(t, err, manager.ErrLocked)
+	assert.NoError(t, leaseCtx.Err())
+
+	store.Expire("/app/state.json")
+	<-leaseCtx.Done()
+	assert.ErrorIs(t, first.Unlock(), manager.ErrLocked)
+
+	_, err = second.LockState(context.Background(), time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, second.Unlock())
+}
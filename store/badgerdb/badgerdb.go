@@ -0,0 +1,238 @@
+// Package badgerdb persists state in a BadgerDB database, for workloads
+// saving state hundreds of times per second where rewriting a file on every
+// save is too slow.
+package badgerdb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v4"
+	"github.com/mchmarny/state/manager"
+)
+
+// lockRetryInterval is the delay between lock attempts when a lock timeout is set
+const lockRetryInterval = time.Millisecond
+
+// Store is a manager.Store keeping every state file as a key of the
+// database. BadgerDB allows a single process to open a database, so the
+// locks of the store guard the keys like lock files do for the file backend.
+type Store struct {
+	db     *badger.DB
+	prefix string
+	owned  bool
+
+	mutex sync.Mutex
+	locks map[string]*sync.RWMutex
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Renamer = (*Store)(nil)
+var _ manager.StoreLocker = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithPrefix prepends the prefix to all keys, so several stores can share a database.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// Open opens or creates the database in the directory and a store on it.
+func Open(dir string, options ...Option) (*Store, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+
+	s, err := New(db, options...)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// New creates a store on the open database.
+func New(db *badger.DB, options ...Option) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("database must not be nil")
+	}
+
+	s := &Store{
+		db:    db,
+		locks: make(map[string]*sync.RWMutex),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// Close closes the database when it was opened by Open.
+func (s *Store) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Get returns the value of the key.
+func (s *Store) Get(key string) ([]byte, error) {
+	var value []byte
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.key(key))
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+// Put sets the value of the key.
+func (s *Store) Put(key string, value []byte) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(s.key(key), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the key.
+func (s *Store) Delete(key string) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(s.key(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns the sorted keys with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = s.key(prefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, strings.TrimPrefix(string(it.Item().Key()), s.prefix))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	return keys, nil
+}
+
+// Rename moves the value of the key in a single transaction, replacing any
+// value of the new key.
+func (s *Store) Rename(oldKey, newKey string) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.key(oldKey))
+		if err != nil {
+			return err
+		}
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(s.key(newKey), v); err != nil {
+			return err
+		}
+		return txn.Delete(s.key(oldKey))
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, oldKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rename %s: %w", oldKey, err)
+	}
+	return nil
+}
+
+// Version returns the version BadgerDB assigned to the last write of the key,
+// e.g. for the version checks of store/cache.
+func (s *Store) Version(key string) (string, error) {
+	var version uint64
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(s.key(key))
+		if err != nil {
+			return err
+		}
+		version = item.Version()
+		return nil
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return strconv.FormatUint(version, 10), nil
+}
+
+// Lock takes the lock of the key, polling for it until the timeout passed.
+func (s *Store) Lock(key string, exclusive bool, timeout time.Duration) (func(), error) {
+	s.mutex.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = &sync.RWMutex{}
+		s.locks[key] = l
+	}
+	s.mutex.Unlock()
+
+	lock, tryLock, unlock := l.RLock, l.TryRLock, l.RUnlock
+	if exclusive {
+		lock, tryLock, unlock = l.Lock, l.TryLock, l.Unlock
+	}
+
+	if timeout <= 0 {
+		lock()
+		return unlock, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !tryLock() {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: timed out after %s", manager.ErrLocked, timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+	return unlock, nil
+}
+
+// key returns the database key of the key.
+func (s *Store) key(key string) []byte {
+	return []byte(s.prefix + key)
+}
@@ -0,0 +1,123 @@
+package badgerdb
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+type testState struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newTestStore(t *testing.T, options ...Option) *Store {
+	s, err := Open(t.TempDir(), options...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// TestStore ensures values are written, listed by prefix, renamed and deleted.
+func TestStore(t *testing.T) {
+	s := newTestStore(t, WithPrefix("app:"))
+
+	_, err := s.Get("/a/state")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+
+	assert.NoError(t, s.Put("/a/state", []byte("a")))
+	assert.NoError(t, s.Put("/a/state.1", []byte("b")))
+	assert.NoError(t, s.Put("/b/state", []byte("c")))
+
+	keys, err := s.List("/a/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a/state", "/a/state.1"}, keys)
+
+	v1, err := s.Version("/a/state")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Rename("/a/state.1", "/a/state"))
+	v, err := s.Get("/a/state")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), v)
+	v2, err := s.Version("/a/state")
+	assert.NoError(t, err)
+	assert.NotEqual(t, v1, v2)
+	assert.ErrorIs(t, s.Rename("/a/state.1", "/a/state"), manager.ErrNotFound)
+
+	assert.NoError(t, s.Delete("/b/state"))
+	assert.NoError(t, s.Delete("/b/state"))
+	_, err = s.Version("/b/state")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+
+	keys, err = s.List("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a/state"}, keys)
+
+	_, err = New(nil)
+	assert.Error(t, err)
+}
+
+// TestStoreLock ensures exclusive locks exclude other holders until released or timed out.
+func TestStoreLock(t *testing.T) {
+	s := newTestStore(t)
+
+	unlock, err := s.Lock("/state", true, 0)
+	assert.NoError(t, err)
+
+	_, err = s.Lock("/state", false, 10*time.Millisecond)
+	assert.ErrorIs(t, err, manager.ErrLocked)
+
+	other, err := s.Lock("/other", true, 10*time.Millisecond)
+	assert.NoError(t, err)
+	other()
+
+	unlock()
+	r1, err := s.Lock("/state", false, 10*time.Millisecond)
+	assert.NoError(t, err)
+	r2, err := s.Lock("/state", false, 10*time.Millisecond)
+	assert.NoError(t, err)
+	r1()
+	r2()
+}
+
+// TestStoreManager ensures concurrent managers save frequently under the store locks and revisions.
+func TestStoreManager(t *testing.T) {
+	s := newTestStore(t)
+	newManager := func() *manager.StateManager {
+		sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/svc/state.json"),
+			manager.WithSerializationType(manager.JSON), manager.WithEnvelope(), manager.WithFileLock())
+		assert.NoError(t, err)
+		return sm
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(sm *manager.StateManager) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				data := &testState{}
+				assert.NoError(t, sm.Update(data, func() error {
+					data.Name = fmt.Sprintf("writer-%d", j)
+					data.Count++
+					return nil
+				}))
+			}
+		}(newManager())
+	}
+	wg.Wait()
+
+	sm := newManager()
+	loaded := &testState{}
+	rev, err := sm.LoadVersion(loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, loaded.Count)
+	assert.Equal(t, int64(200), rev)
+
+	assert.ErrorIs(t, sm.SaveIfVersion(loaded, rev-1), manager.ErrConflict)
+	assert.NoError(t, sm.SaveIfVersion(loaded, rev))
+}
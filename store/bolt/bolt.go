@@ -0,0 +1,247 @@
+// Package bolt persists state in a bbolt database file, so CLIs with many
+// small states keep them in a single file with transactional writes.
+package bolt
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultBucket is the name of the root bucket of the states
+	DefaultBucket = "state"
+
+	// DefaultOpenTimeout is how long Open waits for another process to close the database
+	DefaultOpenTimeout = 5 * time.Second
+)
+
+// Store is a manager.Store keeping every state file as a key of the bucket
+// named after its directory, e.g. the state, its backups and its named
+// states in the same directory share a bucket. The buckets are nested in
+// the root bucket, so several stores can share a database.
+type Store struct {
+	db     *bolt.DB
+	bucket string
+	owned  bool
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Renamer = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithBucket sets the name of the root bucket, DefaultBucket by default.
+func WithBucket(name string) Option {
+	return func(s *Store) {
+		s.bucket = name
+	}
+}
+
+// Open opens or creates the database file and a store on it. The database
+// is locked for the process until Close.
+func Open(file string, options ...Option) (*Store, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: DefaultOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+
+	s, err := New(db, options...)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// New creates a store on the open database.
+func New(db *bolt.DB, options ...Option) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("database must not be nil")
+	}
+
+	s := &Store{
+		db:     db,
+		bucket: DefaultBucket,
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.bucket == "" {
+		return nil, errors.New("bucket name must not be empty")
+	}
+
+	return s, nil
+}
+
+// Close closes the database when it was opened by Open.
+func (s *Store) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Get returns a copy of the value of the key.
+func (s *Store) Get(key string) ([]byte, error) {
+	var value []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := s.dirBucket(tx, key)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(path.Base(key))); v != nil {
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+
+	return value, nil
+}
+
+// Put sets the value of the key.
+func (s *Store) Put(key string, value []byte) error {
+	return s.PutAll(map[string][]byte{key: value})
+}
+
+// PutAll sets the values of the keys in a single transaction, e.g. the
+// content StateManager.Encode returns for several named states.
+func (s *Store) PutAll(values map[string][]byte) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for key, value := range values {
+			if err := s.put(tx, key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the key.
+func (s *Store) Delete(key string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return s.delete(tx, key)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List returns the sorted keys with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(s.bucket))
+		if root == nil {
+			return nil
+		}
+
+		return root.ForEachBucket(func(dir []byte) error {
+			d := string(dir)
+			if !strings.HasPrefix(d, prefix) && !strings.HasPrefix(prefix, d) {
+				return nil
+			}
+			return root.Bucket(dir).ForEach(func(k, _ []byte) error {
+				if key := path.Join(d, string(k)); strings.HasPrefix(key, prefix) {
+					keys = append(keys, key)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Rename moves the value of the key in a single transaction, replacing any
+// value of the new key.
+func (s *Store) Rename(oldKey, newKey string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := s.dirBucket(tx, oldKey)
+		var v []byte
+		if b != nil {
+			v = b.Get([]byte(path.Base(oldKey)))
+		}
+		if v == nil {
+			return fmt.Errorf("%w: %s", manager.ErrNotFound, oldKey)
+		}
+
+		// the value is only valid until the transaction changes the bucket
+		v = append([]byte{}, v...)
+		if err := s.put(tx, newKey, v); err != nil {
+			return err
+		}
+		return s.delete(tx, oldKey)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename %s: %w", oldKey, err)
+	}
+	return nil
+}
+
+// dirBucket returns the bucket of the directory of the key, nil when it does not exist.
+func (s *Store) dirBucket(tx *bolt.Tx, key string) *bolt.Bucket {
+	root := tx.Bucket([]byte(s.bucket))
+	if root == nil {
+		return nil
+	}
+	return root.Bucket([]byte(path.Dir(key)))
+}
+
+// put sets the value of the key, creating the buckets on the way.
+func (s *Store) put(tx *bolt.Tx, key string, value []byte) error {
+	root, err := tx.CreateBucketIfNotExists([]byte(s.bucket))
+	if err != nil {
+		return err
+	}
+	b, err := root.CreateBucketIfNotExists([]byte(path.Dir(key)))
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return b.Put([]byte(path.Base(key)), value)
+}
+
+// delete removes the key and the bucket of its directory once it is empty.
+func (s *Store) delete(tx *bolt.Tx, key string) error {
+	b := s.dirBucket(tx, key)
+	if b == nil {
+		return nil
+	}
+	if err := b.Delete([]byte(path.Base(key))); err != nil {
+		return err
+	}
+
+	if k, _ := b.Cursor().First(); k == nil {
+		return tx.Bucket([]byte(s.bucket)).DeleteBucket([]byte(path.Dir(key)))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+type testState struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newTestStore(t *testing.T, options ...Option) *Store {
+	s, err := Open(filepath.Join(t.TempDir(), "state.db"), options...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// TestStore ensures values are kept per directory bucket and listed by prefix.
+func TestStore(t *testing.T) {
+	s := newTestStore(t)
+
+	_, err := s.Get("/app/state")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+
+	assert.NoError(t, s.Put("/app/state", []byte("a")))
+	assert.NoError(t, s.Put("/app/state.1", []byte("b")))
+	assert.NoError(t, s.Put("/app/sub/state", nil))
+	assert.NoError(t, s.Put("/other/state", []byte("c")))
+
+	v, err := s.Get("/app/state")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), v)
+
+	v, err = s.Get("/app/sub/state")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{}, v)
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/state.1", "/app/sub/state"}, keys)
+
+	keys, err = s.List("/app/state.")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state.1"}, keys)
+
+	assert.NoError(t, s.Rename("/app/state.1", "/app/state"))
+	v, err = s.Get("/app/state")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), v)
+	assert.ErrorIs(t, s.Rename("/app/state.1", "/app/state"), manager.ErrNotFound)
+
+	assert.NoError(t, s.Delete("/other/state"))
+	assert.NoError(t, s.Delete("/other/state"))
+	keys, err = s.List("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/sub/state"}, keys)
+}
+
+// TestStorePutAll ensures several states are written in one transaction.
+func TestStorePutAll(t *testing.T) {
+	s := newTestStore(t, WithBucket("tools"))
+
+	assert.NoError(t, s.PutAll(map[string][]byte{"/a/x": []byte("1"), "/b/y": []byte("2")}))
+	keys, err := s.List("/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a/x", "/b/y"}, keys)
+
+	shared, err := New(s.db)
+	assert.NoError(t, err)
+	keys, err = shared.List("/")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = New(nil)
+	assert.Error(t, err)
+	_, err = New(s.db, WithBucket(""))
+	assert.Error(t, err)
+}
+
+// TestStoreManager ensures a manager persists its state, backups and named states in the database.
+func TestStoreManager(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state.db")
+	s, err := Open(file)
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/tools/cli.json"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(2))
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.Save(&testState{Name: "a", Count: 1}))
+	assert.NoError(t, sm.Save(&testState{Name: "b", Count: 2}))
+	assert.NoError(t, sm.SaveNamed("cache", &testState{Name: "c"}))
+
+	keys, err := s.List("/tools/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/tools/cli.json", "/tools/cli.json-cache", "/tools/cli.json.1"}, keys)
+	assert.NoError(t, s.Close())
+
+	// the state survives reopening the database
+	s, err = Open(file)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	sm, err = manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/tools/cli.json"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(2))
+	assert.NoError(t, err)
+
+	loaded := &testState{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, &testState{Name: "b", Count: 2}, loaded)
+
+	assert.NoError(t, sm.Restore(1))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "a", loaded.Name)
+}
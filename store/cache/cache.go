@@ -0,0 +1,269 @@
+// Package cache keeps the values of a remote store in memory, and optionally
+// in a local file mirror, so loads on request paths only fetch the state
+// when it changed.
+//
+// Checking whether a value changed needs a backend implementing Versioner,
+// which among the stores of this module only rest.Store does. With any other
+// backend every load fetches the value, unless WithMaxAge serves it from the
+// cache without a remote read.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// Versioner is implemented by stores which can cheaply report the version
+// of a value, e.g. its ETag or revision, without fetching it.
+type Versioner interface {
+	// Version returns the version of the value.
+	Version(key string) (string, error)
+	// PutVersion writes the value and returns the version of the write.
+	PutVersion(key string, value []byte) (string, error)
+}
+
+// entry is a cached value.
+type entry struct {
+	value   []byte
+	version string
+	checked time.Time
+}
+
+// Store is a write-through manager.Store caching the values of the backend.
+// A cached value is served without contacting the backend while younger than
+// the max age, and afterwards as long as the version reported by a backend
+// implementing Versioner is unchanged.
+type Store struct {
+	backend manager.Store
+	mirror  string
+	maxAge  time.Duration
+	clock   manager.Clock
+
+	mutex   sync.Mutex
+	entries map[string]*entry
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Renamer = (*Store)(nil)
+var _ manager.StoreLocker = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithMirror mirrors the cached values to files in the directory, so they
+// survive restarts and only need a version check on the first load.
+func WithMirror(dir string) Option {
+	return func(s *Store) {
+		s.mirror = dir
+	}
+}
+
+// WithMaxAge serves cached values for the duration without any backend call.
+func WithMaxAge(d time.Duration) Option {
+	return func(s *Store) {
+		s.maxAge = d
+	}
+}
+
+// WithClock sets the clock timing the max age, the system clock by default.
+func WithClock(c manager.Clock) Option {
+	return func(s *Store) {
+		s.clock = c
+	}
+}
+
+// New creates a cache in front of the backend.
+func New(backend manager.Store, options ...Option) (*Store, error) {
+	if backend == nil {
+		return nil, errors.New("backend store must not be nil")
+	}
+
+	s := &Store{
+		backend: backend,
+		entries: make(map[string]*entry),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.maxAge < 0 {
+		return nil, errors.New("max age must not be negative")
+	}
+	if s.mirror != "" {
+		if err := os.MkdirAll(s.mirror, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create mirror directory: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the cached value when it is still current and fetches it otherwise.
+func (s *Store) Get(key string) ([]byte, error) {
+	e := s.cached(key)
+	if e != nil && s.maxAge > 0 && s.now().Sub(e.checked) < s.maxAge {
+		return e.value, nil
+	}
+
+	var version string
+	if v, ok := s.backend.(Versioner); ok {
+		var err error
+		if version, err = v.Version(key); err != nil {
+			if errors.Is(err, manager.ErrNotFound) {
+				s.Invalidate(key)
+			}
+			return nil, err
+		}
+		if e != nil && version != "" && version == e.version {
+			s.mutex.Lock()
+			e.checked = s.now()
+			s.mutex.Unlock()
+			return e.value, nil
+		}
+	}
+
+	value, err := s.backend.Get(key)
+	if err != nil {
+		if errors.Is(err, manager.ErrNotFound) {
+			s.Invalidate(key)
+		}
+		return nil, err
+	}
+
+	s.store(key, value, version)
+	return value, nil
+}
+
+// Put writes the value to the backend and the cache. The value is cached with
+// the version returned by the write of a Versioner backend, and without one
+// otherwise, so the next load past the max age fetches it again.
+func (s *Store) Put(key string, value []byte) error {
+	var version string
+	var err error
+	if v, ok := s.backend.(Versioner); ok {
+		version, err = v.PutVersion(key, value)
+	} else {
+		err = s.backend.Put(key, value)
+	}
+	if err != nil {
+		s.Invalidate(key)
+		return err
+	}
+
+	s.store(key, value, version)
+	return nil
+}
+
+// Delete removes the value from the backend and the cache.
+func (s *Store) Delete(key string) error {
+	s.Invalidate(key)
+	return s.backend.Delete(key)
+}
+
+// List returns the keys with the prefix from the backend.
+func (s *Store) List(prefix string) ([]string, error) {
+	return s.backend.List(prefix)
+}
+
+// Rename moves the value on the backend, copying it when the backend can not rename.
+func (s *Store) Rename(oldKey, newKey string) error {
+	if r, ok := s.backend.(manager.Renamer); ok {
+		s.Invalidate(oldKey)
+		s.Invalidate(newKey)
+		return r.Rename(oldKey, newKey)
+	}
+
+	value, err := s.Get(oldKey)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(newKey, value); err != nil {
+		return err
+	}
+	return s.Delete(oldKey)
+}
+
+// Lock locks the key on the backend when it supports locks.
+func (s *Store) Lock(key string, exclusive bool, timeout time.Duration) (func(), error) {
+	if l, ok := s.backend.(manager.StoreLocker); ok {
+		return l.Lock(key, exclusive, timeout)
+	}
+	return func() {}, nil
+}
+
+// Invalidate drops the cached value of the key.
+func (s *Store) Invalidate(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, key)
+	if s.mirror != "" {
+		_ = os.Remove(s.mirrorPath(key))
+		_ = os.Remove(s.mirrorPath(key) + ".version")
+	}
+}
+
+// cached returns the cached entry of the key, reading the mirror on a miss.
+func (s *Store) cached(key string) *entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		return e
+	}
+	if s.mirror == "" {
+		return nil
+	}
+
+	value, err := os.ReadFile(s.mirrorPath(key))
+	if err != nil {
+		return nil
+	}
+	version, err := os.ReadFile(s.mirrorPath(key) + ".version")
+	if err != nil {
+		return nil
+	}
+
+	// mirrored values were not checked since the restart
+	e := &entry{value: value, version: string(version)}
+	s.entries[key] = e
+	return e
+}
+
+// store caches the value and its version.
+func (s *Store) store(key string, value []byte, version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[key] = &entry{value: value, version: version, checked: s.now()}
+	if s.mirror == "" {
+		return
+	}
+
+	// the version is written last so a torn write never looks current
+	p := s.mirrorPath(key)
+	_ = os.Remove(p + ".version")
+	if err := os.WriteFile(p, value, 0600); err == nil && version != "" {
+		_ = os.WriteFile(p+".version", []byte(version), 0600)
+	}
+}
+
+// mirrorPath returns the path of the mirror file of the key.
+func (s *Store) mirrorPath(key string) string {
+	return filepath.Join(s.mirror, url.PathEscape(key))
+}
+
+// now returns the current time of the clock.
+func (s *Store) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/statetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// versionedStore counts fetches and versions values by write count.
+type versionedStore struct {
+	*statetest.MemStore
+	writes   map[string]int
+	gets     int
+	versions int
+}
+
+func newVersionedStore() *versionedStore {
+	return &versionedStore{MemStore: statetest.NewMemStore(), writes: make(map[string]int)}
+}
+
+func (v *versionedStore) Get(key string) ([]byte, error) {
+	v.gets++
+	return v.MemStore.Get(key)
+}
+
+func (v *versionedStore) Put(key string, value []byte) error {
+	v.writes[key]++
+	return v.MemStore.Put(key, value)
+}
+
+func (v *versionedStore) PutVersion(key string, value []byte) (string, error) {
+	if err := v.Put(key, value); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v.writes[key]), nil
+}
+
+func (v *versionedStore) Version(key string) (string, error) {
+	v.versions++
+	if _, err := v.MemStore.Get(key); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v.writes[key]), nil
+}
+
+// TestNew ensures invalid settings are rejected.
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	assert.Error(t, err)
+	_, err = New(statetest.NewMemStore(), WithMaxAge(-time.Second))
+	assert.Error(t, err)
+}
+
+// TestStoreVersionCheck ensures values are only fetched after they changed.
+func TestStoreVersionCheck(t *testing.T) {
+	backend := newVersionedStore()
+	s, err := New(backend)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.Equal(t, 0, backend.versions)
+	b, err := s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+	assert.Equal(t, 0, backend.gets)
+
+	assert.NoError(t, backend.Put("/state", []byte("b")))
+	b, err = s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b))
+	assert.Equal(t, 1, backend.gets)
+
+	_, err = s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, backend.gets)
+
+	assert.NoError(t, backend.Delete("/state"))
+	_, err = s.Get("/state")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+}
+
+// TestStoreMaxAge ensures fresh values are served without backend calls.
+func TestStoreMaxAge(t *testing.T) {
+	backend := newVersionedStore()
+	clock := statetest.NewFakeClock(time.Now())
+	s, err := New(backend, WithMaxAge(time.Minute), WithClock(clock))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	backend.versions = 0
+	assert.NoError(t, backend.Put("/state", []byte("b")))
+
+	b, err := s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+	assert.Equal(t, 0, backend.versions)
+
+	clock.Advance(time.Minute)
+	b, err = s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b))
+	assert.Equal(t, 1, backend.versions)
+}
+
+// TestStoreUnversioned ensures values written to other backends are fetched again on load.
+func TestStoreUnversioned(t *testing.T) {
+	backend := statetest.NewMemStore()
+	s, err := New(backend)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.Equal(t, "", s.cached("/state").version)
+
+	assert.NoError(t, backend.Put("/state", []byte("b")))
+	b, err := s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b))
+}
+
+// TestStoreMirror ensures mirrored values survive a restart.
+func TestStoreMirror(t *testing.T) {
+	backend := newVersionedStore()
+	dir := t.TempDir()
+	s, err := New(backend, WithMirror(dir))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Put("/app/state", []byte("a")))
+
+	restarted, err := New(backend, WithMirror(dir))
+	assert.NoError(t, err)
+	b, err := restarted.Get("/app/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+	assert.Equal(t, 0, backend.gets)
+
+	assert.NoError(t, restarted.Delete("/app/state"))
+	assert.Nil(t, restarted.cached("/app/state"))
+}
+
+// TestStoreManager ensures a manager round-trips state through the cache.
+func TestStoreManager(t *testing.T) {
+	backend := newVersionedStore()
+	s, err := New(backend)
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"bob"}))
+
+	backend.gets = 0
+	for i := 0; i < 3; i++ {
+		loaded := &config{}
+		assert.NoError(t, sm.Load(loaded))
+		assert.Equal(t, "bob", loaded.Name)
+	}
+	assert.Equal(t, 0, backend.gets)
+	assert.Equal(t, []string{"/app/state", "/app/state.1"}, backend.Keys())
+}
@@ -0,0 +1,402 @@
+// Package consul persists state in the Consul KV store over its HTTP API.
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultAddress is the address of the local Consul agent
+	DefaultAddress = "http://127.0.0.1:8500"
+
+	// minSessionTTL is the shortest session TTL accepted by Consul
+	minSessionTTL = 10 * time.Second
+)
+
+// Store is a manager.Store keeping every state file as a Consul key.
+type Store struct {
+	client     *http.Client
+	address    string
+	prefix     string
+	token      string
+	datacenter string
+	cas        bool
+
+	mutex    sync.Mutex
+	indexes  map[string]uint64
+	sessions map[string]leaseSession
+}
+
+// leaseSession is the session holding the lease on a key for its owner.
+type leaseSession struct {
+	id    string
+	owner string
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Leaser = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithToken sets the ACL token, CONSUL_HTTP_TOKEN by default.
+func WithToken(token string) Option {
+	return func(s *Store) {
+		s.token = token
+	}
+}
+
+// WithDatacenter sets the datacenter of the keys, the one of the agent by default.
+func WithDatacenter(dc string) Option {
+	return func(s *Store) {
+		s.datacenter = dc
+	}
+}
+
+// WithPrefix prepends the prefix to all keys.
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithHTTPClient sets the client used to reach Consul, http.DefaultClient by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) {
+		s.client = c
+	}
+}
+
+// WithCAS makes writes check-and-set against the ModifyIndex of the key last
+// read or written by the store. A write of a key changed by someone else since
+// then, or of an existing key never read, fails with manager.ErrConflict.
+func WithCAS() Option {
+	return func(s *Store) {
+		s.cas = true
+	}
+}
+
+// New creates a store on the Consul agent at the address, CONSUL_HTTP_ADDR or
+// DefaultAddress when empty.
+func New(address string, options ...Option) (*Store, error) {
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = DefaultAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consul address %q: %w", address, err)
+	}
+
+	s := &Store{
+		client:   http.DefaultClient,
+		address:  strings.TrimSuffix(u.String(), "/"),
+		token:    os.Getenv("CONSUL_HTTP_TOKEN"),
+		indexes:  make(map[string]uint64),
+		sessions: make(map[string]leaseSession),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// kvPair is an entry of the KV API.
+type kvPair struct {
+	Key         string
+	Value       []byte
+	ModifyIndex uint64
+}
+
+// Get returns the value of the key.
+func (s *Store) Get(key string) ([]byte, error) {
+	var pairs []kvPair
+	if err := s.do(http.MethodGet, "/v1/kv/"+s.escape(key), nil, nil, &pairs); err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+
+	s.setIndex(key, pairs[0].ModifyIndex)
+	return pairs[0].Value, nil
+}
+
+// Put sets the value of the key, checking its ModifyIndex with WithCAS.
+func (s *Store) Put(key string, value []byte) error {
+	if !s.cas {
+		return s.do(http.MethodPut, "/v1/kv/"+s.escape(key), nil, value, nil)
+	}
+
+	// the transaction API returns the new ModifyIndex of the key
+	ops := []map[string]interface{}{{
+		"KV": map[string]interface{}{"Verb": "cas", "Key": s.key(key), "Value": value, "Index": s.index(key)},
+	}}
+	b, err := json.Marshal(ops)
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction: %w", err)
+	}
+
+	var result struct {
+		Results []struct{ KV kvPair }
+	}
+	if err := s.do(http.MethodPut, "/v1/txn", nil, b, &result); err != nil {
+		return err
+	}
+	if len(result.Results) == 1 {
+		s.setIndex(key, result.Results[0].KV.ModifyIndex)
+	}
+	return nil
+}
+
+// Delete removes the key, checking its ModifyIndex with WithCAS.
+func (s *Store) Delete(key string) error {
+	query := url.Values{}
+	if s.cas {
+		index := s.index(key)
+		if index == 0 {
+			if _, err := s.Get(key); err != nil {
+				if errors.Is(err, manager.ErrNotFound) {
+					return nil
+				}
+				return err
+			}
+			index = s.index(key)
+		}
+		query.Set("cas", strconv.FormatUint(index, 10))
+	}
+
+	var ok bool
+	if err := s.do(http.MethodDelete, "/v1/kv/"+s.escape(key), query, nil, &ok); err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", manager.ErrConflict, key)
+	}
+
+	s.setIndex(key, 0)
+	return nil
+}
+
+// List returns the keys with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	var keys []string
+	err := s.do(http.MethodGet, "/v1/kv/"+s.escape(prefix), url.Values{"keys": {""}}, nil, &keys)
+	if err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return nil, err
+	}
+
+	list := make([]string, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, s.unkey(k))
+	}
+	return list, nil
+}
+
+// Acquire takes the lease on the key by acquiring a lock key under .leases
+// with a new session. Consul rounds the ttl up to its 10s minimum.
+func (s *Store) Acquire(ctx context.Context, key, owner string, ttl time.Duration) error {
+	ttl = max(ttl, minSessionTTL)
+	b, err := json.Marshal(map[string]string{
+		"Name":      owner,
+		"TTL":       ttl.Round(time.Second).String(),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	var session struct{ ID string }
+	if err := s.doContext(ctx, http.MethodPut, "/v1/session/create", nil, b, &session); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	var ok bool
+	err = s.doContext(ctx, http.MethodPut, "/v1/kv/"+s.escape(leaseKey(key)), url.Values{"acquire": {session.ID}},
+		[]byte(owner), &ok)
+	if err != nil || !ok {
+		_ = s.doContext(ctx, http.MethodPut, "/v1/session/destroy/"+session.ID, nil, nil, nil)
+		if err != nil {
+			return fmt.Errorf("failed to acquire lease: %w", err)
+		}
+		return fmt.Errorf("%w: %s is leased by another session", manager.ErrLocked, key)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sessions[key] = leaseSession{id: session.ID, owner: owner}
+	return nil
+}
+
+// Renew renews the session holding the lease.
+func (s *Store) Renew(ctx context.Context, key, owner string, _ time.Duration) error {
+	id := s.session(key, owner)
+	if id == "" {
+		return fmt.Errorf("%w: no lease on %s", manager.ErrLocked, key)
+	}
+
+	err := s.doContext(ctx, http.MethodPut, "/v1/session/renew/"+id, nil, nil, nil)
+	if errors.Is(err, manager.ErrNotFound) {
+		return fmt.Errorf("%w: session of the lease on %s expired", manager.ErrLocked, key)
+	}
+	return err
+}
+
+// Release releases the lock key and destroys the session holding the lease.
+func (s *Store) Release(ctx context.Context, key, owner string) error {
+	id := s.session(key, owner)
+	if id == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	delete(s.sessions, key)
+	s.mutex.Unlock()
+
+	if err := s.doContext(ctx, http.MethodPut, "/v1/kv/"+s.escape(leaseKey(key)), url.Values{"release": {id}},
+		nil, nil); err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return s.doContext(ctx, http.MethodPut, "/v1/session/destroy/"+id, nil, nil, nil)
+}
+
+// session returns the id of the session holding the lease on the key for
+// the owner, empty when the owner holds none.
+func (s *Store) session(key, owner string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if ls, ok := s.sessions[key]; ok && ls.owner == owner {
+		return ls.id
+	}
+	return ""
+}
+
+// leaseKey returns the key of the lock key of the lease on the key.
+func leaseKey(key string) string {
+	return path.Join("/.leases", key)
+}
+
+// key maps the store key to the Consul key.
+func (s *Store) key(key string) string {
+	return strings.TrimPrefix(path.Join(s.prefix, strings.TrimPrefix(key, "/")), "/")
+}
+
+// unkey maps the Consul key to the store key.
+func (s *Store) unkey(key string) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(key, s.prefix), "/")
+}
+
+// escape maps the store key to the escaped Consul key, keeping a trailing slash.
+func (s *Store) escape(key string) string {
+	k := s.key(key)
+	if strings.HasSuffix(key, "/") && k != "" {
+		k += "/"
+	}
+
+	parts := strings.Split(k, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// index returns the ModifyIndex of the key last seen.
+func (s *Store) index(key string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.indexes[key]
+}
+
+// setIndex records the ModifyIndex of the key.
+func (s *Store) setIndex(key string, index uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if index == 0 {
+		delete(s.indexes, key)
+		return
+	}
+	s.indexes[key] = index
+}
+
+// do sends the request and decodes the JSON response into out when set.
+func (s *Store) do(method, p string, query url.Values, body []byte, out interface{}) error {
+	return s.doContext(context.Background(), method, p, query, body, out)
+}
+
+// doContext sends the request with the context and decodes the JSON response into out when set.
+func (s *Store) doContext(ctx context.Context, method, p string, query url.Values, body []byte, out interface{}) error {
+	if s.datacenter != "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("dc", s.datacenter)
+	}
+
+	u := s.address + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read consul response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, p)
+	case resp.StatusCode == http.StatusConflict:
+		return fmt.Errorf("%w: %s", manager.ErrConflict, strings.TrimSpace(string(b)))
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("consul returned %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to decode consul response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConsul serves the subset of the Consul KV, transaction and session APIs used by the store.
+type fakeConsul struct {
+	mutex    sync.Mutex
+	index    uint64
+	values   map[string]kvPair
+	tokens   []string
+	sessions map[string]bool
+	holders  map[string]string
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.tokens = append(f.tokens, r.Header.Get("X-Consul-Token"))
+	body, _ := io.ReadAll(r.Body)
+
+	if r.URL.Path == "/v1/txn" {
+		var ops []struct{ KV kvPair }
+		var raw []struct{ KV struct{ Index uint64 } }
+		_ = json.Unmarshal(body, &ops)
+		_ = json.Unmarshal(body, &raw)
+		if f.values[ops[0].KV.Key].ModifyIndex != raw[0].KV.Index {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"Errors":[{"What":"failed to set key"}]}`))
+			return
+		}
+		p := f.set(ops[0].KV.Key, ops[0].KV.Value)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Results": []interface{}{map[string]interface{}{"KV": p}}})
+		return
+	}
+
+	if id, ok := strings.CutPrefix(r.URL.Path, "/v1/session/"); ok {
+		switch {
+		case id == "create":
+			f.index++
+			id = fmt.Sprintf("session-%d", f.index)
+			f.sessions[id] = true
+			_ = json.NewEncoder(w).Encode(map[string]string{"ID": id})
+		case strings.HasPrefix(id, "renew/"):
+			if !f.sessions[strings.TrimPrefix(id, "renew/")] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case strings.HasPrefix(id, "destroy/"):
+			delete(f.sessions, strings.TrimPrefix(id, "destroy/"))
+		}
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	if session := r.URL.Query().Get("acquire"); session != "" {
+		if holder, ok := f.holders[key]; ok && holder != session && f.sessions[holder] {
+			_, _ = w.Write([]byte("false"))
+			return
+		}
+		f.holders[key] = session
+		f.set(key, body)
+		_, _ = w.Write([]byte("true"))
+		return
+	}
+	if session := r.URL.Query().Get("release"); session != "" {
+		if f.holders[key] == session {
+			delete(f.holders, key)
+		}
+		_, _ = w.Write([]byte("true"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := r.URL.Query()["keys"]; ok {
+			var keys []string
+			for k := range f.values {
+				if strings.HasPrefix(k, key) {
+					keys = append(keys, k)
+				}
+			}
+			if len(keys) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			sort.Strings(keys)
+			_ = json.NewEncoder(w).Encode(keys)
+			return
+		}
+		p, ok := f.values[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]kvPair{p})
+	case http.MethodPut:
+		f.set(key, body)
+		_, _ = w.Write([]byte("true"))
+	case http.MethodDelete:
+		if cas := r.URL.Query().Get("cas"); cas != "" {
+			if cas != strconv.FormatUint(f.values[key].ModifyIndex, 10) {
+				_, _ = w.Write([]byte("false"))
+				return
+			}
+		}
+		delete(f.values, key)
+		_, _ = w.Write([]byte("true"))
+	}
+}
+
+func (f *fakeConsul) set(key string, value []byte) kvPair {
+	f.index++
+	p := kvPair{Key: key, Value: value, ModifyIndex: f.index}
+	f.values[key] = p
+	return p
+}
+
+// setupStore creates a store on a fake Consul agent.
+func setupStore(t *testing.T, options ...Option) (*Store, *fakeConsul) {
+	t.Helper()
+
+	fake := &fakeConsul{values: make(map[string]kvPair), sessions: make(map[string]bool), holders: make(map[string]string)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	s, err := New(srv.URL, append([]Option{WithToken("secret"), WithPrefix("tools/")}, options...)...)
+	assert.NoError(t, err)
+
+	return s, fake
+}
+
+// TestStore ensures state round-trips through Consul under the prefix.
+func TestStore(t *testing.T) {
+	s, fake := setupStore(t)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, sm.Load(&struct{}{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&struct{ Name string }{"alice"}))
+	assert.NoError(t, sm.Save(&struct{ Name string }{"bob"}))
+
+	loaded := &struct{ Name string }{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+	assert.Contains(t, fake.values, "tools/app/state")
+	assert.Contains(t, fake.values, "tools/app/state.1")
+	assert.Equal(t, "secret", fake.tokens[0])
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/state.1"}, keys)
+
+	assert.NoError(t, s.Delete("/app/state.1"))
+	_, err = s.Get("/app/state.1")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+}
+
+// TestStoreCAS ensures writes based on a stale ModifyIndex fail with ErrConflict.
+func TestStoreCAS(t *testing.T) {
+	s, fake := setupStore(t, WithCAS())
+	other, err := New(strings.TrimSuffix(s.address, "/"), WithPrefix("tools"), WithCAS())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.ErrorIs(t, other.Put("/state", []byte("b")), manager.ErrConflict)
+
+	v, err := other.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a"), v)
+	assert.NoError(t, other.Put("/state", []byte("b")))
+
+	assert.ErrorIs(t, s.Put("/state", []byte("c")), manager.ErrConflict)
+	assert.ErrorIs(t, s.Delete("/state"), manager.ErrConflict)
+	assert.Equal(t, []byte("b"), fake.values["tools/state"].Value)
+
+	assert.NoError(t, other.Delete("/state"))
+	assert.Empty(t, fake.values)
+}
+
+// TestStoreLease ensures a single session holds the lease and renewals fail once it expired.
+func TestStoreLease(t *testing.T) {
+	s, fake := setupStore(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.Acquire(ctx, "/state", "a", time.Second))
+	assert.ErrorIs(t, s.Acquire(ctx, "/state", "b", time.Second), manager.ErrLocked)
+	assert.Contains(t, fake.values, "tools/.leases/state")
+	assert.NoError(t, s.Renew(ctx, "/state", "a", time.Second))
+
+	assert.NoError(t, s.Release(ctx, "/state", "a"))
+	assert.Empty(t, fake.sessions)
+	assert.ErrorIs(t, s.Renew(ctx, "/state", "a", time.Second), manager.ErrLocked)
+
+	assert.NoError(t, s.Acquire(ctx, "/state", "b", time.Second))
+	for id := range fake.sessions {
+		delete(fake.sessions, id)
+	}
+	assert.ErrorIs(t, s.Renew(ctx, "/state", "b", time.Second), manager.ErrLocked)
+}
+
+// TestStoreLeaseKeys ensures an owner holds leases on several keys independently.
+func TestStoreLeaseKeys(t *testing.T) {
+	s, fake := setupStore(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.Acquire(ctx, "/one", "a", time.Second))
+	assert.NoError(t, s.Acquire(ctx, "/two", "a", time.Second))
+	assert.Len(t, fake.sessions, 2)
+
+	assert.NoError(t, s.Release(ctx, "/one", "a"))
+	assert.Len(t, fake.sessions, 1)
+	assert.NoError(t, s.Renew(ctx, "/two", "a", time.Second))
+	assert.ErrorIs(t, s.Renew(ctx, "/two", "b", time.Second), manager.ErrLocked)
+	assert.NoError(t, s.Release(ctx, "/two", "a"))
+	assert.Empty(t, fake.sessions)
+}
@@ -0,0 +1,442 @@
+// Package dynamodb persists state in an Amazon DynamoDB table, for
+// serverless functions without a durable local disk. Requests go through the
+// AWS SDK with credentials from its default chain, e.g. environment variables,
+// shared config and SSO profiles, web identity tokens of IRSA and container or
+// instance roles, refreshed as they expire.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultPartitionKey is the name of the string partition key of the table
+	DefaultPartitionKey = "id"
+
+	// valueAttribute holds the state
+	valueAttribute = "state"
+
+	// versionAttribute counts the writes of the item for conditional writes
+	versionAttribute = "version"
+
+	// ownerAttribute and expiresAttribute describe the holder of a lease item
+	ownerAttribute   = "owner"
+	expiresAttribute = "expires"
+)
+
+// API is the subset of the DynamoDB client used by the store, implemented by *dynamodb.Client.
+type API interface {
+	GetItem(ctx context.Context, in *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, in *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+var _ API = (*dynamodb.Client)(nil)
+
+// Store is a manager.Store keeping every state file as an item of the table.
+type Store struct {
+	client       API
+	httpClient   *http.Client
+	endpoint     string
+	region       string
+	table        string
+	partitionKey string
+	creds        aws.CredentialsProvider
+	conditional  bool
+
+	mutex    sync.Mutex
+	versions map[string]int64
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Leaser = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithClient sets the DynamoDB client, e.g. one created from an aws.Config
+// of the application. The region, endpoint, credentials and HTTP client
+// options are ignored when it is set.
+func WithClient(c API) Option {
+	return func(s *Store) {
+		s.client = c
+	}
+}
+
+// WithRegion sets the AWS region, the region of the default config, e.g.
+// AWS_REGION or the profile, by default.
+func WithRegion(region string) Option {
+	return func(s *Store) {
+		s.region = region
+	}
+}
+
+// WithEndpoint sets the URL of the DynamoDB API, e.g. of DynamoDB Local,
+// the regional endpoint by default.
+func WithEndpoint(endpoint string) Option {
+	return func(s *Store) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithCredentials sets the provider of the credentials, the default
+// credential chain of the AWS SDK by default.
+func WithCredentials(creds aws.CredentialsProvider) Option {
+	return func(s *Store) {
+		s.creds = creds
+	}
+}
+
+// WithPartitionKey sets the name of the string partition key of the table,
+// DefaultPartitionKey by default.
+func WithPartitionKey(name string) Option {
+	return func(s *Store) {
+		s.partitionKey = name
+	}
+}
+
+// WithHTTPClient sets the client used to reach DynamoDB, the client of the AWS SDK by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) {
+		s.httpClient = c
+	}
+}
+
+// WithConditionalWrites makes writes conditional on the version of the item
+// last read or written by the store. A write of an item changed by someone
+// else since then, or of an existing item never read, fails with manager.ErrConflict.
+func WithConditionalWrites() Option {
+	return func(s *Store) {
+		s.conditional = true
+	}
+}
+
+// New creates a store on the table, which must have a string partition key
+// and no sort key.
+func New(table string, options ...Option) (*Store, error) {
+	if table == "" {
+		return nil, errors.New("table name must not be empty")
+	}
+
+	s := &Store{
+		table:        table,
+		partitionKey: DefaultPartitionKey,
+		versions:     make(map[string]int64),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.client == nil {
+		c, err := s.newClient()
+		if err != nil {
+			return nil, err
+		}
+		s.client = c
+	}
+
+	return s, nil
+}
+
+// newClient creates a client from the default config of the AWS SDK and the options.
+func (s *Store) newClient() (*dynamodb.Client, error) {
+	var loads []func(*config.LoadOptions) error
+	if s.region != "" {
+		loads = append(loads, config.WithRegion(s.region))
+	}
+	if s.creds != nil {
+		loads = append(loads, config.WithCredentialsProvider(s.creds))
+	}
+	if s.httpClient != nil {
+		loads = append(loads, config.WithHTTPClient(s.httpClient))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), loads...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("AWS region is not set")
+	}
+
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if s.endpoint != "" {
+			o.BaseEndpoint = aws.String(s.endpoint)
+		}
+	}), nil
+}
+
+// str returns the string attribute.
+func str(v string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: v}
+}
+
+// num returns the number attribute.
+func num(v int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(v, 10)}
+}
+
+// bin returns the binary attribute.
+func bin(v []byte) types.AttributeValue {
+	if v == nil {
+		v = []byte{}
+	}
+	return &types.AttributeValueMemberB{Value: v}
+}
+
+// recordVersion records the version attribute of the item.
+func (s *Store) recordVersion(key string, item map[string]types.AttributeValue) error {
+	v, ok := item[versionAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil
+	}
+
+	version, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version of item %s: %w", key, err)
+	}
+	s.setVersion(key, version)
+	return nil
+}
+
+// Get returns the value of the item with a strongly consistent read.
+func (s *Store) Get(key string) ([]byte, error) {
+	out, err := s.client.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            s.itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, apiError("GetItem", err)
+	}
+	if out.Item == nil {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+
+	if err := s.recordVersion(key, out.Item); err != nil {
+		return nil, err
+	}
+	if b, ok := out.Item[valueAttribute].(*types.AttributeValueMemberB); ok && b.Value != nil {
+		return b.Value, nil
+	}
+	return []byte{}, nil
+}
+
+// Put writes the item and increments its version, conditional on the
+// version last seen with WithConditionalWrites.
+func (s *Store) Put(key string, value []byte) error {
+	names := map[string]string{"#s": valueAttribute, "#v": versionAttribute}
+	values := map[string]types.AttributeValue{":s": bin(value), ":one": num(1)}
+
+	in := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       s.itemKey(key),
+		UpdateExpression:          aws.String("SET #s = :s ADD #v :one"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	}
+	if s.conditional {
+		in.ConditionExpression = aws.String(s.condition(s.version(key), names, values))
+	}
+
+	out, err := s.client.UpdateItem(context.Background(), in)
+	if err != nil {
+		return apiError("UpdateItem", err)
+	}
+
+	return s.recordVersion(key, out.Attributes)
+}
+
+// Delete removes the item, conditional on the version last seen with WithConditionalWrites.
+func (s *Store) Delete(key string) error {
+	in := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       s.itemKey(key),
+	}
+	if s.conditional {
+		version := s.version(key)
+		if version == 0 {
+			if _, err := s.Get(key); err != nil {
+				if errors.Is(err, manager.ErrNotFound) {
+					return nil
+				}
+				return err
+			}
+			version = s.version(key)
+		}
+
+		names := map[string]string{}
+		values := map[string]types.AttributeValue{}
+		in.ConditionExpression = aws.String(s.condition(version, names, values))
+		in.ExpressionAttributeNames = names
+		if len(values) > 0 {
+			in.ExpressionAttributeValues = values
+		}
+	}
+
+	if _, err := s.client.DeleteItem(context.Background(), in); err != nil {
+		return apiError("DeleteItem", err)
+	}
+
+	s.setVersion(key, 0)
+	return nil
+}
+
+// List returns the keys with the prefix, scanning the table.
+func (s *Store) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	var start map[string]types.AttributeValue
+
+	for {
+		out, err := s.client.Scan(context.Background(), &dynamodb.ScanInput{
+			TableName:                 aws.String(s.table),
+			ProjectionExpression:      aws.String("#k"),
+			FilterExpression:          aws.String("begins_with(#k, :p)"),
+			ExpressionAttributeNames:  map[string]string{"#k": s.partitionKey},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":p": str(prefix)},
+			ExclusiveStartKey:         start,
+		})
+		if err != nil {
+			return nil, apiError("Scan", err)
+		}
+
+		for _, item := range out.Items {
+			if k, ok := item[s.partitionKey].(*types.AttributeValueMemberS); ok {
+				keys = append(keys, k.Value)
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return keys, nil
+		}
+		start = out.LastEvaluatedKey
+	}
+}
+
+// Acquire takes the lease on the key by writing a lease item under /.leases,
+// on the condition that no other owner holds an unexpired lease. Expiry is
+// judged by the local clock, so hosts should keep their clocks in sync.
+func (s *Store) Acquire(ctx context.Context, key, owner string, ttl time.Duration) error {
+	now := time.Now()
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]types.AttributeValue{
+			s.partitionKey:   str(leaseKey(key)),
+			ownerAttribute:   str(owner),
+			expiresAttribute: num(now.Add(ttl).UnixMilli()),
+		},
+		ConditionExpression:       aws.String("attribute_not_exists(#k) OR #e < :now OR #o = :o"),
+		ExpressionAttributeNames:  map[string]string{"#k": s.partitionKey, "#e": expiresAttribute, "#o": ownerAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": num(now.UnixMilli()), ":o": str(owner)},
+	})
+	err = apiError("PutItem", err)
+	if errors.Is(err, manager.ErrConflict) {
+		return fmt.Errorf("%w: %s is leased by another owner", manager.ErrLocked, key)
+	}
+	return err
+}
+
+// Renew extends the lease item of the owner.
+func (s *Store) Renew(ctx context.Context, key, owner string, ttl time.Duration) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       s.itemKey(leaseKey(key)),
+		UpdateExpression:          aws.String("SET #e = :e"),
+		ConditionExpression:       aws.String("#o = :o"),
+		ExpressionAttributeNames:  map[string]string{"#e": expiresAttribute, "#o": ownerAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":e": num(time.Now().Add(ttl).UnixMilli()), ":o": str(owner)},
+	})
+	err = apiError("UpdateItem", err)
+	if errors.Is(err, manager.ErrConflict) {
+		return fmt.Errorf("%w: lease on %s was taken over", manager.ErrLocked, key)
+	}
+	return err
+}
+
+// Release deletes the lease item of the owner.
+func (s *Store) Release(ctx context.Context, key, owner string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(s.table),
+		Key:                       s.itemKey(leaseKey(key)),
+		ConditionExpression:       aws.String("#o = :o"),
+		ExpressionAttributeNames:  map[string]string{"#o": ownerAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":o": str(owner)},
+	})
+	err = apiError("DeleteItem", err)
+	if errors.Is(err, manager.ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+// leaseKey returns the key of the lease item of the key.
+func leaseKey(key string) string {
+	return path.Join("/.leases", key)
+}
+
+// itemKey returns the primary key of the item.
+func (s *Store) itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{s.partitionKey: str(key)}
+}
+
+// condition returns the expression requiring the item to be at the version,
+// or absent for 0, adding its names and values.
+func (s *Store) condition(version int64, names map[string]string, values map[string]types.AttributeValue) string {
+	if version == 0 {
+		names["#k"] = s.partitionKey
+		return "attribute_not_exists(#k)"
+	}
+
+	names["#v"] = versionAttribute
+	values[":v"] = num(version)
+	return "#v = :v"
+}
+
+// version returns the version of the item last seen.
+func (s *Store) version(key string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.versions[key]
+}
+
+// setVersion records the version of the item.
+func (s *Store) setVersion(key string, version int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if version == 0 {
+		delete(s.versions, key)
+		return
+	}
+	s.versions[key] = version
+}
+
+// apiError wraps the error of the API operation, failed conditions as manager.ErrConflict.
+func apiError(operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return fmt.Errorf("%w: %s", manager.ErrConflict, failed.ErrorMessage())
+	}
+	return fmt.Errorf("dynamodb %s failed: %w", operation, err)
+}
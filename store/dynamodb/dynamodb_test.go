@@ -0,0 +1,273 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// targetPrefix is the X-Amz-Target prefix of the DynamoDB API operations
+const targetPrefix = "DynamoDB_20120810."
+
+// attribute is a DynamoDB attribute value on the wire.
+type attribute struct {
+	S *string `json:",omitempty"`
+	N *string `json:",omitempty"`
+	B *[]byte `json:",omitempty"`
+}
+
+// errorResponse is the error response of the DynamoDB API.
+type errorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// wireStr returns the string attribute on the wire.
+func wireStr(v string) attribute {
+	return attribute{S: &v}
+}
+
+// wireNum returns the number attribute on the wire.
+func wireNum(v int64) attribute {
+	n := strconv.FormatInt(v, 10)
+	return attribute{N: &n}
+}
+
+// fakeDynamoDB serves the subset of the DynamoDB API used by the store on a table keyed by "pk".
+type fakeDynamoDB struct {
+	mutex sync.Mutex
+	items map[string]map[string]attribute
+}
+
+// request is the union of the request fields used by the store.
+type request struct {
+	TableName                 string
+	Key                       map[string]attribute
+	Item                      map[string]attribute
+	ConditionExpression       string
+	ExpressionAttributeValues map[string]attribute
+	ExclusiveStartKey         map[string]attribute
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var in request
+	b, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(b, &in)
+	if in.TableName != "states" {
+		f.fail(w, "ResourceNotFoundException", "table not found")
+		return
+	}
+
+	var key string
+	if k := in.Key["pk"].S; k != nil {
+		key = *k
+	}
+	if k := in.Item["pk"].S; k != nil {
+		key = *k
+	}
+	item := f.items[key]
+
+	if in.ConditionExpression != "" {
+		ok := item == nil
+		switch in.ConditionExpression {
+		case "#v = :v":
+			ok = item != nil && *item[versionAttribute].N == *in.ExpressionAttributeValues[":v"].N
+		case "#o = :o":
+			ok = item != nil && *item[ownerAttribute].S == *in.ExpressionAttributeValues[":o"].S
+		case "attribute_not_exists(#k) OR #e < :now OR #o = :o":
+			if item != nil {
+				expires, _ := strconv.ParseInt(*item[expiresAttribute].N, 10, 64)
+				now, _ := strconv.ParseInt(*in.ExpressionAttributeValues[":now"].N, 10, 64)
+				ok = expires < now || *item[ownerAttribute].S == *in.ExpressionAttributeValues[":o"].S
+			}
+		}
+		if !ok {
+			f.fail(w, "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException", "The conditional request failed")
+			return
+		}
+	}
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix) {
+	case "GetItem":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Item": item})
+	case "PutItem":
+		f.items[key] = in.Item
+		_, _ = w.Write([]byte("{}"))
+	case "UpdateItem":
+		if e, ok := in.ExpressionAttributeValues[":e"]; ok {
+			item[expiresAttribute] = e
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		version := int64(1)
+		if item != nil {
+			v, _ := strconv.ParseInt(*item[versionAttribute].N, 10, 64)
+			version = v + 1
+		}
+		f.items[key] = map[string]attribute{"pk": wireStr(key), valueAttribute: in.ExpressionAttributeValues[":s"], versionAttribute: wireNum(version)}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Attributes": map[string]attribute{versionAttribute: wireNum(version)}})
+	case "DeleteItem":
+		delete(f.items, key)
+		_, _ = w.Write([]byte("{}"))
+	case "Scan":
+		// one item per page to exercise pagination
+		prefix := *in.ExpressionAttributeValues[":p"].S
+		keys := make([]string, 0)
+		for k := range f.items {
+			if strings.HasPrefix(k, prefix) && (in.ExclusiveStartKey == nil || k > *in.ExclusiveStartKey["pk"].S) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		out := map[string]interface{}{"Items": []interface{}{}}
+		if len(keys) > 0 {
+			out["Items"] = []interface{}{map[string]attribute{"pk": wireStr(keys[0])}}
+			out["LastEvaluatedKey"] = map[string]attribute{"pk": wireStr(keys[0])}
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}
+}
+
+func (f *fakeDynamoDB) fail(w http.ResponseWriter, kind, message string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(errorResponse{Type: kind, Message: message})
+}
+
+// setupStore creates a store on a fake DynamoDB endpoint.
+func setupStore(t *testing.T, options ...Option) (*Store, *fakeDynamoDB) {
+	t.Helper()
+
+	fake := &fakeDynamoDB{items: make(map[string]map[string]attribute)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	s, err := New("states", append([]Option{WithEndpoint(srv.URL), WithRegion("us-west-2"),
+		WithPartitionKey("pk"), WithCredentials(credentials.NewStaticCredentialsProvider("AKID", "secret", ""))}, options...)...)
+	assert.NoError(t, err)
+
+	return s, fake
+}
+
+// TestStore ensures state round-trips through the table.
+func TestStore(t *testing.T) {
+	s, fake := setupStore(t)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.ErrorIs(t, sm.Load(&config{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"bob"}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+	assert.Len(t, fake.items, 2)
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/state.1"}, keys)
+
+	assert.NoError(t, s.Put("/empty", nil))
+	v, err := s.Get("/empty")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+}
+
+// TestStoreConditionalWrites ensures writes based on a stale version fail with ErrConflict.
+func TestStoreConditionalWrites(t *testing.T) {
+	s, fake := setupStore(t, WithConditionalWrites())
+	other, err := New("states", WithEndpoint(s.endpoint), WithRegion("us-west-2"), WithPartitionKey("pk"),
+		WithCredentials(s.creds), WithConditionalWrites())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.ErrorIs(t, other.Put("/state", []byte("b")), manager.ErrConflict)
+
+	_, err = other.Get("/state")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Put("/state", []byte("b")))
+
+	assert.ErrorIs(t, s.Put("/state", []byte("c")), manager.ErrConflict)
+	assert.ErrorIs(t, s.Delete("/state"), manager.ErrConflict)
+	assert.Equal(t, []byte("b"), *fake.items["/state"][valueAttribute].B)
+
+	assert.NoError(t, other.Delete("/state"))
+	assert.Empty(t, fake.items)
+}
+
+// TestNew ensures the region is required and credentials come from the default chain.
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	_, err := New("")
+	assert.Error(t, err)
+
+	_, err = New("states")
+	assert.ErrorContains(t, err, "region")
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+	s, err := New("states")
+	assert.NoError(t, err)
+
+	client, ok := s.client.(*dynamodb.Client)
+	assert.True(t, ok)
+	assert.Equal(t, "us-east-1", client.Options().Region)
+	creds, err := client.Options().Credentials.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID", creds.AccessKeyID)
+	assert.Equal(t, "token", creds.SessionToken)
+}
+
+// TestStoreLease ensures a single owner holds an unexpired lease.
+func TestStoreLease(t *testing.T) {
+	s, fake := setupStore(t)
+	ctx := context.Background()
+
+	assert.NoError(t, s.Acquire(ctx, "/state", "a", time.Minute))
+	assert.ErrorIs(t, s.Acquire(ctx, "/state", "b", time.Minute), manager.ErrLocked)
+	assert.Contains(t, fake.items, "/.leases/state")
+	assert.NoError(t, s.Renew(ctx, "/state", "a", time.Minute))
+	assert.ErrorIs(t, s.Renew(ctx, "/state", "b", time.Minute), manager.ErrLocked)
+
+	assert.NoError(t, s.Release(ctx, "/state", "b"))
+	assert.Contains(t, fake.items, "/.leases/state")
+	assert.NoError(t, s.Release(ctx, "/state", "a"))
+	assert.NotContains(t, fake.items, "/.leases/state")
+
+	assert.NoError(t, s.Acquire(ctx, "/state", "b", -time.Minute))
+	assert.NoError(t, s.Acquire(ctx, "/state", "a", time.Minute))
+	assert.ErrorIs(t, s.Renew(ctx, "/state", "b", time.Minute), manager.ErrLocked)
+}
@@ -0,0 +1,480 @@
+// Package failover keeps state available on intermittently connected
+// devices: a Store writes to a primary backend and fails over to a fallback,
+// e.g. a local file store, once a circuit breaker trips after repeated
+// failures. Changes made while the primary was unavailable are reconciled
+// once it recovers.
+package failover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultThreshold is the number of consecutive failures tripping the breaker
+	DefaultThreshold = 3
+
+	// DefaultCooldown is how long the breaker stays open before the primary is retried
+	DefaultCooldown = 30 * time.Second
+
+	// DefaultJournalKey is the key of the fallback holding the keys still to reconcile
+	DefaultJournalKey = ".failover-journal"
+)
+
+// State is the state of the circuit breaker.
+type State string
+
+const (
+	// Breaker states
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+// Store is a manager.Store over a primary and a fallback store. While the
+// breaker is closed, reads are served by the primary and writes go to both.
+// While it is open, the fallback serves all calls and the keys it changed are
+// pushed to the primary once a retry after the cooldown succeeds. The keys
+// still to reconcile are kept in a journal on the fallback, so they survive
+// restarts during an outage.
+type Store struct {
+	primary    manager.Store
+	fallback   manager.Store
+	threshold  int
+	cooldown   time.Duration
+	clock      manager.Clock
+	onChange   func(State)
+	onConflict func(key string, discarded []byte)
+	journalKey string
+
+	reconciling sync.Mutex
+	mutex       sync.Mutex
+	state       State
+	failures    int
+	openedAt    time.Time
+
+	// dirty maps the keys changed on the fallback only to the version of
+	// their value last in sync with the primary, empty when it was missing
+	dirty map[string]string
+}
+
+var _ manager.Store = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithThreshold sets the number of consecutive primary failures tripping the
+// breaker, DefaultThreshold by default.
+func WithThreshold(n int) Option {
+	return func(s *Store) {
+		s.threshold = n
+	}
+}
+
+// WithCooldown sets how long the breaker stays open before the primary is
+// retried, DefaultCooldown by default.
+func WithCooldown(d time.Duration) Option {
+	return func(s *Store) {
+		s.cooldown = d
+	}
+}
+
+// WithClock sets the clock timing the cooldown, the system clock by default.
+func WithClock(c manager.Clock) Option {
+	return func(s *Store) {
+		s.clock = c
+	}
+}
+
+// WithStateChange calls fn whenever the breaker changes its state. It must
+// not call back into the store.
+func WithStateChange(fn func(State)) Option {
+	return func(s *Store) {
+		s.onChange = fn
+	}
+}
+
+// WithJournalKey sets the key of the fallback holding the keys still to
+// reconcile, DefaultJournalKey by default.
+func WithJournalKey(key string) Option {
+	return func(s *Store) {
+		s.journalKey = key
+	}
+}
+
+// WithConflictHandler calls fn with the value changed on the fallback, nil
+// when it was deleted, whenever reconciling discards it because the primary
+// changed the key during the outage too. It must not call back into the store.
+func WithConflictHandler(fn func(key string, discarded []byte)) Option {
+	return func(s *Store) {
+		s.onConflict = fn
+	}
+}
+
+// New creates a store failing over from the primary to the fallback, resuming
+// the reconciliation of keys changed on the fallback before a restart.
+func New(primary, fallback manager.Store, options ...Option) (*Store, error) {
+	if primary == nil || fallback == nil {
+		return nil, errors.New("primary and fallback stores must not be nil")
+	}
+
+	s := &Store{
+		primary:    primary,
+		fallback:   fallback,
+		threshold:  DefaultThreshold,
+		cooldown:   DefaultCooldown,
+		state:      StateClosed,
+		journalKey: DefaultJournalKey,
+		dirty:      make(map[string]string),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	if s.threshold < 1 {
+		return nil, errors.New("threshold must be at least 1")
+	}
+	if s.cooldown < 0 {
+		return nil, errors.New("cooldown must not be negative")
+	}
+	if s.journalKey == "" {
+		return nil, errors.New("journal key must not be empty")
+	}
+
+	b, err := s.fallback.Get(s.journalKey)
+	if err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &s.dirty); err != nil {
+			return nil, fmt.Errorf("failed to decode journal: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// State returns the state of the circuit breaker.
+func (s *Store) State() State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.state
+}
+
+// Get returns the value from the primary, refreshing the copy of the
+// fallback, or the fallback while the breaker is open.
+func (s *Store) Get(key string) ([]byte, error) {
+	if s.usePrimary() {
+		v, err := s.primary.Get(key)
+		if err == nil && !s.isDirty(key) {
+			_ = s.fallback.Put(key, v)
+		}
+		if !s.failed(err) {
+			return v, err
+		}
+	}
+	return s.fallback.Get(key)
+}
+
+// Put writes the value to the primary and the fallback, or only the
+// fallback while the breaker is open.
+func (s *Store) Put(key string, value []byte) error {
+	if s.usePrimary() {
+		err := s.primary.Put(key, value)
+		if err == nil {
+			_ = s.fallback.Put(key, value)
+			if err := s.markClean(key); err != nil {
+				return err
+			}
+		}
+		if !s.failed(err) {
+			return err
+		}
+	}
+
+	if err := s.markDirty(key); err != nil {
+		return err
+	}
+	return s.fallback.Put(key, value)
+}
+
+// Delete removes the key from the primary and the fallback, or only the
+// fallback while the breaker is open.
+func (s *Store) Delete(key string) error {
+	if s.usePrimary() {
+		err := s.primary.Delete(key)
+		if err == nil {
+			_ = s.fallback.Delete(key)
+			if err := s.markClean(key); err != nil {
+				return err
+			}
+		}
+		if !s.failed(err) {
+			return err
+		}
+	}
+
+	if err := s.markDirty(key); err != nil {
+		return err
+	}
+	return s.fallback.Delete(key)
+}
+
+// List returns the keys with the prefix from the primary, or the fallback
+// while the breaker is open.
+func (s *Store) List(prefix string) ([]string, error) {
+	if s.usePrimary() {
+		keys, err := s.primary.List(prefix)
+		if !s.failed(err) {
+			return keys, err
+		}
+	}
+
+	keys, err := s.fallback.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		if k == s.journalKey {
+			return append(keys[:i], keys[i+1:]...), nil
+		}
+	}
+	return keys, nil
+}
+
+// Reconcile pushes the keys changed on the fallback while the breaker was
+// open to the primary. A key the primary changed too since it was last in
+// sync is not overwritten: the primary value is kept and copied to the
+// fallback, and the fallback change is passed to WithConflictHandler. It runs
+// automatically when the primary recovers.
+func (s *Store) Reconcile() error {
+	s.reconciling.Lock()
+	defer s.reconciling.Unlock()
+
+	s.mutex.Lock()
+	bases := make(map[string]string, len(s.dirty))
+	keys := make([]string, 0, len(s.dirty))
+	for k, base := range s.dirty {
+		bases[k] = base
+		keys = append(keys, k)
+	}
+	s.mutex.Unlock()
+	sort.Strings(keys)
+
+	var errs []string
+	for _, k := range keys {
+		if err := s.reconcile(k, bases[k]); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := s.markClean(k); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reconcile %d keys: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// reconcile copies the key from the fallback to the primary when the primary
+// is still at the base version, and the other way round otherwise.
+func (s *Store) reconcile(key, base string) error {
+	current, err := s.primary.Get(key)
+	if err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	if version(current, err) != base {
+		return s.keepPrimary(key, current, err)
+	}
+
+	v, err := s.fallback.Get(key)
+	if errors.Is(err, manager.ErrNotFound) {
+		if err := s.primary.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s from fallback: %w", key, err)
+	}
+
+	if err := s.primary.Put(key, v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// keepPrimary replaces the fallback change of the key with the value of the
+// primary, missing when found is ErrNotFound.
+func (s *Store) keepPrimary(key string, current []byte, found error) error {
+	discarded, err := s.fallback.Get(key)
+	if err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return fmt.Errorf("failed to read %s from fallback: %w", key, err)
+	}
+
+	if found != nil {
+		err = s.fallback.Delete(key)
+	} else {
+		err = s.fallback.Put(key, current)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update %s on fallback: %w", key, err)
+	}
+
+	if s.onConflict != nil {
+		s.onConflict(key, discarded)
+	}
+	return nil
+}
+
+// version returns the version of the value read with the error, empty when
+// it is missing.
+func version(v []byte, err error) string {
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(v)
+	return hex.EncodeToString(sum[:])
+}
+
+// usePrimary reports whether the call should try the primary. Once the
+// cooldown passed, an open breaker turns half-open and reconciles the changes
+// made on the fallback first, opening again when that fails.
+func (s *Store) usePrimary() bool {
+	s.mutex.Lock()
+	if s.state == StateOpen && s.now().Sub(s.openedAt) >= s.cooldown {
+		s.setState(StateHalfOpen)
+	}
+	state := s.state
+	s.mutex.Unlock()
+
+	if state != StateHalfOpen {
+		return state == StateClosed
+	}
+
+	if err := s.Reconcile(); err != nil {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.openedAt = s.now()
+		s.setState(StateOpen)
+		return false
+	}
+	return true
+}
+
+// failed records the outcome of a primary call and reports whether it
+// failed so that the call should be served by the fallback. Missing keys
+// and conflicts are answers of a healthy primary.
+func (s *Store) failed(err error) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err == nil || errors.Is(err, manager.ErrNotFound) || errors.Is(err, manager.ErrConflict) {
+		s.failures = 0
+		if s.state == StateHalfOpen {
+			s.setState(StateClosed)
+		}
+		return false
+	}
+
+	s.failures++
+	if s.state == StateHalfOpen || s.failures >= s.threshold {
+		s.openedAt = s.now()
+		s.setState(StateOpen)
+	}
+	return true
+}
+
+// isDirty reports whether the key changed on the fallback only.
+func (s *Store) isDirty(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.dirty[key]
+	return ok
+}
+
+// markDirty records in the journal that the key is about to change on the
+// fallback only. The first change records the version of the fallback copy
+// as the one last in sync with the primary.
+func (s *Store) markDirty(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.dirty[key]; ok {
+		return nil
+	}
+
+	v, err := s.fallback.Get(key)
+	if err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return fmt.Errorf("failed to read %s from fallback: %w", key, err)
+	}
+	s.dirty[key] = version(v, err)
+
+	return s.writeJournal()
+}
+
+// markClean records in the journal that the key is in sync on the primary.
+func (s *Store) markClean(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.dirty[key]; !ok {
+		return nil
+	}
+	delete(s.dirty, key)
+
+	return s.writeJournal()
+}
+
+// writeJournal persists the dirty keys to the fallback, removing the journal
+// once they are all reconciled. Caller must hold the lock.
+func (s *Store) writeJournal() error {
+	if len(s.dirty) == 0 {
+		if err := s.fallback.Delete(s.journalKey); err != nil && !errors.Is(err, manager.ErrNotFound) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+		return nil
+	}
+
+	b, err := json.Marshal(s.dirty)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+	if err := s.fallback.Put(s.journalKey, b); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// setState changes the breaker state. Caller must hold the lock.
+func (s *Store) setState(state State) {
+	if s.state == state {
+		return
+	}
+	s.state = state
+	if s.onChange != nil {
+		s.onChange(state)
+	}
+}
+
+// now returns the current time of the clock.
+func (s *Store) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
@@ -0,0 +1,211 @@
+package failover
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/mchmarny/state/statetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// outageStore fails every call while down.
+type outageStore struct {
+	*statetest.MemStore
+	down bool
+}
+
+var errOffline = errors.New("network is unreachable")
+
+func (o *outageStore) Get(key string) ([]byte, error) {
+	if o.down {
+		return nil, errOffline
+	}
+	return o.MemStore.Get(key)
+}
+
+func (o *outageStore) Put(key string, value []byte) error {
+	if o.down {
+		return errOffline
+	}
+	return o.MemStore.Put(key, value)
+}
+
+func (o *outageStore) Delete(key string) error {
+	if o.down {
+		return errOffline
+	}
+	return o.MemStore.Delete(key)
+}
+
+func (o *outageStore) List(prefix string) ([]string, error) {
+	if o.down {
+		return nil, errOffline
+	}
+	return o.MemStore.List(prefix)
+}
+
+// TestNew ensures invalid settings are rejected.
+func TestNew(t *testing.T) {
+	_, err := New(nil, statetest.NewMemStore())
+	assert.Error(t, err)
+	_, err = New(statetest.NewMemStore(), statetest.NewMemStore(), WithThreshold(0))
+	assert.Error(t, err)
+	_, err = New(statetest.NewMemStore(), statetest.NewMemStore(), WithCooldown(-time.Second))
+	assert.Error(t, err)
+}
+
+// TestStoreFailover ensures the breaker trips, serves the fallback and reconciles on recovery.
+func TestStoreFailover(t *testing.T) {
+	primary := &outageStore{MemStore: statetest.NewMemStore()}
+	fallback := statetest.NewMemStore()
+	clock := statetest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var states []State
+	s, err := New(primary, fallback, WithThreshold(2), WithCooldown(time.Minute), WithClock(clock),
+		WithStateChange(func(st State) { states = append(states, st) }))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/a", []byte("1")))
+	assert.NoError(t, s.Put("/b", []byte("1")))
+	assert.Equal(t, []string{"/a", "/b"}, fallback.Keys())
+
+	primary.down = true
+	b, err := s.Get("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(b))
+	assert.Equal(t, StateClosed, s.State())
+
+	assert.NoError(t, s.Put("/a", []byte("2")))
+	assert.Equal(t, StateOpen, s.State())
+	assert.NoError(t, s.Delete("/b"))
+	assert.NoError(t, s.Put("/c", []byte("3")))
+
+	keys, err := s.List("/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a", "/c"}, keys)
+
+	primary.down = false
+	clock.Advance(30 * time.Second)
+	_, err = s.Get("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, StateOpen, s.State())
+	assert.Equal(t, []string{"/a", "/b"}, primary.Keys())
+
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, s.Put("/c", []byte("4")))
+	assert.Equal(t, StateClosed, s.State())
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateClosed}, states)
+
+	assert.Equal(t, []string{"/a", "/c"}, primary.Keys())
+	b, err = primary.Get("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(b))
+	b, err = primary.Get("/c")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", string(b))
+}
+
+// TestStoreHalfOpenFailure ensures a failed retry opens the breaker again.
+func TestStoreHalfOpenFailure(t *testing.T) {
+	primary := &outageStore{MemStore: statetest.NewMemStore(), down: true}
+	clock := statetest.NewFakeClock(time.Now())
+	s, err := New(primary, statetest.NewMemStore(), WithThreshold(1), WithClock(clock))
+	assert.NoError(t, err)
+
+	_, err = s.Get("/a")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+	assert.Equal(t, StateOpen, s.State())
+
+	clock.Advance(DefaultCooldown)
+	assert.NoError(t, s.Put("/a", []byte("1")))
+	assert.Equal(t, StateOpen, s.State())
+
+	primary.down = false
+	assert.NoError(t, s.Reconcile())
+	b, err := primary.Get("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(b))
+}
+
+// TestStoreManager ensures a manager keeps working through an outage.
+func TestStoreManager(t *testing.T) {
+	primary := &outageStore{MemStore: statetest.NewMemStore()}
+	s, err := New(primary, statetest.NewMemStore(), WithThreshold(1), WithCooldown(0))
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/device"),
+		manager.WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	primary.down = true
+	assert.NoError(t, sm.Save(&config{"offline"}))
+
+	primary.down = false
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "offline", loaded.Name)
+	assert.Equal(t, StateClosed, s.State())
+	assert.Equal(t, []string{"/device"}, primary.Keys())
+}
+
+// TestStoreRestart ensures the keys still to reconcile survive a restart during an outage.
+func TestStoreRestart(t *testing.T) {
+	primary := &outageStore{MemStore: statetest.NewMemStore(), down: true}
+	fallback := statetest.NewMemStore()
+	s, err := New(primary, fallback, WithThreshold(1), WithCooldown(0))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/a", []byte("1")))
+	assert.Equal(t, StateOpen, s.State())
+	assert.Equal(t, []string{DefaultJournalKey, "/a"}, fallback.Keys())
+
+	keys, err := s.List("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a"}, keys)
+
+	primary.down = false
+	restarted, err := New(primary, fallback, WithThreshold(1), WithCooldown(0))
+	assert.NoError(t, err)
+	assert.NoError(t, restarted.Reconcile())
+
+	b, err := primary.Get("/a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(b))
+	assert.Equal(t, []string{"/a"}, fallback.Keys())
+}
+
+// TestStoreReconcileConflict ensures changes of the primary made during the
+// outage are not overwritten.
+func TestStoreReconcileConflict(t *testing.T) {
+	primary := &outageStore{MemStore: statetest.NewMemStore()}
+	fallback := statetest.NewMemStore()
+	var conflicts []string
+	s, err := New(primary, fallback, WithThreshold(1), WithCooldown(0),
+		WithConflictHandler(func(key string, discarded []byte) { conflicts = append(conflicts, key+"="+string(discarded)) }))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/a", []byte("1")))
+	assert.NoError(t, s.Put("/b", []byte("1")))
+
+	primary.down = true
+	assert.NoError(t, s.Put("/a", []byte("offline")))
+	assert.NoError(t, s.Put("/b", []byte("offline")))
+
+	// another writer updates the primary meanwhile
+	primary.down = false
+	assert.NoError(t, primary.Put("/a", []byte("newer")))
+
+	assert.NoError(t, s.Reconcile())
+	assert.Equal(t, []string{"/a=offline"}, conflicts)
+
+	for key, want := range map[string]string{"/a": "newer", "/b": "offline"} {
+		b, err := primary.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(b), key)
+		b, err = fallback.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(b), key)
+	}
+}
@@ -0,0 +1,375 @@
+// Package firestore persists state as documents of a Google Cloud Firestore
+// collection over its REST API, so Firebase based apps keep server-side state
+// next to their data.
+//
+// Read-modify-write cycles are guarded by update time preconditions rather
+// than transactions. Every state is a single document, so a commit
+// conditional on the update time of the last read applies exactly when a
+// transaction reading and writing that document would commit, without
+// holding the locks of a transaction across the round trips of the manager.
+package firestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultEndpoint is the address of the Firestore API
+	DefaultEndpoint = "https://firestore.googleapis.com"
+
+	// DefaultDatabase is the id of the default database of a project
+	DefaultDatabase = "(default)"
+
+	// listPageSize is the number of documents listed per request
+	listPageSize = 300
+)
+
+// Store is a manager.Store keeping every state file, e.g. each named state,
+// as a document of the collection. Writes are committed with the update time
+// of the document last read by the store as precondition, so read-modify-write
+// cycles such as manager Update fail with manager.ErrConflict when the
+// document was changed in between.
+type Store struct {
+	client     *http.Client
+	endpoint   string
+	database   string
+	collection string
+	token      string
+
+	mutex       sync.Mutex
+	updateTimes map[string]string
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Renamer = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithHTTPClient sets the client used to reach Firestore, e.g. one authorized
+// with the application default credentials, http.DefaultClient by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) {
+		s.client = c
+	}
+}
+
+// WithToken sets a static OAuth 2.0 access token sent with every request.
+func WithToken(token string) Option {
+	return func(s *Store) {
+		s.token = token
+	}
+}
+
+// WithDatabase sets the id of the database, DefaultDatabase by default.
+func WithDatabase(id string) Option {
+	return func(s *Store) {
+		s.database = id
+	}
+}
+
+// WithEndpoint sets the address of the API, the emulator at
+// FIRESTORE_EMULATOR_HOST or DefaultEndpoint by default.
+func WithEndpoint(endpoint string) Option {
+	return func(s *Store) {
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// New creates a store on the collection of the project.
+func New(project, collection string, options ...Option) (*Store, error) {
+	if project == "" || collection == "" {
+		return nil, errors.New("project and collection must not be empty")
+	}
+
+	s := &Store{
+		client:      http.DefaultClient,
+		endpoint:    DefaultEndpoint,
+		database:    DefaultDatabase,
+		updateTimes: make(map[string]string),
+	}
+	if host := os.Getenv("FIRESTORE_EMULATOR_HOST"); host != "" {
+		s.endpoint = "http://" + host
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	s.database = fmt.Sprintf("projects/%s/databases/%s", project, s.database)
+	s.collection = collection
+
+	return s, nil
+}
+
+// value is a Firestore field value.
+type value struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BytesValue  *[]byte `json:"bytesValue,omitempty"`
+}
+
+// document is a Firestore document.
+type document struct {
+	Name       string           `json:"name,omitempty"`
+	Fields     map[string]value `json:"fields"`
+	UpdateTime string           `json:"updateTime,omitempty"`
+}
+
+// precondition is a condition on the document for a write to apply.
+type precondition struct {
+	Exists     *bool  `json:"exists,omitempty"`
+	UpdateTime string `json:"updateTime,omitempty"`
+}
+
+// write is a single write of a commit.
+type write struct {
+	Update          *document     `json:"update,omitempty"`
+	Delete          string        `json:"delete,omitempty"`
+	CurrentDocument *precondition `json:"currentDocument,omitempty"`
+}
+
+// Get returns the payload of the document.
+func (s *Store) Get(key string) ([]byte, error) {
+	var doc document
+	if err := s.do(http.MethodGet, s.path(key), nil, &doc); err != nil {
+		return nil, err
+	}
+
+	s.setUpdateTime(key, doc.UpdateTime)
+	if p := doc.Fields["payload"].BytesValue; p != nil {
+		return *p, nil
+	}
+	return []byte{}, nil
+}
+
+// Put writes the document, on the condition that it was not changed since it was last read.
+func (s *Store) Put(key string, payload []byte) error {
+	times, err := s.commit([]string{key}, s.update(key, payload))
+	if err != nil {
+		return err
+	}
+
+	s.setUpdateTime(key, times[0])
+	return nil
+}
+
+// Delete removes the document, on the condition that it was not changed since it was last read.
+func (s *Store) Delete(key string) error {
+	if _, err := s.commit(nil, s.delete(key)); err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return err
+	}
+
+	s.setUpdateTime(key, "")
+	return nil
+}
+
+// Rename moves the document to the new key in a single atomic commit.
+func (s *Store) Rename(oldKey, newKey string) error {
+	payload, err := s.Get(oldKey)
+	if err != nil {
+		return err
+	}
+
+	w := s.update(newKey, payload)
+	w.CurrentDocument = nil
+	times, err := s.commit([]string{newKey}, w, s.delete(oldKey))
+	if err != nil {
+		return err
+	}
+
+	s.setUpdateTime(oldKey, "")
+	s.setUpdateTime(newKey, times[0])
+	return nil
+}
+
+// List returns the keys of the documents with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	query := url.Values{"pageSize": {fmt.Sprint(listPageSize)}, "mask.fieldPaths": {"key"}}
+
+	for {
+		var page struct {
+			Documents     []document `json:"documents"`
+			NextPageToken string     `json:"nextPageToken"`
+		}
+		p := "/v1/" + s.database + "/documents/" + url.PathEscape(s.collection) + "?" + query.Encode()
+		if err := s.do(http.MethodGet, p, nil, &page); err != nil {
+			if errors.Is(err, manager.ErrNotFound) {
+				return keys, nil
+			}
+			return nil, err
+		}
+
+		for _, d := range page.Documents {
+			if k := d.Fields["key"].StringValue; k != nil && strings.HasPrefix(*k, prefix) {
+				keys = append(keys, *k)
+			}
+		}
+
+		if page.NextPageToken == "" {
+			return keys, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// update returns the write of the document, conditional on its last read update time.
+func (s *Store) update(key string, payload []byte) write {
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	w := write{Update: &document{
+		Name:   s.name(key),
+		Fields: map[string]value{"key": {StringValue: &key}, "payload": {BytesValue: &payload}},
+	}}
+	if t := s.updateTime(key); t != "" {
+		w.CurrentDocument = &precondition{UpdateTime: t}
+	}
+	return w
+}
+
+// delete returns the delete of the document, conditional on its last read update time.
+func (s *Store) delete(key string) write {
+	w := write{Delete: s.name(key)}
+	if t := s.updateTime(key); t != "" {
+		w.CurrentDocument = &precondition{UpdateTime: t}
+	}
+	return w
+}
+
+// commit applies the writes atomically and returns the update times of the
+// first len(keys) writes. Failed preconditions surface as manager.ErrConflict.
+func (s *Store) commit(keys []string, writes ...write) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{"writes": writes})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode commit: %w", err)
+	}
+
+	var resp struct {
+		WriteResults []struct {
+			UpdateTime string `json:"updateTime"`
+		} `json:"writeResults"`
+	}
+	err = s.do(http.MethodPost, "/v1/"+s.database+"/documents:commit", body, &resp)
+	if err != nil {
+		if errors.Is(err, manager.ErrNotFound) && writes[0].CurrentDocument != nil && writes[0].Update != nil {
+			return nil, fmt.Errorf("%w: %w", manager.ErrConflict, err)
+		}
+		return nil, err
+	}
+	if len(resp.WriteResults) < len(keys) {
+		return nil, errors.New("firestore returned no write results")
+	}
+
+	times := make([]string, len(keys))
+	for i := range keys {
+		times[i] = resp.WriteResults[i].UpdateTime
+	}
+	return times, nil
+}
+
+// name returns the resource name of the document of the key, as used in
+// request bodies.
+func (s *Store) name(key string) string {
+	return s.database + "/documents/" + s.collection + "/" + docID(key)
+}
+
+// path returns the URL path of the document of the key.
+func (s *Store) path(key string) string {
+	return "/v1/" + s.database + "/documents/" + url.PathEscape(s.collection) + "/" + url.PathEscape(docID(key))
+}
+
+// docID maps the key to a valid document id, which must not contain slashes,
+// by percent-encoding them. The id is escaped again in URLs.
+func docID(key string) string {
+	return url.PathEscape(strings.TrimPrefix(key, "/"))
+}
+
+// updateTime returns the update time of the document last seen.
+func (s *Store) updateTime(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.updateTimes[key]
+}
+
+// setUpdateTime records the update time of the document.
+func (s *Store) setUpdateTime(key, t string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if t == "" {
+		delete(s.updateTimes, key)
+		return
+	}
+	s.updateTimes[key] = t
+}
+
+// apiError is the error response of the API.
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// do sends the request and decodes the JSON response into out when set.
+func (s *Store) do(method, p string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, s.endpoint+p, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach firestore: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read firestore response: %w", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var e apiError
+		_ = json.Unmarshal(b, &e)
+
+		switch e.Error.Status {
+		case "NOT_FOUND":
+			return fmt.Errorf("%w: %s", manager.ErrNotFound, e.Error.Message)
+		case "FAILED_PRECONDITION", "ALREADY_EXISTS", "ABORTED":
+			return fmt.Errorf("%w: %s", manager.ErrConflict, e.Error.Message)
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", manager.ErrNotFound, p)
+		}
+		return fmt.Errorf("firestore returned %s: %s", resp.Status, e.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to decode firestore response: %w", err)
+	}
+	return nil
+}
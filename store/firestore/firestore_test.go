@@ -0,0 +1,199 @@
+package firestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFirestore serves the subset of the Firestore REST API used by the store.
+type fakeFirestore struct {
+	mutex  sync.Mutex
+	clock  int
+	docs   map[string]document
+	tokens []string
+}
+
+func (f *fakeFirestore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.tokens = append(f.tokens, r.Header.Get("Authorization"))
+	p := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	switch {
+	case strings.HasSuffix(p, "/documents:commit"):
+		var req struct{ Writes []write }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		for _, wr := range req.Writes {
+			name := wr.Delete
+			if wr.Update != nil {
+				name = wr.Update.Name
+			}
+			doc, ok := f.docs[name]
+			if c := wr.CurrentDocument; c != nil && c.UpdateTime != "" {
+				if !ok {
+					f.fail(w, http.StatusNotFound, "NOT_FOUND")
+					return
+				}
+				if doc.UpdateTime != c.UpdateTime {
+					f.fail(w, http.StatusBadRequest, "FAILED_PRECONDITION")
+					return
+				}
+			}
+		}
+		f.clock++
+		now := fmt.Sprintf("2024-01-01T00:00:%02dZ", f.clock)
+		results := make([]map[string]string, 0)
+		for _, wr := range req.Writes {
+			if wr.Update != nil {
+				d := *wr.Update
+				d.UpdateTime = now
+				f.docs[d.Name] = d
+				results = append(results, map[string]string{"updateTime": now})
+				continue
+			}
+			delete(f.docs, wr.Delete)
+			results = append(results, map[string]string{})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"writeResults": results})
+	case r.Method == http.MethodGet && strings.Count(p, "/") == 5:
+		docs := make([]document, 0)
+		for name, d := range f.docs {
+			if strings.HasPrefix(name, p+"/") {
+				docs = append(docs, document{Name: name, Fields: map[string]value{"key": d.Fields["key"]}})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"documents": docs})
+	case r.Method == http.MethodGet:
+		d, ok := f.docs[p]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NOT_FOUND")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(d)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeFirestore) fail(w http.ResponseWriter, code int, status string) {
+	w.WriteHeader(code)
+	_, _ = fmt.Fprintf(w, `{"error":{"code":%d,"message":"%s","status":"%s"}}`, code, strings.ToLower(status), status)
+}
+
+func newTestStore(t *testing.T) (*Store, *fakeFirestore) {
+	f := &fakeFirestore{docs: make(map[string]document)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := New("demo", "states", WithEndpoint(srv.URL), WithToken("secret"))
+	assert.NoError(t, err)
+	return s, f
+}
+
+// TestNew ensures the project, collection and emulator settings are applied.
+func TestNew(t *testing.T) {
+	_, err := New("", "states")
+	assert.Error(t, err)
+
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	s, err := New("demo", "states", WithDatabase("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", s.endpoint)
+	assert.Equal(t, "projects/demo/databases/other", s.database)
+}
+
+// TestStore ensures documents round-trip, list and rename.
+func TestStore(t *testing.T) {
+	s, f := newTestStore(t)
+
+	_, err := s.Get("/app/one.json")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+
+	assert.NoError(t, s.Put("/app/one.json", []byte("one")))
+	assert.NoError(t, s.Put("/app/two.json", []byte("two")))
+	assert.Contains(t, f.docs, "projects/demo/databases/(default)/documents/states/app%2Fone.json")
+	assert.Equal(t, "Bearer secret", f.tokens[0])
+
+	b, err := s.Get("/app/one.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "one", string(b))
+
+	keys, err := s.List("/app/o")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/one.json"}, keys)
+
+	assert.NoError(t, s.Rename("/app/one.json", "/app/three.json"))
+	_, err = s.Get("/app/one.json")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+	b, err = s.Get("/app/three.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "one", string(b))
+
+	assert.NoError(t, s.Delete("/app/three.json"))
+	assert.NoError(t, s.Delete("/app/missing.json"))
+	keys, err = s.List("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/two.json"}, keys)
+}
+
+// TestStoreConflict ensures writes based on a stale update time fail with ErrConflict.
+func TestStoreConflict(t *testing.T) {
+	s, f := newTestStore(t)
+	other, err := New("demo", "states", WithEndpoint(s.endpoint))
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state.json", []byte("v1")))
+	_, err = other.Get("/state.json")
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state.json", []byte("v2")))
+	assert.ErrorIs(t, other.Put("/state.json", []byte("stale")), manager.ErrConflict)
+	assert.ErrorIs(t, other.Delete("/state.json"), manager.ErrConflict)
+
+	_, err = other.Get("/state.json")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Put("/state.json", []byte("v3")))
+
+	delete(f.docs, s.name("/state.json"))
+	assert.ErrorIs(t, other.Put("/state.json", []byte("v4")), manager.ErrConflict)
+}
+
+// TestStoreUpdate ensures manager Update round-trips and conflicts with concurrent writers.
+func TestStoreUpdate(t *testing.T) {
+	s, _ := newTestStore(t)
+	other, err := New("demo", "states", WithEndpoint(s.endpoint))
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	data := &config{"alice"}
+	assert.NoError(t, sm.Save(data))
+	assert.NoError(t, sm.Update(data, func() error {
+		data.Name = "bob"
+		return nil
+	}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+
+	assert.ErrorIs(t, sm.Update(data, func() error {
+		_, err := other.Get("/app/state")
+		assert.NoError(t, err)
+		assert.NoError(t, other.Put("/app/state", []byte(`{"Name": "carol"}`)))
+		data.Name = "dave"
+		return nil
+	}), manager.ErrConflict)
+}
@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// collection is the Collection over a collection of the MongoDB driver.
+type collection struct {
+	c *mongo.Collection
+}
+
+var _ Collection = (*collection)(nil)
+
+// NewCollection returns the Collection over the MongoDB collection.
+func NewCollection(c *mongo.Collection) Collection {
+	return &collection{c: c}
+}
+
+// Find returns the document with the id.
+func (c *collection) Find(ctx context.Context, id string) (*Document, error) {
+	doc := &Document{}
+	if err := c.c.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(doc); err != nil {
+		return nil, mapError(id, err)
+	}
+	return doc, nil
+}
+
+// Upsert writes the document whatever its version.
+func (c *collection) Upsert(ctx context.Context, doc *Document) (int64, error) {
+	return c.findAndUpdate(ctx, bson.D{{Key: "_id", Value: doc.ID}}, doc, true)
+}
+
+// Update writes the document when it is at the version. With version 0 the
+// document is inserted, failing with a duplicate key when it exists.
+func (c *collection) Update(ctx context.Context, doc *Document, version int64) (int64, error) {
+	filter := bson.D{{Key: "_id", Value: doc.ID}, {Key: "version", Value: version}}
+	return c.findAndUpdate(ctx, filter, doc, version == 0)
+}
+
+// Delete removes the document, when it is at the version unless 0.
+func (c *collection) Delete(ctx context.Context, id string, version int64) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	if version != 0 {
+		filter = append(filter, bson.E{Key: "version", Value: version})
+	}
+
+	res, err := c.c.DeleteOne(ctx, filter)
+	if err != nil {
+		return mapError(id, err)
+	}
+	if res.DeletedCount > 0 || version == 0 {
+		return nil
+	}
+
+	// the document is gone or at another version
+	n, err := c.c.CountDocuments(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return mapError(id, err)
+	}
+	if n > 0 {
+		return fmt.Errorf("%w: %s changed since version %d", manager.ErrConflict, id, version)
+	}
+	return nil
+}
+
+// IDs returns the sorted ids starting with the prefix.
+func (c *collection) IDs(ctx context.Context, prefix string) ([]string, error) {
+	filter := bson.D{}
+	if prefix != "" {
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(prefix)}}}}
+	}
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "_id", Value: 1}}).
+		SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	cur, err := c.c.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
+// findAndUpdate applies the update of the document to the document matching
+// the filter and returns its new version.
+func (c *collection) findAndUpdate(ctx context.Context, filter bson.D, doc *Document, upsert bool) (int64, error) {
+	opts := options.FindOneAndUpdate().
+		SetUpsert(upsert).
+		SetReturnDocument(options.After).
+		SetProjection(bson.D{{Key: "version", Value: 1}})
+
+	var updated struct {
+		Version int64 `bson:"version"`
+	}
+	if err := c.c.FindOneAndUpdate(ctx, filter, update(doc, time.Now()), opts).Decode(&updated); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) || mongo.IsDuplicateKeyError(err) {
+			return 0, fmt.Errorf("%w: %s: %w", manager.ErrConflict, doc.ID, err)
+		}
+		return 0, mapError(doc.ID, err)
+	}
+	return updated.Version, nil
+}
+
+// update returns the update setting the state or payload of the document,
+// clearing the other, and incrementing its version.
+func update(doc *Document, now time.Time) bson.D {
+	set := bson.D{{Key: "updated_at", Value: now}}
+	var unset string
+	if doc.State != nil {
+		set = append(set, bson.E{Key: "state", Value: doc.State})
+		unset = "payload"
+	} else {
+		set = append(set, bson.E{Key: "payload", Value: doc.Payload})
+		unset = "state"
+	}
+
+	return bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{{Key: unset, Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: int64(1)}}},
+	}
+}
+
+// mapError maps missing documents to manager.ErrNotFound and duplicate keys
+// to manager.ErrConflict.
+func mapError(id string, err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, id)
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %s: %w", manager.ErrConflict, id, err)
+	}
+	return fmt.Errorf("failed to access %s: %w", id, err)
+}
@@ -0,0 +1,245 @@
+// Package mongo persists state as documents of a MongoDB collection.
+//
+// The collection is accessed through the Collection interface. NewCollection
+// implements it over a *mongo.Collection of the MongoDB driver, writing with
+// FindOneAndUpdate of {"$set": {"payload" or "state", "updated_at"}, "$inc":
+// {"version": 1}}, filtered by {"_id", "version"} for conditional writes.
+//
+// With WithSerializationType(manager.BSON) state is stored as the embedded
+// document "state", so it can be queried like any other document, e.g.
+// {"state.name": "alice"}. Otherwise it is stored as the binary "payload".
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	// DefaultTimeout limits every operation on the collection
+	DefaultTimeout = 10 * time.Second
+)
+
+// Document is a state file stored in the collection. Either the State or
+// the Payload is set.
+type Document struct {
+	ID        string    `bson:"_id"`
+	Payload   []byte    `bson:"payload,omitempty"`
+	State     bson.Raw  `bson:"state,omitempty"`
+	Version   int64     `bson:"version"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// content returns the state file kept in the document.
+func (d *Document) content() []byte {
+	if len(d.State) > 0 {
+		return d.State
+	}
+	return d.Payload
+}
+
+// Collection is the subset of a MongoDB collection used by the store.
+type Collection interface {
+	// Find returns the document, failing with manager.ErrNotFound when missing.
+	Find(ctx context.Context, id string) (*Document, error)
+	// Upsert sets the payload and state of the document, clearing the one
+	// not set, increments the version and returns it.
+	Upsert(ctx context.Context, doc *Document) (int64, error)
+	// Update sets the payload and state like Upsert only when the document
+	// is at the version, 0 inserting it, and returns the new version.
+	// It fails with manager.ErrConflict otherwise.
+	Update(ctx context.Context, doc *Document, version int64) (int64, error)
+	// Delete removes the document, only when it is at the version unless 0,
+	// failing with manager.ErrConflict otherwise.
+	Delete(ctx context.Context, id string, version int64) error
+	// IDs returns the ids of the documents starting with the prefix.
+	IDs(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Store is a manager.Store keeping every state file as a document.
+type Store struct {
+	collection   Collection
+	timeout      time.Duration
+	versionCheck bool
+	embed        bool
+
+	mutex    sync.Mutex
+	versions map[string]int64
+}
+
+var _ manager.Store = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithTimeout limits every operation on the collection, DefaultTimeout by default.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Store) {
+		s.timeout = d
+	}
+}
+
+// WithVersionCheck makes writes conditional on the version of the document
+// last read or written by the store. A write of a document changed by someone
+// else since then, or of an existing document never read, fails with manager.ErrConflict.
+func WithVersionCheck() Option {
+	return func(s *Store) {
+		s.versionCheck = true
+	}
+}
+
+// WithSerializationType sets the serialization type of the state written
+// through the store. With manager.BSON values are stored as the embedded
+// document, so they must not be compressed, encrypted or signed.
+func WithSerializationType(st manager.SerializationType) Option {
+	return func(s *Store) {
+		s.embed = st == manager.BSON
+	}
+}
+
+// New creates a store on the collection.
+func New(collection Collection, options ...Option) (*Store, error) {
+	if collection == nil {
+		return nil, errors.New("collection must not be nil")
+	}
+
+	s := &Store{
+		collection: collection,
+		timeout:    DefaultTimeout,
+		versions:   make(map[string]int64),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// Get returns the payload of the document.
+func (s *Store) Get(key string) ([]byte, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	doc, err := s.collection.Find(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setVersion(key, doc.Version)
+	return doc.content(), nil
+}
+
+// Put upserts the document, conditional on its version with WithVersionCheck.
+// With the BSON serialization type the value is stored as the embedded state.
+func (s *Store) Put(key string, value []byte) error {
+	doc := &Document{ID: key}
+	if s.embed {
+		raw := bson.Raw(value)
+		if err := raw.Validate(); err != nil {
+			return fmt.Errorf("failed to embed %s as BSON document: %w", key, err)
+		}
+		doc.State = raw
+	} else {
+		doc.Payload = value
+		if doc.Payload == nil {
+			doc.Payload = []byte{}
+		}
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	var version int64
+	var err error
+	if s.versionCheck {
+		version, err = s.collection.Update(ctx, doc, s.version(key))
+	} else {
+		version, err = s.collection.Upsert(ctx, doc)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.setVersion(key, version)
+	return nil
+}
+
+// Delete removes the document, conditional on its version with WithVersionCheck.
+func (s *Store) Delete(key string) error {
+	var version int64
+	if s.versionCheck {
+		if version = s.version(key); version == 0 {
+			if _, err := s.Get(key); err != nil {
+				if errors.Is(err, manager.ErrNotFound) {
+					return nil
+				}
+				return err
+			}
+			version = s.version(key)
+		}
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	if err := s.collection.Delete(ctx, key, version); err != nil {
+		return err
+	}
+
+	s.setVersion(key, 0)
+	return nil
+}
+
+// List returns the ids of the documents with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	ids, err := s.collection.IDs(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix) {
+			keys = append(keys, id)
+		}
+	}
+	return keys, nil
+}
+
+// context returns the context of a single operation.
+func (s *Store) context() (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+// version returns the version of the document last seen.
+func (s *Store) version(key string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.versions[key]
+}
+
+// setVersion records the version of the document.
+func (s *Store) setVersion(key string, version int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if version == 0 {
+		delete(s.versions, key)
+		return
+	}
+	s.versions[key] = version
+}
@@ -0,0 +1,218 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// memCollection is an in-memory Collection.
+type memCollection struct {
+	mutex sync.Mutex
+	docs  map[string]Document
+}
+
+func (c *memCollection) Find(_ context.Context, id string) (*Document, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	d, ok := c.docs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, id)
+	}
+	return &d, nil
+}
+
+func (c *memCollection) Upsert(_ context.Context, doc *Document) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	d := *doc
+	d.Version = c.docs[doc.ID].Version + 1
+	d.UpdatedAt = time.Now()
+	c.docs[doc.ID] = d
+	return d.Version, nil
+}
+
+func (c *memCollection) Update(_ context.Context, doc *Document, version int64) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.docs[doc.ID].Version != version {
+		return 0, fmt.Errorf("%w: %s", manager.ErrConflict, doc.ID)
+	}
+	d := *doc
+	d.Version = version + 1
+	d.UpdatedAt = time.Now()
+	c.docs[doc.ID] = d
+	return d.Version, nil
+}
+
+func (c *memCollection) Delete(_ context.Context, id string, version int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if d, ok := c.docs[id]; ok && version != 0 && d.Version != version {
+		return fmt.Errorf("%w: %s", manager.ErrConflict, id)
+	}
+	delete(c.docs, id)
+	return nil
+}
+
+func (c *memCollection) IDs(_ context.Context, prefix string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ids := make([]string, 0)
+	for id := range c.docs {
+		if strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// TestStore ensures state round-trips through the collection.
+func TestStore(t *testing.T) {
+	collection := &memCollection{docs: make(map[string]Document)}
+	s, err := New(collection, WithTimeout(time.Second))
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.ErrorIs(t, sm.Load(&config{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"bob"}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+	assert.Equal(t, `{
+  "Name": "bob"
+}`, string(collection.docs["/app/state"].Payload))
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/state.1"}, keys)
+
+	assert.NoError(t, sm.Delete())
+	assert.ErrorIs(t, sm.Load(loaded), manager.ErrNotFound)
+}
+
+// TestStoreBSON ensures BSON state is stored as an embedded document.
+func TestStoreBSON(t *testing.T) {
+	collection := &memCollection{docs: make(map[string]Document)}
+	s, err := New(collection, WithSerializationType(manager.BSON))
+	assert.NoError(t, err)
+	assert.Error(t, s.Put("/other", []byte("not bson")))
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.BSON))
+	assert.NoError(t, err)
+
+	type config struct {
+		Name  string `bson:"name"`
+		Count int    `bson:"count"`
+	}
+	assert.NoError(t, sm.Save(&config{Name: "alice", Count: 2}))
+
+	doc := collection.docs["/app/state"]
+	assert.Empty(t, doc.Payload)
+	assert.Equal(t, "alice", doc.State.Lookup("name").StringValue())
+
+	// the document is embedded in the stored document
+	b, err := bson.Marshal(doc)
+	assert.NoError(t, err)
+	var stored struct {
+		State struct {
+			Name  string `bson:"name"`
+			Count int    `bson:"count"`
+		} `bson:"state"`
+	}
+	assert.NoError(t, bson.Unmarshal(b, &stored))
+	assert.Equal(t, "alice", stored.State.Name)
+	assert.Equal(t, 2, stored.State.Count)
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, &config{Name: "alice", Count: 2}, loaded)
+}
+
+// TestStorePayload ensures state is stored as payload without the BSON serialization type.
+func TestStorePayload(t *testing.T) {
+	collection := &memCollection{docs: make(map[string]Document)}
+	s, err := New(collection, WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+
+	b, err := bson.Marshal(bson.D{{Key: "name", Value: "alice"}})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Put("/state", b))
+	assert.Empty(t, collection.docs["/state"].State)
+	assert.Equal(t, b, collection.docs["/state"].Payload)
+}
+
+// TestStoreVersionCheck ensures writes based on a stale version fail with ErrConflict.
+func TestStoreVersionCheck(t *testing.T) {
+	collection := &memCollection{docs: make(map[string]Document)}
+	s, err := New(collection, WithVersionCheck())
+	assert.NoError(t, err)
+	other, err := New(collection, WithVersionCheck())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.ErrorIs(t, other.Put("/state", []byte("b")), manager.ErrConflict)
+
+	_, err = other.Get("/state")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Put("/state", []byte("b")))
+
+	assert.ErrorIs(t, s.Put("/state", []byte("c")), manager.ErrConflict)
+	assert.ErrorIs(t, s.Delete("/state"), manager.ErrConflict)
+	assert.Equal(t, int64(2), collection.docs["/state"].Version)
+
+	assert.NoError(t, other.Delete("/state"))
+	assert.Empty(t, collection.docs)
+
+	_, err = New(nil)
+	assert.Error(t, err)
+}
+
+// TestUpdate ensures writes set either the state or the payload and increment the version.
+func TestUpdate(t *testing.T) {
+	now := time.Now()
+	state := bson.Raw{5, 0, 0, 0, 0}
+
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}, {Key: "state", Value: state}}},
+		{Key: "$unset", Value: bson.D{{Key: "payload", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: int64(1)}}},
+	}, update(&Document{ID: "/state", State: state}, now))
+
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}, {Key: "payload", Value: []byte("a")}}},
+		{Key: "$unset", Value: bson.D{{Key: "state", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: int64(1)}}},
+	}, update(&Document{ID: "/state", Payload: []byte("a")}, now))
+}
+
+// TestMapError ensures driver errors map to the manager errors.
+func TestMapError(t *testing.T) {
+	assert.ErrorIs(t, mapError("/state", mongo.ErrNoDocuments), manager.ErrNotFound)
+	dup := mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "duplicate key"}}}
+	assert.ErrorIs(t, mapError("/state", dup), manager.ErrConflict)
+	assert.NotErrorIs(t, mapError("/state", errors.New("down")), manager.ErrConflict)
+}
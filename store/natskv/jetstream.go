@@ -0,0 +1,125 @@
+package natskv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// keyValue is the Bucket over a JetStream key-value bucket.
+type keyValue struct {
+	kv jetstream.KeyValue
+}
+
+var _ Bucket = (*keyValue)(nil)
+
+// NewBucket returns the Bucket over the JetStream key-value bucket, e.g. one
+// returned by jetstream.JetStream CreateOrUpdateKeyValue. Requests use the
+// default timeout of the JetStream context.
+func NewBucket(kv jetstream.KeyValue) Bucket {
+	return &keyValue{kv: kv}
+}
+
+// Get returns the latest entry of the key.
+func (b *keyValue) Get(key string) (Entry, error) {
+	e, err := b.kv.Get(context.Background(), key)
+	if err != nil {
+		return Entry{}, mapError(key, err)
+	}
+	return entry(e), nil
+}
+
+// Put sets the value of the key.
+func (b *keyValue) Put(key string, value []byte) (uint64, error) {
+	revision, err := b.kv.Put(context.Background(), key, value)
+	if err != nil {
+		return 0, mapError(key, err)
+	}
+	return revision, nil
+}
+
+// Update sets the value of the key when last is its current revision. With
+// last 0 the key is created, also over a delete marker.
+func (b *keyValue) Update(key string, value []byte, last uint64) (uint64, error) {
+	var revision uint64
+	var err error
+
+	if last == 0 {
+		revision, err = b.kv.Create(context.Background(), key, value)
+	} else {
+		revision, err = b.kv.Update(context.Background(), key, value, last)
+	}
+	if err != nil {
+		return 0, mapError(key, err)
+	}
+	return revision, nil
+}
+
+// Delete places a delete marker for the key.
+func (b *keyValue) Delete(key string) error {
+	if err := b.kv.Delete(context.Background(), key); err != nil {
+		return mapError(key, err)
+	}
+	return nil
+}
+
+// Keys returns the keys which are not deleted.
+func (b *keyValue) Keys() ([]string, error) {
+	keys, err := b.kv.Keys(context.Background())
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Watch calls fn with every update of the key made after the watch started
+// until the context is done.
+func (b *keyValue) Watch(ctx context.Context, key string, fn func(Entry)) error {
+	w, err := b.kv.Watch(ctx, key, jetstream.UpdatesOnly())
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", key, err)
+	}
+	defer func() { _ = w.Stop() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-w.Updates():
+			if !ok {
+				return ctx.Err()
+			}
+			if e != nil {
+				fn(entry(e))
+			}
+		}
+	}
+}
+
+// entry converts the JetStream entry.
+func entry(e jetstream.KeyValueEntry) Entry {
+	return Entry{
+		Key:      e.Key(),
+		Value:    e.Value(),
+		Revision: e.Revision(),
+		Deleted:  e.Operation() != jetstream.KeyValuePut,
+	}
+}
+
+// mapError maps missing keys to manager.ErrNotFound and a wrong last
+// sequence to manager.ErrConflict.
+func mapError(key string, err error) error {
+	switch {
+	case errors.Is(err, jetstream.ErrKeyNotFound), errors.Is(err, jetstream.ErrKeyDeleted):
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	case errors.Is(err, jetstream.ErrKeyExists):
+		return fmt.Errorf("%w: %w", manager.ErrConflict, err)
+	}
+	return fmt.Errorf("failed to access %s: %w", key, err)
+}
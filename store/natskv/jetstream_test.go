@@ -0,0 +1,136 @@
+package natskv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+// newJetStreamBucket returns a bucket of an embedded JetStream server.
+func newJetStreamBucket(t *testing.T) Bucket {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	assert.NoError(t, err)
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+
+	nc, err := nats.Connect(srv.ClientURL())
+	assert.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	js, err := jetstream.New(nc)
+	assert.NoError(t, err)
+	kv, err := js.CreateOrUpdateKeyValue(context.Background(), jetstream.KeyValueConfig{Bucket: "state"})
+	assert.NoError(t, err)
+	return NewBucket(kv)
+}
+
+// TestJetStreamBucket ensures the adapter maps JetStream errors and revisions.
+func TestJetStreamBucket(t *testing.T) {
+	b := newJetStreamBucket(t)
+
+	_, err := b.Get("missing")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+	keys, err := b.Keys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	r1, err := b.Update("state", []byte("a"), 0)
+	assert.NoError(t, err)
+	_, err = b.Update("state", []byte("b"), 0)
+	assert.ErrorIs(t, err, manager.ErrConflict)
+	r2, err := b.Update("state", []byte("b"), r1)
+	assert.NoError(t, err)
+	_, err = b.Update("state", []byte("c"), r1)
+	assert.ErrorIs(t, err, manager.ErrConflict)
+
+	e, err := b.Get("state")
+	assert.NoError(t, err)
+	assert.Equal(t, Entry{Key: "state", Value: []byte("b"), Revision: r2}, e)
+
+	assert.NoError(t, b.Delete("state"))
+	_, err = b.Get("state")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+	keys, err = b.Keys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = b.Update("state", []byte("d"), 0)
+	assert.NoError(t, err)
+}
+
+// TestJetStreamStore ensures state round-trips and conflicts through the adapter.
+func TestJetStreamStore(t *testing.T) {
+	b := newJetStreamBucket(t)
+	s, err := New(b, WithRevisionCheck())
+	assert.NoError(t, err)
+	other, err := New(b, WithRevisionCheck())
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/my state"),
+		manager.WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.ErrorIs(t, sm.Load(&config{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&config{"alice"}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/my state"}, keys)
+
+	assert.ErrorIs(t, other.Put("/app/my state", []byte(`{"Name":"bob"}`)), manager.ErrConflict)
+}
+
+// TestJetStreamWatch ensures watchers see updates and deletes of the key.
+func TestJetStreamWatch(t *testing.T) {
+	b := newJetStreamBucket(t)
+	s, err := New(b)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seen := make(chan []byte, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Watch(ctx, "/state", func(value []byte) { seen <- value })
+	}()
+
+	// the watch starts asynchronously, so write until the first update arrives
+	var value []byte
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, s.Put("/state", []byte("a")))
+		select {
+		case value = <-seen:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, "a", string(value))
+
+	// skip the updates of the remaining writes
+	assert.NoError(t, s.Delete("/state"))
+	for value = range seen {
+		if value == nil {
+			break
+		}
+		assert.Equal(t, "a", string(value))
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
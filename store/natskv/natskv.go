@@ -0,0 +1,209 @@
+// Package natskv persists state in a NATS JetStream key-value bucket and
+// notifies watchers of every change, so state saved by one process reaches
+// all subscribers.
+//
+// The bucket is accessed through the Bucket interface. NewBucket implements
+// it over a jetstream.KeyValue of the NATS client, mapping
+// jetstream.ErrKeyNotFound to manager.ErrNotFound and a wrong last sequence
+// to manager.ErrConflict.
+package natskv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mchmarny/state/manager"
+)
+
+// Entry is a value of the bucket with its revision.
+type Entry struct {
+	Key      string
+	Value    []byte
+	Revision uint64
+	Deleted  bool
+}
+
+// Bucket is the subset of a JetStream key-value bucket used by the store.
+type Bucket interface {
+	// Get returns the latest entry, failing with manager.ErrNotFound when missing or deleted.
+	Get(key string) (Entry, error)
+	// Put sets the value and returns its revision.
+	Put(key string, value []byte) (uint64, error)
+	// Update sets the value only when last is its current revision, 0 creating
+	// it, and fails with manager.ErrConflict otherwise.
+	Update(key string, value []byte, last uint64) (uint64, error)
+	// Delete places a delete marker for the key.
+	Delete(key string) error
+	// Keys returns the keys which are not deleted.
+	Keys() ([]string, error)
+	// Watch calls fn with every update of the key until the context is done.
+	Watch(ctx context.Context, key string, fn func(Entry)) error
+}
+
+// Store is a manager.Store keeping every state file as a key of the bucket.
+type Store struct {
+	bucket        Bucket
+	revisionCheck bool
+
+	mutex     sync.Mutex
+	revisions map[string]uint64
+}
+
+var _ manager.Store = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithRevisionCheck makes writes conditional on the revision of the key last
+// read or written by the store. A write of a key changed by someone else
+// since then, or of an existing key never read, fails with manager.ErrConflict.
+func WithRevisionCheck() Option {
+	return func(s *Store) {
+		s.revisionCheck = true
+	}
+}
+
+// New creates a store on the bucket.
+func New(bucket Bucket, options ...Option) (*Store, error) {
+	if bucket == nil {
+		return nil, errors.New("bucket must not be nil")
+	}
+
+	s := &Store{
+		bucket:    bucket,
+		revisions: make(map[string]uint64),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// Get returns the value of the key.
+func (s *Store) Get(key string) ([]byte, error) {
+	e, err := s.bucket.Get(Key(key))
+	if err != nil {
+		return nil, err
+	}
+
+	s.setRevision(key, e.Revision)
+	return e.Value, nil
+}
+
+// Put sets the value of the key, conditional on its revision with WithRevisionCheck.
+func (s *Store) Put(key string, value []byte) error {
+	var revision uint64
+	var err error
+
+	if s.revisionCheck {
+		revision, err = s.bucket.Update(Key(key), value, s.revision(key))
+	} else {
+		revision, err = s.bucket.Put(Key(key), value)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.setRevision(key, revision)
+	return nil
+}
+
+// Delete removes the key.
+func (s *Store) Delete(key string) error {
+	if err := s.bucket.Delete(Key(key)); err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return err
+	}
+
+	s.setRevision(key, 0)
+	return nil
+}
+
+// List returns the keys with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	keys, err := s.bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]string, 0)
+	for _, k := range keys {
+		if key := unkey(k); strings.HasPrefix(key, prefix) {
+			list = append(list, key)
+		}
+	}
+	return list, nil
+}
+
+// Watch calls fn with the value of every update of the state file at the
+// path, nil once it is deleted, until the context is done. Decode it with
+// the manager, e.g. by calling Load from fn.
+func (s *Store) Watch(ctx context.Context, path string, fn func(value []byte)) error {
+	return s.bucket.Watch(ctx, Key(path), func(e Entry) {
+		if e.Deleted {
+			s.setRevision(path, 0)
+			fn(nil)
+			return
+		}
+		fn(e.Value)
+	})
+}
+
+// Key maps the state file path to the bucket key. Leading slashes are
+// dropped and characters not valid in keys are escaped as =XX.
+func Key(path string) string {
+	path = strings.TrimLeft(path, "/")
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '/' || c == '.' && i > 0 && i < len(path)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "=%02X", c)
+	}
+	return b.String()
+}
+
+// unkey maps the bucket key back to the state file path.
+func unkey(key string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(key); i++ {
+		if key[i] == '=' && i+2 < len(key) {
+			if c, err := strconv.ParseUint(key[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+// revision returns the revision of the key last seen.
+func (s *Store) revision(key string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.revisions[key]
+}
+
+// setRevision records the revision of the key.
+func (s *Store) setRevision(key string, revision uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if revision == 0 {
+		delete(s.revisions, key)
+		return
+	}
+	s.revisions[key] = revision
+}
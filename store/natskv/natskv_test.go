@@ -0,0 +1,210 @@
+package natskv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// memBucket is an in-memory Bucket with revisions and watchers.
+type memBucket struct {
+	mutex    sync.Mutex
+	revision uint64
+	entries  map[string]Entry
+	watchers map[string][]chan Entry
+}
+
+func newMemBucket() *memBucket {
+	return &memBucket{entries: make(map[string]Entry), watchers: make(map[string][]chan Entry)}
+}
+
+func (b *memBucket) Get(key string) (Entry, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	e, ok := b.entries[key]
+	if !ok || e.Deleted {
+		return Entry{}, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+	return e, nil
+}
+
+func (b *memBucket) Put(key string, value []byte) (uint64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.set(Entry{Key: key, Value: value}), nil
+}
+
+func (b *memBucket) Update(key string, value []byte, last uint64) (uint64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	e, ok := b.entries[key]
+	current := e.Revision
+	if !ok || e.Deleted {
+		current = 0
+	}
+	if current != last {
+		return 0, fmt.Errorf("%w: wrong last sequence", manager.ErrConflict)
+	}
+	return b.set(Entry{Key: key, Value: value}), nil
+}
+
+func (b *memBucket) Delete(key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.set(Entry{Key: key, Deleted: true})
+	return nil
+}
+
+func (b *memBucket) Keys() ([]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	keys := make([]string, 0)
+	for k, e := range b.entries {
+		if !e.Deleted {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (b *memBucket) Watch(ctx context.Context, key string, fn func(Entry)) error {
+	ch := make(chan Entry, 16)
+	b.mutex.Lock()
+	b.watchers[key] = append(b.watchers[key], ch)
+	b.mutex.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e := <-ch:
+			fn(e)
+		}
+	}
+}
+
+// set stores the entry with the next revision and notifies the watchers. Caller must hold the lock.
+func (b *memBucket) set(e Entry) uint64 {
+	b.revision++
+	e.Revision = b.revision
+	b.entries[e.Key] = e
+	for _, ch := range b.watchers[e.Key] {
+		ch <- e
+	}
+	return e.Revision
+}
+
+// TestStore ensures state round-trips through the bucket.
+func TestStore(t *testing.T) {
+	bucket := newMemBucket()
+	s, err := New(bucket)
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/my state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.ErrorIs(t, sm.Load(&config{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"bob"}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+
+	keys, err := bucket.Keys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app/my=20state", "app/my=20state.1"}, keys)
+
+	keys, err = s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/my state", "/app/my state.1"}, keys)
+
+	assert.NoError(t, sm.Delete())
+	assert.ErrorIs(t, sm.Load(loaded), manager.ErrNotFound)
+}
+
+// TestStoreRevisionCheck ensures writes based on a stale revision fail with ErrConflict.
+func TestStoreRevisionCheck(t *testing.T) {
+	bucket := newMemBucket()
+	s, err := New(bucket, WithRevisionCheck())
+	assert.NoError(t, err)
+	other, err := New(bucket, WithRevisionCheck())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.ErrorIs(t, other.Put("/state", []byte("b")), manager.ErrConflict)
+
+	_, err = other.Get("/state")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Put("/state", []byte("b")))
+	assert.ErrorIs(t, s.Put("/state", []byte("c")), manager.ErrConflict)
+}
+
+// TestStoreWatch ensures subscribers see the states saved by other managers.
+func TestStoreWatch(t *testing.T) {
+	bucket := newMemBucket()
+	s, err := New(bucket)
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	writer, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/state"),
+		manager.WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+	reader, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/state"),
+		manager.WithSerializationType(manager.JSON))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seen := make(chan string, 4)
+	go func() {
+		_ = s.Watch(ctx, "/state", func(value []byte) {
+			if value == nil {
+				seen <- ""
+				return
+			}
+			loaded := &config{}
+			_ = reader.Load(loaded)
+			seen <- loaded.Name
+		})
+	}()
+
+	// wait for the watcher to register
+	assert.Eventually(t, func() bool {
+		bucket.mutex.Lock()
+		defer bucket.mutex.Unlock()
+		return len(bucket.watchers["state"]) == 1
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, writer.Save(&config{"alice"}))
+	assert.Equal(t, "alice", <-seen)
+	assert.NoError(t, writer.Delete())
+	assert.Equal(t, "", <-seen)
+}
+
+// TestKey ensures paths map to valid keys and back.
+func TestKey(t *testing.T) {
+	for path, key := range map[string]string{
+		"/app/state.json": "app/state.json",
+		"/a b=c":          "a=20b=3Dc",
+		"/.hidden.":       "=2Ehidden=2E",
+	} {
+		assert.Equal(t, key, Key(path))
+		assert.Equal(t, path, unkey(key))
+	}
+}
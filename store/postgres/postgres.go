@@ -0,0 +1,312 @@
+// Package postgres persists state in a PostgreSQL table through database/sql,
+// so services can reuse their existing database instead of files on each node.
+// The driver, e.g. pgx or lib/pq, is registered and opened by the caller.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultTable is the name of the table of the states
+	DefaultTable = "state"
+
+	// lockRetryInterval is the delay between lock attempts when a lock timeout is set
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Store is a manager.Store keeping every state file as a row of the table:
+//
+//	name TEXT PRIMARY KEY, revision BIGINT, payload BYTEA, updated_at TIMESTAMPTZ
+type Store struct {
+	db            *sql.DB
+	table         string
+	revisionCheck bool
+
+	mutex     sync.Mutex
+	revisions map[string]int64
+}
+
+var _ manager.Store = (*Store)(nil)
+var _ manager.Renamer = (*Store)(nil)
+var _ manager.StoreLocker = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithTable sets the optionally schema qualified name of the table, DefaultTable by default.
+func WithTable(name string) Option {
+	return func(s *Store) {
+		s.table = name
+	}
+}
+
+// WithRevisionCheck makes updates conditional on the revision of the row last
+// read or written by the store. An update of a row changed by someone else
+// since then, or of an existing row never read, fails with manager.ErrConflict.
+func WithRevisionCheck() Option {
+	return func(s *Store) {
+		s.revisionCheck = true
+	}
+}
+
+// New creates a store on the table of the database, see CreateTable.
+func New(db *sql.DB, options ...Option) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("database must not be nil")
+	}
+
+	s := &Store{
+		db:        db,
+		table:     DefaultTable,
+		revisions: make(map[string]int64),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	if !tableName.MatchString(s.table) {
+		return nil, fmt.Errorf("invalid table name: %q", s.table)
+	}
+
+	return s, nil
+}
+
+// CreateTable creates the table unless it exists.
+func (s *Store) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+	name TEXT PRIMARY KEY,
+	revision BIGINT NOT NULL,
+	payload BYTEA NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+// Get returns the payload of the row.
+func (s *Store) Get(key string) ([]byte, error) {
+	var payload []byte
+	var revision int64
+
+	err := s.db.QueryRow(`SELECT payload, revision FROM `+s.table+` WHERE name = $1`, key).Scan(&payload, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	s.setRevision(key, revision)
+	return payload, nil
+}
+
+// Put inserts or updates the row and increments its revision, conditional on
+// the revision last seen with WithRevisionCheck.
+func (s *Store) Put(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	var row *sql.Row
+	switch revision := s.revision(key); {
+	case !s.revisionCheck:
+		row = s.db.QueryRow(`INSERT INTO `+s.table+` (name, revision, payload, updated_at) VALUES ($1, 1, $2, now())
+ON CONFLICT (name) DO UPDATE SET revision = `+s.table+`.revision + 1, payload = EXCLUDED.payload, updated_at = now()
+RETURNING revision`, key, value)
+	case revision == 0:
+		row = s.db.QueryRow(`INSERT INTO `+s.table+` (name, revision, payload, updated_at) VALUES ($1, 1, $2, now())
+ON CONFLICT (name) DO NOTHING RETURNING revision`, key, value)
+	default:
+		row = s.db.QueryRow(`UPDATE `+s.table+` SET revision = revision + 1, payload = $2, updated_at = now()
+WHERE name = $1 AND revision = $3 RETURNING revision`, key, value, revision)
+	}
+
+	var revision int64
+	err := row.Scan(&revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", manager.ErrConflict, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+
+	s.setRevision(key, revision)
+	return nil
+}
+
+// Delete removes the row, conditional on the revision last seen with WithRevisionCheck.
+func (s *Store) Delete(key string) error {
+	if !s.revisionCheck {
+		if _, err := s.db.Exec(`DELETE FROM `+s.table+` WHERE name = $1`, key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		s.setRevision(key, 0)
+		return nil
+	}
+
+	revision := s.revision(key)
+	if revision == 0 {
+		if _, err := s.Get(key); err != nil {
+			if errors.Is(err, manager.ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+		revision = s.revision(key)
+	}
+
+	res, err := s.db.Exec(`DELETE FROM `+s.table+` WHERE name = $1 AND revision = $2`, key, revision)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", manager.ErrConflict, key)
+	}
+
+	s.setRevision(key, 0)
+	return nil
+}
+
+// List returns the names of the rows with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+
+	rows, err := s.db.Query(`SELECT name FROM `+s.table+` WHERE name LIKE $1 ESCAPE '\' ORDER BY name`, escaped+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	return keys, nil
+}
+
+// Rename moves the row to the new name in a single transaction, replacing any row of that name.
+func (s *Store) Rename(oldKey, newKey string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM `+s.table+` WHERE name = $1`, newKey); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", oldKey, err)
+	}
+
+	var revision int64
+	err = tx.QueryRow(`UPDATE `+s.table+` SET name = $2, revision = revision + 1, updated_at = now()
+WHERE name = $1 RETURNING revision`, oldKey, newKey).Scan(&revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, oldKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rename %s: %w", oldKey, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rename of %s: %w", oldKey, err)
+	}
+
+	s.setRevision(oldKey, 0)
+	s.setRevision(newKey, revision)
+	return nil
+}
+
+// Lock takes a session level advisory lock on the key, holding a connection
+// of the pool until it is released.
+func (s *Store) Lock(key string, exclusive bool, timeout time.Duration) (func(), error) {
+	ctx := context.Background()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	suffix := ""
+	if !exclusive {
+		suffix = "_shared"
+	}
+	id := lockID(s.table + "/" + key)
+
+	unlock := func() {
+		_, _ = conn.ExecContext(ctx, `SELECT pg_advisory_unlock`+suffix+`($1)`, id)
+		conn.Close()
+	}
+
+	if timeout <= 0 {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock`+suffix+`($1)`, id); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", key, err)
+		}
+		return unlock, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var ok bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock`+suffix+`($1)`, id).Scan(&ok); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", key, err)
+		}
+		if ok {
+			return unlock, nil
+		}
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("%w: timed out after %s", manager.ErrLocked, timeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// lockID maps the key to the 64-bit advisory lock identifier.
+func lockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// revision returns the revision of the row last seen.
+func (s *Store) revision(key string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.revisions[key]
+}
+
+// setRevision records the revision of the row.
+func (s *Store) setRevision(key string, revision int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if revision == 0 {
+		delete(s.revisions, key)
+		return
+	}
+	s.revisions[key] = revision
+}
@@ -0,0 +1,265 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDB is an in-memory table answering the statements of the store.
+type fakeDB struct {
+	mutex sync.Mutex
+	rows  map[string]fakeRow
+	locks map[int64]*fakeConn
+}
+
+type fakeRow struct {
+	revision int64
+	payload  []byte
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: d}, nil
+}
+
+// fakeConn runs the statements against the fake table without preparing them.
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c *fakeConn) Commit() error                       { return nil }
+func (c *fakeConn) Rollback() error                     { return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(len(rows.(*fakeRows).values)), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	d := c.db
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	arg := func(i int) driver.Value { return args[i].Value }
+	name := func() string { return arg(0).(string) }
+	result := func(columns []string, values ...[]driver.Value) (driver.Rows, error) {
+		return &fakeRows{columns: columns, values: values}, nil
+	}
+	var key string
+	if len(args) > 0 {
+		key, _ = arg(0).(string)
+	}
+	row, exists := d.rows[key]
+
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+		return result(nil)
+	case strings.HasPrefix(query, "SELECT payload, revision"):
+		if !exists {
+			return result([]string{"payload", "revision"})
+		}
+		return result([]string{"payload", "revision"}, []driver.Value{row.payload, row.revision})
+	case strings.HasPrefix(query, "INSERT") && strings.Contains(query, "DO NOTHING"):
+		if exists {
+			return result([]string{"revision"})
+		}
+		d.rows[name()] = fakeRow{revision: 1, payload: arg(1).([]byte)}
+		return result([]string{"revision"}, []driver.Value{int64(1)})
+	case strings.HasPrefix(query, "INSERT"):
+		d.rows[name()] = fakeRow{revision: row.revision + 1, payload: arg(1).([]byte)}
+		return result([]string{"revision"}, []driver.Value{row.revision + 1})
+	case strings.HasPrefix(query, "UPDATE state SET revision"):
+		if !exists || row.revision != arg(2).(int64) {
+			return result([]string{"revision"})
+		}
+		d.rows[name()] = fakeRow{revision: row.revision + 1, payload: arg(1).([]byte)}
+		return result([]string{"revision"}, []driver.Value{row.revision + 1})
+	case strings.HasPrefix(query, "UPDATE state SET name"):
+		if !exists {
+			return result([]string{"revision"})
+		}
+		delete(d.rows, name())
+		d.rows[arg(1).(string)] = fakeRow{revision: row.revision + 1, payload: row.payload}
+		return result([]string{"revision"}, []driver.Value{row.revision + 1})
+	case strings.HasPrefix(query, "DELETE"):
+		if !exists || (len(args) > 1 && row.revision != arg(1).(int64)) {
+			return result(nil)
+		}
+		delete(d.rows, name())
+		// a single row makes the statement affect one row
+		return result(nil, nil)
+	case strings.HasPrefix(query, "SELECT name"):
+		prefix := strings.NewReplacer(`\\`, `\`, `\%`, `%`, `\_`, `_`).Replace(strings.TrimSuffix(name(), "%"))
+		var keys []string
+		for k := range d.rows {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		values := make([][]driver.Value, 0, len(keys))
+		for _, k := range keys {
+			values = append(values, []driver.Value{k})
+		}
+		return result([]string{"name"}, values...)
+	case strings.HasPrefix(query, "SELECT pg_try_advisory_lock("):
+		id := arg(0).(int64)
+		owner, locked := d.locks[id]
+		if locked && owner != c {
+			return result([]string{"ok"}, []driver.Value{false})
+		}
+		d.locks[id] = c
+		return result([]string{"ok"}, []driver.Value{true})
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock("):
+		delete(d.locks, arg(0).(int64))
+		return result(nil)
+	}
+
+	return nil, errors.New("unexpected query: " + query)
+}
+
+// fakeRows are the rows of a fake statement.
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var fake = &fakeDB{rows: make(map[string]fakeRow), locks: make(map[int64]*fakeConn)}
+
+func init() {
+	sql.Register("fakepg", fake)
+}
+
+// setupStore creates a store on an empty fake table.
+func setupStore(t *testing.T, options ...Option) *Store {
+	t.Helper()
+
+	fake.mutex.Lock()
+	fake.rows = make(map[string]fakeRow)
+	fake.mutex.Unlock()
+
+	db, err := sql.Open("fakepg", "")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	s, err := New(db, options...)
+	assert.NoError(t, err)
+	assert.NoError(t, s.CreateTable(context.Background()))
+
+	return s
+}
+
+// TestStore ensures state round-trips through the table.
+func TestStore(t *testing.T) {
+	s := setupStore(t)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.ErrorIs(t, sm.Load(&config{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"bob"}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/state.1"}, keys)
+	assert.Equal(t, int64(2), fake.rows["/app/state.1"].revision)
+
+	keys, err = s.List("/app_")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, sm.Restore(1))
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "alice", loaded.Name)
+
+	assert.NoError(t, sm.Delete())
+	assert.ErrorIs(t, sm.Load(loaded), manager.ErrNotFound)
+}
+
+// TestStoreRevisionCheck ensures updates based on a stale revision fail with ErrConflict.
+func TestStoreRevisionCheck(t *testing.T) {
+	s := setupStore(t, WithRevisionCheck())
+	other, err := New(s.db, WithRevisionCheck())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.ErrorIs(t, other.Put("/state", []byte("b")), manager.ErrConflict)
+
+	_, err = other.Get("/state")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Put("/state", []byte("b")))
+
+	assert.ErrorIs(t, s.Put("/state", []byte("c")), manager.ErrConflict)
+	assert.ErrorIs(t, s.Delete("/state"), manager.ErrConflict)
+	assert.Equal(t, []byte("b"), fake.rows["/state"].payload)
+
+	assert.NoError(t, other.Delete("/state"))
+	assert.Empty(t, fake.rows)
+}
+
+// TestStoreLock ensures managers sharing the table exclude each other.
+func TestStoreLock(t *testing.T) {
+	s := setupStore(t)
+
+	unlock, err := s.Lock("/state", true, time.Second)
+	assert.NoError(t, err)
+
+	_, err = s.Lock("/state", true, 20*time.Millisecond)
+	assert.ErrorIs(t, err, manager.ErrLocked)
+
+	unlock()
+	unlock, err = s.Lock("/state", true, 20*time.Millisecond)
+	assert.NoError(t, err)
+	unlock()
+}
+
+// TestNew ensures invalid tables are rejected.
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	assert.Error(t, err)
+
+	db, err := sql.Open("fakepg", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = New(db, WithTable("state; DROP TABLE users"))
+	assert.Error(t, err)
+
+	_, err = New(db, WithTable("app.state"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,299 @@
+// Package rest persists state on any HTTP service that serves the encoded
+// state with GET and accepts it with PUT, so state can be centralized behind
+// an existing internal service.
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultRetries is the number of times a failed request is retried
+	DefaultRetries = 3
+
+	// DefaultBackoff is the delay before the first retry, doubled on each retry
+	DefaultBackoff = 100 * time.Millisecond
+)
+
+// Store is a manager.Store keeping every state file at the URL of its key
+// relative to the base URL, e.g. the state at file path /app/state of a
+// store on https://example.com/v1 is at https://example.com/v1/app/state.
+// Requests failing with network errors, 429 or 5xx responses are retried
+// with exponential backoff.
+type Store struct {
+	client      *http.Client
+	base        string
+	header      http.Header
+	retries     int
+	backoff     time.Duration
+	conditional bool
+
+	mutex sync.Mutex
+	etags map[string]string
+}
+
+var _ manager.Store = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithHTTPClient sets the client used to reach the service, http.DefaultClient by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) {
+		s.client = c
+	}
+}
+
+// WithHeader sets a header sent with every request, e.g. an API key.
+func WithHeader(key, value string) Option {
+	return func(s *Store) {
+		s.header.Set(key, value)
+	}
+}
+
+// WithBearerToken authenticates every request with the bearer token.
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithBasicAuth authenticates every request with the username and password.
+func WithBasicAuth(username, password string) Option {
+	return func(s *Store) {
+		r := &http.Request{Header: make(http.Header)}
+		r.SetBasicAuth(username, password)
+		s.header.Set("Authorization", r.Header.Get("Authorization"))
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the delay
+// before the first retry, DefaultRetries and DefaultBackoff by default.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(s *Store) {
+		s.retries = retries
+		s.backoff = backoff
+	}
+}
+
+// WithConditionalRequests makes writes conditional on the ETag last seen by
+// the store: If-Match when the state was read or written before, If-None-Match
+// otherwise. Writes rejected with 412 fail with manager.ErrConflict.
+func WithConditionalRequests() Option {
+	return func(s *Store) {
+		s.conditional = true
+	}
+}
+
+// New creates a store on the service at the base URL.
+func New(base string, options ...Option) (*Store, error) {
+	u, err := url.Parse(base)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", base)
+	}
+
+	s := &Store{
+		client:  http.DefaultClient,
+		base:    strings.TrimSuffix(base, "/"),
+		header:  make(http.Header),
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+		etags:   make(map[string]string),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// Get returns the state at the URL of the key.
+func (s *Store) Get(key string) ([]byte, error) {
+	resp, body, err := s.do(http.MethodGet, s.url(key), key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setETag(key, resp.Header.Get("ETag"))
+	return body, nil
+}
+
+// Version returns the ETag of the state at the URL of the key without
+// fetching it, so caches can check whether it changed.
+func (s *Store) Version(key string) (string, error) {
+	resp, _, err := s.do(http.MethodHead, s.url(key), key, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// Put writes the state to the URL of the key.
+func (s *Store) Put(key string, value []byte) error {
+	_, err := s.PutVersion(key, value)
+	return err
+}
+
+// PutVersion writes the state to the URL of the key and returns the ETag of
+// the response, so caches learn the version without another request.
+func (s *Store) PutVersion(key string, value []byte) (string, error) {
+	h := make(http.Header)
+	h.Set("Content-Type", "application/octet-stream")
+	if s.conditional {
+		if etag := s.etag(key); etag != "" {
+			h.Set("If-Match", etag)
+		} else {
+			h.Set("If-None-Match", "*")
+		}
+	}
+
+	resp, _, err := s.do(http.MethodPut, s.url(key), key, value, h)
+	if err != nil {
+		return "", err
+	}
+
+	etag := resp.Header.Get("ETag")
+	s.setETag(key, etag)
+	return etag, nil
+}
+
+// Delete removes the state at the URL of the key.
+func (s *Store) Delete(key string) error {
+	h := make(http.Header)
+	if etag := s.etag(key); s.conditional && etag != "" {
+		h.Set("If-Match", etag)
+	}
+
+	if _, _, err := s.do(http.MethodDelete, s.url(key), key, nil, h); err != nil && !errors.Is(err, manager.ErrNotFound) {
+		return err
+	}
+
+	s.setETag(key, "")
+	return nil
+}
+
+// List returns the keys with the prefix. It requires the service to answer a
+// GET of the base URL with a prefix query parameter with a JSON array of keys.
+func (s *Store) List(prefix string) ([]string, error) {
+	_, body, err := s.do(http.MethodGet, s.base+"/?"+url.Values{"prefix": {prefix}}.Encode(), prefix, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode key list: %w", err)
+	}
+	return keys, nil
+}
+
+// etag returns the ETag of the key last seen.
+func (s *Store) etag(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.etags[key]
+}
+
+// setETag records the ETag of the key.
+func (s *Store) setETag(key, etag string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if etag == "" {
+		delete(s.etags, key)
+		return
+	}
+	s.etags[key] = etag
+}
+
+// url returns the URL of the key, escaping every segment of its path.
+func (s *Store) url(key string) string {
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return s.base + "/" + strings.Join(segments, "/")
+}
+
+// do sends the request for the key to the target URL, retrying transient
+// failures, and returns the response and its body.
+func (s *Store) do(method, target, key string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	delay := s.backoff
+
+	for attempt := 0; ; attempt++ {
+		resp, b, err := s.send(method, target, body, header)
+		if err == nil && !retryable(resp.StatusCode) {
+			return resp, b, status(resp, b, key)
+		}
+		if attempt >= s.retries {
+			if err != nil {
+				return nil, nil, err
+			}
+			return resp, b, status(resp, b, key)
+		}
+
+		wait := delay
+		if resp != nil {
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+				wait = time.Duration(secs) * time.Second
+			}
+		}
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+// send sends a single request and reads the response body.
+func (s *Store) send(method, target string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reach %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp, b, nil
+}
+
+// retryable reports whether a response with the status code is worth retrying.
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// status maps unsuccessful responses to errors.
+func status(resp *http.Response, body []byte, key string) error {
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return fmt.Errorf("%w: %s was changed", manager.ErrConflict, key)
+	}
+	return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeService stores bodies by path and versions them with ETags.
+type fakeService struct {
+	mutex    sync.Mutex
+	version  int
+	values   map[string][]byte
+	etags    map[string]string
+	failures int
+	requests int
+	auth     []string
+	paths    []string
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{values: make(map[string][]byte), etags: make(map[string]string)}
+}
+
+func (f *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests++
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	f.paths = append(f.paths, r.URL.EscapedPath())
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	key := r.URL.Path
+	etag, exists := f.etags[key]
+	switch {
+	case r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag,
+		r.Header.Get("If-None-Match") == "*" && exists:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	case http.MethodGet:
+		if key == "/" {
+			keys := make([]string, 0)
+			for k := range f.values {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			_ = json.NewEncoder(w).Encode(keys)
+			return
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(f.values[key])
+	case http.MethodPut:
+		f.version++
+		f.values[key], _ = io.ReadAll(r.Body)
+		f.etags[key] = fmt.Sprintf(`"%d"`, f.version)
+		w.Header().Set("ETag", f.etags[key])
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.values, key)
+		delete(f.etags, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// TestNew ensures invalid base URLs are rejected.
+func TestNew(t *testing.T) {
+	_, err := New("not a url")
+	assert.Error(t, err)
+	_, err = New("/relative")
+	assert.Error(t, err)
+}
+
+// TestStore ensures state round-trips through the service.
+func TestStore(t *testing.T) {
+	f := newFakeService()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s, err := New(srv.URL+"/", WithBearerToken("secret"))
+	assert.NoError(t, err)
+
+	sm, err := manager.NewStateManager(manager.WithStore(s), manager.WithFilePath("/app/state"),
+		manager.WithSerializationType(manager.JSON), manager.WithBackups(1))
+	assert.NoError(t, err)
+
+	type config struct{ Name string }
+	assert.ErrorIs(t, sm.Load(&config{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&config{"alice"}))
+	assert.NoError(t, sm.Save(&config{"bob"}))
+
+	loaded := &config{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "bob", loaded.Name)
+	assert.Equal(t, "Bearer secret", f.auth[0])
+
+	keys, err := s.List("/app/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/app/state", "/app/state.1"}, keys)
+
+	version, err := s.Version("/app/state")
+	assert.NoError(t, err)
+	assert.Equal(t, f.etags["/app/state"], version)
+
+	written, err := s.PutVersion("/app/other", []byte("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, f.etags["/app/other"], written)
+	assert.NoError(t, s.Delete("/app/other"))
+
+	assert.NoError(t, sm.Delete())
+	assert.ErrorIs(t, sm.Load(loaded), manager.ErrNotFound)
+	_, err = s.Version("/app/state")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+}
+
+// TestStoreConditional ensures writes based on a stale ETag fail with ErrConflict.
+func TestStoreConditional(t *testing.T) {
+	srv := httptest.NewServer(newFakeService())
+	defer srv.Close()
+
+	s, err := New(srv.URL, WithConditionalRequests(), WithBasicAuth("user", "pass"))
+	assert.NoError(t, err)
+	other, err := New(srv.URL, WithConditionalRequests())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.ErrorIs(t, other.Put("/state", []byte("b")), manager.ErrConflict)
+
+	_, err = other.Get("/state")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Put("/state", []byte("b")))
+	assert.ErrorIs(t, s.Put("/state", []byte("c")), manager.ErrConflict)
+	assert.ErrorIs(t, s.Delete("/state"), manager.ErrConflict)
+
+	assert.NoError(t, other.Delete("/state"))
+	assert.NoError(t, other.Delete("/state"))
+}
+
+// TestStoreRetries ensures transient failures are retried up to the limit.
+func TestStoreRetries(t *testing.T) {
+	f := newFakeService()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s, err := New(srv.URL, WithRetries(2, time.Millisecond))
+	assert.NoError(t, err)
+
+	f.failures = 2
+	assert.NoError(t, s.Put("/state", []byte("a")))
+	assert.Equal(t, 3, f.requests)
+
+	f.failures = 3
+	err = s.Put("/state", []byte("b"))
+	assert.ErrorContains(t, err, "503")
+	assert.Equal(t, 6, f.requests)
+
+	f.failures = 0
+	b, err := s.Get("/state")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(b))
+}
+
+// TestStoreEscaping ensures keys with reserved characters keep their path segments.
+func TestStoreEscaping(t *testing.T) {
+	f := newFakeService()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	s, err := New(srv.URL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Put("a b/c#d", []byte("v")))
+	assert.Equal(t, "/a%20b/c%23d", f.paths[0])
+	assert.Contains(t, f.values, "/a b/c#d")
+
+	b, err := s.Get("/a b/c#d")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", string(b))
+}
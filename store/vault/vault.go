@@ -0,0 +1,223 @@
+// Package vault persists state in a HashiCorp Vault KV version 2 secrets
+// engine over its HTTP API, so secret-bearing state is subject to the
+// policies and audit log of Vault.
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mchmarny/state/manager"
+)
+
+const (
+	// DefaultAddress is the address of a local Vault server
+	DefaultAddress = "https://127.0.0.1:8200"
+
+	// valueKey is the key of the secret data holding the state
+	valueKey = "value"
+)
+
+// Store is a manager.Store keeping every state file as a secret of a KV v2 mount.
+type Store struct {
+	client    *http.Client
+	address   string
+	mount     string
+	token     string
+	namespace string
+}
+
+var _ manager.Store = (*Store)(nil)
+
+// Option configures the Store.
+type Option func(*Store)
+
+// WithToken sets the Vault token, VAULT_TOKEN by default.
+func WithToken(token string) Option {
+	return func(s *Store) {
+		s.token = token
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace, VAULT_NAMESPACE by default.
+func WithNamespace(namespace string) Option {
+	return func(s *Store) {
+		s.namespace = namespace
+	}
+}
+
+// WithHTTPClient sets the client used to reach Vault, http.DefaultClient by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) {
+		s.client = c
+	}
+}
+
+// New creates a store on the KV v2 mount of the Vault server at the address,
+// VAULT_ADDR or DefaultAddress when empty.
+func New(address, mount string, options ...Option) *Store {
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		address = DefaultAddress
+	}
+
+	s := &Store{
+		client:    http.DefaultClient,
+		address:   strings.TrimSuffix(address, "/"),
+		mount:     strings.Trim(mount, "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// WithVault persists the state as the secret at the path of the KV v2 mount
+// of the Vault server at the address. Backups, snapshots and named states are
+// stored as secrets next to it.
+func WithVault(address, mount, secretPath string, options ...Option) manager.StateOption {
+	s := New(address, mount, options...)
+	return func(m *manager.StateManager) {
+		manager.WithStore(s)(m)
+		manager.WithFilePath("/" + strings.Trim(secretPath, "/"))(m)
+	}
+}
+
+// Get returns the value of the latest version of the secret.
+func (s *Store) Get(key string) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Data map[string]json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := s.do(http.MethodGet, "data", key, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	// secrets written by others without the key hold no state
+	raw, ok := resp.Data.Data[valueKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: secret %s has no %q key", manager.ErrNotFound, key, valueKey)
+	}
+
+	var v []byte
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode secret %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// Put writes a new version of the secret.
+func (s *Store) Put(key string, value []byte) error {
+	b, err := json.Marshal(map[string]interface{}{"data": map[string][]byte{valueKey: value}})
+	if err != nil {
+		return fmt.Errorf("failed to encode secret: %w", err)
+	}
+	return s.do(http.MethodPost, "data", key, b, nil)
+}
+
+// Delete permanently removes all versions and the metadata of the secret, so
+// it is no longer listed. Rotated backups would otherwise pile up as soft
+// deleted secrets.
+func (s *Store) Delete(key string) error {
+	err := s.do(http.MethodDelete, "metadata", key, nil, nil)
+	if errors.Is(err, manager.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// List returns the paths of the secrets with the prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	dir, base := path.Split(strings.TrimPrefix(prefix, "/"))
+	return s.list(dir, base)
+}
+
+// list returns the secrets in the folder starting with base, descending into subfolders.
+func (s *Store) list(dir, base string) ([]string, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	if err := s.do("LIST", "metadata", dir, nil, &resp); err != nil {
+		if errors.Is(err, manager.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(resp.Data.Keys))
+	for _, k := range resp.Data.Keys {
+		if !strings.HasPrefix(k, base) {
+			continue
+		}
+		if strings.HasSuffix(k, "/") {
+			sub, err := s.list(dir+k, "")
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, sub...)
+			continue
+		}
+		keys = append(keys, "/"+dir+k)
+	}
+	return keys, nil
+}
+
+// do sends the request to the API of the mount and decodes the JSON response into out when set.
+func (s *Store) do(method, api, key string, body []byte, out interface{}) error {
+	u := fmt.Sprintf("%s/v1/%s/%s/%s", s.address, s.mount, api, strings.TrimPrefix(key, "/"))
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.token != "" {
+		req.Header.Set("X-Vault-Token", s.token)
+	}
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read vault response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", manager.ErrNotFound, key)
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package vault
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mchmarny/state/manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVault serves the subset of the KV v2 API of the "secret" mount used by the store.
+type fakeVault struct {
+	mutex    sync.Mutex
+	secrets  map[string]map[string]interface{}
+	versions map[string]int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	api, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/secret/"), "/")
+	switch {
+	case api == "data" && r.Method == http.MethodGet:
+		data, ok := f.secrets[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	case api == "data" && r.Method == http.MethodPost:
+		var body struct{ Data map[string]interface{} }
+		b, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &body)
+		f.secrets[key] = body.Data
+		f.versions[key]++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"version": f.versions[key]}})
+	case api == "metadata" && r.Method == http.MethodDelete:
+		delete(f.secrets, key)
+		delete(f.versions, key)
+		w.WriteHeader(http.StatusNoContent)
+	case api == "metadata" && r.Method == "LIST":
+		seen := make(map[string]bool)
+		for k := range f.versions {
+			if rest, ok := strings.CutPrefix(k, key); ok {
+				if i := strings.Index(rest, "/"); i >= 0 {
+					rest = rest[:i+1]
+				}
+				seen[rest] = true
+			}
+		}
+		if len(seen) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		keys := make([]string, 0, len(seen))
+		for k := range seen {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// TestWithVault ensures state round-trips through a KV v2 mount.
+func TestWithVault(t *testing.T) {
+	fake := &fakeVault{secrets: make(map[string]map[string]interface{}), versions: make(map[string]int)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sm, err := manager.NewStateManager(manager.WithSerializationType(manager.JSON),
+		WithVault(srv.URL, "/secret/", "apps/tool/creds", WithToken("root")), manager.WithBackups(1))
+	assert.NoError(t, err)
+	assert.Equal(t, "/apps/tool/creds", sm.FilePath)
+
+	type creds struct{ Token string }
+	assert.ErrorIs(t, sm.Load(&creds{}), manager.ErrNotFound)
+	assert.NoError(t, sm.Save(&creds{"a"}))
+	assert.NoError(t, sm.Save(&creds{"b"}))
+
+	loaded := &creds{}
+	assert.NoError(t, sm.Load(loaded))
+	assert.Equal(t, "b", loaded.Token)
+	assert.Contains(t, fake.secrets, "apps/tool/creds")
+	assert.Contains(t, fake.secrets["apps/tool/creds"], valueKey)
+
+	history, err := sm.History()
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	s := New(srv.URL, "secret", WithToken("root"))
+	keys, err := s.List("/apps/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/apps/tool/creds", "/apps/tool/creds.1"}, keys)
+
+	assert.NoError(t, sm.Delete())
+	assert.NoError(t, s.Delete("/apps/missing"))
+	keys, err = s.List("/apps/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/apps/tool/creds.1"}, keys)
+
+	// secrets without the value key hold no state
+	fake.secrets["apps/other"] = map[string]interface{}{"password": "x"}
+	_, err = s.Get("/apps/other")
+	assert.ErrorIs(t, err, manager.ErrNotFound)
+
+	_, err = New(srv.URL, "secret", WithToken("wrong")).Get("/apps/tool/creds")
+	assert.ErrorContains(t, err, "403")
+}